@@ -2,9 +2,10 @@ module github.com/kotsmile/everd-backend
 
 go 1.23.2
 
-require github.com/sirupsen/logrus v1.9.3
-
 require (
-	github.com/gorilla/mux v1.8.1 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.3
+	github.com/sirupsen/logrus v1.9.3
 )
+
+require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+)
+
+// EnvTrashRetention is how long deleted todos stay in trash before they are
+// purged, as Go duration, e.g. "720h".
+const EnvTrashRetention = "EVERD_TRASH_RETENTION"
+
+// config is configuration of app read from environment, variables which are
+// not set keep defaults.
+type config struct {
+	trashRetention time.Duration
+}
+
+func loadConfig(lookupEnv func(key string) (string, bool)) (config, error) {
+	cfg := config{
+		trashRetention: todolist_domain.DefaultTrashRetention,
+	}
+
+	if value, ok := lookupEnv(EnvTrashRetention); ok {
+		retention, err := time.ParseDuration(value)
+		if err != nil || retention <= 0 {
+			return config{}, fmt.Errorf("%s must be positive duration, got %q", EnvTrashRetention, value)
+		}
+		cfg.trashRetention = retention
+	}
+
+	return cfg, nil
+}
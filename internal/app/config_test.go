@@ -0,0 +1,33 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+)
+
+func env(values map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig(env(nil))
+	if err != nil || cfg.trashRetention != todolist_domain.DefaultTrashRetention {
+		t.Fatalf("default config = %+v, %v", cfg, err)
+	}
+
+	cfg, err = loadConfig(env(map[string]string{EnvTrashRetention: "168h"}))
+	if err != nil || cfg.trashRetention != 7*24*time.Hour {
+		t.Fatalf("config = %+v, %v", cfg, err)
+	}
+
+	for _, value := range []string{"", "week", "0s", "-1h"} {
+		if _, err := loadConfig(env(map[string]string{EnvTrashRetention: value})); err == nil {
+			t.Errorf("%q is accepted", value)
+		}
+	}
+}
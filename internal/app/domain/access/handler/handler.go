@@ -10,12 +10,14 @@ import (
 )
 
 type AccessHandler struct {
-	*util.HTTPHandler
+	*util.ApiHelper
+	users access_domain.UserStatusRepository
 }
 
-func NewAccessHandler(httpHandler *util.HTTPHandler) *AccessHandler {
+func NewAccessHandler(apiHelper *util.ApiHelper, users access_domain.UserStatusRepository) *AccessHandler {
 	return &AccessHandler{
-		HTTPHandler: httpHandler,
+		ApiHelper: apiHelper,
+		users:     users,
 	}
 }
 
@@ -44,5 +46,18 @@ func (h *AccessHandler) AuthMiddlerware(ctx context.Context, w http.ResponseWrit
 			WithErrorMessage("user id is invalid")
 	}
 
+	deleted, err := h.users.IsDeleted(ctx, userID)
+	if err != nil {
+		return nil, util.
+			NewHTTPError("failed to check user").
+			WithError(err)
+	}
+	if deleted {
+		return nil, util.
+			NewHTTPError("unauthorized").
+			WithStatus(http.StatusUnauthorized).
+			WithErrorMessage("account is deleted")
+	}
+
 	return context.WithValue(ctx, "userID", userID), nil
 }
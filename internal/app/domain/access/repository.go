@@ -0,0 +1,11 @@
+package access_domain
+
+import (
+	"context"
+)
+
+// UserStatusRepository tells whether account of user is deleted, deleted
+// users are not authorized.
+type UserStatusRepository interface {
+	IsDeleted(ctx context.Context, userID UserID) (bool, error)
+}
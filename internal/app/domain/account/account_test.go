@@ -0,0 +1,223 @@
+package account_domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	account_model "github.com/kotsmile/everd-backend/internal/app/domain/account/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type memoryExportRepository struct {
+	exports  []account_model.DataExport
+	archives map[account_model.ExportID][]byte
+}
+
+func newMemoryExportRepository() *memoryExportRepository {
+	return &memoryExportRepository{archives: make(map[account_model.ExportID][]byte)}
+}
+
+func (r *memoryExportRepository) Create(ctx context.Context, export account_model.DataExport, tx util.Transaction) (account_model.DataExport, error) {
+	exportPF := export.PF()
+	export = account_model.NewDataExportFromDB(
+		account_model.ExportID(len(r.exports)+1),
+		exportPF.UserID,
+		exportPF.Status,
+		exportPF.Error,
+		exportPF.CreatedAt,
+		nil,
+		nil,
+		nil,
+	)
+	r.exports = append(r.exports, export)
+	return export, nil
+}
+
+func (r *memoryExportRepository) Get(
+	ctx context.Context,
+	userID access_domain.UserID,
+	exportID account_model.ExportID,
+	tx util.Transaction,
+) (account_model.DataExport, error) {
+	for _, export := range r.exports {
+		if exportPF := export.PF(); exportPF.ID == exportID && exportPF.UserID == userID {
+			return export, nil
+		}
+	}
+	return account_model.DataExport{}, account_model.ErrExportNotFound
+}
+
+func (r *memoryExportRepository) Unfinished(ctx context.Context, userID access_domain.UserID, tx util.Transaction) (account_model.DataExport, bool, error) {
+	for _, export := range r.exports {
+		exportPF := export.PF()
+		if exportPF.UserID == userID &&
+			(exportPF.Status == account_model.ExportPending || exportPF.Status == account_model.ExportRunning) {
+			return export, true, nil
+		}
+	}
+	return account_model.DataExport{}, false, nil
+}
+
+func (r *memoryExportRepository) Claim(ctx context.Context, staleBefore time.Time, tx util.Transaction) (account_model.DataExport, bool, error) {
+	for _, export := range r.exports {
+		exportPF := export.PF()
+		if exportPF.Status == account_model.ExportPending ||
+			(exportPF.Status == account_model.ExportRunning && exportPF.StartedAt.Before(staleBefore)) {
+			return export, true, nil
+		}
+	}
+	return account_model.DataExport{}, false, nil
+}
+
+func (r *memoryExportRepository) Save(ctx context.Context, export account_model.DataExport, archive []byte, tx util.Transaction) error {
+	exportPF := export.PF()
+	r.exports[exportPF.ID-1] = export
+	if archive != nil {
+		r.archives[exportPF.ID] = archive
+	}
+	return nil
+}
+
+func (r *memoryExportRepository) Archive(ctx context.Context, exportID account_model.ExportID, tx util.Transaction) ([]byte, error) {
+	archive, ok := r.archives[exportID]
+	if !ok {
+		return nil, account_model.ErrExportNotReady
+	}
+	return archive, nil
+}
+
+func (r *memoryExportRepository) DeleteExpired(ctx context.Context, now time.Time, tx util.Transaction) (int64, error) {
+	return 0, nil
+}
+
+type builderFunc func(ctx context.Context, userID access_domain.UserID, w io.Writer) error
+
+func (f builderFunc) Build(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+	return f(ctx, userID, w)
+}
+
+func TestExportWorker(t *testing.T) {
+	ctx := context.Background()
+	exportRepo := newMemoryExportRepository()
+	service := NewAccountService(util.NewTransactionFactoryTest(), nil, exportRepo, DefaultDeletionGracePeriod)
+
+	export, err := service.RequestExport(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := service.RequestExport(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.PF().ID != export.PF().ID {
+		t.Fatalf("unfinished export is queued again: %d, %d", export.PF().ID, again.PF().ID)
+	}
+
+	if _, err := service.ExportArchive(ctx, 1, export.PF().ID); !errors.Is(err, account_model.ErrExportNotReady) {
+		t.Fatalf("archive of pending export: %v", err)
+	}
+
+	worker := NewExportWorker(util.NewTransactionFactoryTest(), exportRepo, builderFunc(
+		func(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+			_, err := io.WriteString(w, "archive")
+			return err
+		},
+	), util.NewLoggerTest(), DefaultExportWorkerConfig)
+
+	processed, err := worker.ProcessNext(ctx)
+	if err != nil || !processed {
+		t.Fatalf("export is not processed: %v", err)
+	}
+
+	archive, err := service.ExportArchive(ctx, 1, export.PF().ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(archive) != "archive" {
+		t.Fatalf("archive = %q", archive)
+	}
+
+	if _, err := service.ExportArchive(ctx, 2, export.PF().ID); !errors.Is(err, account_model.ErrExportNotFound) {
+		t.Fatalf("archive of other user: %v", err)
+	}
+
+	if processed, err := worker.ProcessNext(ctx); err != nil || processed {
+		t.Fatalf("ready export is processed again: %v", err)
+	}
+}
+
+func TestExportWorkerFail(t *testing.T) {
+	ctx := context.Background()
+	exportRepo := newMemoryExportRepository()
+	service := NewAccountService(util.NewTransactionFactoryTest(), nil, exportRepo, DefaultDeletionGracePeriod)
+
+	export, err := service.RequestExport(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worker := NewExportWorker(util.NewTransactionFactoryTest(), exportRepo, builderFunc(
+		func(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+			return errors.New("database is down")
+		},
+	), util.NewLoggerTest(), DefaultExportWorkerConfig)
+
+	if _, err := worker.ProcessNext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err = service.GetExport(ctx, 1, export.PF().ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exportPF := export.PF(); exportPF.Status != account_model.ExportFailed || exportPF.Error != "database is down" {
+		t.Fatalf("export is not failed: %+v", exportPF)
+	}
+
+	next, err := service.RequestExport(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.PF().ID == export.PF().ID {
+		t.Fatal("failed export is returned instead of queueing new one")
+	}
+}
+
+func TestDataExportExpired(t *testing.T) {
+	now := time.Now()
+	export := account_model.NewDataExport(1)
+	export.Start(now)
+	if err := export.Finish(now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := export.CheckReady(now); err != nil {
+		t.Fatal(err)
+	}
+	if err := export.CheckReady(now.Add(time.Hour)); !errors.Is(err, account_model.ErrExportExpired) {
+		t.Fatalf("expired export: %v", err)
+	}
+	if err := export.Fail(now, "late"); !errors.Is(err, account_model.ErrNotRunning) {
+		t.Fatalf("ready export is failed: %v", err)
+	}
+}
+
+func TestUserDelete(t *testing.T) {
+	now := time.Now()
+	user := account_model.NewUserFromDB(1, now, now, nil)
+
+	if err := user.Delete(now); err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsDeleted() {
+		t.Fatal("user is not deleted")
+	}
+	if err := user.Delete(now); !errors.Is(err, account_model.ErrIsDeleted) {
+		t.Fatalf("user is deleted twice: %v", err)
+	}
+}
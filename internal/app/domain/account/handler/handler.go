@@ -0,0 +1,196 @@
+package account_handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	account_domain "github.com/kotsmile/everd-backend/internal/app/domain/account"
+	account_model "github.com/kotsmile/everd-backend/internal/app/domain/account/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type AccountHandler struct {
+	*util.ApiHelper
+	service *account_domain.AccountService
+}
+
+func NewAccountHandler(service *account_domain.AccountService, apiHelper *util.ApiHelper) *AccountHandler {
+	return &AccountHandler{
+		ApiHelper: apiHelper,
+		service:   service,
+	}
+}
+
+func userIDFromContext(ctx context.Context) (access_domain.UserID, error) {
+	userID, ok := ctx.Value("userID").(access_domain.UserID)
+	if !ok {
+		return access_domain.NilUserID, util.
+			NewHTTPError("unauthorized").
+			WithStatus(http.StatusUnauthorized).
+			WithErrorMessage("user id is not provided")
+	}
+
+	return userID, nil
+}
+
+func exportIDFromPath(r *http.Request) (account_model.ExportID, error) {
+	exportIDInt, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return account_model.NilExportID, util.
+			NewHTTPError("invalid export id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	exportID, err := account_model.NewExportID(exportIDInt)
+	if err != nil {
+		return account_model.NilExportID, util.
+			NewHTTPError("invalid export id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	return exportID, nil
+}
+
+func domainError(err error) error {
+	switch {
+	case errors.Is(err, account_model.ErrNotFound),
+		errors.Is(err, account_model.ErrExportNotFound):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusNotFound).
+			WithError(err)
+	case errors.Is(err, account_model.ErrExportNotReady),
+		errors.Is(err, account_model.ErrIsDeleted):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusConflict).
+			WithError(err)
+	case errors.Is(err, account_model.ErrExportExpired):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusGone).
+			WithError(err)
+	case errors.Is(err, account_model.Err):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	default:
+		return err
+	}
+}
+
+type ExportResponse struct {
+	ID         int64      `json:"id"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+func toExportResponse(export account_model.DataExport) ExportResponse {
+	exportPF := export.PF()
+	return ExportResponse{
+		ID:         exportPF.ID.Int64(),
+		Status:     string(exportPF.Status),
+		Error:      exportPF.Error,
+		CreatedAt:  exportPF.CreatedAt,
+		FinishedAt: exportPF.FinishedAt,
+		ExpiresAt:  exportPF.ExpiresAt,
+	}
+}
+
+// PostMeExport queues export of everything stored about user, archive is
+// built in background, progress is polled with GetMeExport.
+func (h *AccountHandler) PostMeExport(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.service.RequestExport(ctx, userID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	return h.WriteJSON(w, http.StatusAccepted, util.JsonResponse{
+		Data: toExportResponse(export),
+	})
+}
+
+func (h *AccountHandler) GetMeExport(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	exportID, err := exportIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.service.GetExport(ctx, userID, exportID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, toExportResponse(export))
+}
+
+func (h *AccountHandler) GetMeExportArchive(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	exportID, err := exportIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	archive, err := h.service.ExportArchive(ctx, userID, exportID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf(`attachment; filename="everd-export-%d.zip"`, exportID.Int64()),
+	)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(archive)
+
+	return err
+}
+
+type DeleteMeResponse struct {
+	// PurgeAt is time after which all data of user is hard deleted.
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// DeleteMe soft deletes account, the user is unauthorized right away and
+// data is hard deleted once grace period is over.
+func (h *AccountHandler) DeleteMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	purgeAt, err := h.service.DeleteAccount(ctx, userID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, DeleteMeResponse{PurgeAt: purgeAt})
+}
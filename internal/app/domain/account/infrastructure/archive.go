@@ -0,0 +1,454 @@
+package account_infrastructure
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	account_domain "github.com/kotsmile/everd-backend/internal/app/domain/account"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+)
+
+// ArchiveSection is one file of data export archive.
+type ArchiveSection struct {
+	Name  string
+	Write func(ctx context.Context, userID access_domain.UserID, w io.Writer) error
+}
+
+// ZipArchiveBuilder writes sections into zip archive in the given order.
+type ZipArchiveBuilder struct {
+	sections []ArchiveSection
+}
+
+func NewZipArchiveBuilder(sections ...ArchiveSection) *ZipArchiveBuilder {
+	return &ZipArchiveBuilder{sections: sections}
+}
+
+var _ account_domain.ArchiveBuilder = (*ZipArchiveBuilder)(nil)
+
+func (b *ZipArchiveBuilder) Build(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+	archive := zip.NewWriter(w)
+
+	for _, section := range b.sections {
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     section.Name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := section.Write(ctx, userID, file); err != nil {
+			return fmt.Errorf("%s: %w", section.Name, err)
+		}
+	}
+
+	return archive.Close()
+}
+
+const archiveReadme = `This archive contains everything everd stores about your account.
+
+profile.json   account record
+todos.json     todos, including trashed and archived ones, and tags
+history.json   history of changes of todos
+webhooks.json  webhooks and their deliveries, secrets are omitted
+calendar.json  calendar feed and CalDAV clients state
+sync.json      operations and deletions recorded for offline sync
+undo.json      undo and redo stacks
+
+everd does not store sessions, authorization is done by the gateway in
+front of it, so there are no sessions to export.
+`
+
+func ReadmeSection() ArchiveSection {
+	return ArchiveSection{
+		Name: "README.txt",
+		Write: func(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+			_, err := io.WriteString(w, archiveReadme)
+			return err
+		},
+	}
+}
+
+func writeArchiveJSON(w io.Writer, data any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(data)
+}
+
+// PostrgesArchiveRepository reads data of user for sections of archive
+// which are not exported by other domains.
+type PostrgesArchiveRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesArchiveRepository(db *sql.DB) *PostrgesArchiveRepository {
+	return &PostrgesArchiveRepository{db: db}
+}
+
+// Sections returns sections of archive read from database.
+func (r *PostrgesArchiveRepository) Sections() []ArchiveSection {
+	return []ArchiveSection{
+		{Name: "profile.json", Write: r.WriteProfile},
+		{Name: "history.json", Write: r.WriteHistory},
+		{Name: "webhooks.json", Write: r.WriteWebhooks},
+		{Name: "calendar.json", Write: r.WriteCalendar},
+		{Name: "sync.json", Write: r.WriteSync},
+		{Name: "undo.json", Write: r.WriteUndo},
+	}
+}
+
+type ProfileArchive struct {
+	ID        int        `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+func (r *PostrgesArchiveRepository) WriteProfile(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+	exec, err := storage.GetDBExecutor(ctx, nil, r.db)
+	if err != nil {
+		return err
+	}
+
+	var (
+		profile   ProfileArchive
+		deletedAt sql.NullTime
+	)
+	if err := exec.QueryRow(`select id, created_at, updated_at, deleted_at
+		from users
+		where id = $1`, userID).Scan(&profile.ID, &profile.CreatedAt, &profile.UpdatedAt, &deletedAt); err != nil {
+		return err
+	}
+	profile.DeletedAt = nullTimePtr(deletedAt)
+
+	return writeArchiveJSON(w, profile)
+}
+
+type HistoryArchive struct {
+	TodoID     int       `json:"todo_id"`
+	Name       string    `json:"name"`
+	OldValue   string    `json:"old_value"`
+	NewValue   string    `json:"new_value"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (r *PostrgesArchiveRepository) WriteHistory(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+	exec, err := storage.GetDBExecutor(ctx, nil, r.db)
+	if err != nil {
+		return err
+	}
+
+	rows, err := exec.Query(`select todo_id, name, old_value, new_value, occurred_at
+		from todo_events
+		where user_id = $1
+		order by id`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	history := []HistoryArchive{}
+	for rows.Next() {
+		var event HistoryArchive
+		if err := rows.Scan(&event.TodoID, &event.Name, &event.OldValue, &event.NewValue, &event.OccurredAt); err != nil {
+			return err
+		}
+		history = append(history, event)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return writeArchiveJSON(w, history)
+}
+
+type WebhookArchive struct {
+	ID                  int                      `json:"id"`
+	URL                 string                   `json:"url"`
+	Events              json.RawMessage          `json:"events"`
+	Enabled             bool                     `json:"enabled"`
+	ConsecutiveFailures int                      `json:"consecutive_failures"`
+	CreatedAt           time.Time                `json:"created_at"`
+	UpdatedAt           time.Time                `json:"updated_at"`
+	Deliveries          []WebhookDeliveryArchive `json:"deliveries"`
+}
+
+type WebhookDeliveryArchive struct {
+	EventID      string          `json:"event_id"`
+	EventName    string          `json:"event_name"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"response_code"`
+	LastError    string          `json:"last_error"`
+	CreatedAt    time.Time       `json:"created_at"`
+	DeliveredAt  *time.Time      `json:"delivered_at"`
+}
+
+// WriteWebhooks writes webhooks of user with their deliveries, secrets are
+// credentials rather than data of user and are omitted.
+func (r *PostrgesArchiveRepository) WriteWebhooks(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+	exec, err := storage.GetDBExecutor(ctx, nil, r.db)
+	if err != nil {
+		return err
+	}
+
+	rows, err := exec.Query(`select id, url, events, enabled, consecutive_failures, created_at, updated_at
+		from webhooks
+		where user_id = $1
+		order by id`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	webhooks := []WebhookArchive{}
+	webhookIndexes := make(map[int]int)
+	for rows.Next() {
+		webhook := WebhookArchive{Deliveries: []WebhookDeliveryArchive{}}
+		if err := rows.Scan(
+			&webhook.ID,
+			&webhook.URL,
+			&webhook.Events,
+			&webhook.Enabled,
+			&webhook.ConsecutiveFailures,
+			&webhook.CreatedAt,
+			&webhook.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		webhookIndexes[webhook.ID] = len(webhooks)
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	deliveryRows, err := exec.Query(`select webhook_deliveries.webhook_id,
+			webhook_deliveries.event_id,
+			webhook_deliveries.event_name,
+			webhook_deliveries.payload,
+			webhook_deliveries.status,
+			webhook_deliveries.attempts,
+			webhook_deliveries.response_code,
+			webhook_deliveries.last_error,
+			webhook_deliveries.created_at,
+			webhook_deliveries.delivered_at
+		from webhook_deliveries
+		join webhooks on webhooks.id = webhook_deliveries.webhook_id
+		where webhooks.user_id = $1
+		order by webhook_deliveries.id`, userID)
+	if err != nil {
+		return err
+	}
+	defer deliveryRows.Close()
+
+	for deliveryRows.Next() {
+		var (
+			webhookID   int
+			delivery    WebhookDeliveryArchive
+			deliveredAt sql.NullTime
+		)
+		if err := deliveryRows.Scan(
+			&webhookID,
+			&delivery.EventID,
+			&delivery.EventName,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.ResponseCode,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&deliveredAt,
+		); err != nil {
+			return err
+		}
+		delivery.DeliveredAt = nullTimePtr(deliveredAt)
+
+		if i, ok := webhookIndexes[webhookID]; ok {
+			webhooks[i].Deliveries = append(webhooks[i].Deliveries, delivery)
+		}
+	}
+	if err := deliveryRows.Err(); err != nil {
+		return err
+	}
+
+	return writeArchiveJSON(w, webhooks)
+}
+
+type CalendarArchive struct {
+	// FeedCreatedAt is time calendar feed token was issued, token itself is
+	// stored hashed.
+	FeedCreatedAt *time.Time              `json:"feed_created_at"`
+	Objects       []CalendarObjectArchive `json:"caldav_objects"`
+}
+
+type CalendarObjectArchive struct {
+	TodoID int    `json:"todo_id"`
+	Name   string `json:"name"`
+	UID    string `json:"uid"`
+}
+
+func (r *PostrgesArchiveRepository) WriteCalendar(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+	exec, err := storage.GetDBExecutor(ctx, nil, r.db)
+	if err != nil {
+		return err
+	}
+
+	calendar := CalendarArchive{Objects: []CalendarObjectArchive{}}
+
+	var feedCreatedAt time.Time
+	err = exec.QueryRow(`select created_at from calendar_tokens where user_id = $1`, userID).Scan(&feedCreatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return err
+	default:
+		calendar.FeedCreatedAt = &feedCreatedAt
+	}
+
+	rows, err := exec.Query(`select todo_id, name, uid
+		from caldav_objects
+		where user_id = $1
+		order by todo_id`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var object CalendarObjectArchive
+		if err := rows.Scan(&object.TodoID, &object.Name, &object.UID); err != nil {
+			return err
+		}
+		calendar.Objects = append(calendar.Objects, object)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return writeArchiveJSON(w, calendar)
+}
+
+type SyncArchive struct {
+	Operations []SyncOperationArchive `json:"operations"`
+	Tombstones []TombstoneArchive     `json:"deleted_todos"`
+}
+
+type SyncOperationArchive struct {
+	OperationID string    `json:"operation_id"`
+	Status      string    `json:"status"`
+	TodoID      int       `json:"todo_id"`
+	Error       string    `json:"error"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type TombstoneArchive struct {
+	TodoID    int       `json:"todo_id"`
+	Seq       int64     `json:"seq"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (r *PostrgesArchiveRepository) WriteSync(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+	exec, err := storage.GetDBExecutor(ctx, nil, r.db)
+	if err != nil {
+		return err
+	}
+
+	sync := SyncArchive{
+		Operations: []SyncOperationArchive{},
+		Tombstones: []TombstoneArchive{},
+	}
+
+	rows, err := exec.Query(`select operation_id, status, todo_id, error, created_at
+		from sync_operations
+		where user_id = $1
+		order by created_at, operation_id`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var operation SyncOperationArchive
+		if err := rows.Scan(
+			&operation.OperationID,
+			&operation.Status,
+			&operation.TodoID,
+			&operation.Error,
+			&operation.CreatedAt,
+		); err != nil {
+			return err
+		}
+		sync.Operations = append(sync.Operations, operation)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tombstoneRows, err := exec.Query(`select todo_id, seq, created_at
+		from todo_tombstones
+		where user_id = $1
+		order by seq`, userID)
+	if err != nil {
+		return err
+	}
+	defer tombstoneRows.Close()
+
+	for tombstoneRows.Next() {
+		var tombstone TombstoneArchive
+		if err := tombstoneRows.Scan(&tombstone.TodoID, &tombstone.Seq, &tombstone.CreatedAt); err != nil {
+			return err
+		}
+		sync.Tombstones = append(sync.Tombstones, tombstone)
+	}
+	if err := tombstoneRows.Err(); err != nil {
+		return err
+	}
+
+	return writeArchiveJSON(w, sync)
+}
+
+type UndoArchive struct {
+	Stack     string          `json:"stack"`
+	Events    json.RawMessage `json:"events"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (r *PostrgesArchiveRepository) WriteUndo(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+	exec, err := storage.GetDBExecutor(ctx, nil, r.db)
+	if err != nil {
+		return err
+	}
+
+	rows, err := exec.Query(`select stack, events, created_at
+		from undo_operations
+		where user_id = $1
+		order by id`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	operations := []UndoArchive{}
+	for rows.Next() {
+		var operation UndoArchive
+		if err := rows.Scan(&operation.Stack, &operation.Events, &operation.CreatedAt); err != nil {
+			return err
+		}
+		operations = append(operations, operation)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return writeArchiveJSON(w, operations)
+}
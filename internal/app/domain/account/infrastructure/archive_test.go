@@ -0,0 +1,74 @@
+package account_infrastructure
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+)
+
+func TestZipArchiveBuilder(t *testing.T) {
+	builder := NewZipArchiveBuilder(
+		ReadmeSection(),
+		ArchiveSection{
+			Name: "todos.json",
+			Write: func(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+				return writeArchiveJSON(w, map[string]any{"user_id": userID})
+			},
+		},
+	)
+
+	var archive bytes.Buffer
+	if err := builder.Build(context.Background(), 7, &archive); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	for _, file := range reader.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = string(content)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("files = %v", files)
+	}
+	if !strings.Contains(files["README.txt"], "sessions") {
+		t.Fatalf("README.txt = %q", files["README.txt"])
+	}
+	if files["todos.json"] != "{\n\t\"user_id\": 7\n}\n" {
+		t.Fatalf("todos.json = %q", files["todos.json"])
+	}
+}
+
+func TestZipArchiveBuilderError(t *testing.T) {
+	errSection := errors.New("database is down")
+	builder := NewZipArchiveBuilder(ArchiveSection{
+		Name: "history.json",
+		Write: func(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+			return errSection
+		},
+	})
+
+	err := builder.Build(context.Background(), 7, io.Discard)
+	if !errors.Is(err, errSection) || !strings.Contains(err.Error(), "history.json") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,452 @@
+package account_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	account_domain "github.com/kotsmile/everd-backend/internal/app/domain/account"
+	account_model "github.com/kotsmile/everd-backend/internal/app/domain/account/model"
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostrgesUserRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesUserRepository(db *sql.DB) *PostrgesUserRepository {
+	return &PostrgesUserRepository{db: db}
+}
+
+var (
+	_ account_domain.UserRepository      = (*PostrgesUserRepository)(nil)
+	_ access_domain.UserStatusRepository = (*PostrgesUserRepository)(nil)
+)
+
+func (r *PostrgesUserRepository) Get(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tx util.Transaction,
+) (account_model.User, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return account_model.User{}, err
+	}
+
+	var (
+		createdAt time.Time
+		updatedAt time.Time
+		deletedAt sql.NullTime
+	)
+	err = exec.QueryRow(`select created_at, updated_at, deleted_at
+		from users
+		where id = $1`, userID).Scan(&createdAt, &updatedAt, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return account_model.User{}, account_model.ErrNotFound
+	}
+	if err != nil {
+		return account_model.User{}, err
+	}
+
+	var deletedAtPtr *time.Time
+	if deletedAt.Valid {
+		deletedAtPtr = &deletedAt.Time
+	}
+
+	return account_model.NewUserFromDB(userID, createdAt, updatedAt, deletedAtPtr), nil
+}
+
+func (r *PostrgesUserRepository) Save(
+	ctx context.Context,
+	user account_model.User,
+	tx util.Transaction,
+) (err error) {
+	exec, commit, rollaback, err := storage.GetTxOrCreateTx(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(rollaback(), err)
+		} else {
+			err = commit()
+		}
+	}()
+
+	userPF := user.PF()
+	deletedAt := sql.NullTime{}
+	if userPF.DeletedAt != nil {
+		deletedAt = sql.NullTime{Time: *userPF.DeletedAt, Valid: true}
+	}
+
+	if _, err := exec.Exec(`update users
+		set updated_at = $2, deleted_at = $3
+		where id = $1`, userPF.ID, userPF.UpdatedAt, deletedAt); err != nil {
+		return err
+	}
+
+	if !deletedAt.Valid {
+		return nil
+	}
+
+	// calendar feed and webhooks send data of user without authorization
+	if _, err := exec.Exec(`delete from calendar_tokens where user_id = $1`, userPF.ID); err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`update webhooks set enabled = false, updated_at = now() where user_id = $1`, userPF.ID)
+	return err
+}
+
+func (r *PostrgesUserRepository) DeletedBefore(
+	ctx context.Context,
+	deletedBefore time.Time,
+	limit int,
+	tx util.Transaction,
+) ([]access_domain.UserID, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.Query(`select id
+		from users
+		where deleted_at < $1
+		order by id
+		limit $2`, deletedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []access_domain.UserID
+	for rows.Next() {
+		var userIDInt int
+		if err := rows.Scan(&userIDInt); err != nil {
+			return nil, err
+		}
+
+		userID, err := access_domain.NewUserID(userIDInt)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// purgeStatements delete data of user. Deleting user cascades only to rows
+// referencing users (todolist, tags, todo_events, data_exports,
+// idempotency_keys), todos are referenced by todolist rather than
+// referencing it, so they are deleted first, which cascades to todo_tags,
+// todo_events and caldav_objects.
+// Tables without foreign keys are cleaned explicitly, outbox messages of
+// todolist events carry titles and comments of todos and are keyed by user
+// id.
+var purgeStatements = []string{
+	`delete from todos where id in (select todo_id from todolist where user_id = $1)`,
+	`delete from todo_tombstones where user_id = $1`,
+	`delete from sync_operations where user_id = $1`,
+	`delete from undo_operations where user_id = $1`,
+	`delete from calendar_tokens where user_id = $1`,
+	`delete from caldav_objects where user_id = $1`,
+	`delete from webhooks where user_id = $1`,
+	`delete from outbox where topic = '` + todolist_infrastructure.EventTopic + `' and key = $1::text`,
+	`delete from users where id = $1`,
+}
+
+func (r *PostrgesUserRepository) Purge(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tx util.Transaction,
+) (err error) {
+	exec, commit, rollaback, err := storage.GetTxOrCreateTx(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(rollaback(), err)
+		} else {
+			err = commit()
+		}
+	}()
+
+	for _, statement := range purgeStatements {
+		if _, err := exec.Exec(statement, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *PostrgesUserRepository) IsDeleted(ctx context.Context, userID access_domain.UserID) (bool, error) {
+	exec, err := storage.GetDBExecutor(ctx, nil, r.db)
+	if err != nil {
+		return false, err
+	}
+
+	var deleted bool
+	err = exec.QueryRow(`select deleted_at is not null
+		from users
+		where id = $1`, userID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return deleted, err
+}
+
+type DataExportDTO struct {
+	ID         int64
+	UserID     int
+	Status     string
+	Error      string
+	CreatedAt  time.Time
+	StartedAt  sql.NullTime
+	FinishedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+}
+
+const dataExportColumns = `id, user_id, status, error, created_at,
+	started_at, finished_at, expires_at`
+
+func scanDataExportDTO(row interface{ Scan(...any) error }) (DataExportDTO, error) {
+	var exportDTO DataExportDTO
+	err := row.Scan(
+		&exportDTO.ID,
+		&exportDTO.UserID,
+		&exportDTO.Status,
+		&exportDTO.Error,
+		&exportDTO.CreatedAt,
+		&exportDTO.StartedAt,
+		&exportDTO.FinishedAt,
+		&exportDTO.ExpiresAt,
+	)
+
+	return exportDTO, err
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func ptrNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func fromDataExportDTO(exportDTO DataExportDTO) (account_model.DataExport, error) {
+	id, err := account_model.NewExportID(exportDTO.ID)
+	if err != nil {
+		return account_model.DataExport{}, err
+	}
+
+	userID, err := access_domain.NewUserID(exportDTO.UserID)
+	if err != nil {
+		return account_model.DataExport{}, err
+	}
+
+	return account_model.NewDataExportFromDB(
+		id,
+		userID,
+		account_model.ExportStatus(exportDTO.Status),
+		exportDTO.Error,
+		exportDTO.CreatedAt,
+		nullTimePtr(exportDTO.StartedAt),
+		nullTimePtr(exportDTO.FinishedAt),
+		nullTimePtr(exportDTO.ExpiresAt),
+	), nil
+}
+
+type PostrgesExportRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesExportRepository(db *sql.DB) *PostrgesExportRepository {
+	return &PostrgesExportRepository{db: db}
+}
+
+var _ account_domain.ExportRepository = (*PostrgesExportRepository)(nil)
+
+func (r *PostrgesExportRepository) Create(
+	ctx context.Context,
+	export account_model.DataExport,
+	tx util.Transaction,
+) (account_model.DataExport, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return account_model.DataExport{}, err
+	}
+
+	exportPF := export.PF()
+	exportDTO, err := scanDataExportDTO(exec.QueryRow(`insert into data_exports (user_id, status, created_at)
+		values ($1, $2, $3)
+		returning `+dataExportColumns, exportPF.UserID, exportPF.Status, exportPF.CreatedAt))
+	if err != nil {
+		return account_model.DataExport{}, err
+	}
+
+	return fromDataExportDTO(exportDTO)
+}
+
+func (r *PostrgesExportRepository) Get(
+	ctx context.Context,
+	userID access_domain.UserID,
+	exportID account_model.ExportID,
+	tx util.Transaction,
+) (account_model.DataExport, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return account_model.DataExport{}, err
+	}
+
+	exportDTO, err := scanDataExportDTO(exec.QueryRow(`select `+dataExportColumns+`
+		from data_exports
+		where id = $1
+		and user_id = $2`, exportID.Int64(), userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return account_model.DataExport{}, account_model.ErrExportNotFound
+	}
+	if err != nil {
+		return account_model.DataExport{}, err
+	}
+
+	return fromDataExportDTO(exportDTO)
+}
+
+func (r *PostrgesExportRepository) Unfinished(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tx util.Transaction,
+) (account_model.DataExport, bool, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return account_model.DataExport{}, false, err
+	}
+
+	exportDTO, err := scanDataExportDTO(exec.QueryRow(`select `+dataExportColumns+`
+		from data_exports
+		where user_id = $1
+		and status in ($2, $3)
+		order by id
+		limit 1`, userID, account_model.ExportPending, account_model.ExportRunning))
+	if errors.Is(err, sql.ErrNoRows) {
+		return account_model.DataExport{}, false, nil
+	}
+	if err != nil {
+		return account_model.DataExport{}, false, err
+	}
+
+	export, err := fromDataExportDTO(exportDTO)
+	return export, err == nil, err
+}
+
+func (r *PostrgesExportRepository) Claim(
+	ctx context.Context,
+	staleBefore time.Time,
+	tx util.Transaction,
+) (account_model.DataExport, bool, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return account_model.DataExport{}, false, err
+	}
+
+	exportDTO, err := scanDataExportDTO(exec.QueryRow(`select `+dataExportColumns+`
+		from data_exports
+		where status = $1
+		or (status = $2 and started_at < $3)
+		order by id
+		limit 1
+		for update skip locked`, account_model.ExportPending, account_model.ExportRunning, staleBefore))
+	if errors.Is(err, sql.ErrNoRows) {
+		return account_model.DataExport{}, false, nil
+	}
+	if err != nil {
+		return account_model.DataExport{}, false, err
+	}
+
+	export, err := fromDataExportDTO(exportDTO)
+	return export, err == nil, err
+}
+
+func (r *PostrgesExportRepository) Save(
+	ctx context.Context,
+	export account_model.DataExport,
+	archive []byte,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	exportPF := export.PF()
+	_, err = exec.Exec(`update data_exports
+		set status = $2,
+			error = $3,
+			started_at = $4,
+			finished_at = $5,
+			expires_at = $6,
+			archive = coalesce($7, archive)
+		where id = $1`,
+		exportPF.ID.Int64(),
+		exportPF.Status,
+		exportPF.Error,
+		ptrNullTime(exportPF.StartedAt),
+		ptrNullTime(exportPF.FinishedAt),
+		ptrNullTime(exportPF.ExpiresAt),
+		archive,
+	)
+	return err
+}
+
+func (r *PostrgesExportRepository) Archive(
+	ctx context.Context,
+	exportID account_model.ExportID,
+	tx util.Transaction,
+) ([]byte, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive []byte
+	err = exec.QueryRow(`select archive from data_exports where id = $1 and archive is not null`, exportID.Int64()).Scan(&archive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, account_model.ErrExportNotReady
+	}
+
+	return archive, err
+}
+
+func (r *PostrgesExportRepository) DeleteExpired(
+	ctx context.Context,
+	now time.Time,
+	tx util.Transaction,
+) (int64, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := exec.Exec(`delete from data_exports where expires_at < $1`, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,189 @@
+package account_model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+)
+
+var (
+	Err               = errors.New("account")
+	ErrNotFound       = fmt.Errorf("%w: user is not found", Err)
+	ErrIsDeleted      = fmt.Errorf("%w: account is deleted", Err)
+	ErrExportNotFound = fmt.Errorf("%w: export is not found", Err)
+	ErrExportNotReady = fmt.Errorf("%w: export is not ready", Err)
+	ErrExportExpired  = fmt.Errorf("%w: export is expired", Err)
+	ErrNotRunning     = fmt.Errorf("%w: export is not running", Err)
+)
+
+type User struct {
+	id access_domain.UserID
+
+	createdAt time.Time
+	updatedAt time.Time
+	deletedAt *time.Time
+}
+
+func NewUserFromDB(
+	id access_domain.UserID,
+	createdAt time.Time,
+	updatedAt time.Time,
+	deletedAt *time.Time,
+) User {
+	return User{
+		id:        id,
+		createdAt: createdAt,
+		updatedAt: updatedAt,
+		deletedAt: deletedAt,
+	}
+}
+
+type UserPF struct {
+	ID        access_domain.UserID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+func (u *User) PF() UserPF {
+	return UserPF{
+		ID:        u.id,
+		CreatedAt: u.createdAt,
+		UpdatedAt: u.updatedAt,
+		DeletedAt: u.deletedAt,
+	}
+}
+
+func (u *User) IsDeleted() bool {
+	return u.deletedAt != nil
+}
+
+// Delete soft deletes account, owned data is hard deleted after grace
+// period.
+func (u *User) Delete(now time.Time) error {
+	if u.IsDeleted() {
+		return ErrIsDeleted
+	}
+
+	u.deletedAt = &now
+	u.updatedAt = now
+
+	return nil
+}
+
+// DataExport is background job which collects everything stored about user
+// into archive.
+type DataExport struct {
+	id     ExportID
+	userID access_domain.UserID
+
+	status ExportStatus
+	error  string
+
+	createdAt  time.Time
+	startedAt  *time.Time
+	finishedAt *time.Time
+	// expiresAt is set for ready export, archive is deleted after it.
+	expiresAt *time.Time
+}
+
+func NewDataExport(userID access_domain.UserID) DataExport {
+	return DataExport{
+		userID:    userID,
+		status:    ExportPending,
+		createdAt: time.Now(),
+	}
+}
+
+func NewDataExportFromDB(
+	id ExportID,
+	userID access_domain.UserID,
+	status ExportStatus,
+	errorMessage string,
+	createdAt time.Time,
+	startedAt *time.Time,
+	finishedAt *time.Time,
+	expiresAt *time.Time,
+) DataExport {
+	return DataExport{
+		id:         id,
+		userID:     userID,
+		status:     status,
+		error:      errorMessage,
+		createdAt:  createdAt,
+		startedAt:  startedAt,
+		finishedAt: finishedAt,
+		expiresAt:  expiresAt,
+	}
+}
+
+type DataExportPF struct {
+	ID         ExportID
+	UserID     access_domain.UserID
+	Status     ExportStatus
+	Error      string
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	ExpiresAt  *time.Time
+}
+
+func (e *DataExport) PF() DataExportPF {
+	return DataExportPF{
+		ID:         e.id,
+		UserID:     e.userID,
+		Status:     e.status,
+		Error:      e.error,
+		CreatedAt:  e.createdAt,
+		StartedAt:  e.startedAt,
+		FinishedAt: e.finishedAt,
+		ExpiresAt:  e.expiresAt,
+	}
+}
+
+// Start marks export as running, export which got stuck in running state
+// is started again.
+func (e *DataExport) Start(now time.Time) {
+	e.status = ExportRunning
+	e.startedAt = &now
+}
+
+func (e *DataExport) Finish(now time.Time, expiresAt time.Time) error {
+	if e.status != ExportRunning {
+		return ErrNotRunning
+	}
+
+	e.status = ExportReady
+	e.error = ""
+	e.finishedAt = &now
+	e.expiresAt = &expiresAt
+
+	return nil
+}
+
+func (e *DataExport) Fail(now time.Time, errorMessage string) error {
+	if e.status != ExportRunning {
+		return ErrNotRunning
+	}
+
+	e.status = ExportFailed
+	e.error = errorMessage
+	e.finishedAt = &now
+
+	return nil
+}
+
+// CheckReady returns error unless archive of export can be downloaded.
+func (e *DataExport) CheckReady(now time.Time) error {
+	if e.status != ExportReady {
+		return ErrExportNotReady
+	}
+
+	if e.expiresAt != nil && !now.Before(*e.expiresAt) {
+		return ErrExportExpired
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package account_model
+
+import (
+	"fmt"
+)
+
+var ErrExportID = fmt.Errorf("%w: export id", Err)
+
+type ExportID uint64
+
+var NilExportID ExportID
+
+func NewExportID(id int64) (ExportID, error) {
+	if id < 0 {
+		return NilExportID, ErrExportID
+	}
+
+	return ExportID(uint64(id)), nil
+}
+
+func (id ExportID) Int64() int64 {
+	return int64(id)
+}
+
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportRunning ExportStatus = "running"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
@@ -0,0 +1,168 @@
+package account_domain
+
+import (
+	"context"
+	"io"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	account_model "github.com/kotsmile/everd-backend/internal/app/domain/account/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	// DefaultDeletionGracePeriod is time between soft deletion of account
+	// and hard deletion of its data.
+	DefaultDeletionGracePeriod = 30 * 24 * time.Hour
+	// DefaultExportTTL is time archive of ready export can be downloaded.
+	DefaultExportTTL = 7 * 24 * time.Hour
+)
+
+type UserRepository interface {
+	// Get returns user, including deleted one, or account_model.ErrNotFound.
+	Get(ctx context.Context, userID access_domain.UserID, tx util.Transaction) (account_model.User, error)
+	// Save stores deleted_at of user. Once user is deleted, everything
+	// acting on behalf of user without authorization, calendar feed and
+	// webhooks, is disabled.
+	Save(ctx context.Context, user account_model.User, tx util.Transaction) error
+	// DeletedBefore returns up to limit users soft deleted before time.
+	DeletedBefore(
+		ctx context.Context,
+		deletedBefore time.Time,
+		limit int,
+		tx util.Transaction,
+	) ([]access_domain.UserID, error)
+	// Purge hard deletes user and all data owned by user.
+	Purge(ctx context.Context, userID access_domain.UserID, tx util.Transaction) error
+}
+
+type ExportRepository interface {
+	// Create stores new export and returns it with id.
+	Create(ctx context.Context, export account_model.DataExport, tx util.Transaction) (account_model.DataExport, error)
+	// Get returns export of user or account_model.ErrExportNotFound.
+	Get(
+		ctx context.Context,
+		userID access_domain.UserID,
+		exportID account_model.ExportID,
+		tx util.Transaction,
+	) (account_model.DataExport, error)
+	// Unfinished returns pending or running export of user, if any.
+	Unfinished(ctx context.Context, userID access_domain.UserID, tx util.Transaction) (account_model.DataExport, bool, error)
+	// Claim locks the oldest pending export, or export running since before
+	// staleBefore, until tx is finished. Exports locked by other
+	// transactions are skipped.
+	Claim(ctx context.Context, staleBefore time.Time, tx util.Transaction) (account_model.DataExport, bool, error)
+	// Save stores state of export, archive is stored unless it is nil.
+	Save(ctx context.Context, export account_model.DataExport, archive []byte, tx util.Transaction) error
+	Archive(ctx context.Context, exportID account_model.ExportID, tx util.Transaction) ([]byte, error)
+	// DeleteExpired deletes exports which expired before now.
+	DeleteExpired(ctx context.Context, now time.Time, tx util.Transaction) (int64, error)
+}
+
+// ArchiveBuilder writes archive of everything stored about user.
+type ArchiveBuilder interface {
+	Build(ctx context.Context, userID access_domain.UserID, w io.Writer) error
+}
+
+type AccountService struct {
+	txFactory  util.TransactionFactory
+	userRepo   UserRepository
+	exportRepo ExportRepository
+
+	gracePeriod time.Duration
+}
+
+func NewAccountService(
+	txFactory util.TransactionFactory,
+	userRepo UserRepository,
+	exportRepo ExportRepository,
+	gracePeriod time.Duration,
+) *AccountService {
+	return &AccountService{
+		txFactory:   txFactory,
+		userRepo:    userRepo,
+		exportRepo:  exportRepo,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// RequestExport queues export of user data, unfinished export is returned
+// instead of queueing another one.
+func (s *AccountService) RequestExport(
+	ctx context.Context,
+	userID access_domain.UserID,
+) (account_model.DataExport, error) {
+	var export account_model.DataExport
+
+	err := s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		unfinished, ok, err := s.exportRepo.Unfinished(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			export = unfinished
+			return nil
+		}
+
+		export, err = s.exportRepo.Create(ctx, account_model.NewDataExport(userID), tx)
+		return err
+	})
+	if err != nil {
+		return account_model.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+func (s *AccountService) GetExport(
+	ctx context.Context,
+	userID access_domain.UserID,
+	exportID account_model.ExportID,
+) (account_model.DataExport, error) {
+	return s.exportRepo.Get(ctx, userID, exportID, nil)
+}
+
+// ExportArchive returns zip archive of ready export.
+func (s *AccountService) ExportArchive(
+	ctx context.Context,
+	userID access_domain.UserID,
+	exportID account_model.ExportID,
+) ([]byte, error) {
+	export, err := s.exportRepo.Get(ctx, userID, exportID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := export.CheckReady(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s.exportRepo.Archive(ctx, exportID, nil)
+}
+
+// DeleteAccount soft deletes account of user right away, its data is hard
+// deleted by AccountPurger once grace period is over. Time of hard deletion
+// is returned.
+func (s *AccountService) DeleteAccount(ctx context.Context, userID access_domain.UserID) (time.Time, error) {
+	var purgeAt time.Time
+
+	err := s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		user, err := s.userRepo.Get(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := user.Delete(now); err != nil {
+			return err
+		}
+		purgeAt = now.Add(s.gracePeriod)
+
+		return s.userRepo.Save(ctx, user, tx)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return purgeAt, nil
+}
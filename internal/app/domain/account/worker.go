@@ -0,0 +1,207 @@
+package account_domain
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	account_model "github.com/kotsmile/everd-backend/internal/app/domain/account/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	DefaultExportPollInterval = 10 * time.Second
+	// DefaultExportStaleAfter is time after which running export is
+	// considered abandoned, e.g. by crashed process, and is started again.
+	DefaultExportStaleAfter = time.Hour
+
+	DefaultPurgeInterval  = time.Hour
+	DefaultPurgeBatchSize = 100
+)
+
+type ExportWorkerConfig struct {
+	PollInterval time.Duration
+	StaleAfter   time.Duration
+	TTL          time.Duration
+}
+
+var DefaultExportWorkerConfig = ExportWorkerConfig{
+	PollInterval: DefaultExportPollInterval,
+	StaleAfter:   DefaultExportStaleAfter,
+	TTL:          DefaultExportTTL,
+}
+
+// ExportWorker builds archives of queued exports one by one and deletes
+// expired ones.
+type ExportWorker struct {
+	txFactory  util.TransactionFactory
+	exportRepo ExportRepository
+	builder    ArchiveBuilder
+	logger     util.Logger
+	config     ExportWorkerConfig
+
+	now func() time.Time
+}
+
+func NewExportWorker(
+	txFactory util.TransactionFactory,
+	exportRepo ExportRepository,
+	builder ArchiveBuilder,
+	logger util.Logger,
+	config ExportWorkerConfig,
+) *ExportWorker {
+	return &ExportWorker{
+		txFactory:  txFactory,
+		exportRepo: exportRepo,
+		builder:    builder,
+		logger:     logger,
+		config:     config,
+		now:        time.Now,
+	}
+}
+
+// Run processes exports every poll interval until ctx is done.
+func (w *ExportWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		processed, err := w.ProcessNext(ctx)
+		if err != nil {
+			w.logger.WithError(err).Error("failed to process data export")
+		}
+
+		if err == nil && processed {
+			continue
+		}
+
+		if _, err := w.exportRepo.DeleteExpired(ctx, w.now(), nil); err != nil {
+			w.logger.WithError(err).Error("failed to delete expired data exports")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessNext builds archive of the oldest queued export and reports
+// whether there was one. Export is marked running in its own transaction,
+// so archive is built without holding a lock.
+func (w *ExportWorker) ProcessNext(ctx context.Context) (bool, error) {
+	var (
+		export account_model.DataExport
+		found  bool
+	)
+
+	err := w.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		var err error
+		export, found, err = w.exportRepo.Claim(ctx, w.now().Add(-w.config.StaleAfter), tx)
+		if err != nil || !found {
+			return err
+		}
+
+		export.Start(w.now())
+		return w.exportRepo.Save(ctx, export, nil, tx)
+	})
+	if err != nil || !found {
+		return false, err
+	}
+
+	exportPF := export.PF()
+	logger := w.logger.
+		WithField("export_id", exportPF.ID).
+		WithField("user_id", exportPF.UserID)
+
+	var archive bytes.Buffer
+	if buildErr := w.builder.Build(ctx, exportPF.UserID, &archive); buildErr != nil {
+		logger.WithError(buildErr).Error("failed to build data export")
+		if err := export.Fail(w.now(), buildErr.Error()); err != nil {
+			return true, err
+		}
+
+		return true, w.exportRepo.Save(ctx, export, nil, nil)
+	}
+
+	if err := export.Finish(w.now(), w.now().Add(w.config.TTL)); err != nil {
+		return true, err
+	}
+
+	if err := w.exportRepo.Save(ctx, export, archive.Bytes(), nil); err != nil {
+		return true, err
+	}
+
+	logger.WithField("size", archive.Len()).Info("data export is ready")
+	return true, nil
+}
+
+// AccountPurger periodically hard deletes accounts which were soft deleted
+// longer than grace period ago.
+type AccountPurger struct {
+	txFactory util.TransactionFactory
+	userRepo  UserRepository
+	logger    util.Logger
+
+	gracePeriod time.Duration
+	interval    time.Duration
+}
+
+func NewAccountPurger(
+	txFactory util.TransactionFactory,
+	userRepo UserRepository,
+	logger util.Logger,
+	gracePeriod time.Duration,
+	interval time.Duration,
+) *AccountPurger {
+	return &AccountPurger{
+		txFactory:   txFactory,
+		userRepo:    userRepo,
+		logger:      logger,
+		gracePeriod: gracePeriod,
+		interval:    interval,
+	}
+}
+
+// Run purges accounts every interval until ctx is done.
+func (p *AccountPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Purge(ctx); err != nil {
+			p.logger.WithError(err).Error("failed to purge deleted accounts")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge hard deletes due accounts, every account in its own transaction.
+func (p *AccountPurger) Purge(ctx context.Context) error {
+	for {
+		userIDs, err := p.userRepo.DeletedBefore(ctx, time.Now().Add(-p.gracePeriod), DefaultPurgeBatchSize, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, userID := range userIDs {
+			if err := p.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+				return p.userRepo.Purge(ctx, userID, tx)
+			}); err != nil {
+				return err
+			}
+
+			p.logger.WithField("user_id", userID).Info("purged deleted account")
+		}
+
+		if len(userIDs) < DefaultPurgeBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package todolist_domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	DefaultAutoArchiveAfter    = 30 * 24 * time.Hour
+	DefaultAutoArchiveInterval = time.Hour
+	// autoArchiveBatch is the number of users archived by a single query of
+	// AutoArchiver.
+	autoArchiveBatch = 100
+)
+
+type ArchiveRepository interface {
+	// GetArchived returns todo of user from archive or
+	// todolist_model.ErrNotFound.
+	GetArchived(
+		ctx context.Context,
+		userID access_domain.UserID,
+		todoID todolist_model.TodoID,
+		tx util.Transaction,
+	) (todolist_model.Todo, error)
+	// UsersWithCompleted returns up to limit users with greater id than
+	// afterUserID having todos completed before completedBefore in todolist,
+	// ordered by id.
+	UsersWithCompleted(
+		ctx context.Context,
+		completedBefore time.Time,
+		afterUserID access_domain.UserID,
+		limit int,
+		tx util.Transaction,
+	) ([]access_domain.UserID, error)
+}
+
+// ClearCompleted moves every completed todo of user to archive and returns
+// number of archived todos.
+func (s *TodolistService) ClearCompleted(ctx context.Context, userID access_domain.UserID) (int, error) {
+	return s.archiveCompleted(ctx, userID, time.Now())
+}
+
+func (s *TodolistService) archiveCompleted(
+	ctx context.Context,
+	userID access_domain.UserID,
+	completedBefore time.Time,
+) (int, error) {
+	var archived []todolist_model.TodoID
+	err := s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		archived = list.ArchiveCompleted(completedBefore)
+		if len(archived) == 0 {
+			return nil
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		return s.save(ctx, list, tx)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(archived), nil
+}
+
+func (s *TodolistService) ArchiveTodo(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.ArchiveTodo(todoID); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		return s.save(ctx, list, tx)
+	})
+}
+
+func (s *TodolistService) UnarchiveTodo(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		todo, err := s.archiveRepo.GetArchived(ctx, userID, todoID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.UnarchiveTodo(todo); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		return s.save(ctx, list, tx)
+	})
+}
+
+func (s *TodolistService) QueryArchive(
+	ctx context.Context,
+	userID access_domain.UserID,
+	query TodoQuery,
+) (TodoPage, error) {
+	query.Archived = true
+	return s.QueryTodos(ctx, userID, query)
+}
+
+// AutoArchiver periodically archives todos which were completed longer
+// than after ago. Todos are archived through todolist of every user, so
+// archiving is seen by sync and history like any other change.
+type AutoArchiver struct {
+	service     *TodolistService
+	archiveRepo ArchiveRepository
+	logger      util.Logger
+
+	after    time.Duration
+	interval time.Duration
+}
+
+func NewAutoArchiver(
+	service *TodolistService,
+	archiveRepo ArchiveRepository,
+	logger util.Logger,
+	after time.Duration,
+	interval time.Duration,
+) *AutoArchiver {
+	return &AutoArchiver{
+		service:     service,
+		archiveRepo: archiveRepo,
+		logger:      logger,
+		after:       after,
+		interval:    interval,
+	}
+}
+
+// Run archives todos every interval until ctx is done.
+func (a *AutoArchiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.Archive(ctx); err != nil {
+			a.logger.WithError(err).Error("failed to archive completed todos")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *AutoArchiver) Archive(ctx context.Context) error {
+	completedBefore := time.Now().Add(-a.after)
+	// archiving is not an operation of user, so it is not undoable
+	ctx = withoutUndo(ctx)
+
+	var afterUserID access_domain.UserID
+	var errs []error
+	total := 0
+	for {
+		userIDs, err := a.archiveRepo.UsersWithCompleted(ctx, completedBefore, afterUserID, autoArchiveBatch, nil)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		for _, userID := range userIDs {
+			archived, err := a.service.archiveCompleted(ctx, userID, completedBefore)
+			if err != nil {
+				// other users are still archived
+				errs = append(errs, err)
+				continue
+			}
+			total += archived
+		}
+
+		if len(userIDs) < autoArchiveBatch {
+			break
+		}
+		afterUserID = userIDs[len(userIDs)-1]
+	}
+
+	if total > 0 {
+		a.logger.WithField("count", total).Info("archived completed todos")
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,161 @@
+package todolist_domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const MaxBatchOperations = 500
+
+var (
+	ErrBatchOperationsLimit = fmt.Errorf("%w: at most %d operations are allowed", todolist_model.Err, MaxBatchOperations)
+	ErrBatchOperationType   = fmt.Errorf("%w: unknown operation type", todolist_model.Err)
+	ErrBatchMode            = fmt.Errorf("%w: mode must be one of: atomic, best_effort", todolist_model.Err)
+)
+
+type BatchOperationType string
+
+const (
+	BatchCompleteTodo   BatchOperationType = "complete"
+	BatchUncompleteTodo BatchOperationType = "uncomplete"
+	BatchDeleteTodo     BatchOperationType = "delete"
+	BatchMoveTodo       BatchOperationType = "move"
+	BatchTagTodo        BatchOperationType = "tag"
+	BatchChangeTitle    BatchOperationType = "change_title"
+)
+
+type BatchOperation struct {
+	Type   BatchOperationType
+	TodoID todolist_model.TodoID
+
+	Title string
+	TagID todolist_model.TagID
+	// After is todo to move todo after, nil moves todo to the start.
+	After *todolist_model.TodoID
+}
+
+// BatchMode defines what happens with batch when some of its operations
+// are rejected.
+type BatchMode string
+
+const (
+	// BatchAtomic applies either all operations or none of them.
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort applies every operation which is not rejected.
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+func ParseBatchMode(mode string) (BatchMode, error) {
+	switch BatchMode(mode) {
+	case "":
+		return BatchAtomic, nil
+	case BatchAtomic, BatchBestEffort:
+		return BatchMode(mode), nil
+	default:
+		return "", ErrBatchMode
+	}
+}
+
+type BatchStatus string
+
+const (
+	BatchApplied  BatchStatus = "applied"
+	BatchRejected BatchStatus = "rejected"
+	// BatchSkipped operations were valid, but are not applied because
+	// another operation of atomic batch was rejected.
+	BatchSkipped BatchStatus = "skipped"
+)
+
+type BatchResult struct {
+	Status BatchStatus
+	Error  string
+}
+
+// Batch applies operations in order to todolist loaded once and saves it
+// in a single transaction. Operations rejected by the domain are reported
+// in results, other errors fail the whole batch. Applied reports whether
+// todolist was saved, it is false when no operation is applied.
+func (s *TodolistService) Batch(
+	ctx context.Context,
+	userID access_domain.UserID,
+	mode BatchMode,
+	operations []BatchOperation,
+) (results []BatchResult, applied bool, err error) {
+	if len(operations) > MaxBatchOperations {
+		return nil, false, ErrBatchOperationsLimit
+	}
+
+	err = s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		results = make([]BatchResult, len(operations))
+		applied = false
+
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		rejected := false
+		for i, operation := range operations {
+			err := applyBatchOperation(list, operation)
+			if errors.Is(err, todolist_model.Err) {
+				results[i] = BatchResult{Status: BatchRejected, Error: err.Error()}
+				rejected = true
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			results[i] = BatchResult{Status: BatchApplied}
+			applied = true
+		}
+
+		if rejected && mode == BatchAtomic {
+			for i := range results {
+				if results[i].Status == BatchApplied {
+					results[i].Status = BatchSkipped
+				}
+			}
+			applied = false
+		}
+		// nothing is saved, transaction is committed empty
+		if !applied {
+			return nil
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		return s.save(ctx, list, tx)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return results, applied, nil
+}
+
+func applyBatchOperation(list *todolist_model.Todolist, operation BatchOperation) error {
+	switch operation.Type {
+	case BatchCompleteTodo:
+		return list.CompleteTodo(operation.TodoID)
+	case BatchUncompleteTodo:
+		return list.UncompleteTodo(operation.TodoID)
+	case BatchDeleteTodo:
+		return list.DeleteTodo(operation.TodoID)
+	case BatchMoveTodo:
+		return list.MoveTodo(operation.TodoID, operation.After)
+	case BatchTagTodo:
+		return list.TagTodo(operation.TodoID, operation.TagID)
+	case BatchChangeTitle:
+		return list.ChangeTitle(operation.TodoID, operation.Title)
+	default:
+		return ErrBatchOperationType
+	}
+}
@@ -0,0 +1,122 @@
+package todolist_domain
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+// newBatchFixture returns fixture with todos 1 "milk" and 2 "bread".
+func newBatchFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	f := newFixture()
+	for _, title := range []string{"milk", "bread"} {
+		if _, err := f.service.AddTodo(context.Background(), testUserID, title, todolist_model.PriorityNone, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return f
+}
+
+func (f *fixture) todo(t *testing.T, todoID todolist_model.TodoID) todolist_model.TodoPF {
+	t.Helper()
+
+	todo, ok := f.repo.lists[testUserID].todos[todoID]
+	if !ok {
+		t.Fatalf("todo %d is not found", todoID)
+	}
+
+	return todo
+}
+
+func batchStatuses(results []BatchResult) []BatchStatus {
+	statuses := make([]BatchStatus, len(results))
+	for i, result := range results {
+		statuses[i] = result.Status
+	}
+
+	return statuses
+}
+
+func TestBatchAtomicRejected(t *testing.T) {
+	f := newBatchFixture(t)
+	events := len(f.outbox.events)
+
+	results, applied, err := f.service.Batch(context.Background(), testUserID, BatchAtomic, []BatchOperation{
+		{Type: BatchCompleteTodo, TodoID: 1},
+		{Type: BatchCompleteTodo, TodoID: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if applied {
+		t.Error("rejected atomic batch is applied")
+	}
+	if got := batchStatuses(results); !reflect.DeepEqual(got, []BatchStatus{BatchSkipped, BatchRejected}) {
+		t.Errorf("statuses = %v", got)
+	}
+	if results[1].Error == "" {
+		t.Error("rejected operation has no error")
+	}
+	if f.todo(t, 1).Done {
+		t.Error("skipped operation is saved")
+	}
+	if len(f.outbox.events) != events {
+		t.Errorf("events = %+v", f.outbox.events[events:])
+	}
+}
+
+func TestBatchBestEffort(t *testing.T) {
+	f := newBatchFixture(t)
+
+	results, applied, err := f.service.Batch(context.Background(), testUserID, BatchBestEffort, []BatchOperation{
+		{Type: BatchCompleteTodo, TodoID: 1},
+		{Type: BatchCompleteTodo, TodoID: 3},
+		{Type: BatchChangeTitle, TodoID: 2, Title: "eggs"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !applied {
+		t.Error("best effort batch is not applied")
+	}
+	if got := batchStatuses(results); !reflect.DeepEqual(got, []BatchStatus{BatchApplied, BatchRejected, BatchApplied}) {
+		t.Errorf("statuses = %v", got)
+	}
+	if !f.todo(t, 1).Done || f.todo(t, 2).Title != "eggs" {
+		t.Errorf("todos = %+v, %+v", f.todo(t, 1), f.todo(t, 2))
+	}
+}
+
+func TestBatchAllRejected(t *testing.T) {
+	for _, mode := range []BatchMode{BatchAtomic, BatchBestEffort} {
+		t.Run(string(mode), func(t *testing.T) {
+			f := newBatchFixture(t)
+			events := len(f.outbox.events)
+
+			results, applied, err := f.service.Batch(context.Background(), testUserID, mode, []BatchOperation{
+				{Type: BatchCompleteTodo, TodoID: 3},
+				{Type: BatchChangeTitle, TodoID: 1, Title: ""},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if applied {
+				t.Error("batch without applied operations is applied")
+			}
+			if got := batchStatuses(results); !reflect.DeepEqual(got, []BatchStatus{BatchRejected, BatchRejected}) {
+				t.Errorf("statuses = %v", got)
+			}
+			if len(f.outbox.events) != events {
+				t.Errorf("events = %+v", f.outbox.events[events:])
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package todolist_domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	calendarObjectPrefix    = "everd-"
+	calendarObjectExtension = ".ics"
+	calendarUIDSuffix       = "@everd"
+)
+
+var (
+	ErrCalendarObjectNotFound = fmt.Errorf("%w: calendar object not found", todolist_model.ErrNotFound)
+	ErrCalendarObjectName     = fmt.Errorf("%w: calendar object name is reserved", todolist_model.Err)
+	ErrCalendarObject         = fmt.Errorf("%w: calendar object must contain exactly one VTODO", todolist_model.Err)
+	ErrPreconditionFailed     = fmt.Errorf("%w: precondition failed", todolist_model.Err)
+)
+
+// CalendarObjectName is name of CalDAV resource of todo and UID of its
+// VTODO. Todos created by CalDAV clients keep names and UIDs chosen by
+// clients, other todos have DefaultCalendarObjectName.
+type CalendarObjectName struct {
+	Name string
+	UID  string
+}
+
+func DefaultCalendarObjectName(todoID todolist_model.TodoID) CalendarObjectName {
+	id := strconv.Itoa(todoID.Int())
+	return CalendarObjectName{
+		Name: calendarObjectPrefix + id + calendarObjectExtension,
+		UID:  "todo-" + id + calendarUIDSuffix,
+	}
+}
+
+// isDefaultCalendarObjectName reports whether name has form of default
+// names, clients can not create todos under such names.
+func isDefaultCalendarObjectName(name string) bool {
+	id, ok := strings.CutPrefix(name, calendarObjectPrefix)
+	if !ok {
+		return false
+	}
+
+	id, ok = strings.CutSuffix(id, calendarObjectExtension)
+	if !ok {
+		return false
+	}
+
+	_, err := strconv.Atoi(id)
+	return err == nil
+}
+
+type CalendarObjectRepository interface {
+	// Names returns names of todos of user which were created by CalDAV
+	// clients.
+	Names(
+		ctx context.Context,
+		userID access_domain.UserID,
+		tx util.Transaction,
+	) (map[todolist_model.TodoID]CalendarObjectName, error)
+	SetName(
+		ctx context.Context,
+		userID access_domain.UserID,
+		todoID todolist_model.TodoID,
+		name CalendarObjectName,
+		tx util.Transaction,
+	) error
+}
+
+// CalendarObject is todo exposed as CalDAV resource. ETag changes with
+// every change of todo.
+type CalendarObject struct {
+	CalendarObjectName
+	TodoID todolist_model.TodoID
+	ETag   string
+	Todo   ExportTodo
+}
+
+func newCalendarObject(
+	todo todolist_model.TodoPF,
+	name CalendarObjectName,
+	tagNames map[todolist_model.TagID]string,
+) CalendarObject {
+	exportTodo := NewExportTodo(todo, tagNames)
+
+	// every change of todo is stamped by its clock, tags are added
+	// because renaming tag does not change todos
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\x00%s\x00%s", todo.ID.Int(), todo.Clock.Latest(), strings.Join(exportTodo.Tags, "\x00"))
+
+	return CalendarObject{
+		CalendarObjectName: name,
+		TodoID:             todo.ID,
+		ETag:               `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`,
+		Todo:               exportTodo,
+	}
+}
+
+// CalendarCTag changes whenever any of objects changes, clients use it to
+// skip listing of unchanged collection.
+func CalendarCTag(objects []CalendarObject) string {
+	hash := sha256.New()
+	for _, object := range objects {
+		fmt.Fprintf(hash, "%s\x00%s\x00", object.Name, object.ETag)
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// CalendarPrecondition holds If-Match and If-None-Match of request, empty
+// value is not checked and "*" matches any existing object.
+type CalendarPrecondition struct {
+	IfMatch     string
+	IfNoneMatch string
+}
+
+func (p CalendarPrecondition) check(object CalendarObject, exists bool) error {
+	matches := func(etags string) bool {
+		if etags == "*" {
+			return exists
+		}
+
+		for _, etag := range strings.Split(etags, ",") {
+			if exists && strings.TrimPrefix(strings.TrimSpace(etag), "W/") == object.ETag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if p.IfMatch != "" && !matches(p.IfMatch) {
+		return ErrPreconditionFailed
+	}
+
+	if p.IfNoneMatch != "" && matches(p.IfNoneMatch) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// calendarObjects is calendar collection of todolist, trashed and archived
+// todos are not in it.
+type calendarObjects struct {
+	list     *todolist_model.Todolist
+	tagNames map[todolist_model.TagID]string
+	names    map[todolist_model.TodoID]CalendarObjectName
+	objects  []CalendarObject
+}
+
+func (s *TodolistService) getCalendarObjects(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tx util.Transaction,
+) (calendarObjects, error) {
+	list, err := s.getOrCreateTodolist(ctx, userID, tx)
+	if err != nil {
+		return calendarObjects{}, err
+	}
+
+	names, err := s.calendarObjectRepo.Names(ctx, userID, tx)
+	if err != nil {
+		return calendarObjects{}, err
+	}
+
+	collection := calendarObjects{
+		list:     list,
+		tagNames: map[todolist_model.TagID]string{},
+		names:    names,
+	}
+	for _, tag := range list.PF().Tags {
+		collection.tagNames[tag.ID] = tag.Name
+	}
+
+	for _, todo := range list.OrderedTodos() {
+		collection.objects = append(collection.objects, newCalendarObject(todo, collection.name(todo.ID), collection.tagNames))
+	}
+
+	return collection, nil
+}
+
+func (c calendarObjects) name(todoID todolist_model.TodoID) CalendarObjectName {
+	if name, ok := c.names[todoID]; ok {
+		return name
+	}
+
+	return DefaultCalendarObjectName(todoID)
+}
+
+func (c calendarObjects) find(name string) (CalendarObject, bool) {
+	for _, object := range c.objects {
+		if object.Name == name {
+			return object, true
+		}
+	}
+
+	return CalendarObject{}, false
+}
+
+// CalendarObjects returns calendar collection of todolist of user.
+func (s *TodolistService) CalendarObjects(ctx context.Context, userID access_domain.UserID) ([]CalendarObject, error) {
+	collection, err := s.getCalendarObjects(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection.objects, nil
+}
+
+// CalendarObject returns todo of calendar collection by resource name.
+func (s *TodolistService) CalendarObject(
+	ctx context.Context,
+	userID access_domain.UserID,
+	name string,
+) (CalendarObject, error) {
+	collection, err := s.getCalendarObjects(ctx, userID, nil)
+	if err != nil {
+		return CalendarObject{}, err
+	}
+
+	object, ok := collection.find(name)
+	if !ok {
+		return CalendarObject{}, ErrCalendarObjectNotFound
+	}
+
+	return object, nil
+}
+
+// PutCalendarObject creates or replaces todo of calendar collection by
+// resource name. Fields which VTODO does not carry, e.g. position, are
+// kept. Cancelled todo is moved to trash, so no object is returned for it.
+// Whether object was created is returned along with it.
+func (s *TodolistService) PutCalendarObject(
+	ctx context.Context,
+	userID access_domain.UserID,
+	name string,
+	todo ImportTodo,
+	precondition CalendarPrecondition,
+) (CalendarObject, bool, error) {
+	var (
+		result  CalendarObject
+		created bool
+	)
+	err := s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		collection, err := s.getCalendarObjects(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		object, exists := collection.find(name)
+		if err := precondition.check(object, exists); err != nil {
+			return err
+		}
+
+		tagIDs := map[string]todolist_model.TagID{}
+		for tagID, tagName := range collection.tagNames {
+			tagIDs[tagName] = tagID
+		}
+
+		ids := importIDs{}
+		if ids.tag, err = s.tagRepo.NextID(ctx, tx); err != nil {
+			return err
+		}
+
+		todoID := object.TodoID
+		if exists {
+			if err := replaceCalendarTodo(collection.list, todoID, todo, tagIDs, &ids); err != nil {
+				return err
+			}
+		} else {
+			if isDefaultCalendarObjectName(name) {
+				return ErrCalendarObjectName
+			}
+
+			if todoID, err = s.todoRepo.NextID(ctx, tx); err != nil {
+				return err
+			}
+			ids.nextTodo = func() (todolist_model.TodoID, error) {
+				return todoID, nil
+			}
+
+			// archived todos are not in calendar collection
+			todo.Archived = false
+			if err := importTodo(collection.list, todo, nil, tagIDs, &ids, &ImportResult{}); err != nil {
+				return err
+			}
+
+			uid := todo.UID
+			if uid == "" {
+				uid = DefaultCalendarObjectName(todoID).UID
+			}
+			if err := s.calendarObjectRepo.SetName(ctx, userID, todoID, CalendarObjectName{Name: name, UID: uid}, tx); err != nil {
+				return err
+			}
+			collection.names[todoID] = CalendarObjectName{Name: name, UID: uid}
+			created = true
+		}
+
+		if err := collection.list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, collection.list, tx); err != nil {
+			return err
+		}
+
+		if todo.Deleted {
+			return nil
+		}
+
+		saved, err := collection.list.Todo(todoID)
+		if err != nil {
+			return err
+		}
+		result = newCalendarObject(saved.PF(), collection.name(todoID), collection.tagNames)
+
+		return nil
+	})
+	if err != nil {
+		return CalendarObject{}, false, err
+	}
+
+	return result, created, nil
+}
+
+// replaceCalendarTodo changes fields of todo which differ from VTODO. Todo
+// and its new tags are validated before anything is changed.
+func replaceCalendarTodo(
+	list *todolist_model.Todolist,
+	todoID todolist_model.TodoID,
+	todo ImportTodo,
+	tagIDs map[string]todolist_model.TagID,
+	ids *importIDs,
+) error {
+	candidate := todolist_model.NewTodo(todoID, todo.Title)
+	if err := candidate.Validate(); err != nil {
+		return err
+	}
+	if err := candidate.ChangeComment(todo.Comment); err != nil {
+		return err
+	}
+
+	if _, err := addImportTags(list, todo.Tags, nil, tagIDs, ids); err != nil {
+		return err
+	}
+
+	current, err := list.Todo(todoID)
+	if err != nil {
+		return err
+	}
+	currentPF := current.PF()
+
+	if todo.Title != currentPF.Title {
+		if err := list.ChangeTitle(todoID, todo.Title); err != nil {
+			return err
+		}
+	}
+	if todo.Comment != currentPF.Comment {
+		if err := list.ChangeComment(todoID, todo.Comment); err != nil {
+			return err
+		}
+	}
+	if todo.Priority != currentPF.Priority {
+		if err := list.ChangePriority(todoID, todo.Priority); err != nil {
+			return err
+		}
+	}
+	if !equalDue(todo.DueAt, currentPF.DueAt) {
+		if err := list.ChangeDue(todoID, todo.DueAt); err != nil {
+			return err
+		}
+	}
+
+	wanted := map[todolist_model.TagID]bool{}
+	for _, name := range todo.Tags {
+		wanted[tagIDs[name]] = true
+	}
+	for _, tagID := range currentPF.Tags {
+		if !wanted[tagID] {
+			if err := list.UntagTodo(todoID, tagID); err != nil {
+				return err
+			}
+		}
+		delete(wanted, tagID)
+	}
+	for _, name := range todo.Tags {
+		if tagID := tagIDs[name]; wanted[tagID] {
+			if err := list.TagTodo(todoID, tagID); err != nil {
+				return err
+			}
+			delete(wanted, tagID)
+		}
+	}
+
+	if todo.Done != currentPF.Done {
+		if todo.Done {
+			err = list.CompleteTodo(todoID)
+		} else {
+			err = list.UncompleteTodo(todoID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if todo.Deleted {
+		return list.DeleteTodo(todoID)
+	}
+
+	return nil
+}
+
+func equalDue(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+// DeleteCalendarObject moves todo of calendar collection to trash.
+func (s *TodolistService) DeleteCalendarObject(
+	ctx context.Context,
+	userID access_domain.UserID,
+	name string,
+	precondition CalendarPrecondition,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		collection, err := s.getCalendarObjects(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		object, exists := collection.find(name)
+		if err := precondition.check(object, exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrCalendarObjectNotFound
+		}
+
+		if err := collection.list.DeleteTodo(object.TodoID); err != nil {
+			return err
+		}
+
+		return s.save(ctx, collection.list, tx)
+	})
+}
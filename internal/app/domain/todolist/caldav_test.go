@@ -0,0 +1,129 @@
+package todolist_domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+func TestReplaceCalendarTodo(t *testing.T) {
+	list := todolist_model.NewTodolistEmpty(1)
+	list.UseClock(todolist_model.NewClock("test"))
+	list.AddTodo(1, "write report")
+	if err := list.AddTag(1, "work", todolist_model.DefaultTagColor); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.TagTodo(1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := list.Todo(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagNames := map[todolist_model.TagID]string{1: "work"}
+	object := newCalendarObject(before.PF(), DefaultCalendarObjectName(1), tagNames)
+
+	due := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	tagIDs := map[string]todolist_model.TagID{"work": 1}
+	ids := importIDs{tag: 2}
+	err = replaceCalendarTodo(list, 1, ImportTodo{
+		Title:    "write annual report",
+		Done:     true,
+		Priority: todolist_model.PriorityHigh,
+		DueAt:    &due,
+		Tags:     []string{"home"},
+	}, tagIDs, &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := list.Todo(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterPF := after.PF()
+	if afterPF.Title != "write annual report" || !afterPF.Done || afterPF.Priority != todolist_model.PriorityHigh || !equalDue(afterPF.DueAt, &due) {
+		t.Fatalf("unexpected todo %+v", afterPF)
+	}
+	if !reflect.DeepEqual(afterPF.Tags, []todolist_model.TagID{2}) {
+		t.Fatalf("got tags %v, expected [2]", afterPF.Tags)
+	}
+
+	tagNames[2] = "home"
+	if newCalendarObject(afterPF, DefaultCalendarObjectName(1), tagNames).ETag == object.ETag {
+		t.Fatal("etag is not changed")
+	}
+
+	// unchanged todo keeps its etag
+	list.ClearEvents()
+	err = replaceCalendarTodo(list, 1, ImportTodo{
+		Title:    afterPF.Title,
+		Done:     true,
+		Priority: afterPF.Priority,
+		DueAt:    &due,
+		Tags:     []string{"home"},
+	}, tagIDs, &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := list.Events(); len(events) != 0 {
+		t.Fatalf("got %d events, expected none", len(events))
+	}
+}
+
+func TestReplaceCalendarTodoValidatesFirst(t *testing.T) {
+	list := todolist_model.NewTodolistEmpty(1)
+	list.UseClock(todolist_model.NewClock("test"))
+	list.AddTodo(1, "write report")
+	list.ClearEvents()
+
+	ids := importIDs{tag: 1}
+	err := replaceCalendarTodo(list, 1, ImportTodo{Title: "", Done: true, Tags: []string{"work"}}, map[string]todolist_model.TagID{}, &ids)
+	if !errors.Is(err, todolist_model.ErrTitleIsEmpty) {
+		t.Fatalf("got %v, expected %v", err, todolist_model.ErrTitleIsEmpty)
+	}
+
+	if events := list.Events(); len(events) != 0 || len(list.PF().Tags) != 0 {
+		t.Fatal("todolist is changed")
+	}
+}
+
+func TestCalendarPrecondition(t *testing.T) {
+	object := CalendarObject{ETag: `"a"`}
+
+	for _, tt := range []struct {
+		precondition CalendarPrecondition
+		exists       bool
+		ok           bool
+	}{
+		{CalendarPrecondition{}, false, true},
+		{CalendarPrecondition{IfMatch: `"a"`}, true, true},
+		{CalendarPrecondition{IfMatch: `"b", W/"a"`}, true, true},
+		{CalendarPrecondition{IfMatch: `"b"`}, true, false},
+		{CalendarPrecondition{IfMatch: "*"}, false, false},
+		{CalendarPrecondition{IfNoneMatch: "*"}, false, true},
+		{CalendarPrecondition{IfNoneMatch: "*"}, true, false},
+		{CalendarPrecondition{IfNoneMatch: `"a"`}, true, false},
+	} {
+		err := tt.precondition.check(object, tt.exists)
+		if (err == nil) != tt.ok {
+			t.Fatalf("%+v exists=%v: got %v", tt.precondition, tt.exists, err)
+		}
+	}
+}
+
+func TestIsDefaultCalendarObjectName(t *testing.T) {
+	if !isDefaultCalendarObjectName(DefaultCalendarObjectName(12).Name) {
+		t.Fatal("default name is not recognized")
+	}
+
+	for _, name := range []string{"12.ics", "everd-x.ics", "everd-12", "4f1c-uid.ics"} {
+		if isDefaultCalendarObjectName(name) {
+			t.Fatalf("%s is recognized as default name", name)
+		}
+	}
+}
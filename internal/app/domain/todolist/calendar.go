@@ -0,0 +1,74 @@
+package todolist_domain
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const calendarTokenLength = 32
+
+var ErrCalendarTokenNotFound = fmt.Errorf("%w: calendar feed not found", todolist_model.ErrNotFound)
+
+// CalendarRepository stores tokens of calendar feeds, one per user. Only
+// hash of token is stored, token itself is shown once when it is created.
+type CalendarRepository interface {
+	SetToken(ctx context.Context, userID access_domain.UserID, tokenHash string, tx util.Transaction) error
+	DeleteToken(ctx context.Context, userID access_domain.UserID, tx util.Transaction) error
+	// UserByToken returns ErrCalendarTokenNotFound for unknown token.
+	UserByToken(ctx context.Context, tokenHash string, tx util.Transaction) (access_domain.UserID, error)
+}
+
+// RotateCalendarToken creates secret token of user calendar feed, the
+// previous token stops working.
+func (s *TodolistService) RotateCalendarToken(ctx context.Context, userID access_domain.UserID) (string, error) {
+	token, err := generateCalendarToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.calendarRepo.SetToken(ctx, userID, hashCalendarToken(token), nil); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RevokeCalendarToken disables calendar feed of user.
+func (s *TodolistService) RevokeCalendarToken(ctx context.Context, userID access_domain.UserID) error {
+	return s.calendarRepo.DeleteToken(ctx, userID, nil)
+}
+
+// CalendarFeed writes todos of todolist of the token owner to w, trashed
+// and archived todos are left out.
+func (s *TodolistService) CalendarFeed(ctx context.Context, token string, w ExportWriter) error {
+	userID, err := s.calendarRepo.UserByToken(ctx, hashCalendarToken(token), nil)
+	if err != nil {
+		return err
+	}
+
+	// feed shows todos calendar app can act on
+	return s.writeExport(ctx, userID, w, func(todo todolist_model.TodoPF) bool {
+		return todo.DeletedAt == nil && todo.ArchivedAt == nil
+	})
+}
+
+func generateCalendarToken() (string, error) {
+	token := make([]byte, calendarTokenLength)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
+
+func hashCalendarToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,150 @@
+package todolist_domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// ExportVersion is version of everd export format, it is bumped whenever
+// ExportTodo or ExportTag change incompatibly.
+const (
+	ExportFormatName = "everd"
+	ExportVersion    = 1
+)
+
+var ErrExportFormat = fmt.Errorf("%w: format must be one of: csv, json, ndjson", todolist_model.Err)
+
+type ExportFormat string
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportJSON   ExportFormat = "json"
+	ExportNDJSON ExportFormat = "ndjson"
+)
+
+func ParseExportFormat(format string) (ExportFormat, error) {
+	switch ExportFormat(format) {
+	case "":
+		return ExportJSON, nil
+	case ExportCSV, ExportJSON, ExportNDJSON:
+		return ExportFormat(format), nil
+	default:
+		return "", ErrExportFormat
+	}
+}
+
+type ExportTag struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// ExportTodo is todo in export, tags are referenced by name, so export can
+// be imported by another user.
+type ExportTodo struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Comment     string     `json:"comment"`
+	Done        bool       `json:"done"`
+	Priority    string     `json:"priority"`
+	Due         *time.Time `json:"due"`
+	Tags        []string   `json:"tags"`
+	Position    string     `json:"position"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ArchivedAt  *time.Time `json:"archived_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+func NewExportTodo(todo todolist_model.TodoPF, tagNames map[todolist_model.TagID]string) ExportTodo {
+	tags := make([]string, 0, len(todo.Tags))
+	for _, tagID := range todo.Tags {
+		tags = append(tags, tagNames[tagID])
+	}
+
+	return ExportTodo{
+		ID:          todo.ID.Int(),
+		Title:       todo.Title,
+		Comment:     todo.Comment,
+		Done:        todo.Done,
+		Priority:    todo.Priority.String(),
+		Due:         todo.DueAt,
+		Tags:        tags,
+		Position:    todo.Position.String(),
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+		CompletedAt: todo.CompletedAt,
+		ArchivedAt:  todo.ArchivedAt,
+		DeletedAt:   todo.DeletedAt,
+	}
+}
+
+// ExportWriter encodes export in one of formats. Tags are written once
+// before todos.
+type ExportWriter interface {
+	WriteTags(tags []ExportTag) error
+	WriteTodo(todo ExportTodo) error
+	Close() error
+}
+
+type ExportRepository interface {
+	Tags(ctx context.Context, userID access_domain.UserID, tx util.Transaction) ([]todolist_model.Tag, error)
+	// EachTodo calls f for every todo of user, including trashed and
+	// archived ones, ordered by id. Todos are read one by one, iteration
+	// stops at the first error of f.
+	EachTodo(
+		ctx context.Context,
+		userID access_domain.UserID,
+		f func(todo todolist_model.TodoPF) error,
+		tx util.Transaction,
+	) error
+}
+
+// Export writes every todo and tag of user to w without loading all todos
+// at once.
+func (s *TodolistService) Export(ctx context.Context, userID access_domain.UserID, w ExportWriter) error {
+	return s.writeExport(ctx, userID, w, nil)
+}
+
+// writeExport writes tags and todos of user to w, todos are filtered by
+// include unless it is nil.
+func (s *TodolistService) writeExport(
+	ctx context.Context,
+	userID access_domain.UserID,
+	w ExportWriter,
+	include func(todo todolist_model.TodoPF) bool,
+) error {
+	userTags, err := s.exportRepo.Tags(ctx, userID, nil)
+	if err != nil {
+		return err
+	}
+
+	tags := make([]ExportTag, len(userTags))
+	tagNames := make(map[todolist_model.TagID]string, len(userTags))
+	for i, tag := range userTags {
+		tagPF := tag.PF()
+		tags[i] = ExportTag{Name: tagPF.Name, Color: tagPF.Color.String()}
+		tagNames[tagPF.ID] = tagPF.Name
+	}
+
+	if err := w.WriteTags(tags); err != nil {
+		return err
+	}
+
+	if err := s.exportRepo.EachTodo(ctx, userID, func(todo todolist_model.TodoPF) error {
+		if include != nil && !include(todo) {
+			return nil
+		}
+
+		return w.WriteTodo(NewExportTodo(todo, tagNames))
+	}, nil); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,107 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+type PostClearCompletedResponse struct {
+	Archived int `json:"archived"`
+}
+
+// PostClearCompleted moves every completed todo to archive.
+func (h *TodolistHandler) PostClearCompleted(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	archived, err := h.service.ClearCompleted(ctx, userID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostClearCompletedResponse{Archived: archived})
+}
+
+type PostTodoArchiveResponse struct{}
+
+// PostTodoArchive moves completed todo to archive.
+func (h *TodolistHandler) PostTodoArchive(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.ArchiveTodo(ctx, userID, todoID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostTodoArchiveResponse{})
+}
+
+type GetArchiveResponse struct {
+	Todos []TodoResponse `json:"todos"`
+}
+
+// GetArchive returns a page of archived todos, it supports the same query
+// parameters as GetTodolist.
+func (h *TodolistHandler) GetArchive(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoQuery, err := parseTodoQuery(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	page, err := h.service.QueryArchive(ctx, userID, todoQuery)
+	if err != nil {
+		return domainError(err)
+	}
+
+	tagNames := make(map[todolist_model.TagID]string, len(page.Tags))
+	for _, tag := range page.Tags {
+		tagNames[tag.ID] = tag.Name
+	}
+
+	archiveResponse := GetArchiveResponse{
+		Todos: make([]TodoResponse, len(page.Todos)),
+	}
+
+	for i, todo := range page.Todos {
+		archiveResponse.Todos[i] = toTodoResponse(todo, tagNames)
+	}
+
+	return h.OkPageJSON(w, archiveResponse, page.NextCursor)
+}
+
+type PostArchiveUnarchiveResponse struct{}
+
+// PostArchiveUnarchive moves archived todo back to todolist.
+func (h *TodolistHandler) PostArchiveUnarchive(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.UnarchiveTodo(ctx, userID, todoID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostArchiveUnarchiveResponse{})
+}
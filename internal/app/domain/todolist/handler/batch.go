@@ -0,0 +1,113 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+type BatchOperationRequest struct {
+	Type   string `json:"type"`
+	TodoID int    `json:"todo_id"`
+	Title  string `json:"title"`
+	TagID  int    `json:"tag_id"`
+	After  *int   `json:"after"`
+}
+
+type PostTodoBatchRequest struct {
+	// Mode is atomic (default) or best_effort.
+	Mode       string                  `json:"mode" validate:"oneof=atomic best_effort"`
+	Operations []BatchOperationRequest `json:"operations" validate:"max=500"`
+}
+
+type BatchResultResponse struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type PostTodoBatchResponse struct {
+	// Applied is false when nothing changed: atomic batch was rejected or
+	// no operation was applied.
+	Applied bool                  `json:"applied"`
+	Results []BatchResultResponse `json:"results"`
+}
+
+// PostTodoBatch applies several operations to todos in one transaction,
+// results are in order of operations.
+func (h *TodolistHandler) PostTodoBatch(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var batchRequest PostTodoBatchRequest
+	if err := h.ReadValidJSON(w, r, &batchRequest); err != nil {
+		return err
+	}
+
+	mode, err := todolist_domain.ParseBatchMode(batchRequest.Mode)
+	if err != nil {
+		return domainError(err)
+	}
+
+	operations := make([]todolist_domain.BatchOperation, len(batchRequest.Operations))
+	for i, operationRequest := range batchRequest.Operations {
+		operation, err := toBatchOperation(operationRequest)
+		if err != nil {
+			return err
+		}
+
+		operations[i] = operation
+	}
+
+	results, applied, err := h.service.Batch(ctx, userID, mode, operations)
+	if err != nil {
+		return domainError(err)
+	}
+
+	batchResponse := PostTodoBatchResponse{
+		Applied: applied,
+		Results: make([]BatchResultResponse, len(results)),
+	}
+	for i, result := range results {
+		batchResponse.Results[i] = BatchResultResponse{
+			Index:  i,
+			Status: string(result.Status),
+			Error:  result.Error,
+		}
+	}
+
+	return h.OkJSON(w, batchResponse)
+}
+
+func toBatchOperation(operationRequest BatchOperationRequest) (todolist_domain.BatchOperation, error) {
+	todoID, err := todolist_model.NewTodoID(operationRequest.TodoID)
+	if err != nil {
+		return todolist_domain.BatchOperation{}, domainError(err)
+	}
+
+	tagID, err := todolist_model.NewTagID(operationRequest.TagID)
+	if err != nil {
+		return todolist_domain.BatchOperation{}, domainError(err)
+	}
+
+	var after *todolist_model.TodoID
+	if operationRequest.After != nil {
+		afterID, err := todolist_model.NewTodoID(*operationRequest.After)
+		if err != nil {
+			return todolist_domain.BatchOperation{}, domainError(err)
+		}
+		after = &afterID
+	}
+
+	return todolist_domain.BatchOperation{
+		Type:   todolist_domain.BatchOperationType(operationRequest.Type),
+		TodoID: todoID,
+		Title:  operationRequest.Title,
+		TagID:  tagID,
+		After:  after,
+	}, nil
+}
@@ -0,0 +1,597 @@
+package todolist_handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// Minimal CalDAV (RFC 4791) server: principal and calendar home are
+// CalDAVHome, todolist is calendar collection CalDAVCollection and every
+// todo is VTODO resource in it. Sync is done by comparing getctag of
+// collection and getetag of resources, sync-collection REPORT is not
+// supported.
+const (
+	CalDAVHome       = "/caldav/"
+	CalDAVCollection = "/caldav/todolist/"
+
+	// MaxCalDAVObjectSize limits size of PUT resource and request bodies.
+	MaxCalDAVObjectSize = 1 << 20
+
+	davNamespace            = "DAV:"
+	caldavNamespace         = "urn:ietf:params:xml:ns:caldav"
+	calendarserverNamespace = "http://calendarserver.org/ns/"
+
+	calendarObjectContentType = "text/calendar; charset=utf-8; component=VTODO"
+	davStatusNotFound         = "HTTP/1.1 404 Not Found"
+)
+
+var davPrefixes = map[string]string{
+	davNamespace:            "d",
+	caldavNamespace:         "c",
+	calendarserverNamespace: "cs",
+}
+
+var (
+	davResourceType             = xml.Name{Space: davNamespace, Local: "resourcetype"}
+	davDisplayName              = xml.Name{Space: davNamespace, Local: "displayname"}
+	davGetETag                  = xml.Name{Space: davNamespace, Local: "getetag"}
+	davGetContentType           = xml.Name{Space: davNamespace, Local: "getcontenttype"}
+	davCurrentUserPrincipal     = xml.Name{Space: davNamespace, Local: "current-user-principal"}
+	davPrincipalURL             = xml.Name{Space: davNamespace, Local: "principal-URL"}
+	davCurrentUserPrivilegeSet  = xml.Name{Space: davNamespace, Local: "current-user-privilege-set"}
+	caldavCalendarHomeSet       = xml.Name{Space: caldavNamespace, Local: "calendar-home-set"}
+	caldavSupportedComponentSet = xml.Name{Space: caldavNamespace, Local: "supported-calendar-component-set"}
+	caldavCalendarData          = xml.Name{Space: caldavNamespace, Local: "calendar-data"}
+	calendarserverGetCTag       = xml.Name{Space: calendarserverNamespace, Local: "getctag"}
+)
+
+// davProp is list of requested properties, e.g. children of DAV:prop.
+type davProp struct {
+	Names []xml.Name
+}
+
+func (p *davProp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			p.Names = append(p.Names, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+type davPropfindRequest struct {
+	XMLName xml.Name  `xml:"DAV: propfind"`
+	AllProp *struct{} `xml:"DAV: allprop"`
+	Prop    *davProp  `xml:"DAV: prop"`
+}
+
+type caldavCompFilter struct {
+	Name    string             `xml:"name,attr"`
+	Filters []caldavCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// davReportRequest is calendar-query or calendar-multiget REPORT.
+type davReportRequest struct {
+	XMLName xml.Name
+	Prop    *davProp          `xml:"DAV: prop"`
+	Hrefs   []string          `xml:"DAV: href"`
+	Filter  *caldavCompFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+// matchesTodos reports whether calendar-query filter can match VTODO,
+// filters of VTODO properties are not applied, clients filter results
+// again anyway.
+func (f *caldavCompFilter) matchesTodos() bool {
+	if f == nil || len(f.Filters) == 0 {
+		return true
+	}
+
+	for _, filter := range f.Filters {
+		if strings.EqualFold(filter.Name, "VTODO") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// davResource is properties of resource in multistatus, values are inner
+// xml. Status is set instead for missing resource.
+type davResource struct {
+	Href   string
+	Props  map[xml.Name]string
+	Status string
+}
+
+func escapeXML(value string) string {
+	var buf bytes.Buffer
+	// writes to buffer do not fail
+	_ = xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
+
+func davHref(href string) string {
+	return "<d:href>" + escapeXML(href) + "</d:href>"
+}
+
+func writeDAVProp(buf *bytes.Buffer, name xml.Name, value string) {
+	prefix, ok := davPrefixes[name.Space]
+	if !ok {
+		buf.WriteString("<x:" + name.Local + ` xmlns:x="` + escapeXML(name.Space) + `">` + value + "</x:" + name.Local + ">")
+		return
+	}
+
+	if value == "" {
+		buf.WriteString("<" + prefix + ":" + name.Local + "/>")
+		return
+	}
+	buf.WriteString("<" + prefix + ":" + name.Local + ">" + value + "</" + prefix + ":" + name.Local + ">")
+}
+
+// writeMultistatus writes requested properties of resources, missing
+// properties are reported with 404 status. All properties are written when
+// requested is nil.
+func (h *TodolistHandler) writeMultistatus(w http.ResponseWriter, resources []davResource, requested []xml.Name) error {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">`)
+
+	for _, resource := range resources {
+		buf.WriteString("<d:response>" + davHref(resource.Href))
+		if resource.Status != "" {
+			buf.WriteString("<d:status>" + resource.Status + "</d:status></d:response>")
+			continue
+		}
+
+		names := requested
+		if names == nil {
+			for name := range resource.Props {
+				names = append(names, name)
+			}
+			sortXMLNames(names)
+		}
+
+		var found, missing []xml.Name
+		for _, name := range names {
+			if _, ok := resource.Props[name]; ok {
+				found = append(found, name)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+
+		if len(found) > 0 {
+			buf.WriteString("<d:propstat><d:prop>")
+			for _, name := range found {
+				writeDAVProp(&buf, name, resource.Props[name])
+			}
+			buf.WriteString("</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		if len(missing) > 0 {
+			buf.WriteString("<d:propstat><d:prop>")
+			for _, name := range missing {
+				writeDAVProp(&buf, name, "")
+			}
+			buf.WriteString("</d:prop><d:status>" + davStatusNotFound + "</d:status></d:propstat>")
+		}
+
+		buf.WriteString("</d:response>")
+	}
+
+	buf.WriteString("</d:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func sortXMLNames(names []xml.Name) {
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].Space != names[j].Space {
+			return names[i].Space < names[j].Space
+		}
+		return names[i].Local < names[j].Local
+	})
+}
+
+// readDAVRequest decodes xml body into request, empty body leaves request
+// untouched.
+func readDAVRequest(w http.ResponseWriter, r *http.Request, request any) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxCalDAVObjectSize))
+	if err != nil {
+		return util.
+			NewHTTPError("invalid request").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	if err := xml.Unmarshal(body, request); err != nil {
+		return util.
+			NewHTTPError("invalid request").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	return nil
+}
+
+// readPropfind returns requested properties, nil is all properties.
+func readPropfind(w http.ResponseWriter, r *http.Request) ([]xml.Name, error) {
+	var propfind davPropfindRequest
+	if err := readDAVRequest(w, r, &propfind); err != nil {
+		return nil, err
+	}
+
+	if propfind.AllProp != nil || propfind.Prop == nil {
+		return nil, nil
+	}
+
+	return propfind.Prop.Names, nil
+}
+
+// davDepthOne reports whether children of resource are requested, missing
+// Depth is infinity.
+func davDepthOne(r *http.Request) bool {
+	return r.Header.Get("Depth") != "0"
+}
+
+func calendarObjectHref(name string) string {
+	return CalDAVCollection + url.PathEscape(name)
+}
+
+func homeResource() davResource {
+	return davResource{
+		Href: CalDAVHome,
+		Props: map[xml.Name]string{
+			davResourceType:         "<d:collection/>",
+			davDisplayName:          "everd",
+			davCurrentUserPrincipal: davHref(CalDAVHome),
+			davPrincipalURL:         davHref(CalDAVHome),
+			caldavCalendarHomeSet:   davHref(CalDAVHome),
+		},
+	}
+}
+
+func collectionResource(objects []todolist_domain.CalendarObject) davResource {
+	ctag := escapeXML(todolist_domain.CalendarCTag(objects))
+	return davResource{
+		Href: CalDAVCollection,
+		Props: map[xml.Name]string{
+			davResourceType:             "<d:collection/><c:calendar/>",
+			davDisplayName:              "Todolist",
+			davCurrentUserPrincipal:     davHref(CalDAVHome),
+			davCurrentUserPrivilegeSet:  "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>",
+			caldavSupportedComponentSet: `<c:comp name="VTODO"/>`,
+			calendarserverGetCTag:       ctag,
+			davGetETag:                  ctag,
+		},
+	}
+}
+
+// objectResource returns properties of todo resource, calendar data is
+// only returned when it is requested.
+func objectResource(object todolist_domain.CalendarObject, requested []xml.Name) (davResource, error) {
+	resource := davResource{
+		Href: calendarObjectHref(object.Name),
+		Props: map[xml.Name]string{
+			davResourceType:   "",
+			davGetETag:        escapeXML(object.ETag),
+			davGetContentType: calendarObjectContentType,
+		},
+	}
+
+	for _, name := range requested {
+		if name != caldavCalendarData {
+			continue
+		}
+
+		var data bytes.Buffer
+		if err := todolist_infrastructure.WriteICalendarObject(&data, object); err != nil {
+			return davResource{}, err
+		}
+		resource.Props[caldavCalendarData] = escapeXML(data.String())
+	}
+
+	return resource, nil
+}
+
+func calendarObjectName(r *http.Request) string {
+	return mux.Vars(r)["name"]
+}
+
+func calendarPrecondition(r *http.Request) todolist_domain.CalendarPrecondition {
+	return todolist_domain.CalendarPrecondition{
+		IfMatch:     r.Header.Get("If-Match"),
+		IfNoneMatch: r.Header.Get("If-None-Match"),
+	}
+}
+
+// OptionsCalDAV advertises CalDAV support, it does not require
+// authorization.
+func (h *TodolistHandler) OptionsCalDAV(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// PropfindCalDAVHome returns principal and calendar home of user, with
+// Depth 1 the todolist collection too.
+func (h *TodolistHandler) PropfindCalDAVHome(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	requested, err := readPropfind(w, r)
+	if err != nil {
+		return err
+	}
+
+	resources := []davResource{homeResource()}
+	if davDepthOne(r) {
+		objects, err := h.service.CalendarObjects(ctx, userID)
+		if err != nil {
+			return domainError(err)
+		}
+		resources = append(resources, collectionResource(objects))
+	}
+
+	return h.writeMultistatus(w, resources, requested)
+}
+
+// PropfindCalDAVCollection returns todolist collection, with Depth 1 its
+// todos too.
+func (h *TodolistHandler) PropfindCalDAVCollection(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	requested, err := readPropfind(w, r)
+	if err != nil {
+		return err
+	}
+
+	objects, err := h.service.CalendarObjects(ctx, userID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	resources := []davResource{collectionResource(objects)}
+	if davDepthOne(r) {
+		for _, object := range objects {
+			resource, err := objectResource(object, requested)
+			if err != nil {
+				return err
+			}
+			resources = append(resources, resource)
+		}
+	}
+
+	return h.writeMultistatus(w, resources, requested)
+}
+
+// PropfindCalDAVObject returns properties of a single todo.
+func (h *TodolistHandler) PropfindCalDAVObject(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	requested, err := readPropfind(w, r)
+	if err != nil {
+		return err
+	}
+
+	object, err := h.service.CalendarObject(ctx, userID, calendarObjectName(r))
+	if err != nil {
+		return domainError(err)
+	}
+
+	resource, err := objectResource(object, requested)
+	if err != nil {
+		return err
+	}
+
+	return h.writeMultistatus(w, []davResource{resource}, requested)
+}
+
+// ReportCalDAVCollection answers calendar-query with every todo and
+// calendar-multiget with todos of requested hrefs.
+func (h *TodolistHandler) ReportCalDAVCollection(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var report davReportRequest
+	if err := readDAVRequest(w, r, &report); err != nil {
+		return err
+	}
+
+	var requested []xml.Name
+	if report.Prop != nil {
+		requested = report.Prop.Names
+	}
+
+	objects, err := h.service.CalendarObjects(ctx, userID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	var resources []davResource
+	switch report.XMLName {
+	case xml.Name{Space: caldavNamespace, Local: "calendar-query"}:
+		if !report.Filter.matchesTodos() {
+			break
+		}
+
+		for _, object := range objects {
+			resource, err := objectResource(object, requested)
+			if err != nil {
+				return err
+			}
+			resources = append(resources, resource)
+		}
+	case xml.Name{Space: caldavNamespace, Local: "calendar-multiget"}:
+		byName := make(map[string]todolist_domain.CalendarObject, len(objects))
+		for _, object := range objects {
+			byName[object.Name] = object
+		}
+
+		for _, href := range report.Hrefs {
+			object, ok := byName[calendarObjectNameFromHref(href)]
+			if !ok {
+				resources = append(resources, davResource{Href: href, Status: davStatusNotFound})
+				continue
+			}
+
+			resource, err := objectResource(object, requested)
+			if err != nil {
+				return err
+			}
+			resources = append(resources, resource)
+		}
+	default:
+		return util.
+			NewHTTPError("unsupported report").
+			WithStatus(http.StatusForbidden).
+			WithErrorMessage(report.XMLName.Local + " is not supported")
+	}
+
+	return h.writeMultistatus(w, resources, requested)
+}
+
+// calendarObjectNameFromHref returns name of resource of href in todolist
+// collection, href is either absolute path or url.
+func calendarObjectNameFromHref(href string) string {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || path.Dir(u.Path)+"/" != CalDAVCollection {
+		return ""
+	}
+
+	return path.Base(u.Path)
+}
+
+// GetCalDAVObject returns todo as iCalendar resource.
+func (h *TodolistHandler) GetCalDAVObject(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	object, err := h.service.CalendarObject(ctx, userID, calendarObjectName(r))
+	if err != nil {
+		return domainError(err)
+	}
+
+	if r.Header.Get("If-None-Match") == object.ETag {
+		w.Header().Set("ETag", object.ETag)
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	var data bytes.Buffer
+	if err := todolist_infrastructure.WriteICalendarObject(&data, object); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", calendarObjectContentType)
+	w.Header().Set("ETag", object.ETag)
+	_, err = w.Write(data.Bytes())
+	return err
+}
+
+// PutCalDAVObject creates or replaces todo from iCalendar resource with a
+// single VTODO, If-Match and If-None-Match are respected.
+func (h *TodolistHandler) PutCalDAVObject(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todo, err := readCalendarObject(w, r)
+	if err != nil {
+		return err
+	}
+
+	object, created, err := h.service.PutCalendarObject(ctx, userID, calendarObjectName(r), todo, calendarPrecondition(r))
+	if err != nil {
+		return domainError(err)
+	}
+
+	if object.ETag != "" {
+		w.Header().Set("ETag", object.ETag)
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	return nil
+}
+
+func readCalendarObject(w http.ResponseWriter, r *http.Request) (todolist_domain.ImportTodo, error) {
+	file, err := todolist_infrastructure.ParseImport(
+		todolist_domain.ImportICalendar,
+		http.MaxBytesReader(w, r.Body, MaxCalDAVObjectSize),
+	)
+	if err != nil {
+		return todolist_domain.ImportTodo{}, domainError(err)
+	}
+
+	if len(file.Errors) > 0 {
+		return todolist_domain.ImportTodo{}, domainError(fmt.Errorf("%w: %s", todolist_domain.ErrCalendarObject, file.Errors[0].Error))
+	}
+
+	if len(file.Todos) != 1 {
+		return todolist_domain.ImportTodo{}, domainError(todolist_domain.ErrCalendarObject)
+	}
+
+	return file.Todos[0], nil
+}
+
+// DeleteCalDAVObject moves todo to trash.
+func (h *TodolistHandler) DeleteCalDAVObject(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.DeleteCalendarObject(ctx, userID, calendarObjectName(r), calendarPrecondition(r)); err != nil {
+		return domainError(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
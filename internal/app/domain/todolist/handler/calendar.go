@@ -0,0 +1,71 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+)
+
+type PostCalendarTokenResponse struct {
+	// Token is shown only once, it can be rotated or revoked later.
+	Token string `json:"token"`
+	// URL is path of feed for calendar apps relative to api host.
+	URL string `json:"url"`
+}
+
+type DeleteCalendarTokenResponse struct{}
+
+// PostCalendarToken creates secret url of calendar feed of user, previous
+// url stops working.
+func (h *TodolistHandler) PostCalendarToken(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.service.RotateCalendarToken(ctx, userID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostCalendarTokenResponse{
+		Token: token,
+		URL:   "/calendar/" + token + ".ics",
+	})
+}
+
+// DeleteCalendarToken disables calendar feed of user.
+func (h *TodolistHandler) DeleteCalendarToken(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.RevokeCalendarToken(ctx, userID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, DeleteCalendarTokenResponse{})
+}
+
+// GetCalendarFeed serves todolist as iCalendar with VTODO components, it is
+// authorized by token in path only, so calendar apps can subscribe to it.
+func (h *TodolistHandler) GetCalendarFeed(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	sw := &startedWriter{ResponseWriter: w}
+	if err := h.service.CalendarFeed(ctx, mux.Vars(r)["token"], todolist_infrastructure.NewICalendarWriter(sw)); err != nil {
+		if !sw.started {
+			w.Header().Del("Content-Type")
+			return domainError(err)
+		}
+
+		// response status is already sent, client gets truncated feed
+		return nil
+	}
+
+	return nil
+}
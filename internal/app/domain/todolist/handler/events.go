@@ -0,0 +1,105 @@
+package todolist_handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/pubsub"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	HeartbeatInterval = 15 * time.Second
+	// resetEvent tells client that events were lost and it has to reload
+	// todolist.
+	resetEvent = "reset"
+)
+
+// GetTodolistEvents streams todolist events of user as server-sent events.
+// Client resumes stream by sending id of the last received event in
+// Last-Event-ID header.
+func (h *TodolistHandler) GetTodolistEvents(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return util.
+			NewHTTPError("streaming is not supported").
+			WithStatus(http.StatusInternalServerError).
+			WithErrorMessage("response writer is not a flusher")
+	}
+
+	var lastEventID uint64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		lastEventID, err = strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			return util.
+				NewHTTPError("invalid Last-Event-ID").
+				WithStatus(http.StatusBadRequest).
+				WithError(err)
+		}
+	}
+
+	subscription, missed, err := h.hub.Subscribe(todolist_infrastructure.UserTopic(userID), lastEventID)
+	if err != nil && !errors.Is(err, pubsub.ErrMessagesLost) {
+		return err
+	}
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if errors.Is(err, pubsub.ErrMessagesLost) {
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: {}\n\n", resetEvent); err != nil {
+			return nil
+		}
+	}
+
+	for _, message := range missed {
+		if err := writeEvent(w, message); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// errors are not returned after stream is started, response status is
+	// already sent
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message, ok := <-subscription.Messages():
+			if !ok {
+				// client fell behind, it reconnects with Last-Event-ID
+				return nil
+			}
+			if err := writeEvent(w, message); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, message pubsub.Message) error {
+	_, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", message.ID, message.Data)
+	return err
+}
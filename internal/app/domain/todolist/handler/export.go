@@ -0,0 +1,61 @@
+package todolist_handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+)
+
+var exportContentTypes = map[todolist_domain.ExportFormat]string{
+	todolist_domain.ExportCSV:    "text/csv; charset=utf-8",
+	todolist_domain.ExportJSON:   "application/json",
+	todolist_domain.ExportNDJSON: "application/x-ndjson",
+}
+
+// startedWriter reports whether anything was written to response.
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *startedWriter) Write(data []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(data)
+}
+
+// GetExport streams every todo of user, including trashed and archived
+// ones, as a file download. Query parameter format is csv, json (default)
+// or ndjson, json and ndjson can be imported back.
+func (h *TodolistHandler) GetExport(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	format, err := todolist_domain.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		return domainError(err)
+	}
+
+	filename := fmt.Sprintf("everd-export-%s.%s", time.Now().UTC().Format("2006-01-02"), format)
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	sw := &startedWriter{ResponseWriter: w}
+	if err := h.service.Export(ctx, userID, todolist_infrastructure.NewExportWriter(format, sw)); err != nil {
+		if !sw.started {
+			w.Header().Del("Content-Disposition")
+			return domainError(err)
+		}
+
+		// response status is already sent, client gets truncated file
+		return nil
+	}
+
+	return nil
+}
@@ -2,94 +2,524 @@ package todolist_handler
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
 	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/pubsub"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/realtime"
 	"github.com/kotsmile/everd-backend/internal/util"
 )
 
 type TodolistHandler struct {
 	*util.ApiHelper
 	service *todolist_domain.TodolistService
+	hub     *pubsub.Hub
+	rooms   realtime.Hub
+
+	upgrader websocket.Upgrader
 }
 
-func NewTodolistHandler(service *todolist_domain.TodolistService, apiHelper *util.ApiHelper) *TodolistHandler {
+func NewTodolistHandler(
+	service *todolist_domain.TodolistService,
+	hub *pubsub.Hub,
+	rooms realtime.Hub,
+	apiHelper *util.ApiHelper,
+) *TodolistHandler {
 	return &TodolistHandler{
 		ApiHelper: apiHelper,
 		service:   service,
+		hub:       hub,
+		rooms:     rooms,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+}
+
+func userIDFromContext(ctx context.Context) (access_domain.UserID, error) {
+	userID, ok := ctx.Value("userID").(access_domain.UserID)
+	if !ok {
+		return access_domain.NilUserID, util.
+			NewHTTPError("unauthorized").
+			WithStatus(http.StatusUnauthorized).
+			WithErrorMessage("user id is not provided")
+	}
+
+	return userID, nil
+}
+
+func todoIDFromPath(r *http.Request) (todolist_model.TodoID, error) {
+	todoIDInt, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return todolist_model.NilTodoID, util.
+			NewHTTPError("invalid todo id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	todoID, err := todolist_model.NewTodoID(todoIDInt)
+	if err != nil {
+		return todolist_model.NilTodoID, util.
+			NewHTTPError("invalid todo id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	return todoID, nil
+}
+
+// domainError maps todolist domain errors to http errors, other errors are
+// returned as is and end up as internal server errors.
+func domainError(err error) error {
+	switch {
+	case errors.Is(err, todolist_model.ErrNotFound),
+		errors.Is(err, todolist_model.ErrTagNotFound):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusNotFound).
+			WithError(err)
+	case errors.Is(err, todolist_model.ErrUndoConflict),
+		errors.Is(err, todolist_model.ErrNothingToUndo),
+		errors.Is(err, todolist_model.ErrNothingToRedo):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusConflict).
+			WithError(err)
+	case errors.Is(err, todolist_domain.ErrPreconditionFailed):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusPreconditionFailed).
+			WithError(err)
+	case errors.Is(err, todolist_model.Err):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	default:
+		return err
 	}
 }
 
 type TodoResponse struct {
-	ID      int    `json:"id"`
-	Title   string `json:"title"`
-	Comment string `json:"comment"`
-	Done    bool   `json:"done"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Comment     string     `json:"comment"`
+	Done        bool       `json:"done"`
+	Tags        []string   `json:"tags"`
+	Priority    string     `json:"priority"`
+	Due         *time.Time `json:"due,omitempty"`
+	Position    string     `json:"position"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 type GetTodolistResponse struct {
 	Todos []TodoResponse `json:"todos"`
 }
 
+func toTodoResponse(todo todolist_model.TodoPF, tagNames map[todolist_model.TagID]string) TodoResponse {
+	tags := make([]string, 0, len(todo.Tags))
+	for _, tagID := range todo.Tags {
+		tags = append(tags, tagNames[tagID])
+	}
+
+	return TodoResponse{
+		ID:          todo.ID.Int(),
+		Title:       todo.Title,
+		Comment:     todo.Comment,
+		Done:        todo.Done,
+		Tags:        tags,
+		Priority:    todo.Priority.String(),
+		Due:         todo.DueAt,
+		Position:    todo.Position.String(),
+		CompletedAt: todo.CompletedAt,
+		ArchivedAt:  todo.ArchivedAt,
+		DeletedAt:   todo.DeletedAt,
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+}
+
+func parseTimeQuery(query url.Values, key string) (*time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, util.
+			NewHTTPError("invalid " + key).
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	return &t, nil
+}
+
+func parseTodoQuery(query url.Values) (todolist_domain.TodoQuery, error) {
+	todoQuery := todolist_domain.TodoQuery{
+		Search: query.Get("q"),
+		Tags:   query["tag"],
+		Cursor: query.Get("cursor"),
+	}
+
+	if done := query.Get("done"); done != "" {
+		doneBool, err := strconv.ParseBool(done)
+		if err != nil {
+			return todolist_domain.TodoQuery{}, util.
+				NewHTTPError("invalid done").
+				WithStatus(http.StatusBadRequest).
+				WithError(err)
+		}
+		todoQuery.Done = &doneBool
+	}
+
+	switch query.Get("tag_mode") {
+	case "", "and":
+		todoQuery.TagMatch = todolist_model.TagMatchAll
+	case "or":
+		todoQuery.TagMatch = todolist_model.TagMatchAny
+	default:
+		return todolist_domain.TodoQuery{}, util.
+			NewHTTPError("invalid tag_mode").
+			WithStatus(http.StatusBadRequest).
+			WithErrorMessage("tag_mode must be one of: and, or")
+	}
+
+	var err error
+	if todoQuery.CreatedAfter, err = parseTimeQuery(query, "created_after"); err != nil {
+		return todolist_domain.TodoQuery{}, err
+	}
+	if todoQuery.CreatedBefore, err = parseTimeQuery(query, "created_before"); err != nil {
+		return todolist_domain.TodoQuery{}, err
+	}
+	if todoQuery.UpdatedAfter, err = parseTimeQuery(query, "updated_after"); err != nil {
+		return todolist_domain.TodoQuery{}, err
+	}
+	if todoQuery.UpdatedBefore, err = parseTimeQuery(query, "updated_before"); err != nil {
+		return todolist_domain.TodoQuery{}, err
+	}
+
+	if todoQuery.Sort, err = todolist_model.ParseTodoSort(query.Get("sort")); err != nil {
+		return todolist_domain.TodoQuery{}, domainError(err)
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		if todoQuery.Limit, err = strconv.Atoi(limit); err != nil {
+			return todolist_domain.TodoQuery{}, util.
+				NewHTTPError("invalid limit").
+				WithStatus(http.StatusBadRequest).
+				WithError(err)
+		}
+	}
+
+	return todoQuery, nil
+}
+
+// GetTodolist returns a page of todos of user. Supported query parameters:
+//   - done: true or false
+//   - q: text searched in title and comment
+//   - tag: tag name, can be repeated; tag_mode=or matches todos having any
+//     of the tags instead of all of them
+//   - created_after, created_before, updated_after, updated_before: RFC 3339
+//   - sort: e.g. `-priority,due` or `smart`
+//   - limit and cursor: page size and next_cursor of the previous page
+//
+// format=markdown returns the whole todolist as markdown task list
+// instead, other parameters are ignored then.
 func (h *TodolistHandler) GetTodolist(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	userID, ok := ctx.Value("userID").(access_domain.UserID)
-	if !ok {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+	case "markdown":
+		return h.getTodolistMarkdown(ctx, w, userID)
+	default:
 		return util.
-			NewHTTPError("unauthorized").
-			WithStatus(http.StatusUnauthorized).
-			WithErrorMessage("user id is not provided")
+			NewHTTPError("format must be one of: json, markdown").
+			WithStatus(http.StatusBadRequest).
+			WithErrorMessage("invalid format")
 	}
 
-	todolist, err := h.service.GetTodolist(ctx, userID)
+	todoQuery, err := parseTodoQuery(r.URL.Query())
 	if err != nil {
 		return err
 	}
 
-	todolistPF := todolist.PF()
+	page, err := h.service.QueryTodos(ctx, userID, todoQuery)
+	if err != nil {
+		return domainError(err)
+	}
+
+	tagNames := make(map[todolist_model.TagID]string, len(page.Tags))
+	for _, tag := range page.Tags {
+		tagNames[tag.ID] = tag.Name
+	}
 
 	todolistResponse := GetTodolistResponse{
-		Todos: make([]TodoResponse, len(todolistPF.Todos)),
+		Todos: make([]TodoResponse, len(page.Todos)),
 	}
 
-	for i, todo := range todolistPF.Todos {
-		todolistResponse.Todos[i] = TodoResponse{
-			ID:      todo.ID.Int(),
-			Title:   todo.Title,
-			Comment: todo.Comment,
-			Done:    todo.Done,
-		}
+	for i, todo := range page.Todos {
+		todolistResponse.Todos[i] = toTodoResponse(todo, tagNames)
 	}
 
-	return h.OkJSON(w, todolistResponse)
+	return h.OkPageJSON(w, todolistResponse, page.NextCursor)
 }
 
 type PostTodoRequest struct {
-	Title string `json:"title"`
+	Title    string     `json:"title" validate:"required,max=100"`
+	Priority string     `json:"priority" validate:"oneof=none low medium high urgent"`
+	Due      *time.Time `json:"due"`
 }
 
+// PostTodoResponse is response of v1, later versions respond with created
+// todo.
 type PostTodoResponse struct{}
 
 func (h *TodolistHandler) PostTodo(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	userID, ok := ctx.Value("userID").(access_domain.UserID)
-	if !ok {
-		return util.
-			NewHTTPError("unauthorized").
-			WithStatus(http.StatusUnauthorized).
-			WithErrorMessage("user id is not provided")
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
 	}
 
 	var todoRequest PostTodoRequest
-	if err := h.ReadJSON(w, r, &todoRequest); err != nil {
-		return util.
-			NewHTTPError("invalid request").
-			WithStatus(http.StatusBadRequest).
-			WithError(err)
+	if err := h.ReadValidJSON(w, r, &todoRequest); err != nil {
+		return err
 	}
 
-	if err := h.service.AddTodo(ctx, userID, todoRequest.Title); err != nil {
+	priority, err := todolist_model.ParsePriority(todoRequest.Priority)
+	if err != nil {
+		return domainError(err)
+	}
+
+	todo, err := h.service.AddTodo(
+		ctx,
+		userID,
+		todoRequest.Title,
+		priority,
+		todoRequest.Due,
+	)
+	if err != nil {
+		return domainError(err)
+	}
+
+	// todo is created without tags
+	return h.OkJSON(w, toTodoResponse(todo, nil))
+}
+
+type PutTodoPriorityRequest struct {
+	Priority string `json:"priority" validate:"oneof=none low medium high urgent"`
+}
+
+type PutTodoPriorityResponse struct{}
+
+func (h *TodolistHandler) PutTodoPriority(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
 		return err
 	}
 
-	return h.OkJSON(w, PostTodoResponse{})
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	var priorityRequest PutTodoPriorityRequest
+	if err := h.ReadValidJSON(w, r, &priorityRequest); err != nil {
+		return err
+	}
+
+	priority, err := todolist_model.ParsePriority(priorityRequest.Priority)
+	if err != nil {
+		return domainError(err)
+	}
+
+	if err := h.service.ChangePriority(ctx, userID, todoID, priority); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PutTodoPriorityResponse{})
+}
+
+type PutTodoDueRequest struct {
+	Due *time.Time `json:"due"`
+}
+
+type PutTodoDueResponse struct{}
+
+func (h *TodolistHandler) PutTodoDue(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	var dueRequest PutTodoDueRequest
+	if err := h.ReadValidJSON(w, r, &dueRequest); err != nil {
+		return err
+	}
+
+	if err := h.service.ChangeDue(ctx, userID, todoID, dueRequest.Due); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PutTodoDueResponse{})
+}
+
+type PutTodoPositionRequest struct {
+	// After is id of todo to place todo after, null places todo first.
+	After *int `json:"after"`
+}
+
+type PutTodoPositionResponse struct{}
+
+func (h *TodolistHandler) PutTodoPosition(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	var positionRequest PutTodoPositionRequest
+	if err := h.ReadValidJSON(w, r, &positionRequest); err != nil {
+		return err
+	}
+
+	var after *todolist_model.TodoID
+	if positionRequest.After != nil {
+		afterID, err := todolist_model.NewTodoID(*positionRequest.After)
+		if err != nil {
+			return domainError(err)
+		}
+		after = &afterID
+	}
+
+	if err := h.service.MoveTodo(ctx, userID, todoID, after); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PutTodoPositionResponse{})
+}
+
+type PostTodoCompleteResponse struct{}
+
+func (h *TodolistHandler) PostTodoComplete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.CompleteTodo(ctx, userID, todoID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostTodoCompleteResponse{})
+}
+
+type PostTodoUncompleteResponse struct{}
+
+func (h *TodolistHandler) PostTodoUncomplete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.UncompleteTodo(ctx, userID, todoID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostTodoUncompleteResponse{})
+}
+
+type PutTodoTitleRequest struct {
+	Title string `json:"title" validate:"required,max=100"`
+}
+
+type PutTodoTitleResponse struct{}
+
+func (h *TodolistHandler) PutTodoTitle(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	var titleRequest PutTodoTitleRequest
+	if err := h.ReadValidJSON(w, r, &titleRequest); err != nil {
+		return err
+	}
+
+	if err := h.service.ChangeTitle(ctx, userID, todoID, titleRequest.Title); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PutTodoTitleResponse{})
+}
+
+type PutTodoCommentRequest struct {
+	Comment string `json:"comment" validate:"max=1000"`
+}
+
+type PutTodoCommentResponse struct{}
+
+func (h *TodolistHandler) PutTodoComment(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	var commentRequest PutTodoCommentRequest
+	if err := h.ReadValidJSON(w, r, &commentRequest); err != nil {
+		return err
+	}
+
+	if err := h.service.ChangeComment(ctx, userID, todoID, commentRequest.Comment); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PutTodoCommentResponse{})
 }
@@ -0,0 +1,56 @@
+package todolist_handler
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// validationParam returns param of rule in validate tag of field of request.
+func validationParam(t *testing.T, request any, field string, rule string) string {
+	t.Helper()
+
+	structField, ok := reflect.TypeOf(request).FieldByName(field)
+	if !ok {
+		t.Fatalf("%T has no field %s", request, field)
+	}
+
+	rules, err := util.ParseValidationRules(structField.Tag.Get("validate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rules {
+		if r.Name == rule {
+			return r.Param
+		}
+	}
+
+	t.Fatalf("%T.%s has no %s rule", request, field, rule)
+	return ""
+}
+
+// TestValidationBounds fails when limit of request differs from limit of
+// domain it is checked against.
+func TestValidationBounds(t *testing.T) {
+	for _, tt := range []struct {
+		request any
+		field   string
+		want    int
+	}{
+		{PostTodoRequest{}, "Title", todolist_model.MaxTitleLength},
+		{PutTodoTitleRequest{}, "Title", todolist_model.MaxTitleLength},
+		{PutTodoCommentRequest{}, "Comment", todolist_model.MaxCommentLength},
+		{PostTagRequest{}, "Name", todolist_model.MaxTagNameLength},
+		{PutTagRequest{}, "Name", todolist_model.MaxTagNameLength},
+		{PostTodoBatchRequest{}, "Operations", todolist_domain.MaxBatchOperations},
+		{PostSyncRequest{}, "Operations", todolist_domain.MaxSyncOperations},
+	} {
+		if got := validationParam(t, tt.request, tt.field, "max"); got != strconv.Itoa(tt.want) {
+			t.Errorf("%T.%s: max=%s, want max=%d", tt.request, tt.field, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type TodoEventResponse struct {
+	Event      string    `json:"event"`
+	UserID     int       `json:"user_id"`
+	OldValue   string    `json:"old_value,omitempty"`
+	NewValue   string    `json:"new_value,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type GetTodoHistoryResponse struct {
+	Events []TodoEventResponse `json:"events"`
+}
+
+// GetTodoHistory returns changes of todo ordered from the oldest.
+func (h *TodolistHandler) GetTodoHistory(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	events, err := h.service.TodoHistory(ctx, userID, todoID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	historyResponse := GetTodoHistoryResponse{
+		Events: make([]TodoEventResponse, len(events)),
+	}
+
+	for i, event := range events {
+		historyResponse.Events[i] = TodoEventResponse{
+			Event:      string(event.Name),
+			UserID:     int(event.UserID),
+			OldValue:   event.OldValue,
+			NewValue:   event.NewValue,
+			OccurredAt: event.OccurredAt,
+		}
+	}
+
+	return h.OkJSON(w, historyResponse)
+}
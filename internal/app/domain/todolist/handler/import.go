@@ -0,0 +1,98 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// MaxImportSize limits size of multipart import request.
+const MaxImportSize = 10 << 20
+
+type ImportErrorResponse struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type PostImportResponse struct {
+	DryRun      bool                  `json:"dry_run"`
+	Created     int                   `json:"created"`
+	CreatedTags []string              `json:"created_tags"`
+	Errors      []ImportErrorResponse `json:"errors"`
+}
+
+// PostImport imports todos from multipart form with file field and format
+// field todotxt, todoist, everd or ics. With dry_run=true in query nothing is
+// created, response reports what would be.
+func (h *TodolistHandler) PostImport(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var dryRun bool
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			return util.
+				NewHTTPError("invalid dry_run").
+				WithStatus(http.StatusBadRequest).
+				WithError(err)
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+	if err := r.ParseMultipartForm(MaxImportSize); err != nil {
+		return util.
+			NewHTTPError("invalid request").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	format, err := todolist_domain.ParseImportFormat(r.FormValue("format"))
+	if err != nil {
+		return domainError(err)
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return util.
+			NewHTTPError("file is missing").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+	defer file.Close()
+
+	importFile, err := todolist_infrastructure.ParseImport(format, file)
+	if err != nil {
+		return domainError(err)
+	}
+
+	result, err := h.service.Import(ctx, userID, importFile, dryRun)
+	if err != nil {
+		return domainError(err)
+	}
+
+	importResponse := PostImportResponse{
+		DryRun:      result.DryRun,
+		Created:     result.Created,
+		CreatedTags: result.CreatedTags,
+		Errors:      make([]ImportErrorResponse, len(result.Errors)),
+	}
+	if importResponse.CreatedTags == nil {
+		importResponse.CreatedTags = []string{}
+	}
+	for i, importError := range result.Errors {
+		importResponse.Errors[i] = ImportErrorResponse{
+			Line:  importError.Line,
+			Error: importError.Error,
+		}
+	}
+
+	return h.OkJSON(w, importResponse)
+}
@@ -0,0 +1,68 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// MaxMarkdownSize limits size of markdown document.
+const MaxMarkdownSize = 1 << 20
+
+type PostTodolistMarkdownResponse struct {
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+	Errors    []ImportErrorResponse `json:"errors"`
+}
+
+func (h *TodolistHandler) getTodolistMarkdown(ctx context.Context, w http.ResponseWriter, userID access_domain.UserID) error {
+	list, err := h.service.GetTodolist(ctx, userID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	return todolist_infrastructure.RenderMarkdown(w, list.OrderedTodos())
+}
+
+// PostTodolistMarkdown reads markdown document from request body and
+// upserts its task list items by title, see
+// todolist_domain.TodolistService.UpsertMarkdown.
+func (h *TodolistHandler) PostTodolistMarkdown(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	items, err := todolist_infrastructure.ParseMarkdown(http.MaxBytesReader(w, r.Body, MaxMarkdownSize))
+	if err != nil {
+		return util.
+			NewHTTPError("invalid request").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	result, err := h.service.UpsertMarkdown(ctx, userID, items)
+	if err != nil {
+		return domainError(err)
+	}
+
+	markdownResponse := PostTodolistMarkdownResponse{
+		Created:   result.Created,
+		Updated:   result.Updated,
+		Unchanged: result.Unchanged,
+		Errors:    make([]ImportErrorResponse, len(result.Errors)),
+	}
+	for i, markdownError := range result.Errors {
+		markdownResponse.Errors[i] = ImportErrorResponse{
+			Line:  markdownError.Line,
+			Error: markdownError.Error,
+		}
+	}
+
+	return h.OkJSON(w, markdownResponse)
+}
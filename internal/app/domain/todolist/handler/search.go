@@ -0,0 +1,66 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type SearchHitResponse struct {
+	Todo           TodoResponse `json:"todo"`
+	Rank           float64      `json:"rank"`
+	TitleSnippet   string       `json:"title_snippet"`
+	CommentSnippet string       `json:"comment_snippet"`
+}
+
+type GetSearchResponse struct {
+	Hits []SearchHitResponse `json:"hits"`
+}
+
+// GetSearch ranks todos of user by relevance of `q` to title and comment.
+func (h *TodolistHandler) GetSearch(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+
+	var limit int
+	if limitQuery := query.Get("limit"); limitQuery != "" {
+		if limit, err = strconv.Atoi(limitQuery); err != nil {
+			return util.
+				NewHTTPError("invalid limit").
+				WithStatus(http.StatusBadRequest).
+				WithError(err)
+		}
+	}
+
+	result, err := h.service.Search(ctx, userID, query.Get("q"), limit)
+	if err != nil {
+		return domainError(err)
+	}
+
+	tagNames := make(map[todolist_model.TagID]string, len(result.Tags))
+	for _, tag := range result.Tags {
+		tagNames[tag.ID] = tag.Name
+	}
+
+	searchResponse := GetSearchResponse{
+		Hits: make([]SearchHitResponse, len(result.Hits)),
+	}
+
+	for i, hit := range result.Hits {
+		searchResponse.Hits[i] = SearchHitResponse{
+			Todo:           toTodoResponse(hit.Todo, tagNames),
+			Rank:           hit.Rank,
+			TitleSnippet:   hit.TitleSnippet,
+			CommentSnippet: hit.CommentSnippet,
+		}
+	}
+
+	return h.OkJSON(w, searchResponse)
+}
@@ -0,0 +1,206 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// TodoChangeResponse is a changed todo, Todo is omitted for todos purged
+// from trash. Deleted is set for trashed and purged todos.
+type TodoChangeResponse struct {
+	ID      int           `json:"id"`
+	Deleted bool          `json:"deleted"`
+	Todo    *TodoResponse `json:"todo,omitempty"`
+}
+
+type GetSyncResponse struct {
+	Changes []TodoChangeResponse `json:"changes"`
+	Tags    []TagResponse        `json:"tags"`
+	Cursor  string               `json:"cursor"`
+	HasMore bool                 `json:"has_more"`
+}
+
+// GetSync returns todos changed after since cursor, client repeats request
+// with returned cursor while has_more is set.
+func (h *TodolistHandler) GetSync(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+
+	var limit int
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			return util.
+				NewHTTPError("invalid limit").
+				WithStatus(http.StatusBadRequest).
+				WithError(err)
+		}
+	}
+
+	page, err := h.service.Changes(ctx, userID, query.Get("since"), limit)
+	if err != nil {
+		return domainError(err)
+	}
+
+	tagNames := make(map[todolist_model.TagID]string, len(page.Tags))
+	syncResponse := GetSyncResponse{
+		Changes: make([]TodoChangeResponse, len(page.Changes)),
+		Tags:    make([]TagResponse, len(page.Tags)),
+		Cursor:  page.Cursor,
+		HasMore: page.HasMore,
+	}
+
+	for i, tag := range page.Tags {
+		tagNames[tag.ID] = tag.Name
+		syncResponse.Tags[i] = TagResponse{
+			ID:    tag.ID.Int(),
+			Name:  tag.Name,
+			Color: tag.Color.String(),
+		}
+	}
+
+	for i, change := range page.Changes {
+		changeResponse := TodoChangeResponse{
+			ID:      change.TodoID.Int(),
+			Deleted: true,
+		}
+		if change.Todo != nil {
+			todoResponse := toTodoResponse(*change.Todo, tagNames)
+			changeResponse.Todo = &todoResponse
+			changeResponse.Deleted = change.Todo.DeletedAt != nil
+		}
+
+		syncResponse.Changes[i] = changeResponse
+	}
+
+	return h.OkJSON(w, syncResponse)
+}
+
+type SyncOperationRequest struct {
+	ID            string     `json:"id"`
+	Type          string     `json:"type"`
+	TodoID        int        `json:"todo_id"`
+	Title         string     `json:"title"`
+	Comment       string     `json:"comment"`
+	Priority      string     `json:"priority"`
+	Due           *time.Time `json:"due"`
+	TagID         int        `json:"tag_id"`
+	BaseUpdatedAt *time.Time `json:"base_updated_at"`
+}
+
+type PostSyncRequest struct {
+	Operations []SyncOperationRequest `json:"operations" validate:"max=100"`
+}
+
+type SyncResultResponse struct {
+	ID        string        `json:"id"`
+	Status    string        `json:"status"`
+	TodoID    int           `json:"todo_id,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Duplicate bool          `json:"duplicate,omitempty"`
+	Todo      *TodoResponse `json:"todo,omitempty"`
+}
+
+type PostSyncResponse struct {
+	Results []SyncResultResponse `json:"results"`
+}
+
+// PostSync applies operations made by client offline. Every operation is
+// applied at most once, retrying the request returns results of already
+// applied operations marked as duplicate.
+func (h *TodolistHandler) PostSync(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var syncRequest PostSyncRequest
+	if err := h.ReadValidJSON(w, r, &syncRequest); err != nil {
+		return err
+	}
+
+	operations := make([]todolist_domain.SyncOperation, len(syncRequest.Operations))
+	for i, operationRequest := range syncRequest.Operations {
+		operation, err := toSyncOperation(operationRequest)
+		if err != nil {
+			return err
+		}
+
+		operations[i] = operation
+	}
+
+	results, err := h.service.Sync(ctx, userID, operations)
+	if err != nil {
+		return domainError(err)
+	}
+
+	todolist, err := h.service.GetTodolist(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	tagNames := map[todolist_model.TagID]string{}
+	for _, tag := range todolist.PF().Tags {
+		tagNames[tag.ID] = tag.Name
+	}
+
+	syncResponse := PostSyncResponse{
+		Results: make([]SyncResultResponse, len(results)),
+	}
+	for i, result := range results {
+		resultResponse := SyncResultResponse{
+			ID:        result.OperationID,
+			Status:    string(result.Status),
+			TodoID:    result.TodoID.Int(),
+			Error:     result.Error,
+			Duplicate: result.Duplicate,
+		}
+		if result.Todo != nil {
+			todoResponse := toTodoResponse(*result.Todo, tagNames)
+			resultResponse.Todo = &todoResponse
+		}
+
+		syncResponse.Results[i] = resultResponse
+	}
+
+	return h.OkJSON(w, syncResponse)
+}
+
+func toSyncOperation(operationRequest SyncOperationRequest) (todolist_domain.SyncOperation, error) {
+	todoID, err := todolist_model.NewTodoID(operationRequest.TodoID)
+	if err != nil {
+		return todolist_domain.SyncOperation{}, domainError(err)
+	}
+
+	tagID, err := todolist_model.NewTagID(operationRequest.TagID)
+	if err != nil {
+		return todolist_domain.SyncOperation{}, domainError(err)
+	}
+
+	priority, err := todolist_model.ParsePriority(operationRequest.Priority)
+	if err != nil {
+		return todolist_domain.SyncOperation{}, domainError(err)
+	}
+
+	return todolist_domain.SyncOperation{
+		ID:            operationRequest.ID,
+		Type:          todolist_domain.SyncOperationType(operationRequest.Type),
+		TodoID:        todoID,
+		Title:         operationRequest.Title,
+		Comment:       operationRequest.Comment,
+		Priority:      priority,
+		DueAt:         operationRequest.Due,
+		TagID:         tagID,
+		BaseUpdatedAt: operationRequest.BaseUpdatedAt,
+	}, nil
+}
@@ -0,0 +1,234 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+func tagIDFromPath(r *http.Request, key string) (todolist_model.TagID, error) {
+	tagIDInt, err := strconv.Atoi(mux.Vars(r)[key])
+	if err != nil {
+		return todolist_model.NilTagID, util.
+			NewHTTPError("invalid tag id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	return tagIDFromInt(tagIDInt)
+}
+
+func tagIDFromInt(tagIDInt int) (todolist_model.TagID, error) {
+	tagID, err := todolist_model.NewTagID(tagIDInt)
+	if err != nil {
+		return todolist_model.NilTagID, util.
+			NewHTTPError("invalid tag id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	return tagID, nil
+}
+
+type TagResponse struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type GetTagsResponse struct {
+	Tags []TagResponse `json:"tags"`
+}
+
+func (h *TodolistHandler) GetTags(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todolist, err := h.service.GetTodolist(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	todolistPF := todolist.PF()
+
+	tagsResponse := GetTagsResponse{
+		Tags: make([]TagResponse, len(todolistPF.Tags)),
+	}
+
+	for i, tag := range todolistPF.Tags {
+		tagsResponse.Tags[i] = TagResponse{
+			ID:    tag.ID.Int(),
+			Name:  tag.Name,
+			Color: tag.Color.String(),
+		}
+	}
+
+	return h.OkJSON(w, tagsResponse)
+}
+
+type PostTagRequest struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Color string `json:"color"`
+}
+
+func (r PostTagRequest) Validate() util.ValidationErrors {
+	var errs util.ValidationErrors
+	if _, err := todolist_model.NewTagColor(r.Color); err != nil {
+		errs.Add("color", "must be hex color like #1a2b3c")
+	}
+
+	return errs
+}
+
+type PostTagResponse struct{}
+
+func (h *TodolistHandler) PostTag(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tagRequest PostTagRequest
+	if err := h.ReadValidJSON(w, r, &tagRequest); err != nil {
+		return err
+	}
+
+	color, err := todolist_model.NewTagColor(tagRequest.Color)
+	if err != nil {
+		return domainError(err)
+	}
+
+	if err := h.service.AddTag(ctx, userID, tagRequest.Name, color); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostTagResponse{})
+}
+
+type PutTagRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type PutTagResponse struct{}
+
+func (h *TodolistHandler) PutTag(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	tagID, err := tagIDFromPath(r, "id")
+	if err != nil {
+		return err
+	}
+
+	var tagRequest PutTagRequest
+	if err := h.ReadValidJSON(w, r, &tagRequest); err != nil {
+		return err
+	}
+
+	if err := h.service.RenameTag(ctx, userID, tagID, tagRequest.Name); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PutTagResponse{})
+}
+
+type PostTagMergeRequest struct {
+	IntoID int `json:"into_id" validate:"required"`
+}
+
+type PostTagMergeResponse struct{}
+
+func (h *TodolistHandler) PostTagMerge(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	fromID, err := tagIDFromPath(r, "id")
+	if err != nil {
+		return err
+	}
+
+	var mergeRequest PostTagMergeRequest
+	if err := h.ReadValidJSON(w, r, &mergeRequest); err != nil {
+		return err
+	}
+
+	intoID, err := tagIDFromInt(mergeRequest.IntoID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.MergeTags(ctx, userID, fromID, intoID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostTagMergeResponse{})
+}
+
+type PostTodoTagRequest struct {
+	TagID int `json:"tag_id" validate:"required"`
+}
+
+type PostTodoTagResponse struct{}
+
+func (h *TodolistHandler) PostTodoTag(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	var tagRequest PostTodoTagRequest
+	if err := h.ReadValidJSON(w, r, &tagRequest); err != nil {
+		return err
+	}
+
+	tagID, err := tagIDFromInt(tagRequest.TagID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.TagTodo(ctx, userID, todoID, tagID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostTodoTagResponse{})
+}
+
+type DeleteTodoTagResponse struct{}
+
+func (h *TodolistHandler) DeleteTodoTag(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	tagID, err := tagIDFromPath(r, "tagID")
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.UntagTodo(ctx, userID, todoID, tagID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, DeleteTodoTagResponse{})
+}
@@ -0,0 +1,87 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+type DeleteTodoResponse struct{}
+
+// DeleteTodo moves todo to trash.
+func (h *TodolistHandler) DeleteTodo(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.DeleteTodo(ctx, userID, todoID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, DeleteTodoResponse{})
+}
+
+type GetTrashResponse struct {
+	Todos []TodoResponse `json:"todos"`
+}
+
+// GetTrash returns a page of trashed todos, it supports the same query
+// parameters as GetTodolist.
+func (h *TodolistHandler) GetTrash(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoQuery, err := parseTodoQuery(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	page, err := h.service.QueryTrash(ctx, userID, todoQuery)
+	if err != nil {
+		return domainError(err)
+	}
+
+	tagNames := make(map[todolist_model.TagID]string, len(page.Tags))
+	for _, tag := range page.Tags {
+		tagNames[tag.ID] = tag.Name
+	}
+
+	trashResponse := GetTrashResponse{
+		Todos: make([]TodoResponse, len(page.Todos)),
+	}
+
+	for i, todo := range page.Todos {
+		trashResponse.Todos[i] = toTodoResponse(todo, tagNames)
+	}
+
+	return h.OkPageJSON(w, trashResponse, page.NextCursor)
+}
+
+type PostTrashRestoreResponse struct{}
+
+func (h *TodolistHandler) PostTrashRestore(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	todoID, err := todoIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.RestoreTodo(ctx, userID, todoID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostTrashRestoreResponse{})
+}
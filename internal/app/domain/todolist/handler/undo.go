@@ -0,0 +1,40 @@
+package todolist_handler
+
+import (
+	"context"
+	"net/http"
+)
+
+type PostUndoResponse struct{}
+
+// PostUndo reverts the latest operation of user. It responds with 409 when
+// there is nothing to undo or todos of the operation were changed since.
+func (h *TodolistHandler) PostUndo(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Undo(ctx, userID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostUndoResponse{})
+}
+
+type PostRedoResponse struct{}
+
+// PostRedo reverts the latest undo of user, any other operation made after
+// undo makes it impossible.
+func (h *TodolistHandler) PostRedo(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Redo(ctx, userID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostRedoResponse{})
+}
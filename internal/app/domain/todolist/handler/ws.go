@@ -0,0 +1,212 @@
+package todolist_handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/realtime"
+)
+
+// Websocket message types. Client sends subscribe, unsubscribe, typing and
+// ping, server answers with subscribed, unsubscribed, pong and error, and
+// pushes presence, typing and event messages of subscribed lists.
+const (
+	WSSubscribe    = "subscribe"
+	WSUnsubscribe  = "unsubscribe"
+	WSTyping       = "typing"
+	WSPing         = "ping"
+	WSSubscribed   = "subscribed"
+	WSUnsubscribed = "unsubscribed"
+	WSPong         = "pong"
+	WSPresence     = "presence"
+	WSError        = "error"
+
+	PresenceJoined = "joined"
+	PresenceLeft   = "left"
+)
+
+// WSRequest is a message sent by client. List is id of todolist owner.
+type WSRequest struct {
+	Type   string `json:"type"`
+	List   int    `json:"list"`
+	TodoID int    `json:"todo_id,omitempty"`
+	Field  string `json:"field,omitempty"`
+}
+
+type WSResponse struct {
+	Type    string `json:"type"`
+	List    int    `json:"list,omitempty"`
+	Members []int  `json:"members,omitempty"`
+	UserID  int    `json:"user_id,omitempty"`
+	Status  string `json:"status,omitempty"`
+	TodoID  int    `json:"todo_id,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// GetWS upgrades connection to websocket. Events of subscribed lists are
+// pushed by todolist_infrastructure.RealtimeNotifier.
+func (h *TodolistHandler) GetWS(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// upgrader writes error response itself
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil
+	}
+
+	session := &wsSession{
+		client: realtime.NewClient(conn, userID, realtime.DefaultSendBuffer),
+		lists:  map[int]struct{}{},
+	}
+	go session.client.WritePump()
+	defer func() {
+		session.client.Close()
+		for list := range session.lists {
+			h.unsubscribe(session, list)
+		}
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.client.Close()
+		case <-session.client.Done():
+		}
+	}()
+
+	for {
+		message, err := session.client.Read()
+		if err != nil {
+			return nil
+		}
+
+		var request WSRequest
+		if err := json.Unmarshal(message, &request); err != nil {
+			h.reply(session.client, WSResponse{Type: WSError, Message: "invalid message"})
+			continue
+		}
+
+		h.handleWS(session, request)
+	}
+}
+
+// wsSession is state of a single websocket connection.
+type wsSession struct {
+	client *realtime.Client
+	lists  map[int]struct{}
+}
+
+func (h *TodolistHandler) handleWS(session *wsSession, request WSRequest) {
+	client := session.client
+
+	switch request.Type {
+	case WSPing:
+		h.reply(client, WSResponse{Type: WSPong})
+	case WSSubscribe:
+		if !canAccessList(client.UserID(), request.List) {
+			h.reply(client, WSResponse{Type: WSError, List: request.List, Message: "list is not found"})
+			return
+		}
+
+		room := listRoom(request.List)
+		_, subscribed := session.lists[request.List]
+		session.lists[request.List] = struct{}{}
+
+		h.reply(client, WSResponse{
+			Type:    WSSubscribed,
+			List:    request.List,
+			Members: members(h.rooms.Join(room, client)),
+		})
+
+		if !subscribed {
+			h.broadcast(room, WSResponse{
+				Type:   WSPresence,
+				List:   request.List,
+				UserID: int(client.UserID()),
+				Status: PresenceJoined,
+			}, client)
+		}
+	case WSUnsubscribe:
+		h.unsubscribe(session, request.List)
+		h.reply(client, WSResponse{Type: WSUnsubscribed, List: request.List})
+	case WSTyping:
+		if _, ok := session.lists[request.List]; !ok {
+			h.reply(client, WSResponse{Type: WSError, List: request.List, Message: "not subscribed"})
+			return
+		}
+
+		h.broadcast(listRoom(request.List), WSResponse{
+			Type:   WSTyping,
+			List:   request.List,
+			UserID: int(client.UserID()),
+			TodoID: request.TodoID,
+			Field:  request.Field,
+		}, client)
+	default:
+		h.reply(client, WSResponse{Type: WSError, Message: "unknown message type"})
+	}
+}
+
+func (h *TodolistHandler) unsubscribe(session *wsSession, list int) {
+	delete(session.lists, list)
+
+	room := listRoom(list)
+	if h.rooms.Leave(room, session.client) {
+		h.broadcast(room, WSResponse{
+			Type:   WSPresence,
+			List:   list,
+			UserID: int(session.client.UserID()),
+			Status: PresenceLeft,
+		}, session.client)
+	}
+}
+
+func (h *TodolistHandler) reply(client *realtime.Client, response WSResponse) {
+	message, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+
+	client.Send(message)
+}
+
+func (h *TodolistHandler) broadcast(room string, response WSResponse, except *realtime.Client) {
+	message, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+
+	h.rooms.Broadcast(room, message, except)
+}
+
+// canAccessList reports whether user may subscribe to list. Todolists are
+// not shared yet, so user may only subscribe to own list.
+func canAccessList(userID access_domain.UserID, list int) bool {
+	return list >= 0 && access_domain.UserID(list) == userID
+}
+
+func listRoom(list int) string {
+	return todolist_infrastructure.UserTopic(access_domain.UserID(list))
+}
+
+// members returns unique user ids of clients.
+func members(clients []*realtime.Client) []int {
+	seen := map[access_domain.UserID]struct{}{}
+	userIDs := []int{}
+	for _, client := range clients {
+		if _, ok := seen[client.UserID()]; ok {
+			continue
+		}
+		seen[client.UserID()] = struct{}{}
+		userIDs = append(userIDs, int(client.UserID()))
+	}
+
+	return userIDs
+}
@@ -0,0 +1,27 @@
+package todolist_domain
+
+import (
+	"context"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type HistoryRepository interface {
+	// History returns events of todo of user ordered from the oldest.
+	History(
+		ctx context.Context,
+		userID access_domain.UserID,
+		todoID todolist_model.TodoID,
+		tx util.Transaction,
+	) ([]todolist_model.Event, error)
+}
+
+func (s *TodolistService) TodoHistory(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+) ([]todolist_model.Event, error) {
+	return s.historyRepo.History(ctx, userID, todoID, nil)
+}
@@ -0,0 +1,289 @@
+package todolist_domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const MaxImportTodos = 10000
+
+var (
+	ErrImportFormat = fmt.Errorf("%w: format must be one of: todotxt, todoist, everd, ics", todolist_model.Err)
+	ErrImportFile   = fmt.Errorf("%w: invalid import file", todolist_model.Err)
+	ErrImportLimit  = fmt.Errorf("%w: at most %d todos can be imported at once", todolist_model.Err, MaxImportTodos)
+)
+
+type ImportFormat string
+
+const (
+	ImportTodoTxt ImportFormat = "todotxt"
+	ImportTodoist ImportFormat = "todoist"
+	ImportEverd   ImportFormat = "everd"
+	// ImportICalendar is .ics file, every VTODO becomes todo.
+	ImportICalendar ImportFormat = "ics"
+)
+
+func ParseImportFormat(format string) (ImportFormat, error) {
+	switch ImportFormat(format) {
+	case ImportTodoTxt, ImportTodoist, ImportEverd, ImportICalendar:
+		return ImportFormat(format), nil
+	default:
+		return "", ErrImportFormat
+	}
+}
+
+// ImportTodo is todo parsed from import file. Tags are referenced by name,
+// missing tags are created.
+type ImportTodo struct {
+	// Line is line of todo in file, or its number in everd export.
+	Line int
+	// UID is UID of iCalendar todo.
+	UID string
+
+	Title    string
+	Comment  string
+	Done     bool
+	Priority todolist_model.Priority
+	DueAt    *time.Time
+	Tags     []string
+
+	Archived bool
+	Deleted  bool
+}
+
+// ImportTag is tag with known color, other tags are created with default
+// color.
+type ImportTag struct {
+	Name  string
+	Color todolist_model.TagColor
+}
+
+type ImportError struct {
+	Line  int
+	Error string
+}
+
+// ImportFile is parsed import file, Errors are todos which could not be
+// parsed.
+type ImportFile struct {
+	Tags   []ImportTag
+	Todos  []ImportTodo
+	Errors []ImportError
+}
+
+type ImportResult struct {
+	DryRun bool
+	// Created is number of todos which are (or would be for dry run)
+	// created.
+	Created     int
+	CreatedTags []string
+	// Errors are todos which are not imported.
+	Errors []ImportError
+}
+
+var errDryRun = errors.New("dry run")
+
+// Import adds todos to todolist of user in a single transaction. Todos
+// failing validation are skipped and reported in result. Dry run reports
+// the same result without changing anything.
+func (s *TodolistService) Import(
+	ctx context.Context,
+	userID access_domain.UserID,
+	file ImportFile,
+	dryRun bool,
+) (ImportResult, error) {
+	if len(file.Todos) > MaxImportTodos {
+		return ImportResult{}, ErrImportLimit
+	}
+
+	var result ImportResult
+	err := s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		result = ImportResult{
+			DryRun: dryRun,
+			Errors: append([]ImportError{}, file.Errors...),
+		}
+
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		colors := make(map[string]todolist_model.TagColor, len(file.Tags))
+		for _, tag := range file.Tags {
+			colors[tag.Name] = tag.Color
+		}
+
+		tagIDs := map[string]todolist_model.TagID{}
+		for _, tag := range list.PF().Tags {
+			tagIDs[tag.Name] = tag.ID
+		}
+
+		// tag ids are not taken until todolist is saved, so they are taken
+		// once and then incremented, todo ids are taken from sequence
+		ids := importIDs{
+			nextTodo: func() (todolist_model.TodoID, error) {
+				return s.todoRepo.NextID(ctx, tx)
+			},
+		}
+		if ids.tag, err = s.tagRepo.NextID(ctx, tx); err != nil {
+			return err
+		}
+
+		for _, todo := range file.Todos {
+			if err := importTodo(list, todo, colors, tagIDs, &ids, &result); err != nil {
+				if !errors.Is(err, todolist_model.Err) {
+					return err
+				}
+
+				result.Errors = append(result.Errors, ImportError{Line: todo.Line, Error: err.Error()})
+				continue
+			}
+
+			result.Created++
+		}
+
+		sort.SliceStable(result.Errors, func(i, j int) bool {
+			return result.Errors[i].Line < result.Errors[j].Line
+		})
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return ImportResult{}, err
+	}
+
+	return result, nil
+}
+
+type importIDs struct {
+	// nextTodo takes id of created todo.
+	nextTodo func() (todolist_model.TodoID, error)
+	tag      todolist_model.TagID
+}
+
+// importTodo validates todo and its new tags before changing list, so
+// rejected todo leaves nothing behind.
+func importTodo(
+	list *todolist_model.Todolist,
+	todo ImportTodo,
+	colors map[string]todolist_model.TagColor,
+	tagIDs map[string]todolist_model.TagID,
+	ids *importIDs,
+	result *ImportResult,
+) error {
+	candidate := todolist_model.NewTodo(todolist_model.NilTodoID, todo.Title)
+	if err := candidate.Validate(); err != nil {
+		return err
+	}
+	if err := candidate.ChangeComment(todo.Comment); err != nil {
+		return err
+	}
+
+	createdTags, err := addImportTags(list, todo.Tags, colors, tagIDs, ids)
+	if err != nil {
+		return err
+	}
+	result.CreatedTags = append(result.CreatedTags, createdTags...)
+
+	todoID, err := ids.nextTodo()
+	if err != nil {
+		return err
+	}
+
+	// todo is valid, changes below can not fail
+	list.AddTodo(todoID, todo.Title)
+	if todo.Comment != "" {
+		if err := list.ChangeComment(todoID, todo.Comment); err != nil {
+			return err
+		}
+	}
+	if err := list.ChangePriority(todoID, todo.Priority); err != nil {
+		return err
+	}
+	if err := list.ChangeDue(todoID, todo.DueAt); err != nil {
+		return err
+	}
+	for _, name := range todo.Tags {
+		if err := list.TagTodo(todoID, tagIDs[name]); err != nil && !errors.Is(err, todolist_model.ErrAlreadyTagged) {
+			return err
+		}
+	}
+	if todo.Done {
+		if err := list.CompleteTodo(todoID); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case todo.Deleted:
+		return list.DeleteTodo(todoID)
+	case todo.Archived && todo.Done:
+		return list.ArchiveTodo(todoID)
+	}
+
+	return nil
+}
+
+// addImportTags adds tags of names missing in tagIDs, names of added tags
+// are returned. Tags are validated before any of them is added.
+func addImportTags(
+	list *todolist_model.Todolist,
+	names []string,
+	colors map[string]todolist_model.TagColor,
+	tagIDs map[string]todolist_model.TagID,
+	ids *importIDs,
+) ([]string, error) {
+	var newTags []todolist_model.TagPF
+	for _, name := range names {
+		if _, ok := tagIDs[name]; ok {
+			continue
+		}
+
+		color, ok := colors[name]
+		if !ok {
+			color = todolist_model.DefaultTagColor
+		}
+
+		tag, err := todolist_model.NewTag(todolist_model.NilTagID, name, color)
+		if err != nil {
+			return nil, err
+		}
+		newTags = append(newTags, tag.PF())
+	}
+
+	var created []string
+	for _, tag := range newTags {
+		if _, ok := tagIDs[tag.Name]; ok {
+			// repeated in names
+			continue
+		}
+
+		if err := list.AddTag(ids.tag, tag.Name, tag.Color); err != nil {
+			return nil, err
+		}
+		tagIDs[tag.Name] = ids.tag
+		ids.tag++
+		created = append(created, tag.Name)
+	}
+
+	return created, nil
+}
@@ -0,0 +1,111 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostrgesArchiveRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesArchiveRepository(db *sql.DB) *PostrgesArchiveRepository {
+	return &PostrgesArchiveRepository{db: db}
+}
+
+var _ todolist_domain.ArchiveRepository = (*PostrgesArchiveRepository)(nil)
+
+func (r *PostrgesArchiveRepository) GetArchived(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	tx util.Transaction,
+) (todolist_model.Todo, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+
+	rows, err := exec.Query(`select `+todoColumns+`
+		from todolist
+		join todos on todolist.todo_id = todos.id
+		where todolist.user_id = $1
+		and todos.id = $2
+		and todos.deleted_at is null
+		and todos.archived_at is not null`, userID, todoID)
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return todolist_model.Todo{}, err
+		}
+		return todolist_model.Todo{}, todolist_model.ErrNotFound
+	}
+
+	todoDTO, err := scanTodoDTO(rows)
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+
+	todoTags, err := getTodoTagsByIDs(exec, []TodoDTO{todoDTO})
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+
+	return fromTodoDTO(todoDTO, todoTags[todoDTO.ID])
+}
+
+func (r *PostrgesArchiveRepository) UsersWithCompleted(
+	ctx context.Context,
+	completedBefore time.Time,
+	afterUserID access_domain.UserID,
+	limit int,
+	tx util.Transaction,
+) ([]access_domain.UserID, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.Query(`select distinct todolist.user_id
+		from todolist
+		join todos on todolist.todo_id = todos.id
+		where todolist.user_id > $1
+		and todos.done
+		and todos.completed_at < $2
+		and todos.deleted_at is null
+		and todos.archived_at is null
+		order by todolist.user_id
+		limit $3`, afterUserID, completedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []access_domain.UserID
+	for rows.Next() {
+		var userIDInt int
+		if err := rows.Scan(&userIDInt); err != nil {
+			return nil, err
+		}
+
+		userID, err := access_domain.NewUserID(userIDInt)
+		if err != nil {
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
@@ -0,0 +1,77 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostrgesCalendarObjectRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesCalendarObjectRepository(db *sql.DB) *PostrgesCalendarObjectRepository {
+	return &PostrgesCalendarObjectRepository{db: db}
+}
+
+var _ todolist_domain.CalendarObjectRepository = (*PostrgesCalendarObjectRepository)(nil)
+
+func (r *PostrgesCalendarObjectRepository) Names(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tx util.Transaction,
+) (map[todolist_model.TodoID]todolist_domain.CalendarObjectName, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.Query(`select todo_id, name, uid from caldav_objects where user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[todolist_model.TodoID]todolist_domain.CalendarObjectName{}
+	for rows.Next() {
+		var (
+			todoIDInt int
+			name      todolist_domain.CalendarObjectName
+		)
+		if err := rows.Scan(&todoIDInt, &name.Name, &name.UID); err != nil {
+			return nil, err
+		}
+
+		todoID, err := todolist_model.NewTodoID(todoIDInt)
+		if err != nil {
+			return nil, err
+		}
+		names[todoID] = name
+	}
+
+	return names, rows.Err()
+}
+
+func (r *PostrgesCalendarObjectRepository) SetName(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	name todolist_domain.CalendarObjectName,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`insert into caldav_objects (todo_id, user_id, name, uid)
+		values ($1, $2, $3, $4)
+		on conflict (todo_id) do update
+		set name = excluded.name, uid = excluded.uid`, todoID, userID, name.Name, name.UID)
+	return err
+}
@@ -0,0 +1,76 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostrgesCalendarRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesCalendarRepository(db *sql.DB) *PostrgesCalendarRepository {
+	return &PostrgesCalendarRepository{db: db}
+}
+
+var _ todolist_domain.CalendarRepository = (*PostrgesCalendarRepository)(nil)
+
+func (r *PostrgesCalendarRepository) SetToken(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tokenHash string,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`insert into calendar_tokens (user_id, token_hash)
+		values ($1, $2)
+		on conflict (user_id) do update
+		set token_hash = excluded.token_hash, created_at = now()`, userID, tokenHash)
+	return err
+}
+
+func (r *PostrgesCalendarRepository) DeleteToken(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`delete from calendar_tokens where user_id = $1`, userID)
+	return err
+}
+
+func (r *PostrgesCalendarRepository) UserByToken(
+	ctx context.Context,
+	tokenHash string,
+	tx util.Transaction,
+) (access_domain.UserID, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return access_domain.NilUserID, err
+	}
+
+	var userID int
+	err = exec.QueryRow(`select user_id from calendar_tokens where token_hash = $1`, tokenHash).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return access_domain.NilUserID, todolist_domain.ErrCalendarTokenNotFound
+	}
+	if err != nil {
+		return access_domain.NilUserID, err
+	}
+
+	return access_domain.NewUserID(userID)
+}
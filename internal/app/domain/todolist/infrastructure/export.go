@@ -0,0 +1,248 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostrgesExportRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesExportRepository(db *sql.DB) *PostrgesExportRepository {
+	return &PostrgesExportRepository{db: db}
+}
+
+var _ todolist_domain.ExportRepository = (*PostrgesExportRepository)(nil)
+
+func (r *PostrgesExportRepository) Tags(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tx util.Transaction,
+) ([]todolist_model.Tag, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	return getTags(exec, userID)
+}
+
+func (r *PostrgesExportRepository) EachTodo(
+	ctx context.Context,
+	userID access_domain.UserID,
+	f func(todo todolist_model.TodoPF) error,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	// tags are aggregated per row, so todos are streamed without loading
+	// tags of all todos first
+	rows, err := exec.Query(`select `+todoColumns+`,
+		coalesce(string_agg(todo_tags.tag_id::text, ',' order by todo_tags.tag_id), '')
+		from todolist
+		join todos on todolist.todo_id = todos.id
+		left join todo_tags on todo_tags.todo_id = todos.id
+		where todolist.user_id = $1
+		group by todos.id
+		order by todos.id`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tagIDs string
+		todoDTO, err := scanTodoDTO(rows, &tagIDs)
+		if err != nil {
+			return err
+		}
+
+		tags, err := parseTagIDs(tagIDs)
+		if err != nil {
+			return err
+		}
+
+		todo, err := fromTodoDTO(todoDTO, tags)
+		if err != nil {
+			return err
+		}
+
+		if err := f(todo.PF()); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func parseTagIDs(value string) ([]todolist_model.TagID, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	tagIDs := make([]todolist_model.TagID, len(parts))
+	for i, part := range parts {
+		tagIDInt, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+
+		if tagIDs[i], err = todolist_model.NewTagID(tagIDInt); err != nil {
+			return nil, err
+		}
+	}
+
+	return tagIDs, nil
+}
+
+// ExportHeader starts json and ndjson exports.
+type ExportHeader struct {
+	Format     string                      `json:"format"`
+	Version    int                         `json:"version"`
+	ExportedAt time.Time                   `json:"exported_at"`
+	Tags       []todolist_domain.ExportTag `json:"tags"`
+}
+
+func newExportHeader(tags []todolist_domain.ExportTag) ExportHeader {
+	return ExportHeader{
+		Format:     todolist_domain.ExportFormatName,
+		Version:    todolist_domain.ExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Tags:       tags,
+	}
+}
+
+// NewExportWriter returns writer of export in format, format must be
+// parsed with todolist_domain.ParseExportFormat.
+func NewExportWriter(format todolist_domain.ExportFormat, w io.Writer) todolist_domain.ExportWriter {
+	switch format {
+	case todolist_domain.ExportCSV:
+		return &csvExportWriter{w: csv.NewWriter(w)}
+	case todolist_domain.ExportNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}
+	default:
+		return &jsonExportWriter{w: w}
+	}
+}
+
+// jsonExportWriter writes a single document: header with todos field
+// holding array of todos.
+type jsonExportWriter struct {
+	w     io.Writer
+	todos int
+}
+
+func (e *jsonExportWriter) WriteTags(tags []todolist_domain.ExportTag) error {
+	header, err := json.Marshal(newExportHeader(tags))
+	if err != nil {
+		return err
+	}
+
+	// header is an object, todos are appended as its last field
+	_, err = io.WriteString(e.w, string(header[:len(header)-1])+`,"todos":[`)
+	return err
+}
+
+func (e *jsonExportWriter) WriteTodo(todo todolist_domain.ExportTodo) error {
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return err
+	}
+
+	if e.todos > 0 {
+		data = append([]byte{','}, data...)
+	}
+	e.todos++
+
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonExportWriter) Close() error {
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
+
+// ndjsonExportWriter writes header on the first line and a todo on every
+// following line.
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExportWriter) WriteTags(tags []todolist_domain.ExportTag) error {
+	return e.encoder.Encode(newExportHeader(tags))
+}
+
+func (e *ndjsonExportWriter) WriteTodo(todo todolist_domain.ExportTodo) error {
+	return e.encoder.Encode(todo)
+}
+
+func (e *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+// ExportCSVHeader lists columns of csv export, tags are separated by
+// ExportCSVTagSeparator and times are in RFC 3339.
+var ExportCSVHeader = []string{
+	"id", "title", "comment", "done", "priority", "due", "tags", "position",
+	"created_at", "updated_at", "completed_at", "archived_at", "deleted_at",
+}
+
+const ExportCSVTagSeparator = ";"
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) WriteTags(tags []todolist_domain.ExportTag) error {
+	// colors of tags are not exported to csv
+	return e.w.Write(ExportCSVHeader)
+}
+
+func (e *csvExportWriter) WriteTodo(todo todolist_domain.ExportTodo) error {
+	return e.w.Write([]string{
+		strconv.Itoa(todo.ID),
+		todo.Title,
+		todo.Comment,
+		strconv.FormatBool(todo.Done),
+		todo.Priority,
+		formatCSVTime(todo.Due),
+		strings.Join(todo.Tags, ExportCSVTagSeparator),
+		todo.Position,
+		formatCSVTime(&todo.CreatedAt),
+		formatCSVTime(&todo.UpdatedAt),
+		formatCSVTime(todo.CompletedAt),
+		formatCSVTime(todo.ArchivedAt),
+		formatCSVTime(todo.DeletedAt),
+	})
+}
+
+func (e *csvExportWriter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
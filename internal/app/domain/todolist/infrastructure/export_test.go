@@ -0,0 +1,98 @@
+package todolist_infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+)
+
+func writeExport(t *testing.T, format todolist_domain.ExportFormat, todos ...todolist_domain.ExportTodo) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := NewExportWriter(format, &buf)
+	if err := w.WriteTags([]todolist_domain.ExportTag{{Name: "work", Color: "#ff0000"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, todo := range todos {
+		if err := w.WriteTodo(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func exportTodos() []todolist_domain.ExportTodo {
+	created := time.Date(2024, 11, 1, 10, 0, 0, 0, time.UTC)
+	return []todolist_domain.ExportTodo{
+		{ID: 1, Title: "write, report", Comment: "line\nbreak", Priority: "high", Tags: []string{"work"}, Position: "V", CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Title: "buy milk", Done: true, Priority: "none", Tags: []string{}, Position: "k", CreatedAt: created, UpdatedAt: created, CompletedAt: &created},
+	}
+}
+
+func TestJSONExport(t *testing.T) {
+	for _, todos := range [][]todolist_domain.ExportTodo{nil, exportTodos()} {
+		var document struct {
+			ExportHeader
+			Todos []todolist_domain.ExportTodo `json:"todos"`
+		}
+		if err := json.Unmarshal(writeExport(t, todolist_domain.ExportJSON, todos...), &document); err != nil {
+			t.Fatal(err)
+		}
+
+		if document.Format != todolist_domain.ExportFormatName || document.Version != todolist_domain.ExportVersion {
+			t.Fatalf("unexpected header %+v", document.ExportHeader)
+		}
+		if len(document.Tags) != 1 || len(document.Todos) != len(todos) {
+			t.Fatalf("expected 1 tag and %d todos, got %+v", len(todos), document)
+		}
+		for i, todo := range document.Todos {
+			if todo.Title != todos[i].Title || todo.Comment != todos[i].Comment || todo.Done != todos[i].Done {
+				t.Fatalf("todo %d: got %+v, want %+v", i, todo, todos[i])
+			}
+		}
+	}
+}
+
+func TestNDJSONExport(t *testing.T) {
+	scanner := bufio.NewScanner(bytes.NewReader(writeExport(t, todolist_domain.ExportNDJSON, exportTodos()...)))
+
+	lines := 0
+	for scanner.Scan() {
+		var value map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &value); err != nil {
+			t.Fatalf("line %d: %v", lines, err)
+		}
+		if lines == 0 && value["format"] != todolist_domain.ExportFormatName {
+			t.Fatalf("expected header on the first line, got %v", value)
+		}
+		lines++
+	}
+
+	if lines != 3 {
+		t.Fatalf("expected header and 2 todos, got %d lines", lines)
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeExport(t, todolist_domain.ExportCSV, exportTodos()...))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 3 || len(records[0]) != len(ExportCSVHeader) {
+		t.Fatalf("unexpected records %v", records)
+	}
+	if records[1][1] != "write, report" || records[1][2] != "line\nbreak" || records[2][10] != "2024-11-01T10:00:00Z" {
+		t.Fatalf("unexpected todo records %q", records[1:])
+	}
+}
@@ -0,0 +1,132 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type EventDTO struct {
+	Name       string
+	UserID     int
+	TodoID     int
+	OldValue   string
+	NewValue   string
+	OccurredAt time.Time
+}
+
+func fromEventDTO(eventDTO EventDTO) (todolist_model.Event, error) {
+	userID, err := access_domain.NewUserID(eventDTO.UserID)
+	if err != nil {
+		return todolist_model.Event{}, err
+	}
+
+	todoID, err := todolist_model.NewTodoID(eventDTO.TodoID)
+	if err != nil {
+		return todolist_model.Event{}, err
+	}
+
+	return todolist_model.Event{
+		Name:       todolist_model.EventName(eventDTO.Name),
+		UserID:     userID,
+		TodoID:     todoID,
+		OldValue:   eventDTO.OldValue,
+		NewValue:   eventDTO.NewValue,
+		OccurredAt: eventDTO.OccurredAt,
+	}, nil
+}
+
+// saveEvents persists events recorded by todolist, it is called by Save
+// inside the same transaction.
+func saveEvents(exec storage.Executor, events []todolist_model.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	stmtEventInsert, err := exec.Prepare(`insert into todo_events
+		(todo_id, user_id, name, old_value, new_value, occurred_at)
+		values ($1, $2, $3, $4, $5, $6)`)
+	if err != nil {
+		return err
+	}
+	defer stmtEventInsert.Close()
+
+	for _, event := range events {
+		if _, err := stmtEventInsert.Exec(
+			event.TodoID,
+			event.UserID,
+			string(event.Name),
+			event.OldValue,
+			event.NewValue,
+			event.OccurredAt,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type PostrgesHistoryRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesHistoryRepository(db *sql.DB) *PostrgesHistoryRepository {
+	return &PostrgesHistoryRepository{db: db}
+}
+
+var _ todolist_domain.HistoryRepository = (*PostrgesHistoryRepository)(nil)
+
+func (r *PostrgesHistoryRepository) History(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	tx util.Transaction,
+) ([]todolist_model.Event, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.Query(`select todo_events.name, todo_events.user_id, todo_events.todo_id,
+		todo_events.old_value, todo_events.new_value, todo_events.occurred_at
+		from todo_events
+		join todolist on todolist.todo_id = todo_events.todo_id
+		where todolist.user_id = $1
+		and todo_events.todo_id = $2
+		order by todo_events.occurred_at, todo_events.id`, userID, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []todolist_model.Event{}
+	for rows.Next() {
+		var eventDTO EventDTO
+		if err := rows.Scan(
+			&eventDTO.Name,
+			&eventDTO.UserID,
+			&eventDTO.TodoID,
+			&eventDTO.OldValue,
+			&eventDTO.NewValue,
+			&eventDTO.OccurredAt,
+		); err != nil {
+			return nil, err
+		}
+
+		event, err := fromEventDTO(eventDTO)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,422 @@
+package todolist_infrastructure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+// iCalendar (RFC 5545) feed and import. Todo maps to VTODO:
+//
+//	title      SUMMARY
+//	comment    DESCRIPTION
+//	done       STATUS:COMPLETED and COMPLETED
+//	priority   PRIORITY, urgent 1, high 3, medium 5, low 9, none is omitted
+//	due        DUE, midnight UTC is written as date
+//	tags       CATEGORIES
+//
+// Todo has no recurrence, so RRULE is never written and is ignored on
+// import, recurring todo is imported once with its DUE.
+const (
+	ICalendarProdID       = "-//everd//everd//EN"
+	icalendarLineLimit    = 75
+	icalendarDate         = "20060102"
+	icalendarDateTime     = "20060102T150405"
+	icalendarDateTimeUTC  = "20060102T150405Z"
+	icalendarCalendarName = "everd"
+)
+
+var icalendarPriorities = map[todolist_model.Priority]int{
+	todolist_model.PriorityUrgent: 1,
+	todolist_model.PriorityHigh:   3,
+	todolist_model.PriorityMedium: 5,
+	todolist_model.PriorityLow:    9,
+}
+
+// parseICalendarPriority maps PRIORITY, 1 is the highest and 9 the lowest
+// priority, 0 is undefined.
+func parseICalendarPriority(value string) (todolist_model.Priority, error) {
+	priority, err := strconv.Atoi(value)
+	switch {
+	case err != nil || priority < 0 || priority > 9:
+		return todolist_model.PriorityNone, fmt.Errorf("%w: PRIORITY must be from 0 to 9", todolist_model.Err)
+	case priority == 0:
+		return todolist_model.PriorityNone, nil
+	case priority == 1:
+		return todolist_model.PriorityUrgent, nil
+	case priority < 5:
+		return todolist_model.PriorityHigh, nil
+	case priority == 5:
+		return todolist_model.PriorityMedium, nil
+	default:
+		return todolist_model.PriorityLow, nil
+	}
+}
+
+// NewICalendarWriter returns writer of VCALENDAR with VTODO for every
+// todo, it is used for calendar feed.
+func NewICalendarWriter(w io.Writer) todolist_domain.ExportWriter {
+	return &icalendarWriter{w: bufio.NewWriter(w)}
+}
+
+type icalendarWriter struct {
+	w *bufio.Writer
+}
+
+func (e *icalendarWriter) WriteTags(tags []todolist_domain.ExportTag) error {
+	e.writeHeader()
+	e.writeLine("X-WR-CALNAME:" + icalendarCalendarName)
+
+	return e.w.Flush()
+}
+
+func (e *icalendarWriter) WriteTodo(todo todolist_domain.ExportTodo) error {
+	todoID, err := todolist_model.NewTodoID(todo.ID)
+	if err != nil {
+		return err
+	}
+
+	e.writeTodo(todo, todolist_domain.DefaultCalendarObjectName(todoID).UID)
+	return e.w.Flush()
+}
+
+func (e *icalendarWriter) Close() error {
+	e.writeLine("END:VCALENDAR")
+	return e.w.Flush()
+}
+
+// WriteICalendarObject writes CalDAV resource of todo, it is VCALENDAR
+// with a single VTODO.
+func WriteICalendarObject(w io.Writer, object todolist_domain.CalendarObject) error {
+	e := &icalendarWriter{w: bufio.NewWriter(w)}
+	e.writeHeader()
+	e.writeTodo(object.Todo, object.UID)
+	e.writeLine("END:VCALENDAR")
+
+	return e.w.Flush()
+}
+
+func (e *icalendarWriter) writeHeader() {
+	e.writeLine("BEGIN:VCALENDAR")
+	e.writeLine("VERSION:2.0")
+	e.writeLine("PRODID:" + ICalendarProdID)
+	e.writeLine("CALSCALE:GREGORIAN")
+}
+
+// writeTodo writes VTODO, errors are reported by Flush.
+func (e *icalendarWriter) writeTodo(todo todolist_domain.ExportTodo, uid string) {
+	e.writeLine("BEGIN:VTODO")
+	e.writeLine("UID:" + escapeICalendarText(uid))
+	e.writeLine("DTSTAMP:" + todo.UpdatedAt.UTC().Format(icalendarDateTimeUTC))
+	e.writeLine("CREATED:" + todo.CreatedAt.UTC().Format(icalendarDateTimeUTC))
+	e.writeLine("LAST-MODIFIED:" + todo.UpdatedAt.UTC().Format(icalendarDateTimeUTC))
+	e.writeLine("SUMMARY:" + escapeICalendarText(todo.Title))
+	if todo.Comment != "" {
+		e.writeLine("DESCRIPTION:" + escapeICalendarText(todo.Comment))
+	}
+
+	if todo.Done {
+		e.writeLine("STATUS:COMPLETED")
+		if todo.CompletedAt != nil {
+			e.writeLine("COMPLETED:" + todo.CompletedAt.UTC().Format(icalendarDateTimeUTC))
+		}
+	} else {
+		e.writeLine("STATUS:NEEDS-ACTION")
+	}
+
+	if priority, err := todolist_model.ParsePriority(todo.Priority); err == nil {
+		if value, ok := icalendarPriorities[priority]; ok {
+			e.writeLine("PRIORITY:" + strconv.Itoa(value))
+		}
+	}
+
+	if todo.Due != nil {
+		due := todo.Due.UTC()
+		if due.Equal(due.Truncate(24 * time.Hour)) {
+			e.writeLine("DUE;VALUE=DATE:" + due.Format(icalendarDate))
+		} else {
+			e.writeLine("DUE:" + due.Format(icalendarDateTimeUTC))
+		}
+	}
+
+	if len(todo.Tags) > 0 {
+		categories := make([]string, len(todo.Tags))
+		for i, tag := range todo.Tags {
+			categories[i] = escapeICalendarText(tag)
+		}
+		e.writeLine("CATEGORIES:" + strings.Join(categories, ","))
+	}
+
+	e.writeLine("END:VTODO")
+}
+
+// writeLine writes content line folded to lines of at most 75 octets,
+// errors are reported by Flush.
+func (e *icalendarWriter) writeLine(line string) {
+	length := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if length+size > icalendarLineLimit {
+			e.w.WriteString("\r\n ")
+			length = 1
+		}
+
+		e.w.WriteRune(r)
+		length += size
+	}
+
+	e.w.WriteString("\r\n")
+}
+
+var icalendarTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", "",
+)
+
+func escapeICalendarText(value string) string {
+	return icalendarTextEscaper.Replace(value)
+}
+
+// splitICalendarText unescapes text value, list values are split by
+// unescaped commas.
+func splitICalendarText(value string) []string {
+	var (
+		values  []string
+		current strings.Builder
+	)
+
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value):
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				current.WriteByte('\n')
+			} else {
+				current.WriteByte(value[i])
+			}
+		case c == ',':
+			values = append(values, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	return append(values, current.String())
+}
+
+func unescapeICalendarText(value string) string {
+	// text is not a list, both escaped and unescaped commas are kept
+	return strings.Join(splitICalendarText(value), ",")
+}
+
+type icalendarProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICalendarProperty parses unfolded content line
+// "NAME;PARAM=value;PARAM=\"quoted\":value".
+func parseICalendarProperty(line string) (icalendarProperty, error) {
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icalendarProperty{}, fmt.Errorf("invalid content line %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	property := icalendarProperty{
+		name:   strings.ToUpper(parts[0]),
+		params: map[string]string{},
+		value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		property.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+	}
+
+	return property, nil
+}
+
+func parseICalendarTime(property icalendarProperty) (time.Time, error) {
+	if property.params["VALUE"] == "DATE" || len(property.value) == len(icalendarDate) {
+		return time.Parse(icalendarDate, property.value)
+	}
+
+	if strings.HasSuffix(property.value, "Z") {
+		return time.Parse(icalendarDateTimeUTC, property.value)
+	}
+
+	// local time of TZID, floating time is read as UTC
+	location := time.UTC
+	if tzid := property.params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			location = tz
+		}
+	}
+
+	t, err := time.ParseInLocation(icalendarDateTime, property.value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.UTC(), nil
+}
+
+// parseICalendar parses .ics file, every VTODO becomes todo and other
+// components (VEVENT, VALARM, VTIMEZONE) are skipped. Line of todo is the
+// line of its BEGIN:VTODO.
+func parseICalendar(r io.Reader) (todolist_domain.ImportFile, error) {
+	var (
+		file       todolist_domain.ImportFile
+		components []string
+		todo       *todolist_domain.ImportTodo
+		todoError  error
+		calendar   bool
+	)
+
+	handle := func(line int, content string) error {
+		property, err := parseICalendarProperty(content)
+		if err != nil {
+			return err
+		}
+
+		switch property.name {
+		case "BEGIN":
+			component := strings.ToUpper(property.value)
+			components = append(components, component)
+			switch {
+			case component == "VCALENDAR":
+				calendar = true
+			case component == "VTODO" && len(components) == 2:
+				todo = &todolist_domain.ImportTodo{Line: line}
+				todoError = nil
+			}
+			return nil
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(property.value) {
+				return fmt.Errorf("unexpected END:%s", property.value)
+			}
+			components = components[:len(components)-1]
+
+			if todo != nil && len(components) == 1 {
+				if todoError != nil {
+					file.Errors = append(file.Errors, todolist_domain.ImportError{Line: todo.Line, Error: todoError.Error()})
+				} else {
+					file.Todos = append(file.Todos, *todo)
+				}
+				todo = nil
+			}
+			return nil
+		}
+
+		// properties of nested components, e.g. VALARM, are not todo ones
+		if todo == nil || components[len(components)-1] != "VTODO" || todoError != nil {
+			return nil
+		}
+
+		switch property.name {
+		case "UID":
+			todo.UID = unescapeICalendarText(property.value)
+		case "SUMMARY":
+			todo.Title = unescapeICalendarText(property.value)
+		case "DESCRIPTION":
+			todo.Comment = unescapeICalendarText(property.value)
+		case "STATUS":
+			switch strings.ToUpper(property.value) {
+			case "COMPLETED":
+				todo.Done = true
+			case "CANCELLED":
+				todo.Deleted = true
+			}
+		case "COMPLETED":
+			todo.Done = true
+		case "PRIORITY":
+			todo.Priority, todoError = parseICalendarPriority(property.value)
+		case "DUE":
+			due, err := parseICalendarTime(property)
+			if err != nil {
+				todoError = fmt.Errorf("%w: invalid DUE %q", todolist_model.Err, property.value)
+				return nil
+			}
+			todo.DueAt = &due
+		case "CATEGORIES":
+			for _, tag := range splitICalendarText(property.value) {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					todo.Tags = appendUnique(todo.Tags, tag)
+				}
+			}
+		}
+
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var (
+		content   strings.Builder
+		startLine int
+	)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+
+		// folded line continues the previous one
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			content.WriteString(text[1:])
+			continue
+		}
+
+		if content.Len() > 0 {
+			if err := handle(startLine, content.String()); err != nil {
+				return todolist_domain.ImportFile{}, importFileError(err)
+			}
+		}
+		content.Reset()
+		content.WriteString(text)
+		startLine = line
+	}
+	if err := scanner.Err(); err != nil {
+		return todolist_domain.ImportFile{}, importFileError(err)
+	}
+	if content.Len() > 0 {
+		if err := handle(startLine, content.String()); err != nil {
+			return todolist_domain.ImportFile{}, importFileError(err)
+		}
+	}
+
+	if !calendar {
+		return todolist_domain.ImportFile{}, importFileError(fmt.Errorf("BEGIN:VCALENDAR is missing"))
+	}
+	if len(components) > 0 {
+		return todolist_domain.ImportFile{}, importFileError(fmt.Errorf("END:%s is missing", components[len(components)-1]))
+	}
+
+	return file, nil
+}
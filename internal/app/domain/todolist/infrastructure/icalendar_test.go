@@ -0,0 +1,128 @@
+package todolist_infrastructure
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+func TestICalendarRoundTrip(t *testing.T) {
+	created := time.Date(2024, 11, 1, 10, 0, 0, 0, time.UTC)
+	dueDate := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	dueTime := time.Date(2024, 12, 24, 18, 30, 0, 0, time.UTC)
+	todos := []todolist_domain.ExportTodo{
+		{ID: 1, Title: "write, report; " + strings.Repeat("long ", 20), Comment: "line\nbreak \\ back", Priority: "urgent", Due: &dueTime, Tags: []string{"work", "a,b"}, CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Title: "buy молоко", Done: true, Priority: "low", Due: &dueDate, Tags: []string{}, CreatedAt: created, UpdatedAt: created, CompletedAt: &created},
+		{ID: 3, Title: "no priority", Priority: "none", Tags: []string{}, CreatedAt: created, UpdatedAt: created},
+	}
+
+	var buf bytes.Buffer
+	w := NewICalendarWriter(&buf)
+	if err := w.WriteTags(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, todo := range todos {
+		if err := w.WriteTodo(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > icalendarLineLimit {
+			t.Fatalf("line is not folded: %q", line)
+		}
+	}
+
+	file, err := ParseImport(todolist_domain.ImportICalendar, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file.Errors) != 0 {
+		t.Fatalf("unexpected errors %+v", file.Errors)
+	}
+	if len(file.Todos) != len(todos) {
+		t.Fatalf("got %d todos, expected %d", len(file.Todos), len(todos))
+	}
+	for i, todo := range file.Todos {
+		expected := todos[i]
+		priority, _ := todolist_model.ParsePriority(expected.Priority)
+		if todo.Title != expected.Title ||
+			todo.Comment != expected.Comment ||
+			todo.Done != expected.Done ||
+			todo.Priority != priority ||
+			!reflect.DeepEqual(todo.DueAt, expected.Due) ||
+			len(todo.Tags) != len(expected.Tags) ||
+			(len(todo.Tags) > 0 && !reflect.DeepEqual(todo.Tags, expected.Tags)) {
+			t.Fatalf("got %+v, expected %+v", todo, expected)
+		}
+	}
+}
+
+func TestParseICalendar(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"SUMMARY:meeting",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"SUMMARY:water the",
+		"  plants",
+		"DUE;TZID=Europe/Berlin:20241224T100000",
+		"RRULE:FREQ=WEEKLY",
+		"PRIORITY:2",
+		"CATEGORIES:home,garden",
+		"BEGIN:VALARM",
+		"DESCRIPTION:reminder",
+		"END:VALARM",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:cancelled",
+		"STATUS:CANCELLED",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:broken",
+		"DUE:tomorrow",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	file, err := ParseImport(todolist_domain.ImportICalendar, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC)
+	expected := []todolist_domain.ImportTodo{
+		{Line: 6, Title: "water the plants", Priority: todolist_model.PriorityHigh, DueAt: &due, Tags: []string{"home", "garden"}},
+		{Line: 17, Title: "cancelled", Deleted: true},
+	}
+	if !reflect.DeepEqual(file.Todos, expected) {
+		t.Fatalf("got %+v, expected %+v", file.Todos, expected)
+	}
+
+	if len(file.Errors) != 1 || file.Errors[0].Line != 21 {
+		t.Fatalf("unexpected errors %+v", file.Errors)
+	}
+}
+
+func TestParseICalendarRejectsBrokenFiles(t *testing.T) {
+	for _, input := range []string{
+		"BEGIN:VTODO\r\nEND:VTODO",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\n",
+		"BEGIN:VCALENDAR\r\nno colon\r\nEND:VCALENDAR",
+	} {
+		if _, err := ParseImport(todolist_domain.ImportICalendar, strings.NewReader(input)); err == nil {
+			t.Fatalf("%q: expected error", input)
+		}
+	}
+}
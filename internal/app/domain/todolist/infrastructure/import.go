@@ -0,0 +1,311 @@
+package todolist_infrastructure
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+// ParseImport parses import file of format, files with broken structure
+// fail with todolist_domain.ErrImportFile.
+func ParseImport(format todolist_domain.ImportFormat, r io.Reader) (todolist_domain.ImportFile, error) {
+	switch format {
+	case todolist_domain.ImportTodoTxt:
+		return parseTodoTxt(r)
+	case todolist_domain.ImportTodoist:
+		return parseTodoistCSV(r)
+	case todolist_domain.ImportICalendar:
+		return parseICalendar(r)
+	default:
+		return parseEverdExport(r)
+	}
+}
+
+func importFileError(err error) error {
+	return fmt.Errorf("%w: %s", todolist_domain.ErrImportFile, err)
+}
+
+var (
+	todoTxtPriority = regexp.MustCompile(`^\(([A-Z])\)$`)
+	todoTxtDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// todoTxtPriorities maps todo.txt priorities, D and lower are low.
+var todoTxtPriorities = map[byte]todolist_model.Priority{
+	'A': todolist_model.PriorityUrgent,
+	'B': todolist_model.PriorityHigh,
+	'C': todolist_model.PriorityMedium,
+}
+
+func parseTodoTxtPriority(letter byte) todolist_model.Priority {
+	if priority, ok := todoTxtPriorities[letter]; ok {
+		return priority
+	}
+
+	return todolist_model.PriorityLow
+}
+
+// parseTodoTxt parses todo.txt file, see
+// https://github.com/todotxt/todo.txt. Projects (+project) and contexts
+// (@context) become tags, due:YYYY-MM-DD becomes due date.
+func parseTodoTxt(r io.Reader) (todolist_domain.ImportFile, error) {
+	var file todolist_domain.ImportFile
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		todo := todolist_domain.ImportTodo{Line: line}
+
+		// completion mark and completion date, or priority, then creation
+		// date
+		i := 0
+		if fields[0] == "x" {
+			todo.Done = true
+			i++
+			if i < len(fields) && todoTxtDate.MatchString(fields[i]) {
+				i++
+			}
+		} else if match := todoTxtPriority.FindStringSubmatch(fields[0]); match != nil {
+			todo.Priority = parseTodoTxtPriority(match[1][0])
+			i++
+		}
+		if i < len(fields) && todoTxtDate.MatchString(fields[i]) {
+			i++
+		}
+
+		var title []string
+		for _, field := range fields[i:] {
+			switch {
+			case len(field) > 1 && (field[0] == '+' || field[0] == '@'):
+				todo.Tags = appendUnique(todo.Tags, field[1:])
+			case strings.HasPrefix(field, "due:"):
+				due, err := time.Parse(time.DateOnly, strings.TrimPrefix(field, "due:"))
+				if err != nil {
+					title = append(title, field)
+					continue
+				}
+				todo.DueAt = &due
+			case strings.HasPrefix(field, "pri:") && len(field) == 5:
+				// priority of completed task
+				todo.Priority = parseTodoTxtPriority(field[4])
+			default:
+				title = append(title, field)
+			}
+		}
+		todo.Title = strings.Join(title, " ")
+
+		file.Todos = append(file.Todos, todo)
+	}
+	if err := scanner.Err(); err != nil {
+		return todolist_domain.ImportFile{}, importFileError(err)
+	}
+
+	return file, nil
+}
+
+// todoistPriorities maps PRIORITY column of Todoist csv, 1 is the highest
+// priority (p1) and 4 is no priority.
+var todoistPriorities = map[string]todolist_model.Priority{
+	"1": todolist_model.PriorityUrgent,
+	"2": todolist_model.PriorityHigh,
+	"3": todolist_model.PriorityMedium,
+	"4": todolist_model.PriorityNone,
+}
+
+var todoistDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", time.DateOnly}
+
+// parseTodoistCSV parses project exported from Todoist as csv. Only task
+// rows are imported, sub-tasks become regular todos and @labels in content
+// become tags. Dates in natural language (e.g. "every day") are ignored.
+func parseTodoistCSV(r io.Reader) (todolist_domain.ImportFile, error) {
+	var file todolist_domain.ImportFile
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return todolist_domain.ImportFile{}, importFileError(err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"TYPE", "CONTENT"} {
+		if _, ok := columns[name]; !ok {
+			return todolist_domain.ImportFile{}, importFileError(fmt.Errorf("column %s is missing", name))
+		}
+	}
+
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return todolist_domain.ImportFile{}, importFileError(err)
+		}
+
+		if !strings.EqualFold(column(record, "TYPE"), "task") {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		todo := todolist_domain.ImportTodo{
+			Line:     line,
+			Comment:  column(record, "DESCRIPTION"),
+			Priority: todoistPriorities[column(record, "PRIORITY")],
+		}
+
+		var title []string
+		for _, field := range strings.Fields(column(record, "CONTENT")) {
+			if len(field) > 1 && field[0] == '@' {
+				todo.Tags = appendUnique(todo.Tags, field[1:])
+				continue
+			}
+			title = append(title, field)
+		}
+		todo.Title = strings.Join(title, " ")
+
+		if date := column(record, "DATE"); date != "" {
+			for _, layout := range todoistDateLayouts {
+				if due, err := time.Parse(layout, date); err == nil {
+					todo.DueAt = &due
+					break
+				}
+			}
+		}
+
+		file.Todos = append(file.Todos, todo)
+	}
+
+	return file, nil
+}
+
+// everdDocument is json export, ndjson export has header on the first
+// line instead.
+type everdDocument struct {
+	ExportHeader
+	Todos []todolist_domain.ExportTodo `json:"todos"`
+}
+
+// parseEverdExport parses json or ndjson export of any version up to
+// todolist_domain.ExportVersion. Todos are imported in their todolist
+// order.
+func parseEverdExport(r io.Reader) (todolist_domain.ImportFile, error) {
+	decoder := json.NewDecoder(r)
+
+	var document everdDocument
+	if err := decoder.Decode(&document); err != nil {
+		return todolist_domain.ImportFile{}, importFileError(err)
+	}
+
+	if document.Format != todolist_domain.ExportFormatName {
+		return todolist_domain.ImportFile{}, importFileError(errors.New("not an everd export"))
+	}
+	if document.Version < 1 || document.Version > todolist_domain.ExportVersion {
+		return todolist_domain.ImportFile{}, importFileError(fmt.Errorf("unsupported version %d", document.Version))
+	}
+
+	// ndjson, todos follow header line by line
+	first := 1
+	if document.Todos == nil {
+		first = 2
+		for {
+			var todo todolist_domain.ExportTodo
+			err := decoder.Decode(&todo)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return todolist_domain.ImportFile{}, importFileError(err)
+			}
+
+			document.Todos = append(document.Todos, todo)
+		}
+	}
+
+	var file todolist_domain.ImportFile
+	for _, tag := range document.Tags {
+		color, err := todolist_model.NewTagColor(tag.Color)
+		if err != nil {
+			color = todolist_model.DefaultTagColor
+		}
+
+		file.Tags = append(file.Tags, todolist_domain.ImportTag{Name: tag.Name, Color: color})
+	}
+
+	// line is the line of ndjson or the number of todo in json
+	type exportedTodo struct {
+		line int
+		todolist_domain.ExportTodo
+	}
+	exported := make([]exportedTodo, len(document.Todos))
+	for i, todo := range document.Todos {
+		exported[i] = exportedTodo{line: first + i, ExportTodo: todo}
+	}
+	sort.SliceStable(exported, func(i, j int) bool {
+		return exported[i].Position < exported[j].Position
+	})
+
+	for _, todo := range exported {
+		priority, err := todolist_model.ParsePriority(todo.Priority)
+		if err != nil {
+			file.Errors = append(file.Errors, todolist_domain.ImportError{Line: todo.line, Error: err.Error()})
+			continue
+		}
+
+		file.Todos = append(file.Todos, todolist_domain.ImportTodo{
+			Line:     todo.line,
+			Title:    todo.Title,
+			Comment:  todo.Comment,
+			Done:     todo.Done,
+			Priority: priority,
+			DueAt:    todo.Due,
+			Tags:     todo.Tags,
+			Archived: todo.ArchivedAt != nil,
+			Deleted:  todo.DeletedAt != nil,
+		})
+	}
+
+	return file, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
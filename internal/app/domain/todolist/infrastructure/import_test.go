@@ -0,0 +1,120 @@
+package todolist_infrastructure
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+func TestParseTodoTxt(t *testing.T) {
+	input := strings.Join([]string{
+		"(A) 2024-11-01 call mom +family @phone due:2024-12-24",
+		"",
+		"x 2024-11-02 2024-11-01 pay rent pri:B",
+		"(D) read book",
+		"+work @office",
+	}, "\n")
+
+	file, err := ParseImport(todolist_domain.ImportTodoTxt, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	expected := []todolist_domain.ImportTodo{
+		{Line: 1, Title: "call mom", Priority: todolist_model.PriorityUrgent, DueAt: &due, Tags: []string{"family", "phone"}},
+		{Line: 3, Title: "pay rent", Done: true, Priority: todolist_model.PriorityHigh},
+		{Line: 4, Title: "read book", Priority: todolist_model.PriorityLow},
+		{Line: 5, Title: "", Tags: []string{"work", "office"}},
+	}
+	if !reflect.DeepEqual(file.Todos, expected) {
+		t.Fatalf("got %+v, expected %+v", file.Todos, expected)
+	}
+}
+
+func TestParseTodoistCSV(t *testing.T) {
+	input := "\ufeffTYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE\n" +
+		"section,Errands,,,,,,,,\n" +
+		"task,Buy milk @shop,\"two\nbottles\",1,1,,,2024-12-01,en,UTC\n" +
+		"task,Sub task,,4,2,,,every day,en,UTC\n"
+
+	file, err := ParseImport(todolist_domain.ImportTodoist, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	expected := []todolist_domain.ImportTodo{
+		{Line: 3, Title: "Buy milk", Comment: "two\nbottles", Priority: todolist_model.PriorityUrgent, DueAt: &due, Tags: []string{"shop"}},
+		{Line: 5, Title: "Sub task", Priority: todolist_model.PriorityNone},
+	}
+	if !reflect.DeepEqual(file.Todos, expected) {
+		t.Fatalf("got %+v, expected %+v", file.Todos, expected)
+	}
+}
+
+func TestParseTodoistCSVWithoutColumns(t *testing.T) {
+	_, err := ParseImport(todolist_domain.ImportTodoist, strings.NewReader("a,b\n1,2\n"))
+	if !errors.Is(err, todolist_domain.ErrImportFile) {
+		t.Fatalf("got %v, expected %v", err, todolist_domain.ErrImportFile)
+	}
+}
+
+func TestParseEverdExport(t *testing.T) {
+	for _, format := range []todolist_domain.ExportFormat{todolist_domain.ExportJSON, todolist_domain.ExportNDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			todos := exportTodos()
+			deleted := todos[1].CreatedAt
+			todos[1].DeletedAt = &deleted
+			todos = append(todos, todolist_domain.ExportTodo{ID: 3, Title: "broken", Priority: "asap", Position: "a"})
+
+			file, err := ParseImport(todolist_domain.ImportEverd, bytes.NewReader(writeExport(t, format, todos...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first := 1
+			if format == todolist_domain.ExportNDJSON {
+				first = 2
+			}
+
+			if len(file.Tags) != 1 || file.Tags[0] != (todolist_domain.ImportTag{Name: "work", Color: "#ff0000"}) {
+				t.Fatalf("unexpected tags %+v", file.Tags)
+			}
+
+			if len(file.Errors) != 1 || file.Errors[0].Line != first+2 {
+				t.Fatalf("unexpected errors %+v", file.Errors)
+			}
+
+			// ordered by position
+			if len(file.Todos) != 2 {
+				t.Fatalf("got %d todos, expected 2", len(file.Todos))
+			}
+			if file.Todos[0].Title != "write, report" || file.Todos[0].Line != first || file.Todos[0].Priority != todolist_model.PriorityHigh {
+				t.Fatalf("unexpected todo %+v", file.Todos[0])
+			}
+			if file.Todos[1].Title != "buy milk" || !file.Todos[1].Done || !file.Todos[1].Deleted {
+				t.Fatalf("unexpected todo %+v", file.Todos[1])
+			}
+		})
+	}
+}
+
+func TestParseEverdExportRejectsOtherFiles(t *testing.T) {
+	for _, input := range []string{
+		`{"format":"other","version":1}`,
+		`{"format":"everd","version":99}`,
+		`not json`,
+	} {
+		_, err := ParseImport(todolist_domain.ImportEverd, strings.NewReader(input))
+		if !errors.Is(err, todolist_domain.ErrImportFile) {
+			t.Fatalf("%s: got %v, expected %v", input, err, todolist_domain.ErrImportFile)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package todolist_infrastructure
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+// markdownIndent indents comment under checklist item, it is the width of
+// list marker "- ".
+const markdownIndent = "  "
+
+// markdownItem matches GitHub task list item, e.g. "- [x] title" or
+// "1. [ ] title".
+var markdownItem = regexp.MustCompile(`^(?:[-*+]|\d+[.)])[ \t]+\[([ xX])\](?:[ \t]+(.*))?$`)
+
+// RenderMarkdown writes todos as GitHub task list, comment is indented
+// under its todo.
+func RenderMarkdown(w io.Writer, todos []todolist_model.TodoPF) error {
+	bw := bufio.NewWriter(w)
+
+	for _, todo := range todos {
+		mark := " "
+		if todo.Done {
+			mark = "x"
+		}
+
+		bw.WriteString("- [" + mark + "] " + strings.Join(strings.Fields(todo.Title), " ") + "\n")
+
+		if todo.Comment == "" {
+			continue
+		}
+		for _, line := range strings.Split(strings.ReplaceAll(todo.Comment, "\r\n", "\n"), "\n") {
+			if strings.TrimSpace(line) == "" {
+				bw.WriteString("\n")
+				continue
+			}
+			bw.WriteString(markdownIndent + line + "\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ParseMarkdown reads task list items of markdown document, text indented
+// under item is its comment. Todos can not be nested, so nested items are
+// read as todos following their parent. Other content is ignored.
+func ParseMarkdown(r io.Reader) ([]todolist_domain.MarkdownTodo, error) {
+	var (
+		items   []todolist_domain.MarkdownTodo
+		current *todolist_domain.MarkdownTodo
+		indent  int
+		comment []string
+		blank   int
+	)
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+
+		current.Comment = strings.Join(comment, "\n")
+		items = append(items, *current)
+		current, comment, blank = nil, nil, 0
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(expandMarkdownTabs(scanner.Text()), " \r")
+		trimmed := strings.TrimLeft(text, " ")
+		lineIndent := len(text) - len(trimmed)
+
+		if trimmed == "" {
+			if current != nil && len(comment) > 0 {
+				blank++
+			}
+			continue
+		}
+
+		if match := markdownItem.FindStringSubmatch(trimmed); match != nil {
+			finish()
+			current = &todolist_domain.MarkdownTodo{
+				Line:  line,
+				Title: strings.TrimSpace(match[2]),
+				Done:  match[1] != " ",
+			}
+			indent = lineIndent
+			continue
+		}
+
+		if current == nil || lineIndent <= indent {
+			finish()
+			continue
+		}
+
+		// keep indentation relative to the item content
+		dedent := min(lineIndent, indent+len(markdownIndent))
+		for ; blank > 0; blank-- {
+			comment = append(comment, "")
+		}
+		comment = append(comment, text[dedent:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, importFileError(err)
+	}
+	finish()
+
+	return items, nil
+}
+
+func expandMarkdownTabs(line string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	prefix := line[:len(line)-len(trimmed)]
+	if !strings.Contains(prefix, "\t") {
+		return line
+	}
+
+	return strings.ReplaceAll(prefix, "\t", "    ") + trimmed
+}
@@ -0,0 +1,80 @@
+package todolist_infrastructure
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	input := strings.Join([]string{
+		"# Release",
+		"",
+		"Some notes.",
+		"",
+		"- [ ] write changelog",
+		"  mention api",
+		"",
+		"      code block",
+		"  - [x] nested item",
+		"    nested comment",
+		"* [X] star item",
+		"1. [ ] numbered item",
+		"- plain item",
+		"  not a comment",
+		"\t- [ ] tab item",
+		"- [ ]",
+	}, "\n")
+
+	items, err := ParseMarkdown(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []todolist_domain.MarkdownTodo{
+		{Line: 5, Title: "write changelog", Comment: "mention api\n\n    code block"},
+		{Line: 9, Title: "nested item", Comment: "nested comment", Done: true},
+		{Line: 11, Title: "star item", Done: true},
+		{Line: 12, Title: "numbered item"},
+		{Line: 15, Title: "tab item"},
+		{Line: 16, Title: ""},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Fatalf("got %+v, expected %+v", items, expected)
+	}
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	todos := []todolist_model.TodoPF{
+		{Title: "write changelog", Comment: "first line\n\n  indented line"},
+		{Title: "release", Done: true},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderMarkdown(&buf, todos); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedMarkdown := "- [ ] write changelog\n  first line\n\n    indented line\n- [x] release\n"
+	if buf.String() != expectedMarkdown {
+		t.Fatalf("got %q, expected %q", buf.String(), expectedMarkdown)
+	}
+
+	items, err := ParseMarkdown(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != len(todos) {
+		t.Fatalf("got %d items, expected %d", len(items), len(todos))
+	}
+	for i, item := range items {
+		if item.Title != todos[i].Title || item.Comment != todos[i].Comment || item.Done != todos[i].Done {
+			t.Fatalf("got %+v, expected %+v", item, todos[i])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package todolist_infrastructure
+
+import (
+	"encoding/json"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/pubsub"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/realtime"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// UserTopic is pubsub topic of todolist events of user, messages carry
+// EventPayload.
+func UserTopic(userID access_domain.UserID) string {
+	return "todolist:" + userKey(userID)
+}
+
+// HubNotifier publishes committed events to pubsub hub.
+type HubNotifier struct {
+	hub    *pubsub.Hub
+	logger util.Logger
+}
+
+func NewHubNotifier(hub *pubsub.Hub, logger util.Logger) *HubNotifier {
+	return &HubNotifier{hub: hub, logger: logger}
+}
+
+var _ todolist_domain.EventNotifier = (*HubNotifier)(nil)
+
+func (n *HubNotifier) Notify(events []todolist_model.Event) {
+	for _, event := range events {
+		payload, err := json.Marshal(toEventPayload(event))
+		if err != nil {
+			n.logger.WithError(err).Error("failed to marshal event")
+			continue
+		}
+
+		n.hub.Publish(UserTopic(event.UserID), payload)
+	}
+}
+
+// RealtimeEventMessage is websocket message with todolist event, list is id
+// of todolist owner.
+type RealtimeEventMessage struct {
+	Type  string       `json:"type"`
+	List  int          `json:"list"`
+	Event EventPayload `json:"event"`
+}
+
+// RealtimeNotifier broadcasts committed events to websocket clients
+// subscribed to todolist room, see UserTopic.
+type RealtimeNotifier struct {
+	hub    realtime.Hub
+	logger util.Logger
+}
+
+func NewRealtimeNotifier(hub realtime.Hub, logger util.Logger) *RealtimeNotifier {
+	return &RealtimeNotifier{hub: hub, logger: logger}
+}
+
+var _ todolist_domain.EventNotifier = (*RealtimeNotifier)(nil)
+
+func (n *RealtimeNotifier) Notify(events []todolist_model.Event) {
+	for _, event := range events {
+		message, err := json.Marshal(RealtimeEventMessage{
+			Type:  "event",
+			List:  int(event.UserID),
+			Event: toEventPayload(event),
+		})
+		if err != nil {
+			n.logger.WithError(err).Error("failed to marshal event")
+			continue
+		}
+
+		n.hub.Broadcast(UserTopic(event.UserID), message, nil)
+	}
+}
@@ -0,0 +1,91 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/outbox"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// EventTopic is outbox topic of todolist events, messages are keyed by user
+// id and carry EventPayload.
+const EventTopic = "todolist.events"
+
+type EventPayload struct {
+	Name       string    `json:"name"`
+	UserID     int       `json:"user_id"`
+	TodoID     int       `json:"todo_id"`
+	OldValue   string    `json:"old_value"`
+	NewValue   string    `json:"new_value"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func toEventPayload(event todolist_model.Event) EventPayload {
+	return EventPayload{
+		Name:       string(event.Name),
+		UserID:     int(event.UserID),
+		TodoID:     event.TodoID.Int(),
+		OldValue:   event.OldValue,
+		NewValue:   event.NewValue,
+		OccurredAt: event.OccurredAt,
+	}
+}
+
+// DecodeEventMessage decodes todolist event from outbox message.
+func DecodeEventMessage(message outbox.Message) (todolist_model.Event, error) {
+	var payload EventPayload
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
+		return todolist_model.Event{}, err
+	}
+
+	return fromEventDTO(EventDTO{
+		Name:       payload.Name,
+		UserID:     payload.UserID,
+		TodoID:     payload.TodoID,
+		OldValue:   payload.OldValue,
+		NewValue:   payload.NewValue,
+		OccurredAt: payload.OccurredAt,
+	})
+}
+
+type OutboxEventWriter struct {
+	store outbox.Store
+}
+
+func NewOutboxEventWriter(store outbox.Store) *OutboxEventWriter {
+	return &OutboxEventWriter{store: store}
+}
+
+var _ todolist_domain.EventOutbox = (*OutboxEventWriter)(nil)
+
+func (w *OutboxEventWriter) Enqueue(
+	ctx context.Context,
+	events []todolist_model.Event,
+	tx util.Transaction,
+) error {
+	messages := make([]outbox.Message, len(events))
+	for i, event := range events {
+		payload, err := json.Marshal(toEventPayload(event))
+		if err != nil {
+			return err
+		}
+
+		messages[i] = outbox.Message{
+			Topic:   EventTopic,
+			Key:     userKey(event.UserID),
+			Payload: payload,
+		}
+	}
+
+	return w.store.Enqueue(ctx, tx, messages...)
+}
+
+func userKey(userID access_domain.UserID) string {
+	return strconv.Itoa(int(userID))
+}
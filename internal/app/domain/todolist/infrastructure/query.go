@@ -0,0 +1,510 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const cursorTimeFormat = "2006-01-02 15:04:05.999999"
+
+type PostrgesTodoQueryRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesTodoQueryRepository(db *sql.DB) *PostrgesTodoQueryRepository {
+	return &PostrgesTodoQueryRepository{db: db}
+}
+
+var _ todolist_domain.TodoQueryRepository = (*PostrgesTodoQueryRepository)(nil)
+
+// todoCursor is the position after the last todo of a page. It is encoded
+// as base64 json, so clients treat it as an opaque string.
+type todoCursor struct {
+	Sort   string    `json:"s"`
+	Now    time.Time `json:"n"`
+	Values []string  `json:"v"`
+}
+
+func encodeTodoCursor(cursor todoCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeTodoCursor(value string) (todoCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return todoCursor{}, todolist_domain.ErrInvalidCursor
+	}
+
+	var cursor todoCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return todoCursor{}, todolist_domain.ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// parseTodoCursor decodes cursor of a page ordered by todoSort.
+func parseTodoCursor(value string, todoSort todolist_model.TodoSort) (todoCursor, error) {
+	cursor, err := decodeTodoCursor(value)
+	if err != nil {
+		return todoCursor{}, err
+	}
+
+	if cursor.Sort != todoSort.String() {
+		return todoCursor{}, todolist_domain.ErrInvalidCursor
+	}
+
+	// values are cast in query, so value postgres can not cast would fail
+	// query instead of being rejected as invalid cursor. Only casts of
+	// columns are used here.
+	columns := sortColumns(todoSort, cursor.Now, "")
+	if len(cursor.Values) != len(columns) {
+		return todoCursor{}, todolist_domain.ErrInvalidCursor
+	}
+	for i, column := range columns {
+		if !validCursorValue(column.cast, cursor.Values[i]) {
+			return todoCursor{}, todolist_domain.ErrInvalidCursor
+		}
+	}
+
+	return cursor, nil
+}
+
+// validCursorValue reports whether value can be cast to type of column.
+func validCursorValue(cast string, value string) bool {
+	switch cast {
+	case "integer":
+		_, err := strconv.ParseInt(value, 10, 32)
+		return err == nil
+	case "smallint":
+		_, err := strconv.ParseInt(value, 10, 16)
+		return err == nil
+	case "boolean":
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case "timestamp":
+		if value == "infinity" || value == "-infinity" {
+			return true
+		}
+		// postgres has no year 0
+		parsed, err := time.Parse(cursorTimeFormat, value)
+		return err == nil && parsed.Year() > 0
+	case "text":
+		// postgres text can not hold NUL
+		return !strings.ContainsRune(value, 0)
+	default:
+		return false
+	}
+}
+
+// sortColumn is a single key of keyset pagination.
+type sortColumn struct {
+	expr  string
+	cast  string
+	desc  bool
+	value func(todo todolist_model.TodoPF) string
+}
+
+func formatCursorTime(t time.Time) string {
+	return t.UTC().Format(cursorTimeFormat)
+}
+
+func dueColumn(desc bool) sortColumn {
+	// todos without due date go last in both directions
+	missing := "infinity"
+	if desc {
+		missing = "-infinity"
+	}
+
+	return sortColumn{
+		expr: fmt.Sprintf("coalesce(todos.due_at, '%s'::timestamp)", missing),
+		cast: "timestamp",
+		desc: desc,
+		value: func(todo todolist_model.TodoPF) string {
+			if todo.DueAt == nil {
+				return missing
+			}
+			return formatCursorTime(*todo.DueAt)
+		},
+	}
+}
+
+// smartScoreExpr mirrors todolist_model.SmartScore.
+func smartScoreExpr(now string) string {
+	var due strings.Builder
+	fmt.Fprintf(&due, "when todos.due_at < %s then %d", now, todolist_model.SmartOverdueWeight)
+	for _, window := range todolist_model.SmartDueWindows {
+		fmt.Fprintf(&due, "\n\t\t\twhen todos.due_at <= %s + interval '%d seconds' then %d",
+			now, int64(window.Within/time.Second), window.Weight)
+	}
+
+	return fmt.Sprintf(`(case when todos.done then 0 else
+		todos.priority * %d +
+		case
+			when todos.due_at is null then 0
+			%s
+			else 0
+		end
+	end)`,
+		todolist_model.SmartPriorityWeight,
+		due.String(),
+	)
+}
+
+func sortColumns(todoSort todolist_model.TodoSort, now time.Time, nowParam string) []sortColumn {
+	var columns []sortColumn
+
+	if todoSort.Smart {
+		columns = append(columns,
+			sortColumn{
+				expr: "todos.done",
+				cast: "boolean",
+				value: func(todo todolist_model.TodoPF) string {
+					return strconv.FormatBool(todo.Done)
+				},
+			},
+			sortColumn{
+				expr: smartScoreExpr(nowParam),
+				cast: "integer",
+				desc: true,
+				value: func(todo todolist_model.TodoPF) string {
+					return strconv.Itoa(todolist_model.SmartScore(todo, now))
+				},
+			},
+			dueColumn(false),
+		)
+	}
+
+	for _, key := range todoSort.Keys {
+		switch key.Field {
+		case todolist_model.SortByPriority:
+			columns = append(columns, sortColumn{
+				expr: "todos.priority",
+				cast: "smallint",
+				desc: key.Desc,
+				value: func(todo todolist_model.TodoPF) string {
+					return strconv.Itoa(todo.Priority.Int())
+				},
+			})
+		case todolist_model.SortByDue:
+			columns = append(columns, dueColumn(key.Desc))
+		case todolist_model.SortByCreated:
+			columns = append(columns, sortColumn{
+				expr: "todos.created_at",
+				cast: "timestamp",
+				desc: key.Desc,
+				value: func(todo todolist_model.TodoPF) string {
+					return formatCursorTime(todo.CreatedAt)
+				},
+			})
+		case todolist_model.SortByUpdated:
+			columns = append(columns, sortColumn{
+				expr: "todos.updated_at",
+				cast: "timestamp",
+				desc: key.Desc,
+				value: func(todo todolist_model.TodoPF) string {
+					return formatCursorTime(todo.UpdatedAt)
+				},
+			})
+		case todolist_model.SortByPosition:
+			columns = append(columns, sortColumn{
+				// positions are compared bytewise, see todolist_model.Position
+				expr: `todos.position collate "C"`,
+				cast: "text",
+				desc: key.Desc,
+				value: func(todo todolist_model.TodoPF) string {
+					return todo.Position.String()
+				},
+			})
+		}
+	}
+
+	// id makes order total, so keyset never skips or repeats todos
+	return append(columns, sortColumn{
+		expr: "todos.id",
+		cast: "integer",
+		value: func(todo todolist_model.TodoPF) string {
+			return strconv.Itoa(todo.ID.Int())
+		},
+	})
+}
+
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (b *queryBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *queryBuilder) where(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+// keysetCondition matches todos going after the todo with values of
+// columns in their order.
+func keysetCondition(b *queryBuilder, columns []sortColumn, values []string) (string, error) {
+	if len(values) != len(columns) {
+		return "", todolist_domain.ErrInvalidCursor
+	}
+
+	// (c1 > v1) or (c1 = v1 and c2 > v2) or ...
+	var keyset []string
+	for i, column := range columns {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s = %s::%s", columns[j].expr, b.arg(values[j]), columns[j].cast))
+		}
+
+		op := ">"
+		if column.desc {
+			op = "<"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s::%s", column.expr, op, b.arg(values[i]), column.cast))
+
+		keyset = append(keyset, "("+strings.Join(parts, " and ")+")")
+	}
+
+	return "(" + strings.Join(keyset, " or ") + ")", nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func (r *PostrgesTodoQueryRepository) Query(
+	ctx context.Context,
+	userID access_domain.UserID,
+	query todolist_domain.TodoQuery,
+	tx util.Transaction,
+) (todolist_domain.TodoPage, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return todolist_domain.TodoPage{}, err
+	}
+
+	cursor := todoCursor{Sort: query.Sort.String(), Now: time.Now().UTC()}
+	if query.Cursor != "" {
+		cursor, err = parseTodoCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return todolist_domain.TodoPage{}, err
+		}
+	}
+
+	var b queryBuilder
+	b.where("todolist.user_id = " + b.arg(userID))
+
+	switch {
+	case query.Trashed:
+		b.where("todos.deleted_at is not null")
+	case query.Archived:
+		b.where("todos.deleted_at is null")
+		b.where("todos.archived_at is not null")
+	default:
+		b.where("todos.deleted_at is null")
+		b.where("todos.archived_at is null")
+	}
+
+	if query.Done != nil {
+		b.where("todos.done = " + b.arg(*query.Done))
+	}
+
+	if query.Search != "" {
+		pattern := b.arg("%" + escapeLike(query.Search) + "%")
+		b.where(fmt.Sprintf("(todos.title ilike %[1]s or todos.comment ilike %[1]s)", pattern))
+	}
+
+	if query.CreatedAfter != nil {
+		b.where("todos.created_at >= " + b.arg(*query.CreatedAfter))
+	}
+	if query.CreatedBefore != nil {
+		b.where("todos.created_at < " + b.arg(*query.CreatedBefore))
+	}
+	if query.UpdatedAfter != nil {
+		b.where("todos.updated_at >= " + b.arg(*query.UpdatedAfter))
+	}
+	if query.UpdatedBefore != nil {
+		b.where("todos.updated_at < " + b.arg(*query.UpdatedBefore))
+	}
+
+	if len(query.Tags) > 0 {
+		names := make([]string, len(query.Tags))
+		for i, name := range query.Tags {
+			names[i] = b.arg(name)
+		}
+
+		tagged := `select count(distinct tags.name) from todo_tags
+			join tags on tags.id = todo_tags.tag_id
+			where todo_tags.todo_id = todos.id
+			and tags.name in (` + strings.Join(names, ", ") + `)`
+
+		if query.TagMatch == todolist_model.TagMatchAny {
+			b.where("(" + tagged + ") > 0")
+		} else {
+			b.where(fmt.Sprintf("(%s) = %d", tagged, len(uniqueStrings(query.Tags))))
+		}
+	}
+
+	columns := sortColumns(query.Sort, cursor.Now, b.arg(cursor.Now.Format(cursorTimeFormat))+"::timestamp")
+
+	if query.Cursor != "" {
+		keyset, err := keysetCondition(&b, columns, cursor.Values)
+		if err != nil {
+			return todolist_domain.TodoPage{}, err
+		}
+		b.where(keyset)
+	}
+
+	orderBy := make([]string, len(columns))
+	for i, column := range columns {
+		orderBy[i] = column.expr + " asc"
+		if column.desc {
+			orderBy[i] = column.expr + " desc"
+		}
+	}
+
+	rows, err := exec.Query(`select `+todoColumns+`
+		from todolist
+		join todos on todolist.todo_id = todos.id
+		where `+strings.Join(b.conditions, " and ")+`
+		order by `+strings.Join(orderBy, ", ")+`
+		limit `+b.arg(query.Limit+1), b.args...)
+	if err != nil {
+		return todolist_domain.TodoPage{}, err
+	}
+	defer rows.Close()
+
+	var todoDTOs []TodoDTO
+	for rows.Next() {
+		todoDTO, err := scanTodoDTO(rows)
+		if err != nil {
+			return todolist_domain.TodoPage{}, err
+		}
+
+		todoDTOs = append(todoDTOs, todoDTO)
+	}
+	if err := rows.Err(); err != nil {
+		return todolist_domain.TodoPage{}, err
+	}
+
+	hasMore := len(todoDTOs) > query.Limit
+	if hasMore {
+		todoDTOs = todoDTOs[:query.Limit]
+	}
+
+	todoTags, err := getTodoTagsByIDs(exec, todoDTOs)
+	if err != nil {
+		return todolist_domain.TodoPage{}, err
+	}
+
+	page := todolist_domain.TodoPage{
+		Todos: make([]todolist_model.TodoPF, len(todoDTOs)),
+	}
+
+	for i, todoDTO := range todoDTOs {
+		todo, err := fromTodoDTO(todoDTO, todoTags[todoDTO.ID])
+		if err != nil {
+			return todolist_domain.TodoPage{}, err
+		}
+
+		page.Todos[i] = todo.PF()
+	}
+
+	tags, err := getTags(exec, userID)
+	if err != nil {
+		return todolist_domain.TodoPage{}, err
+	}
+
+	page.Tags = make([]todolist_model.TagPF, len(tags))
+	for i, tag := range tags {
+		page.Tags[i] = tag.PF()
+	}
+
+	if hasMore {
+		last := page.Todos[len(page.Todos)-1]
+
+		next := todoCursor{Sort: cursor.Sort, Now: cursor.Now}
+		for _, column := range columns {
+			next.Values = append(next.Values, column.value(last))
+		}
+
+		page.NextCursor, err = encodeTodoCursor(next)
+		if err != nil {
+			return todolist_domain.TodoPage{}, err
+		}
+	}
+
+	return page, nil
+}
+
+func getTodoTagsByIDs(
+	exec storage.Executor,
+	todoDTOs []TodoDTO,
+) (map[int][]todolist_model.TagID, error) {
+	todoTags := map[int][]todolist_model.TagID{}
+	if len(todoDTOs) == 0 {
+		return todoTags, nil
+	}
+
+	todoIDs := make([]any, len(todoDTOs))
+	for i, todoDTO := range todoDTOs {
+		todoIDs[i] = todoDTO.ID
+	}
+
+	rows, err := exec.Query(`select todo_id, tag_id from todo_tags
+		where todo_id in (`+storage.Placeholders(1, len(todoIDs))+`)
+		order by tag_id`, todoIDs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID, tagIDInt int
+		if err := rows.Scan(&todoID, &tagIDInt); err != nil {
+			return nil, err
+		}
+
+		tagID, err := todolist_model.NewTagID(tagIDInt)
+		if err != nil {
+			return nil, err
+		}
+
+		todoTags[todoID] = append(todoTags[todoID], tagID)
+	}
+
+	return todoTags, rows.Err()
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		unique = append(unique, value)
+	}
+
+	return unique
+}
@@ -0,0 +1,332 @@
+package todolist_infrastructure
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+func TestTodoCursorRoundTrip(t *testing.T) {
+	todoSort := todolist_model.TodoSort{Keys: []todolist_model.SortKey{{Field: todolist_model.SortByPriority, Desc: true}}}
+	cursor := todoCursor{
+		Sort:   todoSort.String(),
+		Now:    time.Date(2024, 11, 20, 12, 0, 0, 500, time.UTC),
+		Values: []string{"3", "42"},
+	}
+
+	value, err := encodeTodoCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := parseTodoCursor(value, todoSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Sort != cursor.Sort || !got.Now.Equal(cursor.Now) || !reflect.DeepEqual(got.Values, cursor.Values) {
+		t.Fatalf("got %+v, want %+v", got, cursor)
+	}
+
+	otherSort, err := encodeTodoCursor(todoCursor{Sort: "created", Now: cursor.Now, Values: cursor.Values})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, value := range map[string]string{
+		"not base64": "not a cursor!",
+		"not json":   base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		"other sort": otherSort,
+	} {
+		if _, err := parseTodoCursor(value, todoSort); !errors.Is(err, todolist_domain.ErrInvalidCursor) {
+			t.Fatalf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+	}
+}
+
+func TestTodoCursorValues(t *testing.T) {
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		sort   string
+		values []string
+		valid  bool
+	}{
+		{"-priority", []string{"3", "42"}, true},
+		{"-priority", []string{"3"}, false},
+		{"-priority", []string{"3", "42", "1"}, false},
+		{"-priority", []string{"high", "42"}, false},
+		{"-priority", []string{"3", "1.5"}, false},
+		{"-priority", []string{"40000", "42"}, false},
+		{"-priority", []string{"3", "3000000000"}, false},
+		{"due", []string{"2024-11-20 13:00:00.5", "7"}, true},
+		{"due", []string{"infinity", "7"}, true},
+		{"due", []string{"tomorrow", "7"}, false},
+		{"due", []string{"2024-11-20T13:00:00Z", "7"}, false},
+		{"due", []string{"0000-01-01 00:00:00", "7"}, false},
+		{"position", []string{"m", "7"}, true},
+		{"position", []string{"m\x00", "7"}, false},
+		{"smart", []string{"false", "55", "infinity", "7"}, true},
+		{"smart", []string{"no", "55", "infinity", "7"}, false},
+		{"smart", []string{"false", "55", "7", "infinity"}, false},
+	}
+
+	for _, tt := range tests {
+		todoSort, err := todolist_model.ParseTodoSort(tt.sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := encodeTodoCursor(todoCursor{Sort: todoSort.String(), Now: now, Values: tt.values})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = parseTodoCursor(value, todoSort)
+		if tt.valid && err != nil {
+			t.Errorf("%s %q: %v", tt.sort, tt.values, err)
+		}
+		if !tt.valid && !errors.Is(err, todolist_domain.ErrInvalidCursor) {
+			t.Errorf("%s %q: expected ErrInvalidCursor, got %v", tt.sort, tt.values, err)
+		}
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	due := now.Add(time.Hour)
+	position, err := todolist_model.NewPosition("m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo := todolist_model.TodoPF{
+		ID:        7,
+		Priority:  todolist_model.PriorityHigh,
+		DueAt:     &due,
+		CreatedAt: now.Add(-time.Hour),
+		UpdatedAt: now.Add(-time.Minute),
+		Position:  position,
+	}
+
+	tests := []struct {
+		sort string
+		want string
+		args []any
+	}{
+		{
+			"-priority",
+			"((todos.priority < $1::smallint) or (todos.priority = $2::smallint and todos.id > $3::integer))",
+			[]any{"3", "3", "7"},
+		},
+		{
+			"due",
+			"((coalesce(todos.due_at, 'infinity'::timestamp) > $1::timestamp) or " +
+				"(coalesce(todos.due_at, 'infinity'::timestamp) = $2::timestamp and todos.id > $3::integer))",
+			[]any{"2024-11-20 13:00:00", "2024-11-20 13:00:00", "7"},
+		},
+		{
+			"-due",
+			"((coalesce(todos.due_at, '-infinity'::timestamp) < $1::timestamp) or " +
+				"(coalesce(todos.due_at, '-infinity'::timestamp) = $2::timestamp and todos.id > $3::integer))",
+			[]any{"2024-11-20 13:00:00", "2024-11-20 13:00:00", "7"},
+		},
+		{
+			"created",
+			"((todos.created_at > $1::timestamp) or (todos.created_at = $2::timestamp and todos.id > $3::integer))",
+			[]any{"2024-11-20 11:00:00", "2024-11-20 11:00:00", "7"},
+		},
+		{
+			"-updated",
+			"((todos.updated_at < $1::timestamp) or (todos.updated_at = $2::timestamp and todos.id > $3::integer))",
+			[]any{"2024-11-20 11:59:00", "2024-11-20 11:59:00", "7"},
+		},
+		{
+			"position",
+			`((todos.position collate "C" > $1::text) or (todos.position collate "C" = $2::text and todos.id > $3::integer))`,
+			[]any{"m", "m", "7"},
+		},
+		{
+			"smart",
+			"((todos.done > $1::boolean) or " +
+				"(todos.done = $2::boolean and SCORE < $3::integer) or " +
+				"(todos.done = $4::boolean and SCORE = $5::integer and coalesce(todos.due_at, 'infinity'::timestamp) > $6::timestamp) or " +
+				"(todos.done = $7::boolean and SCORE = $8::integer and coalesce(todos.due_at, 'infinity'::timestamp) = $9::timestamp and todos.id > $10::integer))",
+			[]any{
+				"false",
+				"false", "55",
+				"false", "55", "2024-11-20 13:00:00",
+				"false", "55", "2024-11-20 13:00:00", "7",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			todoSort, err := todolist_model.ParseTodoSort(tt.sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			columns := sortColumns(todoSort, now, "now()")
+			values := make([]string, len(columns))
+			for i, column := range columns {
+				values[i] = column.value(todo)
+			}
+
+			var b queryBuilder
+			got, err := keysetCondition(&b, columns, values)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got = strings.ReplaceAll(got, smartScoreExpr("now()"), "SCORE")
+			if got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+			if !reflect.DeepEqual(b.args, tt.args) {
+				t.Fatalf("got args %q, want %q", b.args, tt.args)
+			}
+
+			// cursor of other sort has different number of values
+			if _, err := keysetCondition(&queryBuilder{}, columns, values[1:]); !errors.Is(err, todolist_domain.ErrInvalidCursor) {
+				t.Fatalf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSmartScoreExpr(t *testing.T) {
+	// windows of todolist_model.SmartDueWindows with the same bounds as
+	// todolist_model.SmartScore: overdue is strictly before now, windows
+	// include their end
+	want := `(case when todos.done then 0 else
+		todos.priority * 10 +
+		case
+			when todos.due_at is null then 0
+			when todos.due_at < $1 then 100
+			when todos.due_at <= $1 + interval '86400 seconds' then 25
+			when todos.due_at <= $1 + interval '259200 seconds' then 15
+			when todos.due_at <= $1 + interval '604800 seconds' then 5
+			else 0
+		end
+	end)`
+
+	if got := smartScoreExpr("$1"); strings.Join(strings.Fields(got), " ") != strings.Join(strings.Fields(want), " ") {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	for _, tt := range []struct {
+		due  *time.Time
+		want int
+	}{
+		{nil, 10},
+		{at(-time.Second), 110},
+		{at(0), 35},
+		{at(24 * time.Hour), 35},
+		{at(24*time.Hour + time.Second), 25},
+		{at(3 * 24 * time.Hour), 25},
+		{at(7 * 24 * time.Hour), 15},
+		{at(7*24*time.Hour + time.Second), 10},
+	} {
+		todo := todolist_model.TodoPF{Priority: todolist_model.PriorityLow, DueAt: tt.due}
+		if got := todolist_model.SmartScore(todo, now); got != tt.want {
+			t.Fatalf("due %v: got score %d, want %d", tt.due, got, tt.want)
+		}
+	}
+}
+
+// compareColumnValues compares cursor values the way postgres compares them
+// after cast of column.
+func compareColumnValues(t *testing.T, column sortColumn, a, b string) int {
+	t.Helper()
+
+	switch column.cast {
+	case "integer", "smallint":
+		x, err := strconv.Atoi(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err := strconv.Atoi(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return x - y
+	case "timestamp":
+		parse := func(value string) time.Time {
+			switch value {
+			case "infinity":
+				return time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+			case "-infinity":
+				return time.Time{}
+			}
+			parsed, err := time.Parse(cursorTimeFormat, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return parsed
+		}
+		return parse(a).Compare(parse(b))
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func TestSmartSortColumnsRankLikeSortTodos(t *testing.T) {
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	todos := []todolist_model.TodoPF{
+		{ID: 1, Priority: todolist_model.PriorityUrgent},
+		{ID: 2, Priority: todolist_model.PriorityLow, DueAt: at(-time.Hour)},
+		{ID: 3, Priority: todolist_model.PriorityHigh, DueAt: at(2 * time.Hour)},
+		{ID: 4, Priority: todolist_model.PriorityHigh, DueAt: at(48 * time.Hour)},
+		{ID: 5, Priority: todolist_model.PriorityUrgent, Done: true},
+		{ID: 6, Priority: todolist_model.PriorityHigh, DueAt: at(24 * time.Hour)},
+		{ID: 7, Priority: todolist_model.PriorityLow, DueAt: at(6 * 24 * time.Hour)},
+		{ID: 8, Priority: todolist_model.PriorityLow},
+		{ID: 9, Priority: todolist_model.PriorityLow, DueAt: at(-time.Hour)},
+	}
+
+	todoSort := todolist_model.TodoSort{Smart: true}
+	columns := sortColumns(todoSort, now, "now()")
+
+	want := append([]todolist_model.TodoPF(nil), todos...)
+	todolist_model.SortTodos(want, todoSort, now)
+
+	// order of query is order of its columns, keyset compares the same values
+	got := append([]todolist_model.TodoPF(nil), todos...)
+	sort.SliceStable(got, func(i, j int) bool {
+		for _, column := range columns {
+			c := compareColumnValues(t, column, column.value(got[i]), column.value(got[j]))
+			if column.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	for i := range want {
+		if got[i].ID != want[i].ID {
+			t.Fatalf("position %d: got todo %d, want %d", i, got[i].ID, want[i].ID)
+		}
+	}
+}
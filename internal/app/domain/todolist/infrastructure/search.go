@@ -0,0 +1,135 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// PostrgesSearchRepository searches todos with tsvector column maintained by
+// postgres, see migration of todos search_vector.
+type PostrgesSearchRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesSearchRepository(db *sql.DB) *PostrgesSearchRepository {
+	return &PostrgesSearchRepository{db: db}
+}
+
+var _ todolist_domain.SearchRepository = (*PostrgesSearchRepository)(nil)
+
+// toTSQuery converts user input into prefix tsquery like "rep:* & bob:*".
+// Tokens contain only letters and digits, so they are safe to use in tsquery.
+func toTSQuery(query string) string {
+	tokens := tokenize(query)
+
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.word + ":*"
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+func (r *PostrgesSearchRepository) Search(
+	ctx context.Context,
+	userID access_domain.UserID,
+	query string,
+	limit int,
+	tx util.Transaction,
+) (todolist_domain.SearchResult, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return todolist_domain.SearchResult{}, err
+	}
+
+	tags, err := getTags(exec, userID)
+	if err != nil {
+		return todolist_domain.SearchResult{}, err
+	}
+
+	result := todolist_domain.SearchResult{
+		Hits: []todolist_domain.SearchHit{},
+		Tags: make([]todolist_model.TagPF, len(tags)),
+	}
+	for i, tag := range tags {
+		result.Tags[i] = tag.PF()
+	}
+
+	tsQuery := toTSQuery(query)
+	if tsQuery == "" {
+		return result, nil
+	}
+
+	headline := fmt.Sprintf("StartSel=%s, StopSel=%s", headlineStart, headlineStop)
+
+	rows, err := exec.Query(`select `+todoColumns+`,
+		ts_rank(todos.search_vector, q) as rank,
+		ts_headline('simple', translate(todos.title, $6, ''), q, $3),
+		ts_headline('simple', translate(todos.comment, $6, ''), q, $4)
+		from todolist
+		join todos on todolist.todo_id = todos.id,
+		to_tsquery('simple', $2) q
+		where todolist.user_id = $1
+		and todos.deleted_at is null
+		and todos.archived_at is null
+		and todos.search_vector @@ q
+		order by rank desc, todos.id
+		limit $5`,
+		userID,
+		tsQuery,
+		headline+", HighlightAll=true",
+		fmt.Sprintf("%s, MaxWords=%d, MinWords=5", headline, searchSnippetWords),
+		limit,
+		headlineStart+headlineStop,
+	)
+	if err != nil {
+		return todolist_domain.SearchResult{}, err
+	}
+	defer rows.Close()
+
+	var (
+		todoDTOs []TodoDTO
+		hits     []todolist_domain.SearchHit
+	)
+	for rows.Next() {
+		var hit todolist_domain.SearchHit
+		todoDTO, err := scanTodoDTO(rows, &hit.Rank, &hit.TitleSnippet, &hit.CommentSnippet)
+		if err != nil {
+			return todolist_domain.SearchResult{}, err
+		}
+
+		hit.TitleSnippet = escapeHeadline(hit.TitleSnippet)
+		hit.CommentSnippet = escapeHeadline(hit.CommentSnippet)
+
+		todoDTOs = append(todoDTOs, todoDTO)
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return todolist_domain.SearchResult{}, err
+	}
+
+	todoTags, err := getTodoTagsByIDs(exec, todoDTOs)
+	if err != nil {
+		return todolist_domain.SearchResult{}, err
+	}
+
+	for i, todoDTO := range todoDTOs {
+		todo, err := fromTodoDTO(todoDTO, todoTags[todoDTO.ID])
+		if err != nil {
+			return todolist_domain.SearchResult{}, err
+		}
+
+		hits[i].Todo = todo.PF()
+		result.Hits = append(result.Hits, hits[i])
+	}
+
+	return result, nil
+}
@@ -0,0 +1,346 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	searchTitleWeight   = 1.0
+	searchCommentWeight = 0.4
+
+	searchSnippetWords = 20
+
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+
+	// headlineStart and headlineStop mark matches in ts_headline output until
+	// it is escaped, they are removed from text of todos beforehand.
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+var headlineMarkers = strings.NewReplacer(headlineStart, highlightStart, headlineStop, highlightStop)
+
+// escapeHeadline escapes HTML in ts_headline output and turns its match
+// markers into highlight markup.
+func escapeHeadline(headline string) string {
+	return headlineMarkers.Replace(html.EscapeString(headline))
+}
+
+type searchToken struct {
+	word  string
+	start int
+	end   int
+}
+
+// tokenize splits text into lower-cased words made of letters and digits.
+func tokenize(text string) []searchToken {
+	var tokens []searchToken
+
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+
+		if !isWord && start >= 0 {
+			tokens = append(tokens, searchToken{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, searchToken{strings.ToLower(text[start:]), start, len(text)})
+	}
+
+	return tokens
+}
+
+func matchesAnyPrefix(word string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// highlight wraps words of text matching any of prefixes. Text is HTML
+// escaped, so highlight markup is the only markup in result. With
+// maxWords > 0 only a window of maxWords words around the first match is
+// returned.
+func highlight(text string, prefixes []string, maxWords int) string {
+	tokens := tokenize(text)
+
+	from, to := 0, len(tokens)
+	if maxWords > 0 && len(tokens) > maxWords {
+		first := 0
+		for i, token := range tokens {
+			if matchesAnyPrefix(token.word, prefixes) {
+				first = i
+				break
+			}
+		}
+
+		from = max(0, first-maxWords/2)
+		to = min(len(tokens), from+maxWords)
+		from = max(0, to-maxWords)
+	}
+
+	if len(tokens) == 0 {
+		return html.EscapeString(text)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("... ")
+	}
+
+	offset := tokens[from].start
+	if from == 0 {
+		offset = 0
+	}
+	for _, token := range tokens[from:to] {
+		b.WriteString(html.EscapeString(text[offset:token.start]))
+		if matchesAnyPrefix(token.word, prefixes) {
+			b.WriteString(highlightStart + html.EscapeString(text[token.start:token.end]) + highlightStop)
+		} else {
+			b.WriteString(html.EscapeString(text[token.start:token.end]))
+		}
+		offset = token.end
+	}
+
+	if to < len(tokens) {
+		b.WriteString(" ...")
+	} else {
+		b.WriteString(html.EscapeString(text[offset:]))
+	}
+
+	return b.String()
+}
+
+type searchPosting struct {
+	title   int
+	comment int
+}
+
+// SearchIndex is an in-memory inverted index of todos with prefix matching.
+// It is not safe for concurrent use.
+type SearchIndex struct {
+	postings map[string]map[todolist_model.TodoID]*searchPosting
+	terms    []string
+	todos    map[todolist_model.TodoID]todolist_model.TodoPF
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: map[string]map[todolist_model.TodoID]*searchPosting{},
+		todos:    map[todolist_model.TodoID]todolist_model.TodoPF{},
+	}
+}
+
+func (idx *SearchIndex) Add(todo todolist_model.TodoPF) {
+	idx.Remove(todo.ID)
+	idx.todos[todo.ID] = todo
+
+	add := func(text string, inc func(*searchPosting)) {
+		for _, token := range tokenize(text) {
+			postings, ok := idx.postings[token.word]
+			if !ok {
+				postings = map[todolist_model.TodoID]*searchPosting{}
+				idx.postings[token.word] = postings
+
+				i := sort.SearchStrings(idx.terms, token.word)
+				idx.terms = append(idx.terms, "")
+				copy(idx.terms[i+1:], idx.terms[i:])
+				idx.terms[i] = token.word
+			}
+
+			posting, ok := postings[todo.ID]
+			if !ok {
+				posting = &searchPosting{}
+				postings[todo.ID] = posting
+			}
+			inc(posting)
+		}
+	}
+
+	add(todo.Title, func(p *searchPosting) { p.title++ })
+	add(todo.Comment, func(p *searchPosting) { p.comment++ })
+}
+
+func (idx *SearchIndex) Remove(todoID todolist_model.TodoID) {
+	if _, ok := idx.todos[todoID]; !ok {
+		return
+	}
+	delete(idx.todos, todoID)
+
+	terms := idx.terms[:0]
+	for _, term := range idx.terms {
+		delete(idx.postings[term], todoID)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+			continue
+		}
+		terms = append(terms, term)
+	}
+	idx.terms = terms
+}
+
+// prefixTerms returns indexed terms starting with prefix.
+func (idx *SearchIndex) prefixTerms(prefix string) []string {
+	from := sort.SearchStrings(idx.terms, prefix)
+	to := from
+	for to < len(idx.terms) && strings.HasPrefix(idx.terms[to], prefix) {
+		to++
+	}
+
+	return idx.terms[from:to]
+}
+
+func (idx *SearchIndex) Search(query string, limit int) []todolist_domain.SearchHit {
+	var prefixes []string
+	for _, token := range tokenize(query) {
+		prefixes = append(prefixes, token.word)
+	}
+
+	if len(prefixes) == 0 {
+		return []todolist_domain.SearchHit{}
+	}
+
+	var ranks map[todolist_model.TodoID]float64
+	for _, prefix := range prefixes {
+		prefixRanks := map[todolist_model.TodoID]float64{}
+		for _, term := range idx.prefixTerms(prefix) {
+			for todoID, posting := range idx.postings[term] {
+				prefixRanks[todoID] += searchTitleWeight*float64(posting.title) +
+					searchCommentWeight*float64(posting.comment)
+			}
+		}
+
+		// every word of query must match
+		if ranks == nil {
+			ranks = prefixRanks
+			continue
+		}
+		for todoID, rank := range ranks {
+			prefixRank, ok := prefixRanks[todoID]
+			if !ok {
+				delete(ranks, todoID)
+				continue
+			}
+			ranks[todoID] = rank + prefixRank
+		}
+	}
+
+	hits := make([]todolist_domain.SearchHit, 0, len(ranks))
+	for todoID, rank := range ranks {
+		todo := idx.todos[todoID]
+		hits = append(hits, todolist_domain.SearchHit{
+			Todo:           todo,
+			Rank:           rank,
+			TitleSnippet:   highlight(todo.Title, prefixes, 0),
+			CommentSnippet: highlight(todo.Comment, prefixes, searchSnippetWords),
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].Todo.ID < hits[j].Todo.ID
+	})
+
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits
+}
+
+type userSearchIndex struct {
+	fingerprint string
+	index       *SearchIndex
+}
+
+// IndexedSearchRepository implements search on top of any todolist
+// repository with SearchIndex. Index of user is rebuilt when todolist
+// changes.
+type IndexedSearchRepository struct {
+	todolistRepo todolist_domain.TodolistRepository
+
+	mu      sync.Mutex
+	indexes map[access_domain.UserID]userSearchIndex
+}
+
+func NewIndexedSearchRepository(todolistRepo todolist_domain.TodolistRepository) *IndexedSearchRepository {
+	return &IndexedSearchRepository{
+		todolistRepo: todolistRepo,
+		indexes:      map[access_domain.UserID]userSearchIndex{},
+	}
+}
+
+var _ todolist_domain.SearchRepository = (*IndexedSearchRepository)(nil)
+
+func todolistFingerprint(todolistPF todolist_model.TodolistPF) string {
+	var updatedAt time.Time
+	for _, todo := range todolistPF.Todos {
+		if todo.UpdatedAt.After(updatedAt) {
+			updatedAt = todo.UpdatedAt
+		}
+	}
+
+	return fmt.Sprintf("%d:%d", len(todolistPF.Todos), updatedAt.UnixNano())
+}
+
+func (r *IndexedSearchRepository) Search(
+	ctx context.Context,
+	userID access_domain.UserID,
+	query string,
+	limit int,
+	tx util.Transaction,
+) (todolist_domain.SearchResult, error) {
+	todolist, err := r.todolistRepo.Get(ctx, userID, tx)
+	if err != nil {
+		return todolist_domain.SearchResult{}, err
+	}
+
+	todolistPF := todolist.PF()
+	fingerprint := todolistFingerprint(todolistPF)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userIndex, ok := r.indexes[userID]
+	if !ok || userIndex.fingerprint != fingerprint {
+		userIndex = userSearchIndex{
+			fingerprint: fingerprint,
+			index:       NewSearchIndex(),
+		}
+		for _, todo := range todolistPF.Todos {
+			if todo.DeletedAt == nil {
+				userIndex.index.Add(todo)
+			}
+		}
+		r.indexes[userID] = userIndex
+	}
+
+	return todolist_domain.SearchResult{
+		Hits: userIndex.index.Search(query, limit),
+		Tags: todolistPF.Tags,
+	}, nil
+}
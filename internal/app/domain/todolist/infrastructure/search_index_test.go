@@ -0,0 +1,69 @@
+package todolist_infrastructure
+
+import (
+	"testing"
+
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+)
+
+func TestSearchIndex(t *testing.T) {
+	idx := NewSearchIndex()
+	idx.Add(todolist_model.TodoPF{ID: 1, Title: "Write quarterly report", Comment: "send to Bob"})
+	idx.Add(todolist_model.TodoPF{ID: 2, Title: "Call Bob", Comment: "about the report"})
+	idx.Add(todolist_model.TodoPF{ID: 3, Title: "Buy milk"})
+
+	hits := idx.Search("rep", 10)
+	if len(hits) != 2 {
+		t.Fatalf("expected 2 hits, got %d", len(hits))
+	}
+
+	// title matches rank higher than comment matches
+	if hits[0].Todo.ID != 1 || hits[1].Todo.ID != 2 {
+		t.Fatalf("unexpected order: %d, %d", hits[0].Todo.ID, hits[1].Todo.ID)
+	}
+
+	if got, want := hits[0].TitleSnippet, "Write quarterly <mark>report</mark>"; got != want {
+		t.Fatalf("got title snippet %q, want %q", got, want)
+	}
+
+	if got, want := hits[1].CommentSnippet, "about the <mark>report</mark>"; got != want {
+		t.Fatalf("got comment snippet %q, want %q", got, want)
+	}
+
+	// every word must match
+	if hits := idx.Search("bob milk", 10); len(hits) != 0 {
+		t.Fatalf("expected no hits, got %d", len(hits))
+	}
+
+	idx.Remove(1)
+	if hits := idx.Search("quarterly", 10); len(hits) != 0 {
+		t.Fatalf("expected removed todo not to match, got %d hits", len(hits))
+	}
+}
+
+func TestHighlightWindow(t *testing.T) {
+	text := "one two three four five six seven eight nine ten"
+
+	got := highlight(text, []string{"six"}, 4)
+	if want := "... four five <mark>six</mark> seven ..."; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestHighlightEscapesHTML(t *testing.T) {
+	text := `<img src=x onerror="alert(1)"> & report`
+
+	got := highlight(text, []string{"rep", "img"}, 0)
+	want := `&lt;<mark>img</mark> src=x onerror=&#34;alert(1)&#34;&gt; &amp; <mark>report</mark>`
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEscapeHeadline(t *testing.T) {
+	headline := "<b>" + headlineStart + "report" + headlineStop + "</b>"
+
+	if got, want := escapeHeadline(headline), "&lt;b&gt;<mark>report</mark>&lt;/b&gt;"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package todolist_infrastructure
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -24,39 +25,117 @@ func NewPostrgesTodoRepository(db *sql.DB) *PostrgesTodoRepository {
 var _ todolist_domain.TodoRepository = (*PostrgesTodoRepository)(nil)
 
 type TodoDTO struct {
-	ID        int
-	Title     string
-	Comment   string
-	Done      bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          int
+	Title       string
+	Comment     string
+	Done        bool
+	Priority    int
+	DueAt       sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   sql.NullTime
+	CompletedAt sql.NullTime
+	ArchivedAt  sql.NullTime
+	Position    string
+	Clock       []byte
 }
 
-func toTodoDTO(todo todolist_model.Todo) TodoDTO {
+const todoColumns = `todos.id, todos.title, todos.comment, todos.done,
+	todos.priority, todos.due_at,
+	todos.created_at, todos.updated_at, todos.deleted_at,
+	todos.completed_at, todos.archived_at,
+	todos.position, todos.clock`
+
+// scanTodoDTO scans a row selected with todoColumns, columns selected after
+// todoColumns are scanned into extra.
+func scanTodoDTO(rows *sql.Rows, extra ...any) (TodoDTO, error) {
+	var todoDTO TodoDTO
+	dest := append([]any{
+		&todoDTO.ID,
+		&todoDTO.Title,
+		&todoDTO.Comment,
+		&todoDTO.Done,
+		&todoDTO.Priority,
+		&todoDTO.DueAt,
+		&todoDTO.CreatedAt,
+		&todoDTO.UpdatedAt,
+		&todoDTO.DeletedAt,
+		&todoDTO.CompletedAt,
+		&todoDTO.ArchivedAt,
+		&todoDTO.Position,
+		&todoDTO.Clock,
+	}, extra...)
+
+	err := rows.Scan(dest...)
+
+	return todoDTO, err
+}
+
+func toTodoDTO(todo todolist_model.Todo) (TodoDTO, error) {
 	todoPF := todo.PF()
-	return TodoDTO{
-		ID:        todoPF.ID.Int(),
-		Title:     todoPF.Title,
-		Comment:   todoPF.Comment,
-		Done:      todoPF.Done,
-		CreatedAt: todoPF.CreatedAt,
-		UpdatedAt: todoPF.UpdatedAt,
+
+	clock, err := json.Marshal(todoPF.Clock)
+	if err != nil {
+		return TodoDTO{}, err
 	}
+
+	return TodoDTO{
+		ID:          todoPF.ID.Int(),
+		Title:       todoPF.Title,
+		Comment:     todoPF.Comment,
+		Done:        todoPF.Done,
+		Priority:    todoPF.Priority.Int(),
+		DueAt:       toNullTime(todoPF.DueAt),
+		CreatedAt:   todoPF.CreatedAt,
+		UpdatedAt:   todoPF.UpdatedAt,
+		DeletedAt:   toNullTime(todoPF.DeletedAt),
+		CompletedAt: toNullTime(todoPF.CompletedAt),
+		ArchivedAt:  toNullTime(todoPF.ArchivedAt),
+		Position:    todoPF.Position.String(),
+		Clock:       clock,
+	}, nil
 }
 
-func fromTodoDTO(todoDTO TodoDTO) (todolist_model.Todo, error) {
+func fromTodoDTO(todoDTO TodoDTO, tags []todolist_model.TagID) (todolist_model.Todo, error) {
 	todoID, err := todolist_model.NewTodoID(todoDTO.ID)
 	if err != nil {
 		return todolist_model.Todo{}, err
 	}
 
+	if tags == nil {
+		tags = []todolist_model.TagID{}
+	}
+
+	priority, err := todolist_model.NewPriority(todoDTO.Priority)
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+
+	position, err := todolist_model.NewPosition(todoDTO.Position)
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+
+	var clock todolist_model.TodoClock
+	if err := json.Unmarshal(todoDTO.Clock, &clock); err != nil {
+		return todolist_model.Todo{}, err
+	}
+
 	todo, err := todolist_model.NewTodoFromDB(
 		todoID,
 		todoDTO.Title,
 		todoDTO.Comment,
 		todoDTO.Done,
+		tags,
+		priority,
+		fromNullTime(todoDTO.DueAt),
 		todoDTO.CreatedAt,
 		todoDTO.UpdatedAt,
+		fromNullTime(todoDTO.DeletedAt),
+		fromNullTime(todoDTO.CompletedAt),
+		fromNullTime(todoDTO.ArchivedAt),
+		position,
+		clock,
 	)
 	if err != nil {
 		return todolist_model.Todo{}, err
@@ -65,21 +144,59 @@ func fromTodoDTO(todoDTO TodoDTO) (todolist_model.Todo, error) {
 	return todo, nil
 }
 
+func toNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func fromNullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+type TagDTO struct {
+	ID    int
+	Name  string
+	Color string
+}
+
+func fromTagDTO(tagDTO TagDTO) (todolist_model.Tag, error) {
+	tagID, err := todolist_model.NewTagID(tagDTO.ID)
+	if err != nil {
+		return todolist_model.Tag{}, err
+	}
+
+	color, err := todolist_model.NewTagColor(tagDTO.Color)
+	if err != nil {
+		return todolist_model.Tag{}, err
+	}
+
+	return todolist_model.NewTag(tagID, tagDTO.Name, color)
+}
+
+// NextID takes id from sequence, so ids of purged todos are never given to
+// new todos and every call returns another id.
 func (r *PostrgesTodoRepository) NextID(
 	ctx context.Context,
 	tx util.Transaction,
 ) (todolist_model.TodoID, error) {
-	exec, err := storage.GetDBExecutor(tx, r.db)
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
 	if err != nil {
 		return todolist_model.NilTodoID, err
 	}
 
 	var id int
-	if err := exec.QueryRow("select max(id) from todos").Scan(&id); err != nil {
+	if err := exec.QueryRow("select nextval('todo_id_seq')").Scan(&id); err != nil {
 		return todolist_model.NilTodoID, err
 	}
 
-	todoID, err := todolist_model.NewTodoID(id + 1)
+	todoID, err := todolist_model.NewTodoID(id)
 	if err != nil {
 		return todolist_model.NilTodoID, err
 	}
@@ -87,6 +204,38 @@ func (r *PostrgesTodoRepository) NextID(
 	return todoID, nil
 }
 
+type PostrgesTagRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesTagRepository(db *sql.DB) *PostrgesTagRepository {
+	return &PostrgesTagRepository{db: db}
+}
+
+var _ todolist_domain.TagRepository = (*PostrgesTagRepository)(nil)
+
+func (r *PostrgesTagRepository) NextID(
+	ctx context.Context,
+	tx util.Transaction,
+) (todolist_model.TagID, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return todolist_model.NilTagID, err
+	}
+
+	var id int
+	if err := exec.QueryRow("select coalesce(max(id), 0) from tags").Scan(&id); err != nil {
+		return todolist_model.NilTagID, err
+	}
+
+	tagID, err := todolist_model.NewTagID(id + 1)
+	if err != nil {
+		return todolist_model.NilTagID, err
+	}
+
+	return tagID, nil
+}
+
 type PostrgesTodolistRepository struct {
 	db *sql.DB
 }
@@ -102,15 +251,28 @@ func (r *PostrgesTodolistRepository) Get(
 	userID access_domain.UserID,
 	tx util.Transaction,
 ) (*todolist_model.Todolist, error) {
-	exec, err := storage.GetDBExecutor(tx, r.db)
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := exec.Query(`select todos.* from todolist 
-	                         left join todos 
-	                         on todolist.todo_id = todos.id 
-	                         where user_id = $1`, userID)
+	tags, err := getTags(exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	todoTags, err := r.getTodoTags(exec, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.Query(`select `+todoColumns+`
+	                         from todolist
+	                         join todos on todolist.todo_id = todos.id
+	                         where todolist.user_id = $1
+	                         and todos.deleted_at is null
+	                         and todos.archived_at is null
+	                         order by todos.id`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -119,27 +281,23 @@ func (r *PostrgesTodolistRepository) Get(
 	var todos []todolist_model.Todo
 
 	for rows.Next() {
-		var todoDTO TodoDTO
-		if err := rows.Scan(
-			&todoDTO.ID,
-			&todoDTO.Title,
-			&todoDTO.Comment,
-			&todoDTO.Done,
-			&todoDTO.CreatedAt,
-			&todoDTO.UpdatedAt,
-		); err != nil {
+		todoDTO, err := scanTodoDTO(rows)
+		if err != nil {
 			return nil, err
 		}
 
-		todo, err := fromTodoDTO(todoDTO)
+		todo, err := fromTodoDTO(todoDTO, todoTags[todoDTO.ID])
 		if err != nil {
 			return nil, err
 		}
 
 		todos = append(todos, todo)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	todolist, err := todolist_model.NewTodolist(userID, todos)
+	todolist, err := todolist_model.NewTodolist(userID, todos, tags)
 	if err != nil {
 		return nil, err
 	}
@@ -147,11 +305,122 @@ func (r *PostrgesTodolistRepository) Get(
 	return todolist, nil
 }
 
+// getTodosByIDs returns stored versions of todos, trashed and archived
+// ones included.
+func getTodosByIDs(exec storage.Executor, todos []todolist_model.TodoPF) ([]todolist_model.Todo, error) {
+	if len(todos) == 0 {
+		return nil, nil
+	}
+
+	todoIDs := make([]any, len(todos))
+	for i, todo := range todos {
+		todoIDs[i] = todo.ID
+	}
+
+	rows, err := exec.Query(`select `+todoColumns+`
+		from todos
+		where todos.id in (`+storage.Placeholders(1, len(todoIDs))+`)
+		order by todos.id`, todoIDs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todoDTOs []TodoDTO
+	for rows.Next() {
+		todoDTO, err := scanTodoDTO(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		todoDTOs = append(todoDTOs, todoDTO)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	todoTags, err := getTodoTagsByIDs(exec, todoDTOs)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make([]todolist_model.Todo, len(todoDTOs))
+	for i, todoDTO := range todoDTOs {
+		stored[i], err = fromTodoDTO(todoDTO, todoTags[todoDTO.ID])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stored, nil
+}
+
+func getTags(
+	exec storage.Executor,
+	userID access_domain.UserID,
+) ([]todolist_model.Tag, error) {
+	rows, err := exec.Query(`select id, name, color from tags
+	                         where user_id = $1
+	                         order by id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []todolist_model.Tag{}
+	for rows.Next() {
+		var tagDTO TagDTO
+		if err := rows.Scan(&tagDTO.ID, &tagDTO.Name, &tagDTO.Color); err != nil {
+			return nil, err
+		}
+
+		tag, err := fromTagDTO(tagDTO)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (r *PostrgesTodolistRepository) getTodoTags(
+	exec storage.Executor,
+	userID access_domain.UserID,
+) (map[int][]todolist_model.TagID, error) {
+	rows, err := exec.Query(`select todo_tags.todo_id, todo_tags.tag_id from todo_tags
+	                         join todolist on todolist.todo_id = todo_tags.todo_id
+	                         where todolist.user_id = $1
+	                         order by todo_tags.tag_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todoTags := map[int][]todolist_model.TagID{}
+	for rows.Next() {
+		var todoID, tagIDInt int
+		if err := rows.Scan(&todoID, &tagIDInt); err != nil {
+			return nil, err
+		}
+
+		tagID, err := todolist_model.NewTagID(tagIDInt)
+		if err != nil {
+			return nil, err
+		}
+
+		todoTags[todoID] = append(todoTags[todoID], tagID)
+	}
+
+	return todoTags, rows.Err()
+}
+
 func (r *PostrgesTodolistRepository) Save(
 	ctx context.Context,
 	todolist *todolist_model.Todolist,
 	tx util.Transaction,
-) error {
+) (err error) {
 	exec, commit, rollaback, err := storage.GetTxOrCreateTx(ctx, tx, r.db)
 	if err != nil {
 		return err
@@ -164,42 +433,114 @@ func (r *PostrgesTodolistRepository) Save(
 		}
 	}()
 
-	// insert all todos
-	stmtTodoInsert, err := exec.Prepare(`insert into todos
-		(id, title, comment, done, created_at, updated_at)
-		values ($1, $2, $3, $4, $5, $6)`)
+	userID := todolist.PF().UserID
+
+	// serialize saves of user, so change sequence numbers of user todos are
+	// assigned in commit order, see PostrgesSyncRepository
+	if _, err := exec.Exec(`select pg_advisory_xact_lock($1, $2)`, storage.TodolistLockSpace, userID); err != nil {
+		return err
+	}
+
+	// todolist is loaded without lock, changes saved since then are merged
+	// field by field instead of being overwritten
+	stored, err := getTodosByIDs(exec, todolist.PF().Todos)
 	if err != nil {
 		return err
 	}
-	defer stmtTodoInsert.Close()
+	todolist.MergeTodos(stored)
 
-	// isnert all todos
 	todolistPF := todolist.PF()
+
+	// upsert all tags of user
+	stmtTagUpsert, err := exec.Prepare(`insert into tags
+		(id, user_id, name, color)
+		values ($1, $2, $3, $4)
+		on conflict (id) do update
+		set name = excluded.name, color = excluded.color`)
+	if err != nil {
+		return err
+	}
+	defer stmtTagUpsert.Close()
+
+	tagIDs := make([]any, 0, len(todolistPF.Tags)+1)
+	tagIDs = append(tagIDs, todolistPF.UserID)
+	for _, tag := range todolistPF.Tags {
+		if _, err := stmtTagUpsert.Exec(
+			tag.ID,
+			todolistPF.UserID,
+			tag.Name,
+			tag.Color.String(),
+		); err != nil {
+			return err
+		}
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	// delete tags which were removed from todolist (e.g. merged)
+	deleteTagsQuery := `delete from tags where user_id = $1`
+	if len(todolistPF.Tags) > 0 {
+		deleteTagsQuery += ` and id not in (` + storage.Placeholders(2, len(todolistPF.Tags)) + `)`
+	}
+	if _, err := exec.Exec(deleteTagsQuery, tagIDs...); err != nil {
+		return err
+	}
+
+	// upsert all todos
+	stmtTodoUpsert, err := exec.Prepare(`insert into todos
+		(id, title, comment, done, priority, due_at, created_at, updated_at, deleted_at,
+		 completed_at, archived_at, position, clock)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		on conflict (id) do update
+		set title = excluded.title,
+		    comment = excluded.comment,
+		    done = excluded.done,
+		    priority = excluded.priority,
+		    due_at = excluded.due_at,
+		    updated_at = excluded.updated_at,
+		    deleted_at = excluded.deleted_at,
+		    completed_at = excluded.completed_at,
+		    archived_at = excluded.archived_at,
+		    position = excluded.position,
+		    clock = excluded.clock,
+		    seq = nextval('todo_change_seq')
+		where todos.updated_at is distinct from excluded.updated_at
+		or todos.deleted_at is distinct from excluded.deleted_at
+		or todos.clock is distinct from excluded.clock`)
+	if err != nil {
+		return err
+	}
+	defer stmtTodoUpsert.Close()
+
 	for _, todo := range todolistPF.Todos {
-		if _, err := stmtTodoInsert.Exec(
+		clock, err := json.Marshal(todo.Clock)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmtTodoUpsert.Exec(
 			todo.ID,
 			todo.Title,
 			todo.Comment,
 			todo.Done,
+			todo.Priority.Int(),
+			toNullTime(todo.DueAt),
 			todo.CreatedAt,
 			todo.UpdatedAt,
+			toNullTime(todo.DeletedAt),
+			toNullTime(todo.CompletedAt),
+			toNullTime(todo.ArchivedAt),
+			todo.Position.String(),
+			clock,
 		); err != nil {
 			return err
 		}
 	}
 
-	// delete all todos from todolist for user
-	if _, err := exec.Exec(
-		`delete from todolist where user_id = $1`,
-		todolistPF.UserID,
-	); err != nil {
-		return err
-	}
-
-	// insert all todos from todolist for user
+	// link all todos to todolist of user
 	stmtTodolistInsert, err := exec.Prepare(`insert into todolist
 		(user_id, todo_id)
-		values ($1, $2)`)
+		values ($1, $2)
+		on conflict do nothing`)
 	if err != nil {
 		return err
 	}
@@ -214,5 +555,36 @@ func (r *PostrgesTodolistRepository) Save(
 		}
 	}
 
+	// replace tags of every todo
+	stmtTodoTagsDelete, err := exec.Prepare(`delete from todo_tags where todo_id = $1`)
+	if err != nil {
+		return err
+	}
+	defer stmtTodoTagsDelete.Close()
+
+	stmtTodoTagInsert, err := exec.Prepare(`insert into todo_tags
+		(todo_id, tag_id)
+		values ($1, $2)`)
+	if err != nil {
+		return err
+	}
+	defer stmtTodoTagInsert.Close()
+
+	for _, todo := range todolistPF.Todos {
+		if _, err := stmtTodoTagsDelete.Exec(todo.ID); err != nil {
+			return err
+		}
+
+		for _, tagID := range todo.Tags {
+			if _, err := stmtTodoTagInsert.Exec(todo.ID, tagID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := saveEvents(exec, todolist.Events()); err != nil {
+		return err
+	}
+
 	return nil
 }
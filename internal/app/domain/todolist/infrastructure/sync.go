@@ -0,0 +1,209 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// PostrgesSyncRepository reads changes by todos.seq. The sequence is bumped
+// by PostrgesTodolistRepository.Save whenever todo row changes, saves of a
+// user are serialized, so changes of a user are committed in sequence
+// order and no change is skipped by a cursor.
+type PostrgesSyncRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesSyncRepository(db *sql.DB) *PostrgesSyncRepository {
+	return &PostrgesSyncRepository{db: db}
+}
+
+var _ todolist_domain.SyncRepository = (*PostrgesSyncRepository)(nil)
+
+func (r *PostrgesSyncRepository) Changes(
+	ctx context.Context,
+	userID access_domain.UserID,
+	since int64,
+	limit int,
+	tx util.Transaction,
+) ([]todolist_domain.TodoChange, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.Query(`select `+todoColumns+`, todos.seq
+		from todolist
+		join todos on todolist.todo_id = todos.id
+		where todolist.user_id = $1
+		and todos.seq > $2
+		order by todos.seq
+		limit $3`, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todoDTOs []TodoDTO
+	var seqs []int64
+	for rows.Next() {
+		var seq int64
+		todoDTO, err := scanTodoDTO(rows, &seq)
+		if err != nil {
+			return nil, err
+		}
+
+		todoDTOs = append(todoDTOs, todoDTO)
+		seqs = append(seqs, seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	todoTags, err := getTodoTagsByIDs(exec, todoDTOs)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]todolist_domain.TodoChange, 0, len(todoDTOs))
+	for i, todoDTO := range todoDTOs {
+		todo, err := fromTodoDTO(todoDTO, todoTags[todoDTO.ID])
+		if err != nil {
+			return nil, err
+		}
+
+		todoPF := todo.PF()
+		changes = append(changes, todolist_domain.TodoChange{
+			Seq:    seqs[i],
+			TodoID: todoPF.ID,
+			Todo:   &todoPF,
+		})
+	}
+
+	tombstones, err := r.tombstones(exec, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeChanges(changes, tombstones, limit), nil
+}
+
+func (r *PostrgesSyncRepository) tombstones(
+	exec storage.Executor,
+	userID access_domain.UserID,
+	since int64,
+	limit int,
+) ([]todolist_domain.TodoChange, error) {
+	rows, err := exec.Query(`select todo_id, seq
+		from todo_tombstones
+		where user_id = $1
+		and seq > $2
+		order by seq
+		limit $3`, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tombstones []todolist_domain.TodoChange
+	for rows.Next() {
+		var todoIDInt int
+		var seq int64
+		if err := rows.Scan(&todoIDInt, &seq); err != nil {
+			return nil, err
+		}
+
+		todoID, err := todolist_model.NewTodoID(todoIDInt)
+		if err != nil {
+			return nil, err
+		}
+
+		tombstones = append(tombstones, todolist_domain.TodoChange{Seq: seq, TodoID: todoID})
+	}
+
+	return tombstones, rows.Err()
+}
+
+// mergeChanges merges two lists ordered by sequence and keeps first limit
+// changes.
+func mergeChanges(a []todolist_domain.TodoChange, b []todolist_domain.TodoChange, limit int) []todolist_domain.TodoChange {
+	merged := make([]todolist_domain.TodoChange, 0, min(len(a)+len(b), limit))
+	for len(merged) < limit && (len(a) > 0 || len(b) > 0) {
+		if len(b) == 0 || (len(a) > 0 && a[0].Seq <= b[0].Seq) {
+			merged = append(merged, a[0])
+			a = a[1:]
+		} else {
+			merged = append(merged, b[0])
+			b = b[1:]
+		}
+	}
+
+	return merged
+}
+
+func (r *PostrgesSyncRepository) GetOperation(
+	ctx context.Context,
+	userID access_domain.UserID,
+	operationID string,
+	tx util.Transaction,
+) (todolist_domain.SyncResult, bool, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return todolist_domain.SyncResult{}, false, err
+	}
+
+	var status, errMessage string
+	var todoIDInt int
+	err = exec.QueryRow(`select status, todo_id, error
+		from sync_operations
+		where user_id = $1
+		and operation_id = $2`, userID, operationID).Scan(&status, &todoIDInt, &errMessage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return todolist_domain.SyncResult{}, false, nil
+	}
+	if err != nil {
+		return todolist_domain.SyncResult{}, false, err
+	}
+
+	todoID, err := todolist_model.NewTodoID(todoIDInt)
+	if err != nil {
+		return todolist_domain.SyncResult{}, false, err
+	}
+
+	return todolist_domain.SyncResult{
+		OperationID: operationID,
+		Status:      todolist_domain.SyncStatus(status),
+		TodoID:      todoID,
+		Error:       errMessage,
+	}, true, nil
+}
+
+func (r *PostrgesSyncRepository) SaveOperation(
+	ctx context.Context,
+	userID access_domain.UserID,
+	result todolist_domain.SyncResult,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`insert into sync_operations
+		(user_id, operation_id, status, todo_id, error)
+		values ($1, $2, $3, $4, $5)`,
+		userID,
+		result.OperationID,
+		result.Status,
+		result.TodoID.Int(),
+		result.Error,
+	)
+
+	return err
+}
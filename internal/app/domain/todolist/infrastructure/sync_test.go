@@ -0,0 +1,43 @@
+package todolist_infrastructure
+
+import (
+	"testing"
+
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+)
+
+func TestMergeChanges(t *testing.T) {
+	changes := func(seqs ...int64) []todolist_domain.TodoChange {
+		result := make([]todolist_domain.TodoChange, len(seqs))
+		for i, seq := range seqs {
+			result[i] = todolist_domain.TodoChange{Seq: seq}
+		}
+		return result
+	}
+
+	tests := []struct {
+		name  string
+		a, b  []todolist_domain.TodoChange
+		limit int
+		want  []int64
+	}{
+		{"interleaved", changes(1, 4, 6), changes(2, 3, 7), 10, []int64{1, 2, 3, 4, 6, 7}},
+		{"limited", changes(1, 4, 6), changes(2, 3, 7), 4, []int64{1, 2, 3, 4}},
+		{"only tombstones", nil, changes(5, 8), 10, []int64{5, 8}},
+		{"empty", nil, nil, 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeChanges(tt.a, tt.b, tt.limit)
+			if len(merged) != len(tt.want) {
+				t.Fatalf("got %d changes, want %d", len(merged), len(tt.want))
+			}
+			for i, change := range merged {
+				if change.Seq != tt.want[i] {
+					t.Fatalf("change %d: seq %d, want %d", i, change.Seq, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostrgesTrashRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesTrashRepository(db *sql.DB) *PostrgesTrashRepository {
+	return &PostrgesTrashRepository{db: db}
+}
+
+var _ todolist_domain.TrashRepository = (*PostrgesTrashRepository)(nil)
+
+func (r *PostrgesTrashRepository) GetTrashed(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	tx util.Transaction,
+) (todolist_model.Todo, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+
+	rows, err := exec.Query(`select `+todoColumns+`
+		from todolist
+		join todos on todolist.todo_id = todos.id
+		where todolist.user_id = $1
+		and todos.id = $2
+		and todos.deleted_at is not null`, userID, todoID)
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return todolist_model.Todo{}, err
+		}
+		return todolist_model.Todo{}, todolist_model.ErrNotFound
+	}
+
+	todoDTO, err := scanTodoDTO(rows)
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+
+	todoTags, err := getTodoTagsByIDs(exec, []TodoDTO{todoDTO})
+	if err != nil {
+		return todolist_model.Todo{}, err
+	}
+
+	return fromTodoDTO(todoDTO, todoTags[todoDTO.ID])
+}
+
+func (r *PostrgesTrashRepository) Purge(
+	ctx context.Context,
+	deletedBefore time.Time,
+	tx util.Transaction,
+) (purged int64, err error) {
+	exec, commit, rollback, err := storage.GetTxOrCreateTx(ctx, tx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(rollback(), err)
+		} else {
+			err = commit()
+		}
+	}()
+
+	// keep tombstones of purged todos for clients which did not sync the
+	// deletion, tombstone takes sequence of the deletion
+	if _, err := exec.Exec(`insert into todo_tombstones
+		(todo_id, user_id, seq)
+		select todos.id, todolist.user_id, todos.seq
+		from todos
+		join todolist on todolist.todo_id = todos.id
+		where todos.deleted_at is not null
+		and todos.deleted_at < $1
+		on conflict (todo_id) do nothing`, deletedBefore); err != nil {
+		return 0, err
+	}
+
+	// todolist and todo_tags rows are removed by cascade
+	result, err := exec.Exec(`delete from todos
+		where deleted_at is not null
+		and deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
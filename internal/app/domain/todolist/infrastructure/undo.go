@@ -0,0 +1,179 @@
+package todolist_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostrgesUndoRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesUndoRepository(db *sql.DB) *PostrgesUndoRepository {
+	return &PostrgesUndoRepository{db: db}
+}
+
+var _ todolist_domain.UndoRepository = (*PostrgesUndoRepository)(nil)
+
+// UndoEventDTO is event of operation as stored in undo_operations.events.
+type UndoEventDTO struct {
+	Name       string                   `json:"name"`
+	TodoID     int                      `json:"todo_id"`
+	OldValue   string                   `json:"old_value"`
+	NewValue   string                   `json:"new_value"`
+	OccurredAt time.Time                `json:"occurred_at"`
+	Stamp      todolist_model.Timestamp `json:"stamp"`
+}
+
+func (r *PostrgesUndoRepository) Push(
+	ctx context.Context,
+	userID access_domain.UserID,
+	stack todolist_domain.UndoStack,
+	events []todolist_model.Event,
+	depth int,
+	tx util.Transaction,
+) (err error) {
+	exec, commit, rollaback, err := storage.GetTxOrCreateTx(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(rollaback(), err)
+		} else {
+			err = commit()
+		}
+	}()
+
+	eventDTOs := make([]UndoEventDTO, len(events))
+	for i, event := range events {
+		eventDTOs[i] = UndoEventDTO{
+			Name:       string(event.Name),
+			TodoID:     event.TodoID.Int(),
+			OldValue:   event.OldValue,
+			NewValue:   event.NewValue,
+			OccurredAt: event.OccurredAt,
+			Stamp:      event.Stamp,
+		}
+	}
+
+	data, err := json.Marshal(eventDTOs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := exec.Exec(`insert into undo_operations
+		(user_id, stack, events)
+		values ($1, $2, $3)`, userID, string(stack), data); err != nil {
+		return err
+	}
+
+	if _, err := exec.Exec(`delete from undo_operations
+		where user_id = $1
+		and stack = $2
+		and id not in (
+			select id from undo_operations
+			where user_id = $1
+			and stack = $2
+			order by id desc
+			limit $3
+		)`, userID, string(stack), depth); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *PostrgesUndoRepository) Pop(
+	ctx context.Context,
+	userID access_domain.UserID,
+	stack todolist_domain.UndoStack,
+	tx util.Transaction,
+) (_ []todolist_model.Event, err error) {
+	exec, commit, rollaback, err := storage.GetTxOrCreateTx(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(rollaback(), err)
+		} else {
+			err = commit()
+		}
+	}()
+
+	// the same lock as saves of todolist take, so concurrent undos of user
+	// do not pop the same operation
+	if _, err := exec.Exec(`select pg_advisory_xact_lock($1, $2)`, storage.TodolistLockSpace, userID); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = exec.QueryRow(`delete from undo_operations
+		where id = (
+			select id from undo_operations
+			where user_id = $1
+			and stack = $2
+			order by id desc
+			limit 1
+		)
+		returning events`, userID, string(stack)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var eventDTOs []UndoEventDTO
+	if err := json.Unmarshal(data, &eventDTOs); err != nil {
+		return nil, err
+	}
+
+	events := make([]todolist_model.Event, len(eventDTOs))
+	for i, eventDTO := range eventDTOs {
+		todoID, err := todolist_model.NewTodoID(eventDTO.TodoID)
+		if err != nil {
+			return nil, err
+		}
+
+		events[i] = todolist_model.Event{
+			Name:       todolist_model.EventName(eventDTO.Name),
+			UserID:     userID,
+			TodoID:     todoID,
+			OldValue:   eventDTO.OldValue,
+			NewValue:   eventDTO.NewValue,
+			OccurredAt: eventDTO.OccurredAt,
+			Stamp:      eventDTO.Stamp,
+		}
+	}
+
+	return events, nil
+}
+
+func (r *PostrgesUndoRepository) Clear(
+	ctx context.Context,
+	userID access_domain.UserID,
+	stack todolist_domain.UndoStack,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`delete from undo_operations
+		where user_id = $1
+		and stack = $2`, userID, string(stack))
+
+	return err
+}
@@ -0,0 +1,142 @@
+package todolist_domain
+
+import (
+	"context"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// MarkdownTodo is checklist item of markdown document, Line is line of
+// item in document.
+type MarkdownTodo struct {
+	Line    int
+	Title   string
+	Comment string
+	Done    bool
+}
+
+type MarkdownResult struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	// Errors are items which are neither created nor updated.
+	Errors []ImportError
+}
+
+// UpsertMarkdown updates todos having the same title as checklist items
+// and adds the rest to the end of todolist, in a single transaction. Done
+// state of existing todos follows the checklist, their comment is replaced
+// only by non-empty one, so pasting a bare checklist keeps comments.
+func (s *TodolistService) UpsertMarkdown(
+	ctx context.Context,
+	userID access_domain.UserID,
+	items []MarkdownTodo,
+) (MarkdownResult, error) {
+	if len(items) > MaxImportTodos {
+		return MarkdownResult{}, ErrImportLimit
+	}
+
+	var result MarkdownResult
+	err := s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		result = MarkdownResult{}
+
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		byTitle := map[string]todolist_model.TodoID{}
+		for _, todo := range list.OrderedTodos() {
+			if _, ok := byTitle[todo.Title]; !ok {
+				byTitle[todo.Title] = todo.ID
+			}
+		}
+
+		for _, item := range items {
+			candidate := todolist_model.NewTodo(todolist_model.NilTodoID, item.Title)
+			if err := candidate.Validate(); err != nil {
+				result.Errors = append(result.Errors, ImportError{Line: item.Line, Error: err.Error()})
+				continue
+			}
+			if err := candidate.ChangeComment(item.Comment); err != nil {
+				result.Errors = append(result.Errors, ImportError{Line: item.Line, Error: err.Error()})
+				continue
+			}
+
+			todoID, ok := byTitle[item.Title]
+			if !ok {
+				if todoID, err = s.todoRepo.NextID(ctx, tx); err != nil {
+					return err
+				}
+				byTitle[item.Title] = todoID
+
+				list.AddTodo(todoID, item.Title)
+				if _, err := updateMarkdownTodo(list, todoID, item); err != nil {
+					return err
+				}
+
+				result.Created++
+				continue
+			}
+
+			changed, err := updateMarkdownTodo(list, todoID, item)
+			if err != nil {
+				return err
+			}
+
+			if changed {
+				result.Updated++
+			} else {
+				result.Unchanged++
+			}
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		return s.save(ctx, list, tx)
+	})
+	if err != nil {
+		return MarkdownResult{}, err
+	}
+
+	return result, nil
+}
+
+// updateMarkdownTodo applies done state and comment of validated item.
+func updateMarkdownTodo(
+	list *todolist_model.Todolist,
+	todoID todolist_model.TodoID,
+	item MarkdownTodo,
+) (bool, error) {
+	todo, err := list.Todo(todoID)
+	if err != nil {
+		return false, err
+	}
+	todoPF := todo.PF()
+
+	changed := false
+	if item.Comment != "" && item.Comment != todoPF.Comment {
+		if err := list.ChangeComment(todoID, item.Comment); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	switch {
+	case item.Done && !todoPF.Done:
+		err = list.CompleteTodo(todoID)
+	case !item.Done && todoPF.Done:
+		err = list.UncompleteTodo(todoID)
+	default:
+		return changed, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
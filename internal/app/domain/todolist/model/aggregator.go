@@ -2,28 +2,43 @@ package todolist_model
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
 )
 
-var ErrNotFound = fmt.Errorf("%w: todo is not found", Err)
+var (
+	ErrNotFound       = fmt.Errorf("%w: todo is not found", Err)
+	ErrTagNotFound    = fmt.Errorf("%w: tag is not found", Err)
+	ErrTagExists      = fmt.Errorf("%w: tag with this name already exists", Err)
+	ErrTagMergeItself = fmt.Errorf("%w: tag can not be merged into itself", Err)
+)
 
 type Todolist struct {
 	userID access_domain.UserID
 	todos  []Todo
+	tags   []Tag
+
+	events []Event
+	// clock stamps changes made through todolist methods, it is set by
+	// UseClock before todolist is changed.
+	clock *Clock
 }
 
 func NewTodolistEmpty(userID access_domain.UserID) *Todolist {
 	return &Todolist{
 		userID: userID,
 		todos:  []Todo{},
+		tags:   []Tag{},
 	}
 }
 
-func NewTodolist(userID access_domain.UserID, todos []Todo) (*Todolist, error) {
+func NewTodolist(userID access_domain.UserID, todos []Todo, tags []Tag) (*Todolist, error) {
 	todolist := &Todolist{
 		userID: userID,
 		todos:  todos,
+		tags:   tags,
 	}
 
 	if err := todolist.Validate(); err != nil {
@@ -36,6 +51,23 @@ func NewTodolist(userID access_domain.UserID, todos []Todo) (*Todolist, error) {
 type TodolistPF struct {
 	UserID access_domain.UserID
 	Todos  []TodoPF
+	Tags   []TagPF
+}
+
+// UseClock makes todolist stamp changes with clock of a node, e.g. of the
+// server process or of a client replica.
+func (l *Todolist) UseClock(clock *Clock) {
+	l.clock = clock
+	l.observeTodos()
+}
+
+// observeTodos moves clock past timestamps of todos, so changes made after
+// win over changes already in todolist, even when they were made by a node
+// with clock ahead of ours.
+func (l *Todolist) observeTodos() {
+	for _, todo := range l.todos {
+		l.clock.Observe(todo.clock.Latest())
+	}
 }
 
 func (l *Todolist) PF() TodolistPF {
@@ -43,9 +75,14 @@ func (l *Todolist) PF() TodolistPF {
 	for i, todo := range l.todos {
 		todoPFs[i] = todo.PF()
 	}
+	tagPFs := make([]TagPF, len(l.tags))
+	for i, tag := range l.tags {
+		tagPFs[i] = tag.PF()
+	}
 	return TodolistPF{
 		UserID: l.userID,
 		Todos:  todoPFs,
+		Tags:   tagPFs,
 	}
 }
 
@@ -54,62 +91,601 @@ func (l *Todolist) Validate() error {
 		if err := todo.Validate(); err != nil {
 			return err
 		}
+
+		for _, tagID := range todo.tags {
+			if _, ok := l.findTag(tagID); !ok {
+				return ErrTagNotFound
+			}
+		}
+	}
+
+	names := make(map[string]struct{}, len(l.tags))
+	for _, tag := range l.tags {
+		if err := tag.Validate(); err != nil {
+			return err
+		}
+
+		if _, ok := names[tag.name]; ok {
+			return ErrTagExists
+		}
+		names[tag.name] = struct{}{}
 	}
 
 	return nil
 }
 
+// Events returns events recorded since todolist was loaded or since the last
+// ClearEvents call.
+func (l *Todolist) Events() []Event {
+	events := make([]Event, len(l.events))
+	copy(events, l.events)
+	return events
+}
+
+func (l *Todolist) ClearEvents() {
+	l.events = nil
+}
+
+func (l *Todolist) record(name EventName, todoID TodoID, oldValue string, newValue string) {
+	var stamp Timestamp
+	for _, todo := range l.todos {
+		if todo.id.Equal(todoID) {
+			stamp = todo.clock.Latest()
+			break
+		}
+	}
+
+	l.events = append(l.events, Event{
+		Name:       name,
+		UserID:     l.userID,
+		TodoID:     todoID,
+		OldValue:   oldValue,
+		NewValue:   newValue,
+		OccurredAt: time.Now(),
+		Stamp:      stamp,
+	})
+}
+
+// AddTodo adds todo to the end of todolist.
 func (l *Todolist) AddTodo(id TodoID, title string) {
 	todo := NewTodo(id, title)
+
+	var last Position
+	if ordered := l.orderedTodos(); len(ordered) > 0 {
+		last = ordered[len(ordered)-1].position
+	}
+	// positions are always valid, max length is not reachable by
+	// appending
+	todo.position, _ = PositionBetween(last, "")
+
+	now := l.clock.Now()
+	todo.clock = TodoClock{
+		Title:    now,
+		Comment:  now,
+		Done:     now,
+		Priority: now,
+		Due:      now,
+		Tags:     now,
+		Deleted:  now,
+		Archived: now,
+		Position: now,
+	}
+
 	l.todos = append(l.todos, todo)
+	l.record(EventTodoAdded, id, "", title)
 }
 
 func (l *Todolist) CompleteTodo(todoID TodoID) error {
-	for _, todo := range l.todos {
-		if todo.id.Equal(todoID) {
-			if err := todo.Complete(); err != nil {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := l.todos[i].Complete(); err != nil {
+		return err
+	}
+	l.todos[i].clock.Done = l.clock.Now()
+
+	l.record(EventTodoCompleted, todoID, "", "")
+	return nil
+}
+
+func (l *Todolist) UncompleteTodo(todoID TodoID) error {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := l.todos[i].Uncomplete(); err != nil {
+		return err
+	}
+	l.todos[i].clock.Done = l.clock.Now()
+
+	l.record(EventTodoUncompleted, todoID, "", "")
+	return nil
+}
+
+func (l *Todolist) ChangeTitle(todoID TodoID, title string) error {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	oldTitle := l.todos[i].title
+	if err := l.todos[i].ChangeTitle(title); err != nil {
+		return err
+	}
+	l.todos[i].clock.Title = l.clock.Now()
+
+	l.record(EventTitleChanged, todoID, oldTitle, title)
+	return nil
+}
+
+func (l *Todolist) ChangeComment(todoID TodoID, comment string) error {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	oldComment := l.todos[i].comment
+	if err := l.todos[i].ChangeComment(comment); err != nil {
+		return err
+	}
+	l.todos[i].clock.Comment = l.clock.Now()
+
+	l.record(EventCommentChanged, todoID, oldComment, comment)
+	return nil
+}
+
+// DeleteTodo moves todo to trash. Trashed todo stays in todolist until it is
+// saved, but it can not be found by other methods anymore.
+func (l *Todolist) DeleteTodo(todoID TodoID) error {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := l.todos[i].Delete(); err != nil {
+		return err
+	}
+	l.todos[i].clock.Deleted = l.clock.Now()
+
+	l.record(EventTodoDeleted, todoID, "", "")
+	return nil
+}
+
+// RestoreTodo moves trashed todo back to todolist.
+func (l *Todolist) RestoreTodo(todo Todo) error {
+	if err := todo.Restore(); err != nil {
+		return err
+	}
+	// trashed todos are not loaded with todolist
+	l.clock.Observe(todo.clock.Latest())
+	todo.clock.Deleted = l.clock.Now()
+
+	restored := false
+	for i := range l.todos {
+		if l.todos[i].id.Equal(todo.id) {
+			l.todos[i] = todo
+			restored = true
+			break
+		}
+	}
+	if !restored {
+		l.todos = append(l.todos, todo)
+	}
+
+	l.record(EventTodoRestored, todo.id, "", "")
+	return nil
+}
+
+func (l *Todolist) ChangePriority(todoID TodoID, priority Priority) error {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	oldPriority := l.todos[i].priority
+	if oldPriority == priority {
+		return nil
+	}
+
+	l.todos[i].ChangePriority(priority)
+	l.todos[i].clock.Priority = l.clock.Now()
+	l.record(EventPriorityChanged, todoID, oldPriority.String(), priority.String())
+	return nil
+}
+
+func (l *Todolist) ChangeDue(todoID TodoID, dueAt *time.Time) error {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	oldDue := formatDue(l.todos[i].dueAt)
+	if oldDue == formatDue(dueAt) {
+		return nil
+	}
+
+	l.todos[i].ChangeDue(dueAt)
+	l.todos[i].clock.Due = l.clock.Now()
+	l.record(EventDueChanged, todoID, oldDue, formatDue(dueAt))
+	return nil
+}
+
+func (l *Todolist) AddTag(id TagID, name string, color TagColor) error {
+	if _, ok := l.findTagByName(name); ok {
+		return ErrTagExists
+	}
+
+	tag, err := NewTag(id, name, color)
+	if err != nil {
+		return err
+	}
+
+	l.tags = append(l.tags, tag)
+	return nil
+}
+
+func (l *Todolist) RenameTag(tagID TagID, name string) error {
+	i, ok := l.findTag(tagID)
+	if !ok {
+		return ErrTagNotFound
+	}
+
+	if j, ok := l.findTagByName(name); ok && j != i {
+		return ErrTagExists
+	}
+
+	return l.tags[i].Rename(name)
+}
+
+// MergeTags moves every todo tagged with the source tag to the target tag
+// and removes the source tag from the list.
+func (l *Todolist) MergeTags(fromID TagID, intoID TagID) error {
+	if fromID.Equal(intoID) {
+		return ErrTagMergeItself
+	}
+
+	from, ok := l.findTag(fromID)
+	if !ok {
+		return ErrTagNotFound
+	}
+
+	into, ok := l.findTag(intoID)
+	if !ok {
+		return ErrTagNotFound
+	}
+
+	for i := range l.todos {
+		if !l.todos[i].HasTag(fromID) {
+			continue
+		}
+
+		if err := l.todos[i].RemoveTag(fromID); err != nil {
+			return err
+		}
+		l.todos[i].clock.Tags = l.clock.Now()
+		l.record(EventTodoUntagged, l.todos[i].id, l.tags[from].name, "")
+
+		if !l.todos[i].HasTag(intoID) {
+			if err := l.todos[i].AddTag(intoID); err != nil {
 				return err
 			}
-			return nil
+			l.record(EventTodoTagged, l.todos[i].id, "", l.tags[into].name)
 		}
 	}
 
-	return ErrNotFound
+	l.tags = append(l.tags[:from], l.tags[from+1:]...)
+	return nil
 }
 
-func (l *Todolist) UncompleteTodo(todoID TodoID) error {
-	for i, todo := range l.todos {
-		if todo.id.Equal(todoID) {
-			if err := todo.Uncomplete(); err != nil {
-				return err
+func (l *Todolist) TagTodo(todoID TodoID, tagID TagID) error {
+	tag, ok := l.findTag(tagID)
+	if !ok {
+		return ErrTagNotFound
+	}
+
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := l.todos[i].AddTag(tagID); err != nil {
+		return err
+	}
+	l.todos[i].clock.Tags = l.clock.Now()
+
+	l.record(EventTodoTagged, todoID, "", l.tags[tag].name)
+	return nil
+}
+
+func (l *Todolist) UntagTodo(todoID TodoID, tagID TagID) error {
+	tag, ok := l.findTag(tagID)
+	if !ok {
+		return ErrTagNotFound
+	}
+
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := l.todos[i].RemoveTag(tagID); err != nil {
+		return err
+	}
+	l.todos[i].clock.Tags = l.clock.Now()
+
+	l.record(EventTodoUntagged, todoID, l.tags[tag].name, "")
+	return nil
+}
+
+// TodosWithTags returns todos tagged with the given tag names. With
+// TagMatchAll a todo must carry every tag, with TagMatchAny at least one.
+// Unknown tag names never match.
+func (l *Todolist) TodosWithTags(names []string, match TagMatch) []TodoPF {
+	if len(names) == 0 {
+		match = TagMatchAll
+	}
+
+	tagIDs := make([]TagID, 0, len(names))
+	for _, name := range names {
+		i, ok := l.findTagByName(name)
+		if !ok {
+			if match == TagMatchAll {
+				return []TodoPF{}
 			}
+			continue
+		}
+		tagIDs = append(tagIDs, l.tags[i].id)
+	}
+
+	todos := []TodoPF{}
+	for _, todo := range l.todos {
+		if !todo.inTodolist() {
+			continue
+		}
 
+		matched := 0
+		for _, tagID := range tagIDs {
+			if todo.HasTag(tagID) {
+				matched++
+			}
+		}
+
+		if (match == TagMatchAll && matched == len(tagIDs)) ||
+			(match == TagMatchAny && matched > 0) {
+			todos = append(todos, todo.PF())
+		}
+	}
+
+	return todos
+}
+
+// ArchiveTodo moves completed todo to archive.
+func (l *Todolist) ArchiveTodo(todoID TodoID) error {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := l.todos[i].Archive(); err != nil {
+		return err
+	}
+	l.todos[i].clock.Archived = l.clock.Now()
+
+	l.record(EventTodoArchived, todoID, "", "")
+	return nil
+}
+
+// ArchiveCompleted archives todos completed before completedBefore and
+// returns their ids.
+func (l *Todolist) ArchiveCompleted(completedBefore time.Time) []TodoID {
+	var archived []TodoID
+	for _, todo := range l.orderedTodos() {
+		if !todo.done || (todo.completedAt != nil && !todo.completedAt.Before(completedBefore)) {
+			continue
+		}
+
+		// completed todo in todolist is always archivable
+		_ = l.ArchiveTodo(todo.id)
+		archived = append(archived, todo.id)
+	}
+
+	return archived
+}
+
+// UnarchiveTodo moves archived todo back to todolist.
+func (l *Todolist) UnarchiveTodo(todo Todo) error {
+	if err := todo.Unarchive(); err != nil {
+		return err
+	}
+	// archived todos are not loaded with todolist
+	l.clock.Observe(todo.clock.Latest())
+	todo.clock.Archived = l.clock.Now()
+
+	unarchived := false
+	for i := range l.todos {
+		if l.todos[i].id.Equal(todo.id) {
 			l.todos[i] = todo
-			return nil
+			unarchived = true
+			break
 		}
 	}
+	if !unarchived {
+		l.todos = append(l.todos, todo)
+	}
 
-	return ErrNotFound
+	l.record(EventTodoUnarchived, todo.id, "", "")
+	return nil
 }
 
-func (l *Todolist) ChangeTitle(todoID TodoID, title string) error {
-	for _, todo := range l.todos {
-		if todo.id.Equal(todoID) {
-			todo.ChangeTitle(title)
+// MoveTodo moves todo right after another todo, nil after moves todo to
+// the start of todolist. Only position of the moved todo changes.
+func (l *Todolist) MoveTodo(todoID TodoID, after *TodoID) error {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	ordered := l.orderedTodos()
+
+	var prev, next Position
+	start := 0
+	if after != nil {
+		if after.Equal(todoID) {
 			return nil
 		}
+
+		found := false
+		for j, todo := range ordered {
+			if todo.id.Equal(*after) {
+				prev = todo.position
+				start = j + 1
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrNotFound
+		}
+	}
+
+	// todos with the same position as prev are ordered by id, the moved
+	// todo goes after all of them
+	for _, todo := range ordered[start:] {
+		if todo.id.Equal(todoID) {
+			continue
+		}
+		if todo.position > prev {
+			next = todo.position
+			break
+		}
+	}
+
+	position, err := PositionBetween(prev, next)
+	if err != nil {
+		return err
 	}
 
-	return ErrNotFound
+	l.moveTo(i, position)
+	return nil
 }
 
-func (l *Todolist) ChangeComment(todoID TodoID, comment string) error {
+func (l *Todolist) moveTo(i int, position Position) {
+	oldPosition := l.todos[i].position
+	l.todos[i].position = position
+	l.todos[i].clock.Position = l.clock.Now()
+	l.todos[i].updatedAt = time.Now()
+
+	l.record(EventTodoMoved, l.todos[i].id, oldPosition.String(), position.String())
+}
+
+// OrderedTodos returns todos which are not in trash in todolist order.
+func (l *Todolist) OrderedTodos() []TodoPF {
+	ordered := l.orderedTodos()
+
+	todos := make([]TodoPF, len(ordered))
+	for i, todo := range ordered {
+		todos[i] = todo.PF()
+	}
+
+	return todos
+}
+
+func (l *Todolist) orderedTodos() []Todo {
+	ordered := make([]Todo, 0, len(l.todos))
 	for _, todo := range l.todos {
-		if todo.id.Equal(todoID) {
-			todo.ChangeComment(comment)
-			return nil
+		if todo.inTodolist() {
+			ordered = append(ordered, todo)
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return lessPosition(ordered[i], ordered[j])
+	})
+
+	return ordered
+}
+
+func lessPosition(a Todo, b Todo) bool {
+	if a.position != b.position {
+		return a.position < b.position
+	}
+
+	return a.id < b.id
+}
+
+// Merge merges todos of another replica of the same todolist, todos are
+// merged field by field, see Todo.Merge. Replicas which merged each other
+// in any order end up with the same todos. Tags and events are not merged.
+func (l *Todolist) Merge(other *Todolist) {
+	l.MergeTodos(other.todos)
+}
+
+// MergeTodos merges other versions of todos, e.g. versions saved by
+// concurrent changes after todolist was loaded, see Merge.
+func (l *Todolist) MergeTodos(todos []Todo) {
+	for _, todo := range todos {
+		l.clock.Observe(todo.clock.Latest())
+
+		merged := false
+		for i := range l.todos {
+			if l.todos[i].id.Equal(todo.id) {
+				l.todos[i].Merge(todo)
+				merged = true
+				break
+			}
+		}
+
+		if !merged {
+			todo.tags = append([]TagID{}, todo.tags...)
+			l.todos = append(l.todos, todo)
+		}
+	}
+
+	sort.SliceStable(l.todos, func(i, j int) bool {
+		return l.todos[i].id < l.todos[j].id
+	})
+}
+
+// Todo returns todo which is not in trash.
+func (l *Todolist) Todo(todoID TodoID) (Todo, error) {
+	i, ok := l.findTodo(todoID)
+	if !ok {
+		return Todo{}, ErrNotFound
+	}
+
+	return l.todos[i], nil
+}
+
+// findTodo finds todo which is not in trash.
+func (l *Todolist) findTodo(todoID TodoID) (int, bool) {
+	for i, todo := range l.todos {
+		if todo.id.Equal(todoID) && todo.inTodolist() {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (l *Todolist) findTag(tagID TagID) (int, bool) {
+	for i, tag := range l.tags {
+		if tag.id.Equal(tagID) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (l *Todolist) findTagByName(name string) (int, bool) {
+	for i, tag := range l.tags {
+		if tag.name == name {
+			return i, true
 		}
 	}
 
-	return ErrNotFound
+	return 0, false
 }
@@ -0,0 +1,221 @@
+package todolist_model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTaggedTodolist(t *testing.T) *Todolist {
+	t.Helper()
+
+	list := NewTodolistEmpty(1)
+	list.UseClock(NewClock("test"))
+	list.AddTodo(1, "write report")
+	list.AddTodo(2, "call bob")
+	list.AddTodo(3, "buy milk")
+
+	for id, name := range map[TagID]string{1: "work", 2: "urgent", 3: "home"} {
+		if err := list.AddTag(id, name, DefaultTagColor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, link := range []struct {
+		todoID TodoID
+		tagID  TagID
+	}{{1, 1}, {1, 2}, {2, 1}, {3, 3}} {
+		if err := list.TagTodo(link.todoID, link.tagID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return list
+}
+
+func todoIDs(todos []TodoPF) []TodoID {
+	ids := make([]TodoID, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	return ids
+}
+
+func TestTodosWithTags(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	tests := []struct {
+		name  string
+		tags  []string
+		match TagMatch
+		want  []TodoID
+	}{
+		{"no tags", nil, TagMatchAll, []TodoID{1, 2, 3}},
+		{"and", []string{"work", "urgent"}, TagMatchAll, []TodoID{1}},
+		{"or", []string{"urgent", "home"}, TagMatchAny, []TodoID{1, 3}},
+		{"and with unknown", []string{"work", "missing"}, TagMatchAll, []TodoID{}},
+		{"or with unknown", []string{"home", "missing"}, TagMatchAny, []TodoID{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := todoIDs(list.TodosWithTags(tt.tags, tt.match))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	if err := list.MergeTags(2, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	listPF := list.PF()
+	if len(listPF.Tags) != 2 {
+		t.Fatalf("expected 2 tags after merge, got %d", len(listPF.Tags))
+	}
+
+	if got := todoIDs(list.TodosWithTags([]string{"work"}, TagMatchAll)); len(got) != 2 {
+		t.Fatalf("expected todos 1 and 2 to be tagged with work, got %v", got)
+	}
+
+	if tags := listPF.Todos[0].Tags; len(tags) != 1 || tags[0] != 1 {
+		t.Fatalf("expected todo 1 to keep a single work tag, got %v", tags)
+	}
+
+	if err := list.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := list.MergeTags(1, 1); !errors.Is(err, ErrTagMergeItself) {
+		t.Fatalf("expected ErrTagMergeItself, got %v", err)
+	}
+}
+
+func TestRenameTagToExistingName(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	if err := list.RenameTag(1, "home"); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("expected ErrTagExists, got %v", err)
+	}
+
+	if err := list.RenameTag(1, "office"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteAndRestoreTodo(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	if err := list.DeleteTodo(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := list.ChangePriority(1, PriorityHigh); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected trashed todo to be not found, got %v", err)
+	}
+
+	if got := todoIDs(list.TodosWithTags([]string{"work"}, TagMatchAll)); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("expected trashed todo to be hidden, got %v", got)
+	}
+
+	trashed := list.todos[0]
+	if err := list.RestoreTodo(trashed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := list.ChangePriority(1, PriorityHigh); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTodolistRecordsEvents(t *testing.T) {
+	list := NewTodolistEmpty(7)
+	list.UseClock(NewClock("test"))
+	list.AddTodo(1, "draft")
+
+	if err := list.ChangeTitle(1, "final"); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.CompleteTodo(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.CompleteTodo(1); !errors.Is(err, ErrIsCompleted) {
+		t.Fatalf("expected completed todo to stay completed, got %v", err)
+	}
+	if err := list.ChangeComment(1, string(make([]byte, MaxCommentLength+1))); !errors.Is(err, ErrCommentIsTooLong) {
+		t.Fatalf("expected ErrCommentIsTooLong, got %v", err)
+	}
+
+	want := []Event{
+		{Name: EventTodoAdded, NewValue: "draft"},
+		{Name: EventTitleChanged, OldValue: "draft", NewValue: "final"},
+		{Name: EventTodoCompleted},
+	}
+
+	events := list.Events()
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+
+	for i, event := range events {
+		if event.Name != want[i].Name ||
+			event.OldValue != want[i].OldValue ||
+			event.NewValue != want[i].NewValue ||
+			event.UserID != 7 ||
+			event.TodoID != 1 {
+			t.Fatalf("event %d: got %+v, want %+v", i, event, want[i])
+		}
+	}
+
+	if todo := list.PF().Todos[0]; !todo.Done || todo.Title != "final" {
+		t.Fatalf("changes are not applied to todolist: %+v", todo)
+	}
+}
+
+func TestArchiveCompleted(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	if err := list.ArchiveTodo(1); !errors.Is(err, ErrNotCompleted) {
+		t.Fatalf("expected ErrNotCompleted, got %v", err)
+	}
+
+	for _, id := range []TodoID{1, 3} {
+		if err := list.CompleteTodo(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if archived := list.ArchiveCompleted(time.Now().Add(-time.Hour)); len(archived) != 0 {
+		t.Fatalf("expected recently completed todos to stay, got %v", archived)
+	}
+
+	archived := list.ArchiveCompleted(time.Now().Add(time.Second))
+	if len(archived) != 2 {
+		t.Fatalf("expected 2 archived todos, got %v", archived)
+	}
+
+	if got := todoIDs(list.OrderedTodos()); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("expected archived todos to leave todolist, got %v", got)
+	}
+
+	if err := list.UncompleteTodo(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected archived todo to be not found, got %v", err)
+	}
+
+	if err := list.UnarchiveTodo(list.todos[0]); err != nil {
+		t.Fatal(err)
+	}
+	if todo, err := list.Todo(1); err != nil || !todo.done {
+		t.Fatalf("expected completed todo back in todolist, got %+v, %v", todo.PF(), err)
+	}
+}
@@ -13,11 +13,20 @@ var (
 	ErrTitleIsEmpty     = fmt.Errorf("%w: title is empty", Err)
 	ErrTitleIsTooLong   = fmt.Errorf("%w: title is too long", Err)
 	ErrCommentIsTooLong = fmt.Errorf("%w: comment is too long", Err)
+	ErrAlreadyTagged    = fmt.Errorf("%w: todo is already tagged", Err)
+	ErrNotTagged        = fmt.Errorf("%w: todo is not tagged", Err)
+	ErrIsDeleted        = fmt.Errorf("%w: todo is deleted", Err)
+	ErrNotDeleted       = fmt.Errorf("%w: todo is not deleted", Err)
+	ErrIsArchived       = fmt.Errorf("%w: todo is archived", Err)
+	ErrNotArchived      = fmt.Errorf("%w: todo is not archived", Err)
+	ErrTagNameIsEmpty   = fmt.Errorf("%w: tag name is empty", Err)
+	ErrTagNameIsTooLong = fmt.Errorf("%w: tag name is too long", Err)
 )
 
 const (
 	MaxTitleLength   = 100
 	MaxCommentLength = 1000
+	MaxTagNameLength = 50
 )
 
 type Todo struct {
@@ -26,9 +35,19 @@ type Todo struct {
 	title   string
 	comment string
 	done    bool
+	tags    []TagID
 
-	createdAt time.Time
-	updatedAt time.Time
+	priority Priority
+	dueAt    *time.Time
+
+	createdAt   time.Time
+	updatedAt   time.Time
+	deletedAt   *time.Time
+	completedAt *time.Time
+	archivedAt  *time.Time
+
+	position Position
+	clock    TodoClock
 }
 
 func NewTodo(id TodoID, title string) Todo {
@@ -37,6 +56,9 @@ func NewTodo(id TodoID, title string) Todo {
 		title:     title,
 		comment:   "",
 		done:      false,
+		tags:      []TagID{},
+		priority:  PriorityNone,
+		dueAt:     nil,
 		createdAt: time.Now(),
 		updatedAt: time.Now(),
 	}
@@ -47,16 +69,32 @@ func NewTodoFromDB(
 	title string,
 	comment string,
 	done bool,
+	tags []TagID,
+	priority Priority,
+	dueAt *time.Time,
 	createdAt time.Time,
 	updatedAt time.Time,
+	deletedAt *time.Time,
+	completedAt *time.Time,
+	archivedAt *time.Time,
+	position Position,
+	clock TodoClock,
 ) (Todo, error) {
 	todo := Todo{
-		id:        id,
-		title:     title,
-		comment:   comment,
-		done:      done,
-		createdAt: createdAt,
-		updatedAt: updatedAt,
+		id:          id,
+		title:       title,
+		comment:     comment,
+		done:        done,
+		tags:        tags,
+		priority:    priority,
+		dueAt:       dueAt,
+		createdAt:   createdAt,
+		updatedAt:   updatedAt,
+		deletedAt:   deletedAt,
+		completedAt: completedAt,
+		archivedAt:  archivedAt,
+		position:    position,
+		clock:       clock,
 	}
 
 	if err := todo.Validate(); err != nil {
@@ -67,22 +105,41 @@ func NewTodoFromDB(
 }
 
 type TodoPF struct {
-	ID        TodoID
-	Title     string
-	Comment   string
-	Done      bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          TodoID
+	Title       string
+	Comment     string
+	Done        bool
+	Tags        []TagID
+	Priority    Priority
+	DueAt       *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time
+	CompletedAt *time.Time
+	ArchivedAt  *time.Time
+	Position    Position
+	Clock       TodoClock
 }
 
 func (t *Todo) PF() TodoPF {
+	tags := make([]TagID, len(t.tags))
+	copy(tags, t.tags)
+
 	return TodoPF{
-		ID:        t.id,
-		Title:     t.title,
-		Comment:   t.comment,
-		Done:      t.done,
-		CreatedAt: t.createdAt,
-		UpdatedAt: t.updatedAt,
+		ID:          t.id,
+		Title:       t.title,
+		Comment:     t.comment,
+		Done:        t.done,
+		Tags:        tags,
+		Priority:    t.priority,
+		DueAt:       t.dueAt,
+		CreatedAt:   t.createdAt,
+		UpdatedAt:   t.updatedAt,
+		DeletedAt:   t.deletedAt,
+		CompletedAt: t.completedAt,
+		ArchivedAt:  t.archivedAt,
+		Position:    t.position,
+		Clock:       t.clock,
 	}
 }
 
@@ -102,16 +159,89 @@ func (t *Todo) Validate() error {
 	return nil
 }
 
-// TODO: add validation on new title
-func (t *Todo) ChangeTitle(title string) {
+// Merge merges concurrent versions of the same todo. Every field is a
+// last-writer-wins register: value with the later timestamp in TodoClock
+// wins, so merge is commutative, associative and idempotent.
+func (t *Todo) Merge(other Todo) {
+	if other.clock.Title.After(t.clock.Title) {
+		t.title, t.clock.Title = other.title, other.clock.Title
+	}
+
+	if other.clock.Comment.After(t.clock.Comment) {
+		t.comment, t.clock.Comment = other.comment, other.clock.Comment
+	}
+
+	if other.clock.Done.After(t.clock.Done) {
+		t.done, t.completedAt, t.clock.Done = other.done, other.completedAt, other.clock.Done
+	}
+
+	if other.clock.Priority.After(t.clock.Priority) {
+		t.priority, t.clock.Priority = other.priority, other.clock.Priority
+	}
+
+	if other.clock.Due.After(t.clock.Due) {
+		t.dueAt, t.clock.Due = other.dueAt, other.clock.Due
+	}
+
+	if other.clock.Tags.After(t.clock.Tags) {
+		t.tags, t.clock.Tags = append([]TagID{}, other.tags...), other.clock.Tags
+	}
+
+	if other.clock.Deleted.After(t.clock.Deleted) {
+		t.deletedAt, t.clock.Deleted = other.deletedAt, other.clock.Deleted
+	}
+
+	if other.clock.Archived.After(t.clock.Archived) {
+		t.archivedAt, t.clock.Archived = other.archivedAt, other.clock.Archived
+	}
+
+	if other.clock.Position.After(t.clock.Position) {
+		t.position, t.clock.Position = other.position, other.clock.Position
+	}
+
+	if other.createdAt.Before(t.createdAt) {
+		t.createdAt = other.createdAt
+	}
+
+	if other.updatedAt.After(t.updatedAt) {
+		t.updatedAt = other.updatedAt
+	}
+}
+
+func (t *Todo) ChangeTitle(title string) error {
+	changed := *t
+	changed.title = title
+	if err := changed.Validate(); err != nil {
+		return err
+	}
+
 	t.title = title
 	t.updatedAt = time.Now()
+
+	return nil
 }
 
-// TODO: add validation on new comment
-func (t *Todo) ChangeComment(comment string) {
+func (t *Todo) ChangeComment(comment string) error {
+	changed := *t
+	changed.comment = comment
+	if err := changed.Validate(); err != nil {
+		return err
+	}
+
 	t.comment = comment
 	t.updatedAt = time.Now()
+
+	return nil
+}
+
+func (t *Todo) ChangePriority(priority Priority) {
+	t.priority = priority
+	t.updatedAt = time.Now()
+}
+
+func (t *Todo) ChangeDue(dueAt *time.Time) {
+	t.dueAt = dueAt
+	t.updatedAt = time.Now()
 }
 
 func (t *Todo) Complete() error {
@@ -119,8 +249,10 @@ func (t *Todo) Complete() error {
 		return ErrIsCompleted
 	}
 
+	now := time.Now()
 	t.done = true
-	t.updatedAt = time.Now()
+	t.completedAt = &now
+	t.updatedAt = now
 
 	return nil
 }
@@ -131,7 +263,166 @@ func (t *Todo) Uncomplete() error {
 	}
 
 	t.done = false
+	t.completedAt = nil
+	t.updatedAt = time.Now()
+
+	return nil
+}
+
+func (t *Todo) IsDeleted() bool {
+	return t.deletedAt != nil
+}
+
+// Delete moves todo to trash.
+func (t *Todo) Delete() error {
+	if t.IsDeleted() {
+		return ErrIsDeleted
+	}
+
+	now := time.Now()
+	t.deletedAt = &now
+	t.updatedAt = now
+
+	return nil
+}
+
+// Restore moves todo back from trash.
+func (t *Todo) Restore() error {
+	if !t.IsDeleted() {
+		return ErrNotDeleted
+	}
+
+	t.deletedAt = nil
+	t.updatedAt = time.Now()
+
+	return nil
+}
+
+func (t *Todo) IsArchived() bool {
+	return t.archivedAt != nil
+}
+
+// inTodolist reports whether todo is neither in trash nor in archive.
+func (t *Todo) inTodolist() bool {
+	return !t.IsDeleted() && !t.IsArchived()
+}
+
+// Archive moves completed todo out of todolist to archive.
+func (t *Todo) Archive() error {
+	if t.IsArchived() {
+		return ErrIsArchived
+	}
+
+	if !t.done {
+		return ErrNotCompleted
+	}
+
+	now := time.Now()
+	t.archivedAt = &now
+	t.updatedAt = now
+
+	return nil
+}
+
+// Unarchive moves todo back from archive.
+func (t *Todo) Unarchive() error {
+	if !t.IsArchived() {
+		return ErrNotArchived
+	}
+
+	t.archivedAt = nil
+	t.updatedAt = time.Now()
+
+	return nil
+}
+
+func (t *Todo) HasTag(tagID TagID) bool {
+	for _, id := range t.tags {
+		if id.Equal(tagID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Todo) AddTag(tagID TagID) error {
+	if t.HasTag(tagID) {
+		return ErrAlreadyTagged
+	}
+
+	t.tags = append(t.tags, tagID)
 	t.updatedAt = time.Now()
 
 	return nil
 }
+
+func (t *Todo) RemoveTag(tagID TagID) error {
+	for i, id := range t.tags {
+		if id.Equal(tagID) {
+			t.tags = append(t.tags[:i], t.tags[i+1:]...)
+			t.updatedAt = time.Now()
+			return nil
+		}
+	}
+
+	return ErrNotTagged
+}
+
+type Tag struct {
+	id TagID
+
+	name  string
+	color TagColor
+}
+
+func NewTag(id TagID, name string, color TagColor) (Tag, error) {
+	tag := Tag{
+		id:    id,
+		name:  name,
+		color: color,
+	}
+
+	if err := tag.Validate(); err != nil {
+		return Tag{}, err
+	}
+
+	return tag, nil
+}
+
+type TagPF struct {
+	ID    TagID
+	Name  string
+	Color TagColor
+}
+
+func (t *Tag) PF() TagPF {
+	return TagPF{
+		ID:    t.id,
+		Name:  t.name,
+		Color: t.color,
+	}
+}
+
+func (t *Tag) Validate() error {
+	if t.name == "" {
+		return ErrTagNameIsEmpty
+	}
+
+	if len(t.name) > MaxTagNameLength {
+		return ErrTagNameIsTooLong
+	}
+
+	return nil
+}
+
+func (t *Tag) Rename(name string) error {
+	renamed := *t
+	renamed.name = name
+	if err := renamed.Validate(); err != nil {
+		return err
+	}
+
+	t.name = name
+	return nil
+}
@@ -0,0 +1,67 @@
+package todolist_model
+
+import (
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+)
+
+type EventName string
+
+const (
+	EventTodoAdded       EventName = "TodoAdded"
+	EventTodoCompleted   EventName = "TodoCompleted"
+	EventTodoUncompleted EventName = "TodoUncompleted"
+	EventTitleChanged    EventName = "TitleChanged"
+	EventCommentChanged  EventName = "CommentChanged"
+	EventPriorityChanged EventName = "PriorityChanged"
+	EventDueChanged      EventName = "DueChanged"
+	EventTodoTagged      EventName = "TodoTagged"
+	EventTodoUntagged    EventName = "TodoUntagged"
+	EventTodoDeleted     EventName = "TodoDeleted"
+	EventTodoRestored    EventName = "TodoRestored"
+	EventTodoMoved       EventName = "TodoMoved"
+	EventTodoArchived    EventName = "TodoArchived"
+	EventTodoUnarchived  EventName = "TodoUnarchived"
+)
+
+// EventNames lists every event recorded by Todolist.
+var EventNames = []EventName{
+	EventTodoAdded,
+	EventTodoCompleted,
+	EventTodoUncompleted,
+	EventTitleChanged,
+	EventCommentChanged,
+	EventPriorityChanged,
+	EventDueChanged,
+	EventTodoTagged,
+	EventTodoUntagged,
+	EventTodoDeleted,
+	EventTodoRestored,
+	EventTodoMoved,
+	EventTodoArchived,
+	EventTodoUnarchived,
+}
+
+// Event is a change of a single todo recorded by Todolist. Old and new
+// values are string representations of changed field, they are empty when
+// event does not change a value (e.g. TodoCompleted).
+type Event struct {
+	Name       EventName
+	UserID     access_domain.UserID
+	TodoID     TodoID
+	OldValue   string
+	NewValue   string
+	OccurredAt time.Time
+	// Stamp is the latest timestamp of todo right after the change, see
+	// TodoClock.Latest.
+	Stamp Timestamp
+}
+
+func formatDue(dueAt *time.Time) string {
+	if dueAt == nil {
+		return ""
+	}
+
+	return dueAt.UTC().Format(time.RFC3339)
+}
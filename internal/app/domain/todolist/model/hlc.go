@@ -0,0 +1,124 @@
+package todolist_model
+
+import (
+	"cmp"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Timestamp is a hybrid logical clock timestamp. Timestamps are ordered by
+// wall time, then logical counter, then node, so timestamps of different
+// nodes are never equal.
+type Timestamp struct {
+	// Wall is physical time in unix milliseconds.
+	Wall    int64  `json:"w"`
+	Logical uint32 `json:"l"`
+	Node    string `json:"n"`
+}
+
+func (t Timestamp) Compare(other Timestamp) int {
+	if c := cmp.Compare(t.Wall, other.Wall); c != 0 {
+		return c
+	}
+
+	if c := cmp.Compare(t.Logical, other.Logical); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(t.Node, other.Node)
+}
+
+func (t Timestamp) After(other Timestamp) bool {
+	return t.Compare(other) > 0
+}
+
+func (t Timestamp) IsZero() bool {
+	return t == Timestamp{}
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d.%d@%s", t.Wall, t.Logical, t.Node)
+}
+
+func maxTimestamp(a Timestamp, b Timestamp) Timestamp {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
+
+// Clock issues hybrid logical clock timestamps of a node. Timestamps follow
+// physical time, but never go back and stay after every observed remote
+// timestamp, so causally later changes always get later timestamps.
+type Clock struct {
+	mu   sync.Mutex
+	node string
+	last Timestamp
+	now  func() time.Time
+}
+
+func NewClock(node string) *Clock {
+	return &Clock{node: node, now: time.Now}
+}
+
+// Now returns timestamp of a local change.
+func (c *Clock) Now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := c.now().UnixMilli()
+	if wall > c.last.Wall {
+		c.last = Timestamp{Wall: wall, Node: c.node}
+	} else {
+		c.last = Timestamp{Wall: c.last.Wall, Logical: c.last.Logical + 1, Node: c.node}
+	}
+
+	return c.last
+}
+
+// Observe moves clock past remote timestamp.
+func (c *Clock) Observe(remote Timestamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := max(c.now().UnixMilli(), c.last.Wall, remote.Wall)
+
+	var logical uint32
+	switch {
+	case wall == c.last.Wall && wall == remote.Wall:
+		logical = max(c.last.Logical, remote.Logical) + 1
+	case wall == c.last.Wall:
+		logical = c.last.Logical + 1
+	case wall == remote.Wall:
+		logical = remote.Logical + 1
+	}
+
+	c.last = Timestamp{Wall: wall, Logical: logical, Node: c.node}
+}
+
+// TodoClock holds timestamps of the last write of every field of todo.
+// Together with field values they form last-writer-wins registers, see
+// Todo.Merge.
+type TodoClock struct {
+	Title    Timestamp `json:"title"`
+	Comment  Timestamp `json:"comment"`
+	Done     Timestamp `json:"done"`
+	Priority Timestamp `json:"priority"`
+	Due      Timestamp `json:"due"`
+	Tags     Timestamp `json:"tags"`
+	Deleted  Timestamp `json:"deleted"`
+	Archived Timestamp `json:"archived"`
+	Position Timestamp `json:"position"`
+}
+
+// Latest returns the latest timestamp of all fields.
+func (c TodoClock) Latest() Timestamp {
+	latest := c.Title
+	for _, t := range []Timestamp{c.Comment, c.Done, c.Priority, c.Due, c.Tags, c.Deleted, c.Archived, c.Position} {
+		latest = maxTimestamp(latest, t)
+	}
+
+	return latest
+}
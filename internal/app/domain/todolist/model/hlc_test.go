@@ -0,0 +1,45 @@
+package todolist_model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockIsMonotonic(t *testing.T) {
+	wall := time.UnixMilli(1000)
+	clock := NewClock("a")
+	clock.now = func() time.Time { return wall }
+
+	first := clock.Now()
+	second := clock.Now()
+	if !second.After(first) {
+		t.Fatalf("expected %s after %s", second, first)
+	}
+
+	// physical time goes back, e.g. after clock adjustment
+	wall = time.UnixMilli(500)
+	if third := clock.Now(); !third.After(second) {
+		t.Fatalf("expected %s after %s", third, second)
+	}
+}
+
+func TestClockObserve(t *testing.T) {
+	clock := NewClock("a")
+	clock.now = func() time.Time { return time.UnixMilli(1000) }
+
+	remote := Timestamp{Wall: 5000, Logical: 3, Node: "b"}
+	clock.Observe(remote)
+
+	if now := clock.Now(); !now.After(remote) || now.Wall != remote.Wall {
+		t.Fatalf("expected %s after %s", now, remote)
+	}
+}
+
+func TestTimestampsOfNodesDiffer(t *testing.T) {
+	a := Timestamp{Wall: 1, Logical: 1, Node: "a"}
+	b := Timestamp{Wall: 1, Logical: 1, Node: "b"}
+
+	if !b.After(a) || a.After(b) {
+		t.Fatal("expected timestamps to be ordered by node")
+	}
+}
@@ -0,0 +1,221 @@
+package todolist_model
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// snapshot copies todolist, so replica can be merged while changing.
+func snapshot(list *Todolist) *Todolist {
+	todos := make([]Todo, len(list.todos))
+	for i, todo := range list.todos {
+		todo.tags = append([]TagID{}, todo.tags...)
+		todos[i] = todo
+	}
+
+	return &Todolist{
+		userID: list.userID,
+		todos:  todos,
+		tags:   append([]Tag{}, list.tags...),
+		clock:  list.clock,
+	}
+}
+
+func newReplicas(t *testing.T, rnd *rand.Rand, n int) []*Todolist {
+	base := newTaggedTodolist(t)
+
+	replicas := make([]*Todolist, n)
+	for i := range replicas {
+		replicas[i] = snapshot(base)
+
+		// physical clocks of replicas are skewed and often stand still, so
+		// logical counters and node tie breaks are exercised too
+		clock := NewClock(string(rune('a' + i)))
+		wall := time.UnixMilli(rnd.Int63n(10))
+		clock.now = func() time.Time {
+			wall = wall.Add(time.Duration(rnd.Intn(2)) * time.Millisecond)
+			return wall
+		}
+		replicas[i].UseClock(clock)
+		replicas[i].Merge(base)
+	}
+
+	return replicas
+}
+
+// applyRandomOperation applies random operation to replica, operations which
+// are invalid in the current state are skipped like they would be by API.
+func applyRandomOperation(rnd *rand.Rand, list *Todolist, nextID *TodoID) {
+	ids := []TodoID{}
+	for _, todo := range list.todos {
+		ids = append(ids, todo.id)
+	}
+	todoID := ids[rnd.Intn(len(ids))]
+	tagID := TagID(rnd.Intn(3) + 1)
+
+	switch rnd.Intn(12) {
+	case 0:
+		*nextID++
+		list.AddTodo(*nextID, "todo")
+	case 1:
+		_ = list.CompleteTodo(todoID)
+	case 2:
+		_ = list.UncompleteTodo(todoID)
+	case 3:
+		_ = list.ChangeTitle(todoID, string(rune('a'+rnd.Intn(26))))
+	case 4:
+		_ = list.ChangeComment(todoID, string(rune('a'+rnd.Intn(26))))
+	case 5:
+		_ = list.ChangePriority(todoID, Priority(rnd.Intn(5)))
+	case 6:
+		due := time.Unix(rnd.Int63n(1000), 0)
+		_ = list.ChangeDue(todoID, &due)
+	case 7:
+		_ = list.TagTodo(todoID, tagID)
+	case 8:
+		_ = list.UntagTodo(todoID, tagID)
+	case 9:
+		if _, ok := list.findTodo(todoID); ok {
+			_ = list.DeleteTodo(todoID)
+		} else {
+			for _, todo := range list.todos {
+				if todo.id.Equal(todoID) {
+					_ = list.RestoreTodo(todo)
+				}
+			}
+		}
+	case 10:
+		after := ids[rnd.Intn(len(ids))]
+		if rnd.Intn(4) == 0 {
+			_ = list.MoveTodo(todoID, nil)
+		} else {
+			_ = list.MoveTodo(todoID, &after)
+		}
+	case 11:
+		if _, ok := list.findTodo(todoID); ok {
+			_ = list.ArchiveTodo(todoID)
+		} else {
+			for _, todo := range list.todos {
+				if todo.id.Equal(todoID) && !todo.IsDeleted() {
+					_ = list.UnarchiveTodo(todo)
+				}
+			}
+		}
+	}
+}
+
+func TestTodolistMergeConverges(t *testing.T) {
+	property := func(seed int64) bool {
+		rnd := rand.New(rand.NewSource(seed))
+		replicas := newReplicas(t, rnd, 2+rnd.Intn(3))
+
+		// replicas use disjoint ids for added todos
+		nextIDs := make([]TodoID, len(replicas))
+		for i := range nextIDs {
+			nextIDs[i] = TodoID((i + 1) * 1000)
+		}
+
+		// random operations interleaved with random partial syncs
+		for range 50 + rnd.Intn(100) {
+			i := rnd.Intn(len(replicas))
+			if rnd.Intn(5) == 0 {
+				replicas[i].Merge(snapshot(replicas[rnd.Intn(len(replicas))]))
+				continue
+			}
+			applyRandomOperation(rnd, replicas[i], &nextIDs[i])
+		}
+
+		// every replica merges final states of all replicas in its own
+		// random order
+		states := make([]*Todolist, len(replicas))
+		for i, replica := range replicas {
+			states[i] = snapshot(replica)
+		}
+		for _, replica := range replicas {
+			for _, j := range rnd.Perm(len(states)) {
+				replica.Merge(states[j])
+			}
+		}
+
+		want := replicas[0].PF().Todos
+		wantOrder := todoIDs(replicas[0].OrderedTodos())
+		for _, replica := range replicas[1:] {
+			if !reflect.DeepEqual(replica.PF().Todos, want) {
+				t.Logf("seed %d: todos diverged:\n%+v\n%+v", seed, replica.PF().Todos, want)
+				return false
+			}
+			if got := todoIDs(replica.OrderedTodos()); !reflect.DeepEqual(got, wantOrder) {
+				t.Logf("seed %d: order diverged: %v, %v", seed, got, wantOrder)
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTodoMergeIsIdempotentAndCommutative(t *testing.T) {
+	a := snapshot(newTaggedTodolist(t))
+	a.UseClock(NewClock("a"))
+	b := snapshot(a)
+	b.UseClock(NewClock("b"))
+
+	if err := a.ChangeTitle(1, "from a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ChangeTitle(1, "from b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.CompleteTodo(1); err != nil {
+		t.Fatal(err)
+	}
+
+	ab, ba := snapshot(a), snapshot(b)
+	ab.Merge(b)
+	ba.Merge(a)
+	ab.Merge(snapshot(ab))
+
+	if !reflect.DeepEqual(ab.PF().Todos, ba.PF().Todos) {
+		t.Fatalf("merge is not commutative:\n%+v\n%+v", ab.PF().Todos, ba.PF().Todos)
+	}
+
+	// concurrent changes of different fields are both kept
+	todo, err := ab.Todo(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !todo.done {
+		t.Fatalf("expected completion to survive concurrent title change: %+v", todo.PF())
+	}
+}
+
+func TestMoveTodo(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	first := TodoID(1)
+	if err := list.MoveTodo(3, &first); err != nil {
+		t.Fatal(err)
+	}
+	if got := todoIDs(list.OrderedTodos()); !reflect.DeepEqual(got, []TodoID{1, 3, 2}) {
+		t.Fatalf("unexpected order %v", got)
+	}
+
+	if err := list.MoveTodo(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := todoIDs(list.OrderedTodos()); !reflect.DeepEqual(got, []TodoID{2, 1, 3}) {
+		t.Fatalf("unexpected order %v", got)
+	}
+
+	list.AddTodo(4, "last")
+	if got := todoIDs(list.OrderedTodos()); got[len(got)-1] != 4 {
+		t.Fatalf("expected added todo to go last, got %v", got)
+	}
+}
@@ -2,9 +2,14 @@ package todolist_model
 
 import (
 	"fmt"
+	"regexp"
 )
 
-var ErrTodoID = fmt.Errorf("%w: todo id", Err)
+var (
+	ErrTodoID   = fmt.Errorf("%w: todo id", Err)
+	ErrTagID    = fmt.Errorf("%w: tag id", Err)
+	ErrTagColor = fmt.Errorf("%w: tag color must be in #rrggbb format", Err)
+)
 
 type TodoID uint
 
@@ -25,3 +30,101 @@ func (id TodoID) Equal(other TodoID) bool {
 func (id TodoID) Int() int {
 	return int(id)
 }
+
+type TagID uint
+
+var NilTagID TagID
+
+func NewTagID(id int) (TagID, error) {
+	if id < 0 {
+		return NilTagID, ErrTagID
+	}
+
+	return TagID(uint(id)), nil
+}
+
+func (id TagID) Equal(other TagID) bool {
+	return id == other
+}
+
+func (id TagID) Int() int {
+	return int(id)
+}
+
+type TagColor string
+
+const DefaultTagColor TagColor = "#808080"
+
+var tagColorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func NewTagColor(color string) (TagColor, error) {
+	if color == "" {
+		return DefaultTagColor, nil
+	}
+
+	if !tagColorRegexp.MatchString(color) {
+		return DefaultTagColor, ErrTagColor
+	}
+
+	return TagColor(color), nil
+}
+
+func (c TagColor) String() string {
+	return string(c)
+}
+
+// TagMatch defines how several tags are combined when filtering todos.
+type TagMatch int
+
+const (
+	TagMatchAll TagMatch = iota
+	TagMatchAny
+)
+
+var ErrPriority = fmt.Errorf("%w: priority must be one of: none, low, medium, high, urgent", Err)
+
+type Priority uint8
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+func NewPriority(priority int) (Priority, error) {
+	if priority < int(PriorityNone) || priority > int(PriorityUrgent) {
+		return PriorityNone, ErrPriority
+	}
+
+	return Priority(priority), nil
+}
+
+func ParsePriority(priority string) (Priority, error) {
+	if priority == "" {
+		return PriorityNone, nil
+	}
+
+	for i, name := range priorityNames {
+		if name == priority {
+			return Priority(i), nil
+		}
+	}
+
+	return PriorityNone, ErrPriority
+}
+
+func (p Priority) Int() int {
+	return int(p)
+}
+
+func (p Priority) String() string {
+	if int(p) >= len(priorityNames) {
+		return priorityNames[PriorityNone]
+	}
+
+	return priorityNames[p]
+}
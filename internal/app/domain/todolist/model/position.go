@@ -0,0 +1,116 @@
+package todolist_model
+
+import (
+	"fmt"
+	"strings"
+)
+
+var ErrPosition = fmt.Errorf("%w: invalid position", Err)
+
+const (
+	positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// MaxPositionLength bounds positions, they grow by about one digit per
+	// repeated insert at the same place.
+	MaxPositionLength = 100
+)
+
+// Position is a fractional index of todo in todolist. Positions are
+// compared as strings and there is always a position between any two
+// positions, so moving todo never changes positions of other todos.
+// Together with todo id as tie breaker positions form a sequence CRDT:
+// replicas which saw the same positions agree on the same order.
+type Position string
+
+func NewPosition(position string) (Position, error) {
+	if len(position) > MaxPositionLength {
+		return "", ErrPosition
+	}
+
+	for i := 0; i < len(position); i++ {
+		if strings.IndexByte(positionDigits, position[i]) < 0 {
+			return "", ErrPosition
+		}
+	}
+
+	// position ending with the smallest digit has no position right
+	// before it
+	if strings.HasSuffix(position, positionDigits[:1]) {
+		return "", ErrPosition
+	}
+
+	return Position(position), nil
+}
+
+func (p Position) String() string {
+	return string(p)
+}
+
+// PositionBetween returns position strictly between after and before.
+// Empty after means start of todolist, empty before means its end.
+func PositionBetween(after Position, before Position) (Position, error) {
+	if before != "" && after >= before {
+		return "", ErrPosition
+	}
+
+	position := midpoint(string(after), string(before))
+	if len(position) > MaxPositionLength {
+		return "", ErrPosition
+	}
+
+	return Position(position), nil
+}
+
+// midpoint returns digits between a and b, empty b is above every digit
+// string. Neither a nor b ends with the smallest digit.
+func midpoint(a string, b string) string {
+	if b != "" {
+		// keep common prefix, missing digits of a are the smallest digit
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(positionDigits, a[0])
+	}
+
+	digitB := len(positionDigits)
+	if b != "" {
+		digitB = strings.IndexByte(positionDigits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(positionDigits[(digitA+digitB)/2])
+	}
+
+	// digits are consecutive, b shortened to its first digit is still
+	// greater than a
+	if len(b) > 1 {
+		return b[:1]
+	}
+
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+
+	return string(positionDigits[digitA]) + midpoint(rest, "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+
+	return positionDigits[0]
+}
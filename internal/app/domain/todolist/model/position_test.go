@@ -0,0 +1,81 @@
+package todolist_model
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestPositionBetween(t *testing.T) {
+	for _, tc := range []struct {
+		after, before Position
+	}{
+		{"", ""},
+		{"", "1"},
+		{"", "01"},
+		{"V", ""},
+		{"z", ""},
+		{"zz", ""},
+		{"1", "2"},
+		{"1", "12"},
+		{"1z", "2"},
+		{"A", "B1"},
+		{"0001", "0002"},
+		{"000000000012V", ""},
+	} {
+		position, err := PositionBetween(tc.after, tc.before)
+		if err != nil {
+			t.Fatalf("between %q and %q: %v", tc.after, tc.before, err)
+		}
+
+		if position <= tc.after || (tc.before != "" && position >= tc.before) {
+			t.Fatalf("%q is not between %q and %q", position, tc.after, tc.before)
+		}
+
+		if _, err := NewPosition(position.String()); err != nil {
+			t.Fatalf("between %q and %q: invalid position %q", tc.after, tc.before, position)
+		}
+	}
+
+	if _, err := PositionBetween("2", "1"); !errors.Is(err, ErrPosition) {
+		t.Fatalf("expected ErrPosition, got %v", err)
+	}
+}
+
+func TestPositionBetweenRandomInserts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	positions := []Position{}
+	for range 1000 {
+		i := rnd.Intn(len(positions) + 1)
+
+		var after, before Position
+		if i > 0 {
+			after = positions[i-1]
+		}
+		if i < len(positions) {
+			before = positions[i]
+		}
+
+		position, err := PositionBetween(after, before)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		positions = append(positions[:i], append([]Position{position}, positions[i:]...)...)
+	}
+
+	for i := 1; i < len(positions); i++ {
+		if positions[i-1] >= positions[i] {
+			t.Fatalf("positions are not ordered: %q >= %q", positions[i-1], positions[i])
+		}
+	}
+}
+
+func TestNewPosition(t *testing.T) {
+	for _, position := range []string{"10", "a-b", "ä"} {
+		if _, err := NewPosition(position); !errors.Is(err, ErrPosition) {
+			t.Fatalf("expected ErrPosition for %q, got %v", position, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package todolist_model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrSort = fmt.Errorf("%w: sort must be smart or a list of: priority, due, created, updated, position", Err)
+
+type SortField string
+
+const (
+	SortByPriority SortField = "priority"
+	SortByDue      SortField = "due"
+	SortByCreated  SortField = "created"
+	SortByUpdated  SortField = "updated"
+	// SortByPosition is the manual order of todolist, see Position.
+	SortByPosition SortField = "position"
+)
+
+// SortSmart is the built-in "what to do next" ordering, see SmartScore.
+const SortSmart = "smart"
+
+type SortKey struct {
+	Field SortField
+	Desc  bool
+}
+
+// TodoSort is either the smart ordering or a list of keys applied in order,
+// later keys break ties of earlier ones. Todos with equal keys are ordered
+// by id.
+type TodoSort struct {
+	Smart bool
+	Keys  []SortKey
+}
+
+var DefaultTodoSort = TodoSort{Keys: []SortKey{{Field: SortByCreated}}}
+
+// ParseTodoSort parses sort query like "smart" or "-priority,due", where
+// "-" prefix means descending order.
+func ParseTodoSort(value string) (TodoSort, error) {
+	if value == "" {
+		return DefaultTodoSort, nil
+	}
+
+	if value == SortSmart {
+		return TodoSort{Smart: true}, nil
+	}
+
+	var keys []SortKey
+	for _, part := range strings.Split(value, ",") {
+		key := SortKey{Field: SortField(strings.TrimPrefix(part, "-"))}
+		key.Desc = strings.HasPrefix(part, "-")
+
+		switch key.Field {
+		case SortByPriority, SortByDue, SortByCreated, SortByUpdated, SortByPosition:
+		default:
+			return TodoSort{}, ErrSort
+		}
+
+		for _, other := range keys {
+			if other.Field == key.Field {
+				return TodoSort{}, ErrSort
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return TodoSort{Keys: keys}, nil
+}
+
+func (s TodoSort) String() string {
+	if s.Smart {
+		return SortSmart
+	}
+
+	parts := make([]string, len(s.Keys))
+	for i, key := range s.Keys {
+		parts[i] = string(key.Field)
+		if key.Desc {
+			parts[i] = "-" + parts[i]
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Weights of smart ordering, a todo with higher score goes first.
+const (
+	SmartOverdueWeight  = 100
+	SmartPriorityWeight = 10
+	SmartDueDayWeight   = 25
+	SmartDue3DaysWeight = 15
+	SmartDueWeekWeight  = 5
+)
+
+// SmartDueWindow adds Weight to score of todo due within Within from now.
+type SmartDueWindow struct {
+	Within time.Duration
+	Weight int
+}
+
+// SmartDueWindows are checked in order, only the first window todo is due
+// within counts.
+var SmartDueWindows = []SmartDueWindow{
+	{Within: 24 * time.Hour, Weight: SmartDueDayWeight},
+	{Within: 3 * 24 * time.Hour, Weight: SmartDue3DaysWeight},
+	{Within: 7 * 24 * time.Hour, Weight: SmartDueWeekWeight},
+}
+
+// SmartScore blends overdue status, priority and due proximity into a
+// single number. Completed todos always score zero.
+func SmartScore(todo TodoPF, now time.Time) int {
+	if todo.Done {
+		return 0
+	}
+
+	score := todo.Priority.Int() * SmartPriorityWeight
+
+	if todo.DueAt != nil {
+		untilDue := todo.DueAt.Sub(now)
+		if untilDue < 0 {
+			return score + SmartOverdueWeight
+		}
+
+		for _, window := range SmartDueWindows {
+			if untilDue <= window.Within {
+				return score + window.Weight
+			}
+		}
+	}
+
+	return score
+}
+
+// SortTodos orders todos in place. Todos without due date go after todos
+// with due date regardless of direction.
+func SortTodos(todos []TodoPF, todoSort TodoSort, now time.Time) {
+	if todoSort.Smart {
+		sort.SliceStable(todos, func(i, j int) bool {
+			a, b := todos[i], todos[j]
+			if a.Done != b.Done {
+				return !a.Done
+			}
+
+			scoreA, scoreB := SmartScore(a, now), SmartScore(b, now)
+			if scoreA != scoreB {
+				return scoreA > scoreB
+			}
+
+			if c := compareDue(a.DueAt, b.DueAt); c != 0 {
+				return c < 0
+			}
+
+			return a.ID < b.ID
+		})
+		return
+	}
+
+	sort.SliceStable(todos, func(i, j int) bool {
+		a, b := todos[i], todos[j]
+		for _, key := range todoSort.Keys {
+			var c int
+			switch key.Field {
+			case SortByPriority:
+				c = a.Priority.Int() - b.Priority.Int()
+			case SortByDue:
+				if (a.DueAt == nil) != (b.DueAt == nil) {
+					return a.DueAt != nil
+				}
+				c = compareDue(a.DueAt, b.DueAt)
+			case SortByCreated:
+				c = a.CreatedAt.Compare(b.CreatedAt)
+			case SortByUpdated:
+				c = a.UpdatedAt.Compare(b.UpdatedAt)
+			case SortByPosition:
+				c = strings.Compare(a.Position.String(), b.Position.String())
+			}
+
+			if c != 0 {
+				if key.Desc {
+					return c > 0
+				}
+				return c < 0
+			}
+		}
+
+		return a.ID < b.ID
+	})
+}
+
+func compareDue(a *time.Time, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	default:
+		return a.Compare(*b)
+	}
+}
@@ -0,0 +1,67 @@
+package todolist_model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTodoSort(t *testing.T) {
+	todoSort, err := ParseTodoSort("-priority,due")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := todoSort.String(); got != "-priority,due" {
+		t.Fatalf("got %q", got)
+	}
+
+	for _, value := range []string{"title", "due,due", "priority,"} {
+		if _, err := ParseTodoSort(value); !errors.Is(err, ErrSort) {
+			t.Fatalf("expected ErrSort for %q, got %v", value, err)
+		}
+	}
+}
+
+func TestSortTodos(t *testing.T) {
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	todos := []TodoPF{
+		{ID: 1, Priority: PriorityUrgent},
+		{ID: 2, Priority: PriorityLow, DueAt: at(-time.Hour)},
+		{ID: 3, Priority: PriorityHigh, DueAt: at(2 * time.Hour)},
+		{ID: 4, Priority: PriorityHigh, DueAt: at(48 * time.Hour)},
+		{ID: 5, Priority: PriorityUrgent, Done: true},
+	}
+
+	tests := []struct {
+		sort string
+		want []TodoID
+	}{
+		{"smart", []TodoID{2, 3, 4, 1, 5}},
+		{"-priority,due", []TodoID{1, 5, 3, 4, 2}},
+		{"due", []TodoID{2, 3, 4, 1, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			todoSort, err := ParseTodoSort(tt.sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sorted := append([]TodoPF(nil), todos...)
+			SortTodos(sorted, todoSort, now)
+
+			for i, todo := range sorted {
+				if todo.ID != tt.want[i] {
+					t.Fatalf("got %v, want %v", todoIDs(sorted), tt.want)
+				}
+			}
+		})
+	}
+}
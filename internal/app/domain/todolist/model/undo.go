@@ -0,0 +1,143 @@
+package todolist_model
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNothingToUndo = fmt.Errorf("%w: nothing to undo", Err)
+	ErrNothingToRedo = fmt.Errorf("%w: nothing to redo", Err)
+	ErrUndoConflict  = fmt.Errorf("%w: todo was changed since, operation cannot be reverted", Err)
+)
+
+// Undo reverts events recorded by a single operation by applying inverse
+// changes in reverse order. Inverse changes are recorded as usual events,
+// so undo is reverted by another Undo of these events.
+//
+// Operation is reverted only if none of its todos was changed after it,
+// otherwise todolist is left untouched and ErrUndoConflict is returned.
+// trashed are todos from trash and archive which events refer to,
+// todolist does not hold them.
+func (l *Todolist) Undo(events []Event, trashed []Todo) error {
+	if err := l.checkUndo(events, trashed); err != nil {
+		return err
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if err := l.undoEvent(events[i], trashed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkUndo checks that every todo of events is still in the state left by
+// the last of events, any later change has a later timestamp.
+func (l *Todolist) checkUndo(events []Event, trashed []Todo) error {
+	stamps := map[TodoID]Timestamp{}
+	for _, event := range events {
+		stamps[event.TodoID] = event.Stamp
+	}
+
+	for todoID, stamp := range stamps {
+		todo, ok := l.findAnyTodo(todoID, trashed)
+		if !ok || todo.clock.Latest() != stamp {
+			return ErrUndoConflict
+		}
+	}
+
+	return nil
+}
+
+func (l *Todolist) findAnyTodo(todoID TodoID, trashed []Todo) (Todo, bool) {
+	for _, todo := range l.todos {
+		if todo.id.Equal(todoID) {
+			return todo, true
+		}
+	}
+
+	for _, todo := range trashed {
+		if todo.id.Equal(todoID) {
+			return todo, true
+		}
+	}
+
+	return Todo{}, false
+}
+
+func (l *Todolist) undoEvent(event Event, trashed []Todo) error {
+	switch event.Name {
+	case EventTodoAdded, EventTodoRestored:
+		return l.DeleteTodo(event.TodoID)
+	case EventTodoDeleted:
+		todo, ok := l.findAnyTodo(event.TodoID, trashed)
+		if !ok {
+			return ErrUndoConflict
+		}
+		return l.RestoreTodo(todo)
+	case EventTodoArchived:
+		todo, ok := l.findAnyTodo(event.TodoID, trashed)
+		if !ok {
+			return ErrUndoConflict
+		}
+		return l.UnarchiveTodo(todo)
+	case EventTodoUnarchived:
+		return l.ArchiveTodo(event.TodoID)
+	case EventTodoCompleted:
+		return l.UncompleteTodo(event.TodoID)
+	case EventTodoUncompleted:
+		return l.CompleteTodo(event.TodoID)
+	case EventTitleChanged:
+		return l.ChangeTitle(event.TodoID, event.OldValue)
+	case EventCommentChanged:
+		return l.ChangeComment(event.TodoID, event.OldValue)
+	case EventPriorityChanged:
+		priority, err := ParsePriority(event.OldValue)
+		if err != nil {
+			return err
+		}
+		return l.ChangePriority(event.TodoID, priority)
+	case EventDueChanged:
+		var dueAt *time.Time
+		if event.OldValue != "" {
+			due, err := time.Parse(time.RFC3339, event.OldValue)
+			if err != nil {
+				return err
+			}
+			dueAt = &due
+		}
+		return l.ChangeDue(event.TodoID, dueAt)
+	case EventTodoTagged, EventTodoUntagged:
+		name := event.NewValue
+		if event.Name == EventTodoUntagged {
+			name = event.OldValue
+		}
+
+		// tag was renamed or deleted since
+		tag, ok := l.findTagByName(name)
+		if !ok {
+			return ErrUndoConflict
+		}
+
+		if event.Name == EventTodoTagged {
+			return l.UntagTodo(event.TodoID, l.tags[tag].id)
+		}
+		return l.TagTodo(event.TodoID, l.tags[tag].id)
+	case EventTodoMoved:
+		i, ok := l.findTodo(event.TodoID)
+		if !ok {
+			return ErrNotFound
+		}
+
+		position, err := NewPosition(event.OldValue)
+		if err != nil {
+			return err
+		}
+		l.moveTo(i, position)
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown event %s", ErrUndoConflict, event.Name)
+	}
+}
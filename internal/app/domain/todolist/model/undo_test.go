@@ -0,0 +1,85 @@
+package todolist_model
+
+import (
+	"errors"
+	"testing"
+)
+
+// operation runs f like a service call and returns events it recorded.
+func operation(t *testing.T, list *Todolist, f func() error) []Event {
+	t.Helper()
+
+	list.ClearEvents()
+	if err := f(); err != nil {
+		t.Fatal(err)
+	}
+
+	events := list.Events()
+	list.ClearEvents()
+	return events
+}
+
+func TestUndoRedo(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	completed := operation(t, list, func() error {
+		if err := list.ChangeTitle(1, "write final report"); err != nil {
+			return err
+		}
+		return list.CompleteTodo(1)
+	})
+
+	undone := operation(t, list, func() error { return list.Undo(completed, nil) })
+	if todo, _ := list.Todo(1); todo.done || todo.title != "write report" {
+		t.Fatalf("operation is not undone: %+v", todo.PF())
+	}
+
+	operation(t, list, func() error { return list.Undo(undone, nil) })
+	if todo, _ := list.Todo(1); !todo.done || todo.title != "write final report" {
+		t.Fatalf("operation is not redone: %+v", todo.PF())
+	}
+}
+
+func TestUndoDeleteAndTags(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	untagged := operation(t, list, func() error { return list.UntagTodo(1, 2) })
+	deleted := operation(t, list, func() error { return list.DeleteTodo(3) })
+
+	// trashed todo is not loaded with todolist
+	trashed := []Todo{list.todos[2]}
+	list.todos = list.todos[:2]
+
+	operation(t, list, func() error { return list.Undo(deleted, trashed) })
+	if _, err := list.Todo(3); err != nil {
+		t.Fatalf("expected todo to be restored, got %v", err)
+	}
+
+	operation(t, list, func() error { return list.Undo(untagged, nil) })
+	if todo, _ := list.Todo(1); !todo.HasTag(2) {
+		t.Fatalf("expected tag to be restored: %+v", todo.PF())
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	list := newTaggedTodolist(t)
+
+	completed := operation(t, list, func() error { return list.CompleteTodo(1) })
+	operation(t, list, func() error { return list.ChangePriority(1, PriorityHigh) })
+
+	list.ClearEvents()
+	if err := list.Undo(completed, nil); !errors.Is(err, ErrUndoConflict) {
+		t.Fatalf("expected ErrUndoConflict, got %v", err)
+	}
+
+	if todo, _ := list.Todo(1); !todo.done || len(list.Events()) != 0 {
+		t.Fatalf("todolist is changed by refused undo: %+v", todo.PF())
+	}
+
+	// other todos do not block undo
+	titled := operation(t, list, func() error { return list.ChangeTitle(2, "call alice") })
+	operation(t, list, func() error { return list.CompleteTodo(3) })
+	if err := list.Undo(titled, nil); err != nil {
+		t.Fatal(err)
+	}
+}
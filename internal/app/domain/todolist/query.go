@@ -0,0 +1,76 @@
+package todolist_domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 200
+)
+
+var (
+	ErrInvalidCursor = fmt.Errorf("%w: invalid cursor", todolist_model.Err)
+	ErrInvalidLimit  = fmt.Errorf("%w: limit must be between 1 and %d", todolist_model.Err, MaxQueryLimit)
+)
+
+// TodoQuery describes a page of todos of a single user. Nil and empty fields
+// do not filter.
+type TodoQuery struct {
+	// Trashed selects todos from trash instead of todolist.
+	Trashed bool
+	// Archived selects todos from archive instead of todolist.
+	Archived bool
+
+	Done   *bool
+	Search string
+
+	Tags     []string
+	TagMatch todolist_model.TagMatch
+
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+
+	Sort   todolist_model.TodoSort
+	Limit  int
+	Cursor string
+}
+
+type TodoPage struct {
+	Todos []todolist_model.TodoPF
+	Tags  []todolist_model.TagPF
+	// NextCursor is empty when there are no more todos.
+	NextCursor string
+}
+
+type TodoQueryRepository interface {
+	Query(ctx context.Context, userID access_domain.UserID, query TodoQuery, tx util.Transaction) (TodoPage, error)
+}
+
+func (s *TodolistService) QueryTodos(
+	ctx context.Context,
+	userID access_domain.UserID,
+	query TodoQuery,
+) (TodoPage, error) {
+	if query.Limit == 0 {
+		query.Limit = DefaultQueryLimit
+	}
+
+	if query.Limit < 0 || query.Limit > MaxQueryLimit {
+		return TodoPage{}, ErrInvalidLimit
+	}
+
+	if !query.Sort.Smart && len(query.Sort.Keys) == 0 {
+		query.Sort = todolist_model.DefaultTodoSort
+	}
+
+	return s.todoQueryRepo.Query(ctx, userID, query, nil)
+}
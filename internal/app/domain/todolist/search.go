@@ -0,0 +1,63 @@
+package todolist_domain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const DefaultSearchLimit = 20
+
+var ErrSearchQueryIsEmpty = fmt.Errorf("%w: search query is empty", todolist_model.Err)
+
+// SearchHit is a todo matching search query. Snippets are HTML escaped text
+// with matched words wrapped in <mark></mark>.
+type SearchHit struct {
+	Todo           todolist_model.TodoPF
+	Rank           float64
+	TitleSnippet   string
+	CommentSnippet string
+}
+
+type SearchResult struct {
+	Hits []SearchHit
+	Tags []todolist_model.TagPF
+}
+
+// SearchRepository finds todos of user whose title or comment contain every
+// word of query as a word prefix, so "rep" matches "report". Hits are ordered
+// by rank, most relevant first.
+type SearchRepository interface {
+	Search(
+		ctx context.Context,
+		userID access_domain.UserID,
+		query string,
+		limit int,
+		tx util.Transaction,
+	) (SearchResult, error)
+}
+
+func (s *TodolistService) Search(
+	ctx context.Context,
+	userID access_domain.UserID,
+	query string,
+	limit int,
+) (SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return SearchResult{}, ErrSearchQueryIsEmpty
+	}
+
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+
+	if limit < 0 || limit > MaxQueryLimit {
+		return SearchResult{}, ErrInvalidLimit
+	}
+
+	return s.searchRepo.Search(ctx, userID, query, limit, nil)
+}
@@ -2,8 +2,12 @@ package todolist_domain
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"os"
+	"time"
 
 	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
 	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
@@ -12,33 +16,128 @@ import (
 
 //go:generate mockgen -package todolist_domain -source=./service.go -destination=./mock.go *
 
+const serverNodeSuffixLength = 8
+
 var ErrTodolistNotFound = fmt.Errorf("%w: todolist not found", todolist_model.Err)
 
 type TodoRepository interface {
+	// NextID returns id never given to other todo, ids of purged todos are
+	// not reused, since they are still known to tombstones, undo stacks
+	// and clients.
 	NextID(ctx context.Context, tx util.Transaction) (todolist_model.TodoID, error)
 }
 
+type TagRepository interface {
+	NextID(ctx context.Context, tx util.Transaction) (todolist_model.TagID, error)
+}
+
 type TodolistRepository interface {
 	Get(ctx context.Context, userID access_domain.UserID, tx util.Transaction) (*todolist_model.Todolist, error)
+	// Save merges into todolist todos saved since it was loaded, see
+	// Todolist.MergeTodos, and saves the result.
 	Save(ctx context.Context, todolist *todolist_model.Todolist, tx util.Transaction) error
 }
 
+// EventOutbox stores events for delivery to other systems, it must write
+// them in the given transaction.
+type EventOutbox interface {
+	Enqueue(ctx context.Context, events []todolist_model.Event, tx util.Transaction) error
+}
+
+// EventNotifier is told about events once transaction which saved them is
+// committed, e.g. to push them to connected clients.
+type EventNotifier interface {
+	Notify(events []todolist_model.Event)
+}
+
+// EventNotifiers notifies every notifier in order.
+type EventNotifiers []EventNotifier
+
+func (n EventNotifiers) Notify(events []todolist_model.Event) {
+	for _, notifier := range n {
+		notifier.Notify(events)
+	}
+}
+
 type TodolistService struct {
-	txFactory    util.TransactionFactory
-	todolistRepo TodolistRepository
-	todoRepo     TodoRepository
+	txFactory          util.TransactionFactory
+	clock              *todolist_model.Clock
+	todolistRepo       TodolistRepository
+	todoRepo           TodoRepository
+	tagRepo            TagRepository
+	todoQueryRepo      TodoQueryRepository
+	searchRepo         SearchRepository
+	trashRepo          TrashRepository
+	historyRepo        HistoryRepository
+	syncRepo           SyncRepository
+	undoRepo           UndoRepository
+	archiveRepo        ArchiveRepository
+	exportRepo         ExportRepository
+	calendarRepo       CalendarRepository
+	calendarObjectRepo CalendarObjectRepository
+	outbox             EventOutbox
+	notifier           EventNotifier
+}
+
+// TodoServiceDeps are dependencies of TodolistService. Repositories of
+// features which are not used may be left nil.
+type TodoServiceDeps struct {
+	TxFactory          util.TransactionFactory
+	Clock              *todolist_model.Clock
+	TodolistRepo       TodolistRepository
+	TodoRepo           TodoRepository
+	TagRepo            TagRepository
+	TodoQueryRepo      TodoQueryRepository
+	SearchRepo         SearchRepository
+	TrashRepo          TrashRepository
+	HistoryRepo        HistoryRepository
+	SyncRepo           SyncRepository
+	UndoRepo           UndoRepository
+	ArchiveRepo        ArchiveRepository
+	ExportRepo         ExportRepository
+	CalendarRepo       CalendarRepository
+	CalendarObjectRepo CalendarObjectRepository
+	Outbox             EventOutbox
+	Notifier           EventNotifier
 }
 
-func NewTodoService(
-	txFactory util.TransactionFactory,
-	todolistRepo TodolistRepository,
-	todoRepo TodoRepository,
-) *TodolistService {
+func NewTodoService(deps TodoServiceDeps) *TodolistService {
 	return &TodolistService{
-		txFactory:    txFactory,
-		todolistRepo: todolistRepo,
-		todoRepo:     todoRepo,
+		txFactory:          deps.TxFactory,
+		clock:              deps.Clock,
+		todolistRepo:       deps.TodolistRepo,
+		todoRepo:           deps.TodoRepo,
+		tagRepo:            deps.TagRepo,
+		todoQueryRepo:      deps.TodoQueryRepo,
+		searchRepo:         deps.SearchRepo,
+		trashRepo:          deps.TrashRepo,
+		historyRepo:        deps.HistoryRepo,
+		syncRepo:           deps.SyncRepo,
+		undoRepo:           deps.UndoRepo,
+		archiveRepo:        deps.ArchiveRepo,
+		exportRepo:         deps.ExportRepo,
+		calendarRepo:       deps.CalendarRepo,
+		calendarObjectRepo: deps.CalendarObjectRepo,
+		outbox:             deps.Outbox,
+		notifier:           deps.Notifier,
+	}
+}
+
+// NewServerClock returns clock stamping changes made by this process. Node
+// of clock is hostname with random suffix, so instances serving the same
+// todolists never issue equal timestamps.
+func NewServerClock() (*todolist_model.Clock, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
 	}
+
+	suffix := make([]byte, serverNodeSuffixLength)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	return todolist_model.NewClock(hostname + "-" + hex.EncodeToString(suffix)), nil
 }
 
 func (s *TodolistService) GetTodolist(
@@ -48,12 +147,16 @@ func (s *TodolistService) GetTodolist(
 	return s.getOrCreateTodolist(ctx, userID, nil)
 }
 
+// AddTodo adds todo to todolist and returns it as it is saved.
 func (s *TodolistService) AddTodo(
 	ctx context.Context,
 	userID access_domain.UserID,
 	title string,
-) error {
-	return s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+	priority todolist_model.Priority,
+	dueAt *time.Time,
+) (todolist_model.TodoPF, error) {
+	var added todolist_model.TodoPF
+	err := s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
 		list, err := s.getOrCreateTodolist(ctx, userID, tx)
 		if err != nil {
 			return err
@@ -65,16 +168,32 @@ func (s *TodolistService) AddTodo(
 		}
 
 		list.AddTodo(todoID, title)
+		if err := list.ChangePriority(todoID, priority); err != nil {
+			return err
+		}
+
+		if err := list.ChangeDue(todoID, dueAt); err != nil {
+			return err
+		}
+
 		if err := list.Validate(); err != nil {
 			return err
 		}
 
-		if err := s.todolistRepo.Save(ctx, list, tx); err != nil {
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		todo, err := list.Todo(todoID)
+		if err != nil {
 			return err
 		}
+		added = todo.PF()
 
 		return nil
 	})
+
+	return added, err
 }
 
 func (s *TodolistService) CompleteTodo(
@@ -82,7 +201,7 @@ func (s *TodolistService) CompleteTodo(
 	userID access_domain.UserID,
 	todoID todolist_model.TodoID,
 ) error {
-	return s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
 		list, err := s.getOrCreateTodolist(ctx, userID, tx)
 		if err != nil {
 			return err
@@ -96,7 +215,7 @@ func (s *TodolistService) CompleteTodo(
 			return err
 		}
 
-		if err := s.todolistRepo.Save(ctx, list, tx); err != nil {
+		if err := s.save(ctx, list, tx); err != nil {
 			return err
 		}
 
@@ -109,7 +228,7 @@ func (s *TodolistService) UncompleteTodo(
 	userID access_domain.UserID,
 	todoID todolist_model.TodoID,
 ) error {
-	return s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
 		list, err := s.getOrCreateTodolist(ctx, userID, tx)
 		if err != nil {
 			return err
@@ -123,7 +242,35 @@ func (s *TodolistService) UncompleteTodo(
 			return err
 		}
 
-		if err := s.todolistRepo.Save(ctx, list, tx); err != nil {
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) ChangeTitle(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	title string,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.ChangeTitle(todoID, title); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
 			return err
 		}
 
@@ -137,7 +284,7 @@ func (s *TodolistService) ChangeComment(
 	todoID todolist_model.TodoID,
 	comment string,
 ) error {
-	return s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
 		list, err := s.getOrCreateTodolist(ctx, userID, tx)
 		if err != nil {
 			return err
@@ -151,7 +298,238 @@ func (s *TodolistService) ChangeComment(
 			return err
 		}
 
-		if err := s.todolistRepo.Save(ctx, list, tx); err != nil {
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) ChangePriority(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	priority todolist_model.Priority,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.ChangePriority(todoID, priority); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) ChangeDue(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	dueAt *time.Time,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.ChangeDue(todoID, dueAt); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// MoveTodo moves todo right after another todo, nil after moves todo to
+// the start of todolist.
+func (s *TodolistService) MoveTodo(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	after *todolist_model.TodoID,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.MoveTodo(todoID, after); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) AddTag(
+	ctx context.Context,
+	userID access_domain.UserID,
+	name string,
+	color todolist_model.TagColor,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := s.tagRepo.NextID(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.AddTag(tagID, name, color); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) RenameTag(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tagID todolist_model.TagID,
+	name string,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.RenameTag(tagID, name); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) MergeTags(
+	ctx context.Context,
+	userID access_domain.UserID,
+	fromID todolist_model.TagID,
+	intoID todolist_model.TagID,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.MergeTags(fromID, intoID); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) TagTodo(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	tagID todolist_model.TagID,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.TagTodo(todoID, tagID); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) UntagTodo(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	tagID todolist_model.TagID,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.UntagTodo(todoID, tagID); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
 			return err
 		}
 
@@ -169,7 +547,7 @@ func (s *TodolistService) getOrCreateTodolist(
 		if errors.Is(err, ErrTodolistNotFound) {
 			list = todolist_model.NewTodolistEmpty(userID)
 
-			if err := s.todolistRepo.Save(ctx, list, tx); err != nil {
+			if err := s.save(ctx, list, tx); err != nil {
 				return nil, err
 			}
 		} else {
@@ -177,5 +555,62 @@ func (s *TodolistService) getOrCreateTodolist(
 		}
 	}
 
+	list.UseClock(s.clock)
 	return list, nil
 }
+
+// save persists todolist and puts its recorded events to outbox within the
+// same transaction.
+func (s *TodolistService) save(
+	ctx context.Context,
+	list *todolist_model.Todolist,
+	tx util.Transaction,
+) error {
+	if err := s.todolistRepo.Save(ctx, list, tx); err != nil {
+		return err
+	}
+
+	if err := s.outbox.Enqueue(ctx, list.Events(), tx); err != nil {
+		return err
+	}
+
+	if saved, ok := ctx.Value(savedEventsKey{}).(*[]todolist_model.Event); ok {
+		*saved = append(*saved, list.Events()...)
+	}
+
+	list.ClearEvents()
+	return nil
+}
+
+type savedEventsKey struct{}
+
+// withTransaction runs f in transaction and notifies about events saved by
+// f after the transaction, or transaction of ctx it joins, is committed, f
+// must save todolist with ctx it is given. Saved events are recorded as a
+// single operation to undo.
+func (s *TodolistService) withTransaction(
+	ctx context.Context,
+	f func(ctx context.Context, tx util.Transaction) error,
+) error {
+	var saved []todolist_model.Event
+	ctx = context.WithValue(ctx, savedEventsKey{}, &saved)
+
+	if err := s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		saved = saved[:0]
+		if err := f(ctx, tx); err != nil {
+			return err
+		}
+
+		return s.recordUndo(ctx, saved, tx)
+	}); err != nil {
+		return err
+	}
+
+	if len(saved) > 0 {
+		util.AfterCommit(ctx, func() {
+			s.notifier.Notify(saved)
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,348 @@
+package todolist_domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	DefaultSyncLimit  = 500
+	MaxSyncLimit      = 1000
+	MaxSyncOperations = 100
+
+	MaxSyncOperationIDLength = 100
+)
+
+var (
+	ErrSyncOperationsLimit = fmt.Errorf("%w: at most %d operations are allowed", todolist_model.Err, MaxSyncOperations)
+	ErrSyncOperationID     = fmt.Errorf("%w: operation id must be 1 to %d characters", todolist_model.Err, MaxSyncOperationIDLength)
+	ErrSyncOperationType   = fmt.Errorf("%w: unknown operation type", todolist_model.Err)
+)
+
+// TodoChange is the latest state of todo changed after sync cursor. Todo is
+// nil when todo was purged from trash, trashed todos are returned with
+// DeletedAt set.
+type TodoChange struct {
+	Seq    int64
+	TodoID todolist_model.TodoID
+	Todo   *todolist_model.TodoPF
+}
+
+type SyncPage struct {
+	Changes []TodoChange
+	Tags    []todolist_model.TagPF
+	// Cursor is passed as since to get changes after this page.
+	Cursor  string
+	HasMore bool
+}
+
+type SyncOperationType string
+
+const (
+	SyncAddTodo        SyncOperationType = "add"
+	SyncCompleteTodo   SyncOperationType = "complete"
+	SyncUncompleteTodo SyncOperationType = "uncomplete"
+	SyncChangeTitle    SyncOperationType = "change_title"
+	SyncChangeComment  SyncOperationType = "change_comment"
+	SyncChangePriority SyncOperationType = "change_priority"
+	SyncChangeDue      SyncOperationType = "change_due"
+	SyncDeleteTodo     SyncOperationType = "delete"
+	SyncRestoreTodo    SyncOperationType = "restore"
+	SyncTagTodo        SyncOperationType = "tag"
+	SyncUntagTodo      SyncOperationType = "untag"
+)
+
+// SyncOperation is a change made by client while it was offline. ID is
+// generated by client, operation with already applied ID is not applied
+// again.
+type SyncOperation struct {
+	ID     string
+	Type   SyncOperationType
+	TodoID todolist_model.TodoID
+
+	Title    string
+	Comment  string
+	Priority todolist_model.Priority
+	DueAt    *time.Time
+	TagID    todolist_model.TagID
+
+	// BaseUpdatedAt is updatedAt of todo known to client, operation
+	// conflicts when todo was changed after it. Nil skips the check.
+	BaseUpdatedAt *time.Time
+}
+
+type SyncStatus string
+
+const (
+	SyncApplied  SyncStatus = "applied"
+	SyncConflict SyncStatus = "conflict"
+	SyncRejected SyncStatus = "rejected"
+)
+
+type SyncResult struct {
+	OperationID string
+	Status      SyncStatus
+	// TodoID is id of changed todo, for add it is id of created todo.
+	TodoID todolist_model.TodoID
+	Error  string
+	// Duplicate is true when operation was applied by earlier request,
+	// result of that request is returned.
+	Duplicate bool
+	// Todo is current state of todo on conflict.
+	Todo *todolist_model.TodoPF
+}
+
+type SyncRepository interface {
+	// Changes returns up to limit changes of user todos with sequence
+	// greater than since, ordered by sequence.
+	Changes(
+		ctx context.Context,
+		userID access_domain.UserID,
+		since int64,
+		limit int,
+		tx util.Transaction,
+	) ([]TodoChange, error)
+	GetOperation(
+		ctx context.Context,
+		userID access_domain.UserID,
+		operationID string,
+		tx util.Transaction,
+	) (SyncResult, bool, error)
+	SaveOperation(ctx context.Context, userID access_domain.UserID, result SyncResult, tx util.Transaction) error
+}
+
+// Changes returns todos changed after cursor, empty cursor returns every
+// todo of user.
+func (s *TodolistService) Changes(
+	ctx context.Context,
+	userID access_domain.UserID,
+	cursor string,
+	limit int,
+) (SyncPage, error) {
+	if limit == 0 {
+		limit = DefaultSyncLimit
+	}
+	if limit < 0 || limit > MaxSyncLimit {
+		return SyncPage{}, ErrInvalidLimit
+	}
+
+	var since int64
+	if cursor != "" {
+		var err error
+		since, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || since < 0 {
+			return SyncPage{}, ErrInvalidCursor
+		}
+	}
+
+	changes, err := s.syncRepo.Changes(ctx, userID, since, limit+1, nil)
+	if err != nil {
+		return SyncPage{}, err
+	}
+
+	page := SyncPage{Cursor: strconv.FormatInt(since, 10)}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		page.HasMore = true
+	}
+	if len(changes) > 0 {
+		page.Cursor = strconv.FormatInt(changes[len(changes)-1].Seq, 10)
+	}
+	page.Changes = changes
+
+	list, err := s.getOrCreateTodolist(ctx, userID, nil)
+	if err != nil {
+		return SyncPage{}, err
+	}
+	page.Tags = list.PF().Tags
+
+	return page, nil
+}
+
+// Sync applies client operations in order, each in its own transaction.
+// Operations rejected by the domain or conflicting with newer changes are
+// reported in results and remembered like applied ones.
+func (s *TodolistService) Sync(
+	ctx context.Context,
+	userID access_domain.UserID,
+	operations []SyncOperation,
+) ([]SyncResult, error) {
+	if len(operations) > MaxSyncOperations {
+		return nil, ErrSyncOperationsLimit
+	}
+
+	for _, operation := range operations {
+		if operation.ID == "" || len(operation.ID) > MaxSyncOperationIDLength {
+			return nil, ErrSyncOperationID
+		}
+	}
+
+	results := make([]SyncResult, len(operations))
+	for i, operation := range operations {
+		err := s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+			result, ok, err := s.syncRepo.GetOperation(ctx, userID, operation.ID, tx)
+			if err != nil {
+				return err
+			}
+			if ok {
+				result.Duplicate = true
+				results[i] = result
+				return nil
+			}
+
+			list, err := s.getOrCreateTodolist(ctx, userID, tx)
+			if err != nil {
+				return err
+			}
+
+			result, err = s.applySyncOperation(ctx, list, operation, tx)
+			if err != nil {
+				return err
+			}
+
+			if result.Status == SyncApplied {
+				if err := s.save(ctx, list, tx); err != nil {
+					return err
+				}
+			}
+
+			results[i] = result
+			return s.syncRepo.SaveOperation(ctx, userID, result, tx)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// applySyncOperation applies operation to list, domain errors are returned
+// as rejected result.
+func (s *TodolistService) applySyncOperation(
+	ctx context.Context,
+	list *todolist_model.Todolist,
+	operation SyncOperation,
+	tx util.Transaction,
+) (SyncResult, error) {
+	result := SyncResult{
+		OperationID: operation.ID,
+		Status:      SyncApplied,
+		TodoID:      operation.TodoID,
+	}
+
+	err := s.checkSyncConflict(ctx, list, operation, &result, tx)
+	if err == nil && result.Status == SyncConflict {
+		return result, nil
+	}
+	if err == nil {
+		err = s.applySyncChange(ctx, list, operation, &result, tx)
+	}
+	if err == nil {
+		err = list.Validate()
+	}
+
+	if errors.Is(err, todolist_model.Err) {
+		result.Status = SyncRejected
+		result.Error = err.Error()
+		return result, nil
+	}
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	return result, nil
+}
+
+func (s *TodolistService) checkSyncConflict(
+	ctx context.Context,
+	list *todolist_model.Todolist,
+	operation SyncOperation,
+	result *SyncResult,
+	tx util.Transaction,
+) error {
+	if operation.Type == SyncAddTodo || operation.BaseUpdatedAt == nil {
+		return nil
+	}
+
+	var todo todolist_model.Todo
+	var err error
+	if operation.Type == SyncRestoreTodo {
+		todo, err = s.trashRepo.GetTrashed(ctx, list.PF().UserID, operation.TodoID, tx)
+	} else {
+		todo, err = list.Todo(operation.TodoID)
+	}
+	if err != nil {
+		return err
+	}
+
+	todoPF := todo.PF()
+	// timestamps are stored with microsecond precision
+	if todoPF.UpdatedAt.Truncate(time.Microsecond).After(operation.BaseUpdatedAt.Truncate(time.Microsecond)) {
+		result.Status = SyncConflict
+		result.Todo = &todoPF
+	}
+
+	return nil
+}
+
+func (s *TodolistService) applySyncChange(
+	ctx context.Context,
+	list *todolist_model.Todolist,
+	operation SyncOperation,
+	result *SyncResult,
+	tx util.Transaction,
+) error {
+	switch operation.Type {
+	case SyncAddTodo:
+		todoID, err := s.todoRepo.NextID(ctx, tx)
+		if err != nil {
+			return err
+		}
+		result.TodoID = todoID
+
+		list.AddTodo(todoID, operation.Title)
+		if operation.Comment != "" {
+			if err := list.ChangeComment(todoID, operation.Comment); err != nil {
+				return err
+			}
+		}
+		if err := list.ChangePriority(todoID, operation.Priority); err != nil {
+			return err
+		}
+		return list.ChangeDue(todoID, operation.DueAt)
+	case SyncCompleteTodo:
+		return list.CompleteTodo(operation.TodoID)
+	case SyncUncompleteTodo:
+		return list.UncompleteTodo(operation.TodoID)
+	case SyncChangeTitle:
+		return list.ChangeTitle(operation.TodoID, operation.Title)
+	case SyncChangeComment:
+		return list.ChangeComment(operation.TodoID, operation.Comment)
+	case SyncChangePriority:
+		return list.ChangePriority(operation.TodoID, operation.Priority)
+	case SyncChangeDue:
+		return list.ChangeDue(operation.TodoID, operation.DueAt)
+	case SyncDeleteTodo:
+		return list.DeleteTodo(operation.TodoID)
+	case SyncRestoreTodo:
+		todo, err := s.trashRepo.GetTrashed(ctx, list.PF().UserID, operation.TodoID, tx)
+		if err != nil {
+			return err
+		}
+		return list.RestoreTodo(todo)
+	case SyncTagTodo:
+		return list.TagTodo(operation.TodoID, operation.TagID)
+	case SyncUntagTodo:
+		return list.UntagTodo(operation.TodoID, operation.TagID)
+	default:
+		return ErrSyncOperationType
+	}
+}
@@ -0,0 +1,196 @@
+package todolist_domain
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func syncStatuses(results []SyncResult) []SyncStatus {
+	statuses := make([]SyncStatus, len(results))
+	for i, result := range results {
+		statuses[i] = result.Status
+	}
+
+	return statuses
+}
+
+func TestSyncSkipsAppliedOperations(t *testing.T) {
+	f := newFixture()
+	ctx := context.Background()
+
+	operations := []SyncOperation{
+		{ID: "add-milk", Type: SyncAddTodo, Title: "milk"},
+		{ID: "complete-unknown", Type: SyncCompleteTodo, TodoID: 42},
+	}
+
+	results, err := f.service.Sync(ctx, testUserID, operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := syncStatuses(results); !reflect.DeepEqual(got, []SyncStatus{SyncApplied, SyncRejected}) {
+		t.Fatalf("statuses = %v", got)
+	}
+	if results[0].Duplicate || results[1].Duplicate {
+		t.Fatalf("first results are duplicates: %+v", results)
+	}
+	todoID := results[0].TodoID
+	events := len(f.outbox.events)
+
+	// client retries after lost response, changed payload is ignored
+	operations[0].Title = "bread"
+	retried, err := f.service.Sync(ctx, testUserID, operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, result := range retried {
+		if !result.Duplicate {
+			t.Fatalf("result %d is not duplicate: %+v", i, result)
+		}
+		result.Duplicate = false
+		if !reflect.DeepEqual(result, results[i]) {
+			t.Fatalf("result %d = %+v, want %+v", i, result, results[i])
+		}
+	}
+
+	if todos := f.repo.todos(testUserID); len(todos) != 1 || todos[0].ID != todoID || todos[0].Title != "milk" {
+		t.Fatalf("todos = %+v", todos)
+	}
+	if len(f.outbox.events) != events {
+		t.Fatalf("retry enqueued %d events", len(f.outbox.events)-events)
+	}
+}
+
+func TestSyncReportsConflict(t *testing.T) {
+	f := newFixture()
+	ctx := context.Background()
+
+	results, err := f.service.Sync(ctx, testUserID, []SyncOperation{{ID: "add", Type: SyncAddTodo, Title: "milk"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo := f.todo(t, results[0].TodoID)
+	stale := todo.UpdatedAt.Add(-time.Second)
+
+	results, err = f.service.Sync(ctx, testUserID, []SyncOperation{
+		// client saw todo before its last change
+		{ID: "stale", Type: SyncChangeTitle, TodoID: todo.ID, Title: "bread", BaseUpdatedAt: &stale},
+		{ID: "unchecked", Type: SyncChangeComment, TodoID: todo.ID, Comment: "2 bottles"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := syncStatuses(results); !reflect.DeepEqual(got, []SyncStatus{SyncConflict, SyncApplied}) {
+		t.Fatalf("statuses = %v", got)
+	}
+	if results[0].Todo == nil || results[0].Todo.Title != "milk" {
+		t.Fatalf("conflict todo = %+v", results[0].Todo)
+	}
+
+	todo = f.todo(t, todo.ID)
+	if todo.Title != "milk" || todo.Comment != "2 bottles" {
+		t.Fatalf("todo = %+v", todo)
+	}
+
+	// client caught up with the latest change
+	current := todo.UpdatedAt
+	results, err = f.service.Sync(ctx, testUserID, []SyncOperation{
+		{ID: "current", Type: SyncChangeTitle, TodoID: todo.ID, Title: "bread", BaseUpdatedAt: &current},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Status != SyncApplied || f.todo(t, todo.ID).Title != "bread" {
+		t.Fatalf("result = %+v, todo = %+v", results[0], f.todo(t, todo.ID))
+	}
+}
+
+func TestChangesPages(t *testing.T) {
+	f := newFixture()
+	ctx := context.Background()
+
+	var operations []SyncOperation
+	for _, title := range []string{"milk", "bread", "eggs", "tea", "jam"} {
+		operations = append(operations, SyncOperation{ID: "add-" + title, Type: SyncAddTodo, Title: title})
+	}
+	added, err := f.service.Sync(ctx, testUserID, operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cursor  string
+		titles  []string
+		hasMore = true
+	)
+	for pages := 0; hasMore; pages++ {
+		if pages == 3 {
+			t.Fatal("changes do not end after 3 pages")
+		}
+
+		page, err := f.service.Changes(ctx, testUserID, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Changes) > 2 {
+			t.Fatalf("page has %d changes", len(page.Changes))
+		}
+
+		for _, change := range page.Changes {
+			titles = append(titles, change.Todo.Title)
+		}
+		cursor, hasMore = page.Cursor, page.HasMore
+	}
+
+	if want := []string{"milk", "bread", "eggs", "tea", "jam"}; !reflect.DeepEqual(titles, want) {
+		t.Fatalf("titles = %v, want %v", titles, want)
+	}
+
+	// only changes made after cursor are returned
+	if _, err := f.service.Sync(ctx, testUserID, []SyncOperation{
+		{ID: "complete", Type: SyncCompleteTodo, TodoID: added[1].TodoID},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := f.service.Changes(ctx, testUserID, cursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Changes) != 1 || !page.Changes[0].Todo.Done || page.HasMore {
+		t.Fatalf("page = %+v", page)
+	}
+
+	page, err = f.service.Changes(ctx, testUserID, page.Cursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Changes) != 0 || page.HasMore {
+		t.Fatalf("page after the last change = %+v", page)
+	}
+
+	if _, err := f.service.Changes(ctx, testUserID, "not a cursor", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := f.service.Changes(ctx, testUserID, "", MaxSyncLimit+1); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("expected ErrInvalidLimit, got %v", err)
+	}
+}
+
+func TestSyncOperationIDs(t *testing.T) {
+	f := newFixture()
+
+	for _, id := range []string{"", string(make([]byte, MaxSyncOperationIDLength+1))} {
+		_, err := f.service.Sync(context.Background(), testUserID, []SyncOperation{
+			{ID: id, Type: SyncAddTodo, Title: "milk"},
+		})
+		if !errors.Is(err, ErrSyncOperationID) {
+			t.Fatalf("expected ErrSyncOperationID for %d characters, got %v", len(id), err)
+		}
+	}
+}
@@ -1 +1,476 @@
 package todolist_domain
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const testUserID access_domain.UserID = 1
+
+// memoryTodolist keeps todos of user by id, trashed and archived todos are
+// kept too but not loaded with todolist. seqs are sequence numbers of the
+// last change of todos.
+type memoryTodolist struct {
+	todos map[todolist_model.TodoID]todolist_model.TodoPF
+	seqs  map[todolist_model.TodoID]int64
+	tags  []todolist_model.TagPF
+}
+
+// memoryRepository keeps todolists of users, changes made in
+// util.TestTransaction are undone when it is rolled back.
+type memoryRepository struct {
+	lists map[access_domain.UserID]memoryTodolist
+	// seq is the last change sequence number, like database sequence it is
+	// not rolled back.
+	seq int64
+	// beforeSave is called once by the next Save before it reads stored
+	// todos, e.g. to save a concurrent change.
+	beforeSave func()
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{lists: make(map[access_domain.UserID]memoryTodolist)}
+}
+
+var (
+	_ TodolistRepository = (*memoryRepository)(nil)
+	_ TrashRepository    = (*memoryRepository)(nil)
+)
+
+func (r *memoryRepository) todo(todoPF todolist_model.TodoPF) (todolist_model.Todo, error) {
+	return todolist_model.NewTodoFromDB(
+		todoPF.ID,
+		todoPF.Title,
+		todoPF.Comment,
+		todoPF.Done,
+		todoPF.Tags,
+		todoPF.Priority,
+		todoPF.DueAt,
+		todoPF.CreatedAt,
+		todoPF.UpdatedAt,
+		todoPF.DeletedAt,
+		todoPF.CompletedAt,
+		todoPF.ArchivedAt,
+		todoPF.Position,
+		todoPF.Clock,
+	)
+}
+
+func (r *memoryRepository) Get(ctx context.Context, userID access_domain.UserID, tx util.Transaction) (*todolist_model.Todolist, error) {
+	stored, ok := r.lists[userID]
+	if !ok {
+		return nil, ErrTodolistNotFound
+	}
+
+	var todos []todolist_model.Todo
+	for _, todoPF := range stored.todos {
+		if todoPF.DeletedAt != nil || todoPF.ArchivedAt != nil {
+			continue
+		}
+
+		todo, err := r.todo(todoPF)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	var tags []todolist_model.Tag
+	for _, tagPF := range stored.tags {
+		tag, err := todolist_model.NewTag(tagPF.ID, tagPF.Name, tagPF.Color)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return todolist_model.NewTodolist(userID, todos, tags)
+}
+
+func (r *memoryRepository) Save(ctx context.Context, list *todolist_model.Todolist, tx util.Transaction) error {
+	if beforeSave := r.beforeSave; beforeSave != nil {
+		r.beforeSave = nil
+		beforeSave()
+	}
+
+	userID := list.PF().UserID
+	previous, existed := r.lists[userID]
+
+	// like PostrgesTodolistRepository changes saved since todolist was
+	// loaded are merged
+	var saved []todolist_model.Todo
+	for _, todoPF := range list.PF().Todos {
+		if savedPF, ok := previous.todos[todoPF.ID]; ok {
+			todo, err := r.todo(savedPF)
+			if err != nil {
+				return err
+			}
+			saved = append(saved, todo)
+		}
+	}
+	list.MergeTodos(saved)
+	listPF := list.PF()
+
+	util.OnRollbackTest(tx, func() {
+		if existed {
+			r.lists[userID] = previous
+		} else {
+			delete(r.lists, userID)
+		}
+	})
+
+	stored := memoryTodolist{
+		todos: make(map[todolist_model.TodoID]todolist_model.TodoPF, len(previous.todos)+len(listPF.Todos)),
+		seqs:  make(map[todolist_model.TodoID]int64, len(previous.todos)+len(listPF.Todos)),
+		tags:  listPF.Tags,
+	}
+	for id, todoPF := range previous.todos {
+		stored.todos[id] = todoPF
+		stored.seqs[id] = previous.seqs[id]
+	}
+	for _, todoPF := range listPF.Todos {
+		if old, ok := stored.todos[todoPF.ID]; ok && reflect.DeepEqual(old, todoPF) {
+			continue
+		}
+
+		r.seq++
+		stored.todos[todoPF.ID] = todoPF
+		stored.seqs[todoPF.ID] = r.seq
+	}
+	r.lists[userID] = stored
+
+	return nil
+}
+
+func (r *memoryRepository) GetTrashed(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+	tx util.Transaction,
+) (todolist_model.Todo, error) {
+	todoPF, ok := r.lists[userID].todos[todoID]
+	if !ok || todoPF.DeletedAt == nil {
+		return todolist_model.Todo{}, todolist_model.ErrNotFound
+	}
+
+	return r.todo(todoPF)
+}
+
+func (r *memoryRepository) Purge(ctx context.Context, deletedBefore time.Time, tx util.Transaction) (int64, error) {
+	var purged int64
+	for _, stored := range r.lists {
+		for id, todoPF := range stored.todos {
+			if todoPF.DeletedAt != nil && todoPF.DeletedAt.Before(deletedBefore) {
+				delete(stored.todos, id)
+				purged++
+			}
+		}
+	}
+
+	return purged, nil
+}
+
+// todos returns every stored todo of user, including trashed ones.
+func (r *memoryRepository) todos(userID access_domain.UserID) []todolist_model.TodoPF {
+	var todos []todolist_model.TodoPF
+	for _, todoPF := range r.lists[userID].todos {
+		todos = append(todos, todoPF)
+	}
+
+	return todos
+}
+
+// memorySyncRepository returns changes of todos saved to repo and
+// remembers results of sync operations.
+type memorySyncRepository struct {
+	repo       *memoryRepository
+	operations map[syncOperationKey]SyncResult
+}
+
+type syncOperationKey struct {
+	userID      access_domain.UserID
+	operationID string
+}
+
+var _ SyncRepository = (*memorySyncRepository)(nil)
+
+func (r *memorySyncRepository) Changes(
+	ctx context.Context,
+	userID access_domain.UserID,
+	since int64,
+	limit int,
+	tx util.Transaction,
+) ([]TodoChange, error) {
+	stored := r.repo.lists[userID]
+
+	var changes []TodoChange
+	for id, todoPF := range stored.todos {
+		if seq := stored.seqs[id]; seq > since {
+			todoPF := todoPF
+			changes = append(changes, TodoChange{Seq: seq, TodoID: id, Todo: &todoPF})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Seq < changes[j].Seq })
+
+	return changes[:min(limit, len(changes))], nil
+}
+
+func (r *memorySyncRepository) GetOperation(
+	ctx context.Context,
+	userID access_domain.UserID,
+	operationID string,
+	tx util.Transaction,
+) (SyncResult, bool, error) {
+	result, ok := r.operations[syncOperationKey{userID: userID, operationID: operationID}]
+	return result, ok, nil
+}
+
+func (r *memorySyncRepository) SaveOperation(
+	ctx context.Context,
+	userID access_domain.UserID,
+	result SyncResult,
+	tx util.Transaction,
+) error {
+	key := syncOperationKey{userID: userID, operationID: result.OperationID}
+	util.OnRollbackTest(tx, func() { delete(r.operations, key) })
+
+	r.operations[key] = result
+	return nil
+}
+
+// memoryTodoIDs is sequence of todo ids, like database sequence it is not
+// rolled back.
+type memoryTodoIDs struct {
+	last todolist_model.TodoID
+}
+
+func (s *memoryTodoIDs) NextID(ctx context.Context, tx util.Transaction) (todolist_model.TodoID, error) {
+	s.last++
+	return s.last, nil
+}
+
+type memoryTagIDs struct {
+	repo *memoryRepository
+}
+
+func (s memoryTagIDs) NextID(ctx context.Context, tx util.Transaction) (todolist_model.TagID, error) {
+	var last todolist_model.TagID
+	for _, stored := range s.repo.lists {
+		for _, tag := range stored.tags {
+			last = max(last, tag.ID)
+		}
+	}
+
+	return last + 1, nil
+}
+
+type memoryOutbox struct {
+	events []todolist_model.Event
+}
+
+func (o *memoryOutbox) Enqueue(ctx context.Context, events []todolist_model.Event, tx util.Transaction) error {
+	previous := o.events
+	util.OnRollbackTest(tx, func() { o.events = previous })
+
+	o.events = append(o.events[:len(o.events):len(o.events)], events...)
+	return nil
+}
+
+type recordingNotifier struct {
+	events []todolist_model.Event
+}
+
+func (n *recordingNotifier) Notify(events []todolist_model.Event) {
+	n.events = append(n.events, events...)
+}
+
+type undoKey struct {
+	userID access_domain.UserID
+	stack  UndoStack
+}
+
+type memoryUndoRepository struct {
+	stacks map[undoKey][][]todolist_model.Event
+}
+
+func (r *memoryUndoRepository) set(key undoKey, operations [][]todolist_model.Event, tx util.Transaction) {
+	previous := r.stacks[key]
+	util.OnRollbackTest(tx, func() { r.stacks[key] = previous })
+
+	r.stacks[key] = operations
+}
+
+func (r *memoryUndoRepository) Push(
+	ctx context.Context,
+	userID access_domain.UserID,
+	stack UndoStack,
+	events []todolist_model.Event,
+	depth int,
+	tx util.Transaction,
+) error {
+	key := undoKey{userID: userID, stack: stack}
+	operations := append(r.stacks[key][:len(r.stacks[key]):len(r.stacks[key])], events)
+	if len(operations) > depth {
+		operations = operations[len(operations)-depth:]
+	}
+	r.set(key, operations, tx)
+
+	return nil
+}
+
+func (r *memoryUndoRepository) Pop(
+	ctx context.Context,
+	userID access_domain.UserID,
+	stack UndoStack,
+	tx util.Transaction,
+) ([]todolist_model.Event, error) {
+	key := undoKey{userID: userID, stack: stack}
+	operations := r.stacks[key]
+	if len(operations) == 0 {
+		return nil, nil
+	}
+	r.set(key, operations[:len(operations)-1], tx)
+
+	return operations[len(operations)-1], nil
+}
+
+func (r *memoryUndoRepository) Clear(ctx context.Context, userID access_domain.UserID, stack UndoStack, tx util.Transaction) error {
+	r.set(undoKey{userID: userID, stack: stack}, nil, tx)
+	return nil
+}
+
+// fixture is service with in-memory repositories, repositories service does
+// not use in tests are nil.
+type fixture struct {
+	repo     *memoryRepository
+	sync     *memorySyncRepository
+	outbox   *memoryOutbox
+	notifier *recordingNotifier
+	service  *TodolistService
+}
+
+func newFixture() *fixture {
+	f := &fixture{
+		repo:     newMemoryRepository(),
+		outbox:   &memoryOutbox{},
+		notifier: &recordingNotifier{},
+	}
+	f.sync = &memorySyncRepository{repo: f.repo, operations: make(map[syncOperationKey]SyncResult)}
+	f.service = NewTodoService(TodoServiceDeps{
+		TxFactory:    util.NewTransactionFactoryTest(),
+		Clock:        todolist_model.NewClock("test"),
+		TodolistRepo: f.repo,
+		TodoRepo:     &memoryTodoIDs{},
+		TagRepo:      memoryTagIDs{repo: f.repo},
+		TrashRepo:    f.repo,
+		SyncRepo:     f.sync,
+		UndoRepo:     &memoryUndoRepository{stacks: make(map[undoKey][][]todolist_model.Event)},
+		Outbox:       f.outbox,
+		Notifier:     f.notifier,
+	})
+
+	return f
+}
+
+func TestImportDryRunInContextTransaction(t *testing.T) {
+	f := newFixture()
+	file := ImportFile{Todos: []ImportTodo{
+		{Line: 1, Title: "milk", Tags: []string{"shop"}},
+		{Line: 2, Title: "bread"},
+	}}
+
+	// ctx carries transaction of request with idempotency key, dry run
+	// must undo its changes even though it does not own the transaction
+	ctx, committed := util.ContextWithTransaction(context.Background(), &util.TestTransaction{})
+	result, err := f.service.Import(ctx, testUserID, file, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed()
+
+	if result.Created != 2 || !result.DryRun {
+		t.Fatalf("result = %+v", result)
+	}
+	if _, ok := f.repo.lists[testUserID]; ok {
+		t.Fatalf("dry run saved todolist: %+v", f.repo.lists[testUserID])
+	}
+	if len(f.outbox.events) != 0 || len(f.notifier.events) != 0 {
+		t.Fatalf("dry run events: outbox %+v, notified %+v", f.outbox.events, f.notifier.events)
+	}
+
+	if _, err := f.service.Import(context.Background(), testUserID, file, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if todos := f.repo.todos(testUserID); len(todos) != 2 {
+		t.Fatalf("todos = %+v", todos)
+	}
+	if len(f.notifier.events) == 0 {
+		t.Fatal("import is not notified")
+	}
+}
+
+func TestServiceStampsChangesWithItsClock(t *testing.T) {
+	f := newFixture()
+	added, err := f.service.AddTodo(context.Background(), testUserID, "milk", todolist_model.PriorityNone, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.ID != 1 || added.Title != "milk" {
+		t.Fatalf("added todo = %+v", added)
+	}
+
+	if node := f.todo(t, 1).Clock.Title.Node; node != "test" {
+		t.Fatalf("todo is stamped by node %q", node)
+	}
+
+	a, err := NewServerClock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewServerClock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Now().Node == b.Now().Node {
+		t.Fatalf("processes share node %q", a.Now().Node)
+	}
+}
+
+func TestConcurrentChangesAreMerged(t *testing.T) {
+	f := newFixture()
+	ctx := context.Background()
+	for _, title := range []string{"milk", "tea"} {
+		if _, err := f.service.AddTodo(ctx, testUserID, title, todolist_model.PriorityNone, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// changes of another request are saved after title change loaded
+	// todolist, but before it is saved
+	f.repo.beforeSave = func() {
+		if err := f.service.ChangeComment(ctx, testUserID, 1, "2 bottles"); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.service.CompleteTodo(ctx, testUserID, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.service.ChangeTitle(ctx, testUserID, 1, "oat milk"); err != nil {
+		t.Fatal(err)
+	}
+
+	if todo := f.todo(t, 1); todo.Title != "oat milk" || todo.Comment != "2 bottles" {
+		t.Fatalf("todo 1 = %+v", todo)
+	}
+	if todo := f.todo(t, 2); !todo.Done {
+		t.Fatalf("todo 2 = %+v", todo)
+	}
+}
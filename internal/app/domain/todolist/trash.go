@@ -0,0 +1,150 @@
+package todolist_domain
+
+import (
+	"context"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	DefaultTrashRetention     = 30 * 24 * time.Hour
+	DefaultTrashPurgeInterval = time.Hour
+)
+
+type TrashRepository interface {
+	// GetTrashed returns todo of user from trash or todolist_model.ErrNotFound.
+	GetTrashed(
+		ctx context.Context,
+		userID access_domain.UserID,
+		todoID todolist_model.TodoID,
+		tx util.Transaction,
+	) (todolist_model.Todo, error)
+	// Purge hard deletes todos trashed before deletedBefore.
+	Purge(ctx context.Context, deletedBefore time.Time, tx util.Transaction) (int64, error)
+}
+
+func (s *TodolistService) DeleteTodo(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.DeleteTodo(todoID); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) RestoreTodo(
+	ctx context.Context,
+	userID access_domain.UserID,
+	todoID todolist_model.TodoID,
+) error {
+	return s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		todo, err := s.trashRepo.GetTrashed(ctx, userID, todoID, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := list.RestoreTodo(todo); err != nil {
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *TodolistService) QueryTrash(
+	ctx context.Context,
+	userID access_domain.UserID,
+	query TodoQuery,
+) (TodoPage, error) {
+	query.Trashed = true
+	return s.QueryTodos(ctx, userID, query)
+}
+
+// TrashPurger periodically hard deletes todos which stayed in trash longer
+// than retention.
+type TrashPurger struct {
+	trashRepo TrashRepository
+	logger    util.Logger
+
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTrashPurger(
+	trashRepo TrashRepository,
+	logger util.Logger,
+	retention time.Duration,
+	interval time.Duration,
+) *TrashPurger {
+	return &TrashPurger{
+		trashRepo: trashRepo,
+		logger:    logger,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges trash every interval until ctx is done.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Purge(ctx); err != nil {
+			p.logger.WithError(err).Error("failed to purge trash")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) Purge(ctx context.Context) error {
+	purged, err := p.trashRepo.Purge(ctx, time.Now().Add(-p.retention), nil)
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		p.logger.WithField("count", purged).Info("purged todos from trash")
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package todolist_domain
+
+import (
+	"context"
+	"errors"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// MaxUndoDepth is the number of the latest operations of user which can be
+// undone.
+const MaxUndoDepth = 50
+
+type UndoStack string
+
+const (
+	UndoStackUndo UndoStack = "undo"
+	UndoStackRedo UndoStack = "redo"
+)
+
+// UndoRepository stores stacks of operations of user, an operation is
+// stored as events it recorded, see todolist_model.Todolist.Undo.
+type UndoRepository interface {
+	// Push puts operation on top of stack, only depth latest operations are
+	// kept.
+	Push(
+		ctx context.Context,
+		userID access_domain.UserID,
+		stack UndoStack,
+		events []todolist_model.Event,
+		depth int,
+		tx util.Transaction,
+	) error
+	// Pop removes operation from top of stack, events are empty when stack
+	// is empty.
+	Pop(
+		ctx context.Context,
+		userID access_domain.UserID,
+		stack UndoStack,
+		tx util.Transaction,
+	) ([]todolist_model.Event, error)
+	Clear(ctx context.Context, userID access_domain.UserID, stack UndoStack, tx util.Transaction) error
+}
+
+// Undo reverts the latest operation of user, it can be redone with Redo.
+// Operation is dropped with todolist_model.ErrUndoConflict when its todos
+// were changed since.
+func (s *TodolistService) Undo(ctx context.Context, userID access_domain.UserID) error {
+	return s.replay(ctx, userID, UndoStackUndo, UndoStackRedo, todolist_model.ErrNothingToUndo)
+}
+
+// Redo reverts the latest Undo of user.
+func (s *TodolistService) Redo(ctx context.Context, userID access_domain.UserID) error {
+	return s.replay(ctx, userID, UndoStackRedo, UndoStackUndo, todolist_model.ErrNothingToRedo)
+}
+
+type skipUndoKey struct{}
+
+// withoutUndo marks operations made with ctx as not undoable.
+func withoutUndo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipUndoKey{}, true)
+}
+
+// replay reverts operation from top of stack from and puts reverting
+// operation on stack to.
+func (s *TodolistService) replay(
+	ctx context.Context,
+	userID access_domain.UserID,
+	from UndoStack,
+	to UndoStack,
+	errEmpty error,
+) error {
+	var conflict error
+
+	ctx = withoutUndo(ctx)
+	err := s.withTransaction(ctx, func(ctx context.Context, tx util.Transaction) error {
+		conflict = nil
+
+		events, err := s.undoRepo.Pop(ctx, userID, from, tx)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return errEmpty
+		}
+
+		list, err := s.getOrCreateTodolist(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		var trashed []todolist_model.Todo
+		for _, event := range events {
+			if _, err := list.Todo(event.TodoID); err == nil {
+				continue
+			}
+
+			todo, err := s.trashRepo.GetTrashed(ctx, userID, event.TodoID, tx)
+			if errors.Is(err, todolist_model.ErrNotFound) {
+				todo, err = s.archiveRepo.GetArchived(ctx, userID, event.TodoID, tx)
+			}
+			if errors.Is(err, todolist_model.ErrNotFound) {
+				// purged, Undo reports conflict
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			trashed = append(trashed, todo)
+		}
+
+		if err := list.Undo(events, trashed); err != nil {
+			if errors.Is(err, todolist_model.ErrUndoConflict) {
+				// commit pop, operation can never be reverted
+				conflict = err
+				return nil
+			}
+			return err
+		}
+
+		if err := list.Validate(); err != nil {
+			return err
+		}
+
+		reverting := list.Events()
+		if err := s.save(ctx, list, tx); err != nil {
+			return err
+		}
+
+		return s.undoRepo.Push(ctx, userID, to, reverting, MaxUndoDepth, tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	return conflict
+}
+
+// recordUndo puts operation which saved events on undo stack, a new
+// operation makes undone operations impossible to redo.
+func (s *TodolistService) recordUndo(
+	ctx context.Context,
+	events []todolist_model.Event,
+	tx util.Transaction,
+) error {
+	if skip, _ := ctx.Value(skipUndoKey{}).(bool); skip || len(events) == 0 {
+		return nil
+	}
+
+	userID := events[0].UserID
+	if err := s.undoRepo.Push(ctx, userID, UndoStackUndo, events, MaxUndoDepth, tx); err != nil {
+		return err
+	}
+
+	return s.undoRepo.Clear(ctx, userID, UndoStackRedo, tx)
+}
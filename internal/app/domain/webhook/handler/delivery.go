@@ -0,0 +1,113 @@
+package webhook_handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	webhook_model "github.com/kotsmile/everd-backend/internal/app/domain/webhook/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type DeliveryResponse struct {
+	ID            int64      `json:"id"`
+	EventID       string     `json:"event_id"`
+	Event         string     `json:"event"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	ResponseCode  int        `json:"response_code"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+}
+
+func toDeliveryResponse(delivery webhook_model.Delivery) DeliveryResponse {
+	deliveryPF := delivery.PF()
+
+	var nextAttemptAt *time.Time
+	if deliveryPF.Status == webhook_model.DeliveryPending {
+		nextAttemptAt = &deliveryPF.NextAttemptAt
+	}
+
+	return DeliveryResponse{
+		ID:            deliveryPF.ID.Int64(),
+		EventID:       deliveryPF.EventID,
+		Event:         deliveryPF.EventName,
+		Status:        string(deliveryPF.Status),
+		Attempts:      deliveryPF.Attempts,
+		ResponseCode:  deliveryPF.ResponseCode,
+		LastError:     deliveryPF.LastError,
+		NextAttemptAt: nextAttemptAt,
+		CreatedAt:     deliveryPF.CreatedAt,
+		DeliveredAt:   deliveryPF.DeliveredAt,
+	}
+}
+
+type GetDeliveriesResponse struct {
+	Deliveries []DeliveryResponse `json:"deliveries"`
+}
+
+// GetDeliveries returns delivery log of webhook, newest first.
+func (h *WebhookHandler) GetDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := webhookIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			return util.
+				NewHTTPError("invalid limit").
+				WithStatus(http.StatusBadRequest).
+				WithError(err)
+		}
+	}
+
+	deliveries, err := h.service.Deliveries(ctx, userID, webhookID, limit)
+	if err != nil {
+		return domainError(err)
+	}
+
+	deliveriesResponse := GetDeliveriesResponse{
+		Deliveries: make([]DeliveryResponse, len(deliveries)),
+	}
+	for i, delivery := range deliveries {
+		deliveriesResponse.Deliveries[i] = toDeliveryResponse(delivery)
+	}
+
+	return h.OkJSON(w, deliveriesResponse)
+}
+
+type PostRedeliverResponse struct{}
+
+func (h *WebhookHandler) PostRedeliver(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := webhookIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	deliveryID, err := deliveryIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Redeliver(ctx, userID, webhookID, deliveryID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostRedeliverResponse{})
+}
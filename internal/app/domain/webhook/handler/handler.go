@@ -0,0 +1,217 @@
+package webhook_handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	webhook_domain "github.com/kotsmile/everd-backend/internal/app/domain/webhook"
+	webhook_model "github.com/kotsmile/everd-backend/internal/app/domain/webhook/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type WebhookHandler struct {
+	*util.ApiHelper
+	service *webhook_domain.WebhookService
+}
+
+func NewWebhookHandler(service *webhook_domain.WebhookService, apiHelper *util.ApiHelper) *WebhookHandler {
+	return &WebhookHandler{
+		ApiHelper: apiHelper,
+		service:   service,
+	}
+}
+
+func userIDFromContext(ctx context.Context) (access_domain.UserID, error) {
+	userID, ok := ctx.Value("userID").(access_domain.UserID)
+	if !ok {
+		return access_domain.NilUserID, util.
+			NewHTTPError("unauthorized").
+			WithStatus(http.StatusUnauthorized).
+			WithErrorMessage("user id is not provided")
+	}
+
+	return userID, nil
+}
+
+func webhookIDFromPath(r *http.Request) (webhook_model.WebhookID, error) {
+	webhookIDInt, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return webhook_model.NilWebhookID, util.
+			NewHTTPError("invalid webhook id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	webhookID, err := webhook_model.NewWebhookID(webhookIDInt)
+	if err != nil {
+		return webhook_model.NilWebhookID, util.
+			NewHTTPError("invalid webhook id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	return webhookID, nil
+}
+
+func deliveryIDFromPath(r *http.Request) (webhook_model.DeliveryID, error) {
+	deliveryIDInt, err := strconv.ParseInt(mux.Vars(r)["deliveryID"], 10, 64)
+	if err != nil {
+		return webhook_model.NilDeliveryID, util.
+			NewHTTPError("invalid delivery id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	deliveryID, err := webhook_model.NewDeliveryID(deliveryIDInt)
+	if err != nil {
+		return webhook_model.NilDeliveryID, util.
+			NewHTTPError("invalid delivery id").
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	}
+
+	return deliveryID, nil
+}
+
+func domainError(err error) error {
+	switch {
+	case errors.Is(err, webhook_model.ErrNotFound),
+		errors.Is(err, webhook_model.ErrDeliveryNotFound):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusNotFound).
+			WithError(err)
+	case errors.Is(err, webhook_model.Err):
+		return util.
+			NewHTTPError(err.Error()).
+			WithStatus(http.StatusBadRequest).
+			WithError(err)
+	default:
+		return err
+	}
+}
+
+type WebhookResponse struct {
+	ID                  int       `json:"id"`
+	URL                 string    `json:"url"`
+	Events              []string  `json:"events"`
+	Enabled             bool      `json:"enabled"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func toWebhookResponse(webhook webhook_model.Webhook) WebhookResponse {
+	webhookPF := webhook.PF()
+	return WebhookResponse{
+		ID:                  webhookPF.ID.Int(),
+		URL:                 webhookPF.URL,
+		Events:              webhookPF.Events,
+		Enabled:             webhookPF.Enabled,
+		ConsecutiveFailures: webhookPF.ConsecutiveFailures,
+		CreatedAt:           webhookPF.CreatedAt,
+	}
+}
+
+type GetWebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+func (h *WebhookHandler) GetWebhooks(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := h.service.List(ctx, userID)
+	if err != nil {
+		return domainError(err)
+	}
+
+	webhooksResponse := GetWebhooksResponse{
+		Webhooks: make([]WebhookResponse, len(webhooks)),
+	}
+	for i, webhook := range webhooks {
+		webhooksResponse.Webhooks[i] = toWebhookResponse(webhook)
+	}
+
+	return h.OkJSON(w, webhooksResponse)
+}
+
+type PostWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,max=2000"`
+	Events []string `json:"events"`
+}
+
+// PostWebhookResponse is the only response containing webhook secret.
+type PostWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+func (h *WebhookHandler) PostWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var webhookRequest PostWebhookRequest
+	if err := h.ReadValidJSON(w, r, &webhookRequest); err != nil {
+		return err
+	}
+
+	webhook, err := h.service.Register(ctx, userID, webhookRequest.URL, webhookRequest.Events)
+	if err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostWebhookResponse{
+		WebhookResponse: toWebhookResponse(webhook),
+		Secret:          webhook.PF().Secret,
+	})
+}
+
+type DeleteWebhookResponse struct{}
+
+func (h *WebhookHandler) DeleteWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := webhookIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Delete(ctx, userID, webhookID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, DeleteWebhookResponse{})
+}
+
+type PostWebhookEnableResponse struct{}
+
+// PostWebhookEnable enables webhook disabled after consecutive failures.
+func (h *WebhookHandler) PostWebhookEnable(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := webhookIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Enable(ctx, userID, webhookID); err != nil {
+		return domainError(err)
+	}
+
+	return h.OkJSON(w, PostWebhookEnableResponse{})
+}
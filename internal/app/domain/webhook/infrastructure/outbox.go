@@ -0,0 +1,30 @@
+package webhook_infrastructure
+
+import (
+	"context"
+	"strconv"
+
+	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+	webhook_domain "github.com/kotsmile/everd-backend/internal/app/domain/webhook"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/outbox"
+)
+
+// TodolistEventHandler queues webhook deliveries of todolist events, it is
+// subscribed to todolist_infrastructure.EventTopic. Outbox message id is
+// used as event id, so receivers can drop duplicates of the same event.
+func TodolistEventHandler(service *webhook_domain.WebhookService) outbox.LocalHandler {
+	return func(ctx context.Context, message outbox.Message) error {
+		event, err := todolist_infrastructure.DecodeEventMessage(message)
+		if err != nil {
+			return err
+		}
+
+		return service.Publish(
+			ctx,
+			event.UserID,
+			strconv.FormatInt(message.ID, 10),
+			string(event.Name),
+			message.Payload,
+		)
+	}
+}
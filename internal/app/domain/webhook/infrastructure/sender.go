@@ -0,0 +1,106 @@
+package webhook_infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	webhook_domain "github.com/kotsmile/everd-backend/internal/app/domain/webhook"
+	webhook_model "github.com/kotsmile/everd-backend/internal/app/domain/webhook/model"
+)
+
+const (
+	DefaultSendTimeout = 10 * time.Second
+	// maxResponseBody bounds how much of receiver response is read, it is
+	// only used in error message.
+	maxResponseBody = 1024
+)
+
+// HTTPSender posts delivery payload to webhook url signed with webhook
+// secret.
+type HTTPSender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewHTTPSender returns sender using client, nil client is replaced by one
+// dialing only public addresses.
+func NewHTTPSender(client *http.Client) *HTTPSender {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultSendTimeout, Transport: newPublicTransport()}
+	}
+
+	return &HTTPSender{client: client, now: time.Now}
+}
+
+var _ webhook_domain.Sender = (*HTTPSender)(nil)
+
+func (s *HTTPSender) Send(
+	ctx context.Context,
+	webhook webhook_model.WebhookPF,
+	delivery webhook_model.DeliveryPF,
+) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := s.now()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "everd-webhooks")
+	request.Header.Set(webhook_domain.HeaderEvent, delivery.EventName)
+	request.Header.Set(webhook_domain.HeaderEventID, delivery.EventID)
+	request.Header.Set(webhook_domain.HeaderDelivery, strconv.FormatInt(delivery.ID.Int64(), 10))
+	request.Header.Set(webhook_domain.HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	request.Header.Set(webhook_domain.HeaderSignature, webhook_domain.Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseBody))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected response status %d: %s", response.StatusCode, body)
+	}
+
+	return response.StatusCode, nil
+}
+
+// newPublicTransport returns transport which refuses to connect to addresses
+// which are not public. Address is checked when it is dialed, after host is
+// resolved, so hosts resolving to private addresses, e.g. by DNS rebinding,
+// and redirects to them are rejected too.
+func newPublicTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// proxy would be dialed instead of webhook host
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   DefaultSendTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   publicAddrControl,
+	}).DialContext
+
+	return transport
+}
+
+func publicAddrControl(network string, address string, conn syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !webhook_model.IsPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", webhook_model.ErrURLIsNotPublic, addrPort.Addr())
+	}
+
+	return nil
+}
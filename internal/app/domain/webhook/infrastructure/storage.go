@@ -0,0 +1,491 @@
+package webhook_infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	webhook_domain "github.com/kotsmile/everd-backend/internal/app/domain/webhook"
+	webhook_model "github.com/kotsmile/everd-backend/internal/app/domain/webhook/model"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type WebhookDTO struct {
+	ID                  int
+	UserID              int
+	URL                 string
+	Secret              string
+	Events              []byte
+	Enabled             bool
+	ConsecutiveFailures int
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+const webhookColumns = `id, user_id, url, secret, events, enabled,
+	consecutive_failures, created_at, updated_at`
+
+func scanWebhookDTO(row interface{ Scan(...any) error }) (WebhookDTO, error) {
+	var webhookDTO WebhookDTO
+	err := row.Scan(
+		&webhookDTO.ID,
+		&webhookDTO.UserID,
+		&webhookDTO.URL,
+		&webhookDTO.Secret,
+		&webhookDTO.Events,
+		&webhookDTO.Enabled,
+		&webhookDTO.ConsecutiveFailures,
+		&webhookDTO.CreatedAt,
+		&webhookDTO.UpdatedAt,
+	)
+
+	return webhookDTO, err
+}
+
+func fromWebhookDTO(webhookDTO WebhookDTO) (webhook_model.Webhook, error) {
+	id, err := webhook_model.NewWebhookID(webhookDTO.ID)
+	if err != nil {
+		return webhook_model.Webhook{}, err
+	}
+
+	userID, err := access_domain.NewUserID(webhookDTO.UserID)
+	if err != nil {
+		return webhook_model.Webhook{}, err
+	}
+
+	var events []string
+	if err := json.Unmarshal(webhookDTO.Events, &events); err != nil {
+		return webhook_model.Webhook{}, err
+	}
+
+	return webhook_model.NewWebhookFromDB(
+		id,
+		userID,
+		webhookDTO.URL,
+		webhookDTO.Secret,
+		events,
+		webhookDTO.Enabled,
+		webhookDTO.ConsecutiveFailures,
+		webhookDTO.CreatedAt,
+		webhookDTO.UpdatedAt,
+	)
+}
+
+type PostrgesWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesWebhookRepository(db *sql.DB) *PostrgesWebhookRepository {
+	return &PostrgesWebhookRepository{db: db}
+}
+
+var _ webhook_domain.WebhookRepository = (*PostrgesWebhookRepository)(nil)
+
+func (r *PostrgesWebhookRepository) NextID(
+	ctx context.Context,
+	tx util.Transaction,
+) (webhook_model.WebhookID, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return webhook_model.NilWebhookID, err
+	}
+
+	var id int
+	if err := exec.QueryRow("select coalesce(max(id), 0) from webhooks").Scan(&id); err != nil {
+		return webhook_model.NilWebhookID, err
+	}
+
+	return webhook_model.NewWebhookID(id + 1)
+}
+
+func (r *PostrgesWebhookRepository) Get(
+	ctx context.Context,
+	id webhook_model.WebhookID,
+	tx util.Transaction,
+) (webhook_model.Webhook, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return webhook_model.Webhook{}, err
+	}
+
+	webhookDTO, err := scanWebhookDTO(exec.QueryRow(`select `+webhookColumns+`
+		from webhooks
+		where id = $1`, id.Int()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhook_model.Webhook{}, webhook_model.ErrNotFound
+	}
+	if err != nil {
+		return webhook_model.Webhook{}, err
+	}
+
+	return fromWebhookDTO(webhookDTO)
+}
+
+func (r *PostrgesWebhookRepository) List(
+	ctx context.Context,
+	userID access_domain.UserID,
+	tx util.Transaction,
+) ([]webhook_model.Webhook, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.Query(`select `+webhookColumns+`
+		from webhooks
+		where user_id = $1
+		order by id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []webhook_model.Webhook{}
+	for rows.Next() {
+		webhookDTO, err := scanWebhookDTO(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhook, err := fromWebhookDTO(webhookDTO)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (r *PostrgesWebhookRepository) Save(
+	ctx context.Context,
+	webhook webhook_model.Webhook,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	webhookPF := webhook.PF()
+	events, err := json.Marshal(webhookPF.Events)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`insert into webhooks
+		(`+webhookColumns+`)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		on conflict (id) do update set
+			url = excluded.url,
+			secret = excluded.secret,
+			events = excluded.events,
+			enabled = excluded.enabled,
+			consecutive_failures = excluded.consecutive_failures,
+			updated_at = excluded.updated_at`,
+		webhookPF.ID.Int(),
+		webhookPF.UserID,
+		webhookPF.URL,
+		webhookPF.Secret,
+		events,
+		webhookPF.Enabled,
+		webhookPF.ConsecutiveFailures,
+		webhookPF.CreatedAt,
+		webhookPF.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *PostrgesWebhookRepository) Delete(
+	ctx context.Context,
+	id webhook_model.WebhookID,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	// webhook_deliveries rows are removed by cascade
+	_, err = exec.Exec("delete from webhooks where id = $1", id.Int())
+
+	return err
+}
+
+type DeliveryDTO struct {
+	ID            int64
+	WebhookID     int
+	EventID       string
+	EventName     string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	ResponseCode  int
+	LastError     string
+	CreatedAt     time.Time
+	DeliveredAt   sql.NullTime
+}
+
+const deliveryColumns = `id, webhook_id, event_id, event_name, payload,
+	status, attempts, next_attempt_at, response_code, last_error,
+	created_at, delivered_at`
+
+func scanDeliveryDTO(row interface{ Scan(...any) error }) (DeliveryDTO, error) {
+	var deliveryDTO DeliveryDTO
+	err := row.Scan(
+		&deliveryDTO.ID,
+		&deliveryDTO.WebhookID,
+		&deliveryDTO.EventID,
+		&deliveryDTO.EventName,
+		&deliveryDTO.Payload,
+		&deliveryDTO.Status,
+		&deliveryDTO.Attempts,
+		&deliveryDTO.NextAttemptAt,
+		&deliveryDTO.ResponseCode,
+		&deliveryDTO.LastError,
+		&deliveryDTO.CreatedAt,
+		&deliveryDTO.DeliveredAt,
+	)
+
+	return deliveryDTO, err
+}
+
+func fromDeliveryDTO(deliveryDTO DeliveryDTO) (webhook_model.Delivery, error) {
+	id, err := webhook_model.NewDeliveryID(deliveryDTO.ID)
+	if err != nil {
+		return webhook_model.Delivery{}, err
+	}
+
+	webhookID, err := webhook_model.NewWebhookID(deliveryDTO.WebhookID)
+	if err != nil {
+		return webhook_model.Delivery{}, err
+	}
+
+	var deliveredAt *time.Time
+	if deliveryDTO.DeliveredAt.Valid {
+		deliveredAt = &deliveryDTO.DeliveredAt.Time
+	}
+
+	return webhook_model.NewDeliveryFromDB(
+		id,
+		webhookID,
+		deliveryDTO.EventID,
+		deliveryDTO.EventName,
+		deliveryDTO.Payload,
+		webhook_model.DeliveryStatus(deliveryDTO.Status),
+		deliveryDTO.Attempts,
+		deliveryDTO.NextAttemptAt,
+		deliveryDTO.ResponseCode,
+		deliveryDTO.LastError,
+		deliveryDTO.CreatedAt,
+		deliveredAt,
+	), nil
+}
+
+type PostrgesDeliveryRepository struct {
+	db *sql.DB
+}
+
+func NewPostrgesDeliveryRepository(db *sql.DB) *PostrgesDeliveryRepository {
+	return &PostrgesDeliveryRepository{db: db}
+}
+
+var _ webhook_domain.DeliveryRepository = (*PostrgesDeliveryRepository)(nil)
+
+func (r *PostrgesDeliveryRepository) Create(
+	ctx context.Context,
+	deliveries []webhook_model.Delivery,
+	tx util.Transaction,
+) (err error) {
+	exec, commit, rollback, err := storage.GetTxOrCreateTx(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(rollback(), err)
+		} else {
+			err = commit()
+		}
+	}()
+
+	stmtInsert, err := exec.Prepare(`insert into webhook_deliveries
+		(webhook_id, event_id, event_name, payload, status, next_attempt_at, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7)`)
+	if err != nil {
+		return err
+	}
+	defer stmtInsert.Close()
+
+	for _, delivery := range deliveries {
+		deliveryPF := delivery.PF()
+		if _, err := stmtInsert.Exec(
+			deliveryPF.WebhookID.Int(),
+			deliveryPF.EventID,
+			deliveryPF.EventName,
+			deliveryPF.Payload,
+			deliveryPF.Status,
+			deliveryPF.NextAttemptAt,
+			deliveryPF.CreatedAt,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *PostrgesDeliveryRepository) Get(
+	ctx context.Context,
+	id webhook_model.DeliveryID,
+	tx util.Transaction,
+) (webhook_model.Delivery, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return webhook_model.Delivery{}, err
+	}
+
+	deliveryDTO, err := scanDeliveryDTO(exec.QueryRow(`select `+deliveryColumns+`
+		from webhook_deliveries
+		where id = $1`, id.Int64()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhook_model.Delivery{}, webhook_model.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return webhook_model.Delivery{}, err
+	}
+
+	return fromDeliveryDTO(deliveryDTO)
+}
+
+func (r *PostrgesDeliveryRepository) List(
+	ctx context.Context,
+	webhookID webhook_model.WebhookID,
+	limit int,
+	tx util.Transaction,
+) ([]webhook_model.Delivery, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := exec.Query(`select `+deliveryColumns+`
+		from webhook_deliveries
+		where webhook_id = $1
+		order by id desc
+		limit $2`, webhookID.Int(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDeliveries(rows)
+}
+
+func (r *PostrgesDeliveryRepository) Claim(
+	ctx context.Context,
+	limit int,
+	lease time.Duration,
+	tx util.Transaction,
+) (_ []webhook_model.Delivery, err error) {
+	exec, commit, rollback, err := storage.GetTxOrCreateTx(ctx, tx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(rollback(), err)
+		} else {
+			err = commit()
+		}
+	}()
+
+	rows, err := exec.Query(`with claimed as (
+			select id as claimed_id
+			from webhook_deliveries
+			where status = $1
+			and next_attempt_at <= now()
+			order by next_attempt_at, id
+			limit $2
+			for update skip locked
+		), leased as (
+			update webhook_deliveries
+			set next_attempt_at = now() + make_interval(secs => $3)
+			from claimed
+			where id = claimed_id
+			returning `+deliveryColumns+`
+		)
+		select `+deliveryColumns+` from leased order by id`,
+		webhook_model.DeliveryPending,
+		limit,
+		lease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDeliveries(rows)
+}
+
+func (r *PostrgesDeliveryRepository) Save(
+	ctx context.Context,
+	delivery webhook_model.Delivery,
+	tx util.Transaction,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, r.db)
+	if err != nil {
+		return err
+	}
+
+	deliveryPF := delivery.PF()
+	deliveredAt := sql.NullTime{}
+	if deliveryPF.DeliveredAt != nil {
+		deliveredAt = sql.NullTime{Time: *deliveryPF.DeliveredAt, Valid: true}
+	}
+
+	_, err = exec.Exec(`update webhook_deliveries
+		set status = $2,
+			attempts = $3,
+			next_attempt_at = $4,
+			response_code = $5,
+			last_error = $6,
+			delivered_at = $7
+		where id = $1`,
+		deliveryPF.ID.Int64(),
+		deliveryPF.Status,
+		deliveryPF.Attempts,
+		deliveryPF.NextAttemptAt,
+		deliveryPF.ResponseCode,
+		deliveryPF.LastError,
+		deliveredAt,
+	)
+
+	return err
+}
+
+func scanDeliveries(rows *sql.Rows) ([]webhook_model.Delivery, error) {
+	deliveries := []webhook_model.Delivery{}
+	for rows.Next() {
+		deliveryDTO, err := scanDeliveryDTO(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery, err := fromDeliveryDTO(deliveryDTO)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,474 @@
+package webhook_infrastructure
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	webhook_domain "github.com/kotsmile/everd-backend/internal/app/domain/webhook"
+	webhook_model "github.com/kotsmile/everd-backend/internal/app/domain/webhook/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type memoryWebhookRepository struct {
+	webhooks map[webhook_model.WebhookID]webhook_model.Webhook
+}
+
+func (r *memoryWebhookRepository) NextID(ctx context.Context, tx util.Transaction) (webhook_model.WebhookID, error) {
+	return webhook_model.WebhookID(len(r.webhooks) + 1), nil
+}
+
+func (r *memoryWebhookRepository) Get(ctx context.Context, id webhook_model.WebhookID, tx util.Transaction) (webhook_model.Webhook, error) {
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return webhook_model.Webhook{}, webhook_model.ErrNotFound
+	}
+	return webhook, nil
+}
+
+func (r *memoryWebhookRepository) List(ctx context.Context, userID access_domain.UserID, tx util.Transaction) ([]webhook_model.Webhook, error) {
+	var webhooks []webhook_model.Webhook
+	for id := webhook_model.WebhookID(1); int(id) <= len(r.webhooks); id++ {
+		if webhook, ok := r.webhooks[id]; ok && webhook.PF().UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) Save(ctx context.Context, webhook webhook_model.Webhook, tx util.Transaction) error {
+	r.webhooks[webhook.PF().ID] = webhook
+	return nil
+}
+
+func (r *memoryWebhookRepository) Delete(ctx context.Context, id webhook_model.WebhookID, tx util.Transaction) error {
+	delete(r.webhooks, id)
+	return nil
+}
+
+type memoryDeliveryRepository struct {
+	// offset shifts repository clock, deliveries are due when
+	// next_attempt_at <= now + offset.
+	offset     time.Duration
+	deliveries []webhook_model.Delivery
+	// failSave is delivery which can not be saved.
+	failSave webhook_model.DeliveryID
+}
+
+func (r *memoryDeliveryRepository) Create(ctx context.Context, deliveries []webhook_model.Delivery, tx util.Transaction) error {
+	for _, delivery := range deliveries {
+		pf := delivery.PF()
+		r.deliveries = append(r.deliveries, webhook_model.NewDeliveryFromDB(
+			webhook_model.DeliveryID(len(r.deliveries)+1),
+			pf.WebhookID, pf.EventID, pf.EventName, pf.Payload, pf.Status,
+			pf.Attempts, pf.NextAttemptAt, pf.ResponseCode, pf.LastError,
+			pf.CreatedAt, pf.DeliveredAt,
+		))
+	}
+	return nil
+}
+
+func (r *memoryDeliveryRepository) Get(ctx context.Context, id webhook_model.DeliveryID, tx util.Transaction) (webhook_model.Delivery, error) {
+	if int(id) < 1 || int(id) > len(r.deliveries) {
+		return webhook_model.Delivery{}, webhook_model.ErrDeliveryNotFound
+	}
+	return r.deliveries[id-1], nil
+}
+
+func (r *memoryDeliveryRepository) List(ctx context.Context, webhookID webhook_model.WebhookID, limit int, tx util.Transaction) ([]webhook_model.Delivery, error) {
+	var deliveries []webhook_model.Delivery
+	for i := len(r.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if r.deliveries[i].PF().WebhookID == webhookID {
+			deliveries = append(deliveries, r.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *memoryDeliveryRepository) Claim(ctx context.Context, limit int, lease time.Duration, tx util.Transaction) ([]webhook_model.Delivery, error) {
+	now := time.Now().Add(r.offset)
+
+	var deliveries []webhook_model.Delivery
+	for i, delivery := range r.deliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		pf := delivery.PF()
+		if pf.Status == webhook_model.DeliveryPending && !pf.NextAttemptAt.After(now) {
+			r.deliveries[i] = webhook_model.NewDeliveryFromDB(
+				pf.ID, pf.WebhookID, pf.EventID, pf.EventName, pf.Payload, pf.Status,
+				pf.Attempts, now.Add(lease), pf.ResponseCode, pf.LastError,
+				pf.CreatedAt, pf.DeliveredAt,
+			)
+			deliveries = append(deliveries, r.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *memoryDeliveryRepository) Save(ctx context.Context, delivery webhook_model.Delivery, tx util.Transaction) error {
+	if delivery.PF().ID == r.failSave {
+		return errors.New("save failed")
+	}
+	r.deliveries[delivery.PF().ID-1] = delivery
+	return nil
+}
+
+type fixture struct {
+	webhookRepo  *memoryWebhookRepository
+	deliveryRepo *memoryDeliveryRepository
+	service      *webhook_domain.WebhookService
+	worker       *webhook_domain.DeliveryWorker
+
+	// receiver is address every webhook url is dialed at.
+	receiver string
+}
+
+func newFixture(maxAttempts int) *fixture {
+	f := &fixture{
+		webhookRepo:  &memoryWebhookRepository{webhooks: map[webhook_model.WebhookID]webhook_model.Webhook{}},
+		deliveryRepo: &memoryDeliveryRepository{},
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, f.receiver)
+		},
+	}}
+
+	f.service = webhook_domain.NewWebhookService(
+		util.NewTransactionFactoryTest(),
+		f.webhookRepo,
+		f.deliveryRepo,
+		[]string{"TodoAdded", "TodoCompleted"},
+	)
+	f.worker = webhook_domain.NewDeliveryWorker(
+		util.NewTransactionFactoryTest(),
+		f.webhookRepo,
+		f.deliveryRepo,
+		NewHTTPSender(client),
+		util.NewLoggerTest(),
+		webhook_domain.DeliveryWorkerConfig{
+			BatchSize:    100,
+			PollInterval: time.Millisecond,
+			MaxAttempts:  maxAttempts,
+			BaseBackoff:  time.Minute,
+			MaxBackoff:   time.Hour,
+			ClaimLease:   time.Minute,
+		},
+	)
+
+	return f
+}
+
+// serve starts receiver of webhooks and returns url to register, local
+// urls are not accepted.
+func (f *fixture) serve(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	f.receiver = server.Listener.Addr().String()
+
+	return "http://hooks.example.com/everd"
+}
+
+// advance moves repository clock so that every retry is due.
+func (f *fixture) advance() {
+	f.deliveryRepo.offset += 2 * time.Hour
+}
+
+func TestDeliverySignedAndRetried(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(5)
+
+	var calls atomic.Int32
+	var secret string
+	url := f.serve(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if err := webhook_domain.Verify(
+			secret,
+			r.Header.Get(webhook_domain.HeaderTimestamp),
+			r.Header.Get(webhook_domain.HeaderSignature),
+			body,
+			time.Now(),
+			webhook_domain.DefaultSignatureTolerance,
+		); err != nil {
+			t.Errorf("verify signature: %v", err)
+		}
+		if got := r.Header.Get(webhook_domain.HeaderEvent); got != "TodoAdded" {
+			t.Errorf("event header = %q", got)
+		}
+		if got := r.Header.Get(webhook_domain.HeaderEventID); got != "42" {
+			t.Errorf("event id header = %q", got)
+		}
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	webhook, err := f.service.Register(ctx, 1, url, []string{"TodoAdded"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret = webhook.PF().Secret
+
+	if err := f.service.Publish(ctx, 1, "42", "TodoAdded", []byte(`{"name":"TodoAdded"}`)); err != nil {
+		t.Fatal(err)
+	}
+	// filtered out by webhook events
+	if err := f.service.Publish(ctx, 1, "43", "TodoCompleted", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	// other user
+	if err := f.service.Publish(ctx, 2, "44", "TodoAdded", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.deliveryRepo.deliveries) != 1 {
+		t.Fatalf("deliveries = %d, want 1", len(f.deliveryRepo.deliveries))
+	}
+
+	if _, err := f.worker.DeliverBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := f.deliveryRepo.deliveries[0].PF()
+	if delivery.Status != webhook_model.DeliveryPending || delivery.Attempts != 1 || delivery.ResponseCode != 500 {
+		t.Fatalf("after failure: status=%s attempts=%d code=%d", delivery.Status, delivery.Attempts, delivery.ResponseCode)
+	}
+
+	// retry is not due yet
+	if sent, _ := f.worker.DeliverBatch(ctx); sent != 0 {
+		t.Fatalf("sent %d deliveries before backoff elapsed", sent)
+	}
+
+	f.advance()
+	if _, err := f.worker.DeliverBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery = f.deliveryRepo.deliveries[0].PF()
+	if delivery.Status != webhook_model.DeliverySucceeded || delivery.Attempts != 2 || delivery.ResponseCode != 204 {
+		t.Fatalf("after success: status=%s attempts=%d code=%d", delivery.Status, delivery.Attempts, delivery.ResponseCode)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+	if stored := f.webhookRepo.webhooks[webhook.PF().ID]; stored.PF().ConsecutiveFailures != 0 {
+		t.Fatalf("consecutive failures = %d, want 0", stored.PF().ConsecutiveFailures)
+	}
+}
+
+func TestDeliveryGivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(2)
+
+	url := f.serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	if _, err := f.service.Register(ctx, 1, url, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.Publish(ctx, 1, "1", "TodoAdded", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := f.worker.DeliverBatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+		f.advance()
+	}
+
+	delivery := f.deliveryRepo.deliveries[0].PF()
+	if delivery.Status != webhook_model.DeliveryFailed || delivery.Attempts != 2 || delivery.ResponseCode != 502 {
+		t.Fatalf("status=%s attempts=%d code=%d", delivery.Status, delivery.Attempts, delivery.ResponseCode)
+	}
+}
+
+func TestWebhookDisabledAfterConsecutiveFailures(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(1)
+
+	var calls atomic.Int32
+	url := f.serve(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	webhook, err := f.service.Register(ctx, 1, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < webhook_model.MaxConsecutiveFailures; i++ {
+		if err := f.service.Publish(ctx, 1, "1", "TodoAdded", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.worker.DeliverBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := f.webhookRepo.webhooks[webhook.PF().ID]
+	if stored.IsEnabled() {
+		t.Fatal("webhook is enabled after consecutive failures")
+	}
+
+	// disabled webhook gets no new deliveries
+	if err := f.service.Publish(ctx, 1, "2", "TodoAdded", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.deliveryRepo.deliveries) != webhook_model.MaxConsecutiveFailures {
+		t.Fatalf("deliveries = %d", len(f.deliveryRepo.deliveries))
+	}
+
+	// redelivery to disabled webhook fails without request
+	if err := f.service.Redeliver(ctx, 1, webhook.PF().ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.worker.DeliverBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if int(calls.Load()) != webhook_model.MaxConsecutiveFailures {
+		t.Fatalf("calls = %d", calls.Load())
+	}
+
+	if err := f.service.Enable(ctx, 1, webhook.PF().ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.Redeliver(ctx, 1, webhook.PF().ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.worker.DeliverBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	last := f.deliveryRepo.deliveries[len(f.deliveryRepo.deliveries)-1].PF()
+	if last.EventID != "1" || last.Attempts != 1 || last.ResponseCode != 500 {
+		t.Fatalf("redelivery: event=%s attempts=%d code=%d", last.EventID, last.Attempts, last.ResponseCode)
+	}
+}
+
+func TestDeliveryResultsSavedSeparately(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(5)
+
+	var calls atomic.Int32
+	url := f.serve(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if _, err := f.service.Register(ctx, 1, url, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, eventID := range []string{"1", "2"} {
+		if err := f.service.Publish(ctx, 1, eventID, "TodoAdded", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f.deliveryRepo.failSave = 1
+	sent, err := f.worker.DeliverBatch(ctx)
+	if err == nil || sent != 1 {
+		t.Fatalf("sent = %d, err = %v", sent, err)
+	}
+
+	// result of the other delivery is kept
+	if delivery := f.deliveryRepo.deliveries[1].PF(); delivery.Status != webhook_model.DeliverySucceeded {
+		t.Fatalf("status = %s", delivery.Status)
+	}
+
+	// delivery whose result is lost is leased, it is sent again after lease
+	f.deliveryRepo.failSave = 0
+	if sent, _ := f.worker.DeliverBatch(ctx); sent != 0 {
+		t.Fatalf("sent %d deliveries before lease ended", sent)
+	}
+	f.advance()
+	if sent, err := f.worker.DeliverBatch(ctx); err != nil || sent != 1 {
+		t.Fatalf("sent = %d, err = %v", sent, err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("calls = %d", calls.Load())
+	}
+}
+
+func TestRedeliverOtherUserWebhook(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(1)
+
+	webhook, err := f.service.Register(ctx, 1, "https://example.com/hook", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.service.Redeliver(ctx, 2, webhook.PF().ID, 1); err != webhook_model.ErrNotFound {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVerifyRejectsTamperedPayload(t *testing.T) {
+	now := time.Now()
+	signature := webhook_domain.Sign("secret", now, []byte(`{"a":1}`))
+	timestamp := now.Unix()
+
+	if err := webhook_domain.Verify("secret", strconv.FormatInt(timestamp, 10), signature, []byte(`{"a":2}`), now, time.Minute); err != webhook_domain.ErrSignatureMismatch {
+		t.Fatalf("tampered payload: %v", err)
+	}
+	if err := webhook_domain.Verify("secret", strconv.FormatInt(timestamp, 10), signature, []byte(`{"a":1}`), now.Add(time.Hour), time.Minute); err != webhook_domain.ErrSignatureExpired {
+		t.Fatalf("expired timestamp: %v", err)
+	}
+}
+
+func TestRegisterRejectsLocalURL(t *testing.T) {
+	f := newFixture(1)
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+		"http://localhost/hook",
+	} {
+		if _, err := f.service.Register(context.Background(), 1, url, nil); !errors.Is(err, webhook_model.ErrURLIsNotPublic) {
+			t.Errorf("%s: err = %v", url, err)
+		}
+	}
+}
+
+func TestSenderRejectsLocalAddress(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	sender := NewHTTPSender(nil)
+	// host resolving to loopback is rejected when it is dialed
+	for _, url := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		_, err := sender.Send(context.Background(), webhook_model.WebhookPF{URL: url, Secret: "secret"}, webhook_model.DeliveryPF{})
+		if !errors.Is(err, webhook_model.ErrURLIsNotPublic) {
+			t.Errorf("%s: err = %v", url, err)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("calls = %d", calls.Load())
+	}
+}
@@ -0,0 +1,360 @@
+package webhook_model
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+)
+
+var (
+	Err                 = errors.New("webhook")
+	ErrURLIsInvalid     = fmt.Errorf("%w: url must be absolute http or https url", Err)
+	ErrURLIsTooLong     = fmt.Errorf("%w: url is too long", Err)
+	ErrURLIsNotPublic   = fmt.Errorf("%w: url must not point to local or private network", Err)
+	ErrSecretIsEmpty    = fmt.Errorf("%w: secret is empty", Err)
+	ErrNotFound         = fmt.Errorf("%w: webhook is not found", Err)
+	ErrDeliveryNotFound = fmt.Errorf("%w: delivery is not found", Err)
+	ErrNotPending       = fmt.Errorf("%w: delivery is not pending", Err)
+)
+
+const (
+	MaxURLLength = 2000
+	// MaxConsecutiveFailures is number of failed deliveries in a row after
+	// which webhook is disabled.
+	MaxConsecutiveFailures = 10
+)
+
+type Webhook struct {
+	id     WebhookID
+	userID access_domain.UserID
+
+	url    string
+	secret string
+	// events filter deliveries by event name, empty means every event.
+	events []string
+
+	enabled             bool
+	consecutiveFailures int
+
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+func NewWebhook(
+	id WebhookID,
+	userID access_domain.UserID,
+	url string,
+	secret string,
+	events []string,
+) (Webhook, error) {
+	webhook := Webhook{
+		id:        id,
+		userID:    userID,
+		url:       url,
+		secret:    secret,
+		events:    events,
+		enabled:   true,
+		createdAt: time.Now(),
+		updatedAt: time.Now(),
+	}
+
+	if err := webhook.Validate(); err != nil {
+		return Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func NewWebhookFromDB(
+	id WebhookID,
+	userID access_domain.UserID,
+	url string,
+	secret string,
+	events []string,
+	enabled bool,
+	consecutiveFailures int,
+	createdAt time.Time,
+	updatedAt time.Time,
+) (Webhook, error) {
+	webhook := Webhook{
+		id:                  id,
+		userID:              userID,
+		url:                 url,
+		secret:              secret,
+		events:              events,
+		enabled:             enabled,
+		consecutiveFailures: consecutiveFailures,
+		createdAt:           createdAt,
+		updatedAt:           updatedAt,
+	}
+
+	if err := webhook.Validate(); err != nil {
+		return Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+type WebhookPF struct {
+	ID                  WebhookID
+	UserID              access_domain.UserID
+	URL                 string
+	Secret              string
+	Events              []string
+	Enabled             bool
+	ConsecutiveFailures int
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+func (w *Webhook) PF() WebhookPF {
+	events := make([]string, len(w.events))
+	copy(events, w.events)
+
+	return WebhookPF{
+		ID:                  w.id,
+		UserID:              w.userID,
+		URL:                 w.url,
+		Secret:              w.secret,
+		Events:              events,
+		Enabled:             w.enabled,
+		ConsecutiveFailures: w.consecutiveFailures,
+		CreatedAt:           w.createdAt,
+		UpdatedAt:           w.updatedAt,
+	}
+}
+
+func (w *Webhook) Validate() error {
+	if len(w.url) > MaxURLLength {
+		return ErrURLIsTooLong
+	}
+
+	parsed, err := url.Parse(w.url)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" ||
+		(parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ErrURLIsInvalid
+	}
+
+	// hosts resolving to such addresses are rejected when delivery is sent
+	host := strings.ToLower(parsed.Hostname())
+	if addr, err := netip.ParseAddr(host); (err == nil && !IsPublicAddr(addr)) ||
+		host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrURLIsNotPublic
+	}
+
+	if w.secret == "" {
+		return ErrSecretIsEmpty
+	}
+
+	return nil
+}
+
+// nonPublicPrefixes are ranges not covered by netip.Addr methods used in
+// IsPublicAddr.
+var nonPublicPrefixes = []netip.Prefix{
+	// "this" network
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// carrier-grade NAT
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// IsPublicAddr reports whether webhook may be delivered to addr. Loopback,
+// private, link-local (including cloud metadata 169.254.169.254) and
+// multicast addresses are not public.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Matches reports whether event should be delivered to webhook.
+func (w *Webhook) Matches(eventName string) bool {
+	if !w.enabled {
+		return false
+	}
+
+	if len(w.events) == 0 {
+		return true
+	}
+
+	for _, name := range w.events {
+		if name == eventName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *Webhook) IsEnabled() bool {
+	return w.enabled
+}
+
+func (w *Webhook) RecordSuccess() {
+	w.consecutiveFailures = 0
+	w.updatedAt = time.Now()
+}
+
+// RecordFailure disables webhook after MaxConsecutiveFailures failures in a
+// row.
+func (w *Webhook) RecordFailure() {
+	w.consecutiveFailures++
+	if w.consecutiveFailures >= MaxConsecutiveFailures {
+		w.enabled = false
+	}
+	w.updatedAt = time.Now()
+}
+
+func (w *Webhook) Enable() {
+	w.enabled = true
+	w.consecutiveFailures = 0
+	w.updatedAt = time.Now()
+}
+
+type Delivery struct {
+	id        DeliveryID
+	webhookID WebhookID
+
+	// eventID identifies event across redeliveries, receivers use it to
+	// drop duplicates.
+	eventID   string
+	eventName string
+	payload   []byte
+
+	status        DeliveryStatus
+	attempts      int
+	nextAttemptAt time.Time
+	responseCode  int
+	lastError     string
+
+	createdAt   time.Time
+	deliveredAt *time.Time
+}
+
+func NewDelivery(webhookID WebhookID, eventID string, eventName string, payload []byte) Delivery {
+	return Delivery{
+		webhookID:     webhookID,
+		eventID:       eventID,
+		eventName:     eventName,
+		payload:       payload,
+		status:        DeliveryPending,
+		nextAttemptAt: time.Now(),
+		createdAt:     time.Now(),
+	}
+}
+
+func NewDeliveryFromDB(
+	id DeliveryID,
+	webhookID WebhookID,
+	eventID string,
+	eventName string,
+	payload []byte,
+	status DeliveryStatus,
+	attempts int,
+	nextAttemptAt time.Time,
+	responseCode int,
+	lastError string,
+	createdAt time.Time,
+	deliveredAt *time.Time,
+) Delivery {
+	return Delivery{
+		id:            id,
+		webhookID:     webhookID,
+		eventID:       eventID,
+		eventName:     eventName,
+		payload:       payload,
+		status:        status,
+		attempts:      attempts,
+		nextAttemptAt: nextAttemptAt,
+		responseCode:  responseCode,
+		lastError:     lastError,
+		createdAt:     createdAt,
+		deliveredAt:   deliveredAt,
+	}
+}
+
+type DeliveryPF struct {
+	ID            DeliveryID
+	WebhookID     WebhookID
+	EventID       string
+	EventName     string
+	Payload       []byte
+	Status        DeliveryStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	ResponseCode  int
+	LastError     string
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time
+}
+
+func (d *Delivery) PF() DeliveryPF {
+	return DeliveryPF{
+		ID:            d.id,
+		WebhookID:     d.webhookID,
+		EventID:       d.eventID,
+		EventName:     d.eventName,
+		Payload:       d.payload,
+		Status:        d.status,
+		Attempts:      d.attempts,
+		NextAttemptAt: d.nextAttemptAt,
+		ResponseCode:  d.responseCode,
+		LastError:     d.lastError,
+		CreatedAt:     d.createdAt,
+		DeliveredAt:   d.deliveredAt,
+	}
+}
+
+// Redeliver returns a new pending delivery of the same event.
+func (d *Delivery) Redeliver() Delivery {
+	return NewDelivery(d.webhookID, d.eventID, d.eventName, d.payload)
+}
+
+func (d *Delivery) Succeed(responseCode int) error {
+	if d.status != DeliveryPending {
+		return ErrNotPending
+	}
+
+	now := time.Now()
+	d.status = DeliverySucceeded
+	d.attempts++
+	d.responseCode = responseCode
+	d.lastError = ""
+	d.deliveredAt = &now
+
+	return nil
+}
+
+// Fail records failed attempt. Delivery is retried at nextAttemptAt unless
+// it is nil, then delivery is failed for good.
+func (d *Delivery) Fail(responseCode int, lastError string, nextAttemptAt *time.Time) error {
+	if d.status != DeliveryPending {
+		return ErrNotPending
+	}
+
+	d.attempts++
+	d.responseCode = responseCode
+	d.lastError = lastError
+
+	if nextAttemptAt == nil {
+		d.status = DeliveryFailed
+		return nil
+	}
+
+	d.nextAttemptAt = *nextAttemptAt
+	return nil
+}
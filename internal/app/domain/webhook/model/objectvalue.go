@@ -0,0 +1,54 @@
+package webhook_model
+
+import (
+	"fmt"
+)
+
+var (
+	ErrWebhookID  = fmt.Errorf("%w: webhook id", Err)
+	ErrDeliveryID = fmt.Errorf("%w: delivery id", Err)
+)
+
+type WebhookID uint
+
+var NilWebhookID WebhookID
+
+func NewWebhookID(id int) (WebhookID, error) {
+	if id < 0 {
+		return NilWebhookID, ErrWebhookID
+	}
+
+	return WebhookID(uint(id)), nil
+}
+
+func (id WebhookID) Equal(other WebhookID) bool {
+	return id == other
+}
+
+func (id WebhookID) Int() int {
+	return int(id)
+}
+
+type DeliveryID uint64
+
+var NilDeliveryID DeliveryID
+
+func NewDeliveryID(id int64) (DeliveryID, error) {
+	if id < 0 {
+		return NilDeliveryID, ErrDeliveryID
+	}
+
+	return DeliveryID(uint64(id)), nil
+}
+
+func (id DeliveryID) Int64() int64 {
+	return int64(id)
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
@@ -0,0 +1,265 @@
+package webhook_domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	webhook_model "github.com/kotsmile/everd-backend/internal/app/domain/webhook/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	DefaultDeliveriesLimit = 50
+	MaxDeliveriesLimit     = 200
+	secretLength           = 32
+)
+
+var (
+	ErrUnknownEvent = fmt.Errorf("%w: unknown event", webhook_model.Err)
+	ErrInvalidLimit = fmt.Errorf("%w: invalid limit", webhook_model.Err)
+)
+
+type WebhookRepository interface {
+	NextID(ctx context.Context, tx util.Transaction) (webhook_model.WebhookID, error)
+	// Get returns webhook or webhook_model.ErrNotFound.
+	Get(ctx context.Context, id webhook_model.WebhookID, tx util.Transaction) (webhook_model.Webhook, error)
+	List(ctx context.Context, userID access_domain.UserID, tx util.Transaction) ([]webhook_model.Webhook, error)
+	Save(ctx context.Context, webhook webhook_model.Webhook, tx util.Transaction) error
+	Delete(ctx context.Context, id webhook_model.WebhookID, tx util.Transaction) error
+}
+
+type DeliveryRepository interface {
+	Create(ctx context.Context, deliveries []webhook_model.Delivery, tx util.Transaction) error
+	// Get returns delivery or webhook_model.ErrDeliveryNotFound.
+	Get(ctx context.Context, id webhook_model.DeliveryID, tx util.Transaction) (webhook_model.Delivery, error)
+	// List returns latest deliveries of webhook, newest first.
+	List(
+		ctx context.Context,
+		webhookID webhook_model.WebhookID,
+		limit int,
+		tx util.Transaction,
+	) ([]webhook_model.Delivery, error)
+	// Claim returns due pending deliveries and postpones their next attempt
+	// by lease, so they are not claimed again while they are sent.
+	// Deliveries locked by other transactions are skipped.
+	Claim(ctx context.Context, limit int, lease time.Duration, tx util.Transaction) ([]webhook_model.Delivery, error)
+	Save(ctx context.Context, delivery webhook_model.Delivery, tx util.Transaction) error
+}
+
+type WebhookService struct {
+	txFactory    util.TransactionFactory
+	webhookRepo  WebhookRepository
+	deliveryRepo DeliveryRepository
+
+	// events are names of events webhook may subscribe to.
+	events map[string]struct{}
+}
+
+func NewWebhookService(
+	txFactory util.TransactionFactory,
+	webhookRepo WebhookRepository,
+	deliveryRepo DeliveryRepository,
+	events []string,
+) *WebhookService {
+	known := make(map[string]struct{}, len(events))
+	for _, event := range events {
+		known[event] = struct{}{}
+	}
+
+	return &WebhookService{
+		txFactory:    txFactory,
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		events:       known,
+	}
+}
+
+// Register creates webhook with generated secret. Empty events subscribes
+// webhook to every event.
+func (s *WebhookService) Register(
+	ctx context.Context,
+	userID access_domain.UserID,
+	url string,
+	events []string,
+) (webhook_model.Webhook, error) {
+	for _, event := range events {
+		if _, ok := s.events[event]; !ok {
+			return webhook_model.Webhook{}, fmt.Errorf("%w: %s", ErrUnknownEvent, event)
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return webhook_model.Webhook{}, err
+	}
+
+	var webhook webhook_model.Webhook
+	err = s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		id, err := s.webhookRepo.NextID(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		webhook, err = webhook_model.NewWebhook(id, userID, url, secret, events)
+		if err != nil {
+			return err
+		}
+
+		return s.webhookRepo.Save(ctx, webhook, tx)
+	})
+
+	return webhook, err
+}
+
+func (s *WebhookService) List(
+	ctx context.Context,
+	userID access_domain.UserID,
+) ([]webhook_model.Webhook, error) {
+	return s.webhookRepo.List(ctx, userID, nil)
+}
+
+func (s *WebhookService) Delete(
+	ctx context.Context,
+	userID access_domain.UserID,
+	id webhook_model.WebhookID,
+) error {
+	return s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		if _, err := s.getWebhook(ctx, userID, id, tx); err != nil {
+			return err
+		}
+
+		return s.webhookRepo.Delete(ctx, id, tx)
+	})
+}
+
+// Enable turns webhook on again after it was disabled by failures.
+func (s *WebhookService) Enable(
+	ctx context.Context,
+	userID access_domain.UserID,
+	id webhook_model.WebhookID,
+) error {
+	return s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		webhook, err := s.getWebhook(ctx, userID, id, tx)
+		if err != nil {
+			return err
+		}
+
+		webhook.Enable()
+
+		return s.webhookRepo.Save(ctx, webhook, tx)
+	})
+}
+
+func (s *WebhookService) Deliveries(
+	ctx context.Context,
+	userID access_domain.UserID,
+	id webhook_model.WebhookID,
+	limit int,
+) ([]webhook_model.Delivery, error) {
+	if limit == 0 {
+		limit = DefaultDeliveriesLimit
+	}
+	if limit < 0 || limit > MaxDeliveriesLimit {
+		return nil, ErrInvalidLimit
+	}
+
+	if _, err := s.getWebhook(ctx, userID, id, nil); err != nil {
+		return nil, err
+	}
+
+	return s.deliveryRepo.List(ctx, id, limit, nil)
+}
+
+// Redeliver queues a new delivery of the same event, the original delivery
+// stays in the log.
+func (s *WebhookService) Redeliver(
+	ctx context.Context,
+	userID access_domain.UserID,
+	id webhook_model.WebhookID,
+	deliveryID webhook_model.DeliveryID,
+) error {
+	return s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		if _, err := s.getWebhook(ctx, userID, id, tx); err != nil {
+			return err
+		}
+
+		delivery, err := s.deliveryRepo.Get(ctx, deliveryID, tx)
+		if err != nil {
+			return err
+		}
+
+		if !delivery.PF().WebhookID.Equal(id) {
+			return webhook_model.ErrDeliveryNotFound
+		}
+
+		return s.deliveryRepo.Create(ctx, []webhook_model.Delivery{delivery.Redeliver()}, tx)
+	})
+}
+
+// Publish queues delivery of event to every enabled webhook of user
+// subscribed to it.
+func (s *WebhookService) Publish(
+	ctx context.Context,
+	userID access_domain.UserID,
+	eventID string,
+	eventName string,
+	payload []byte,
+) error {
+	return s.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		webhooks, err := s.webhookRepo.List(ctx, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		var deliveries []webhook_model.Delivery
+		for _, webhook := range webhooks {
+			if !webhook.Matches(eventName) {
+				continue
+			}
+
+			deliveries = append(deliveries, webhook_model.NewDelivery(
+				webhook.PF().ID,
+				eventID,
+				eventName,
+				payload,
+			))
+		}
+
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		return s.deliveryRepo.Create(ctx, deliveries, tx)
+	})
+}
+
+func (s *WebhookService) getWebhook(
+	ctx context.Context,
+	userID access_domain.UserID,
+	id webhook_model.WebhookID,
+	tx util.Transaction,
+) (webhook_model.Webhook, error) {
+	webhook, err := s.webhookRepo.Get(ctx, id, tx)
+	if err != nil {
+		return webhook_model.Webhook{}, err
+	}
+
+	if webhook.PF().UserID != userID {
+		return webhook_model.Webhook{}, webhook_model.ErrNotFound
+	}
+
+	return webhook, nil
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
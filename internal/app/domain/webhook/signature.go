@@ -0,0 +1,68 @@
+package webhook_domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	webhook_model "github.com/kotsmile/everd-backend/internal/app/domain/webhook/model"
+)
+
+const (
+	HeaderEvent     = "X-Everd-Event"
+	HeaderEventID   = "X-Everd-Event-Id"
+	HeaderDelivery  = "X-Everd-Delivery"
+	HeaderTimestamp = "X-Everd-Timestamp"
+	HeaderSignature = "X-Everd-Signature"
+
+	signaturePrefix = "sha256="
+	// DefaultSignatureTolerance is max age of timestamp accepted by Verify,
+	// it protects receivers from replayed requests.
+	DefaultSignatureTolerance = 5 * time.Minute
+)
+
+var (
+	ErrSignatureMismatch = fmt.Errorf("%w: signature mismatch", webhook_model.Err)
+	ErrSignatureExpired  = fmt.Errorf("%w: signature timestamp is out of tolerance", webhook_model.Err)
+)
+
+// Sign returns value of HeaderSignature, it is HMAC-SHA256 of
+// "<timestamp>.<payload>" keyed by webhook secret.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks HeaderSignature and HeaderTimestamp values of received
+// delivery.
+func Verify(
+	secret string,
+	timestampHeader string,
+	signatureHeader string,
+	payload []byte,
+	now time.Time,
+	tolerance time.Duration,
+) error {
+	unix, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrSignatureMismatch
+	}
+
+	timestamp := time.Unix(unix, 0)
+	if now.Sub(timestamp) > tolerance || timestamp.Sub(now) > tolerance {
+		return ErrSignatureExpired
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signatureHeader)) {
+		return ErrSignatureMismatch
+	}
+
+	return nil
+}
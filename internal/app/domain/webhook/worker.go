@@ -0,0 +1,219 @@
+package webhook_domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	webhook_model "github.com/kotsmile/everd-backend/internal/app/domain/webhook/model"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	DefaultDeliveryBatchSize    = 50
+	DefaultDeliveryPollInterval = time.Second
+	DefaultDeliveryMaxAttempts  = 8
+	DefaultDeliveryBaseBackoff  = 10 * time.Second
+	DefaultDeliveryMaxBackoff   = 6 * time.Hour
+	// DefaultDeliveryClaimLease covers sending of the whole batch, claimed
+	// deliveries are sent one by one.
+	DefaultDeliveryClaimLease = 15 * time.Minute
+)
+
+var ErrWebhookDisabled = fmt.Errorf("%w: webhook is disabled", webhook_model.Err)
+
+// Sender sends delivery to webhook url and returns response status code,
+// non 2xx responses are returned as error.
+type Sender interface {
+	Send(ctx context.Context, webhook webhook_model.WebhookPF, delivery webhook_model.DeliveryPF) (int, error)
+}
+
+type DeliveryWorkerConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// ClaimLease is how long claimed deliveries are not claimed again,
+	// deliveries whose result is not saved by then are sent again.
+	ClaimLease time.Duration
+}
+
+var DefaultDeliveryWorkerConfig = DeliveryWorkerConfig{
+	BatchSize:    DefaultDeliveryBatchSize,
+	PollInterval: DefaultDeliveryPollInterval,
+	MaxAttempts:  DefaultDeliveryMaxAttempts,
+	BaseBackoff:  DefaultDeliveryBaseBackoff,
+	MaxBackoff:   DefaultDeliveryMaxBackoff,
+	ClaimLease:   DefaultDeliveryClaimLease,
+}
+
+// DeliveryWorker sends pending deliveries. Failed deliveries are retried
+// with exponential backoff, webhook is disabled after
+// webhook_model.MaxConsecutiveFailures failed attempts in a row.
+type DeliveryWorker struct {
+	txFactory    util.TransactionFactory
+	webhookRepo  WebhookRepository
+	deliveryRepo DeliveryRepository
+	sender       Sender
+	logger       util.Logger
+	config       DeliveryWorkerConfig
+
+	now func() time.Time
+}
+
+func NewDeliveryWorker(
+	txFactory util.TransactionFactory,
+	webhookRepo WebhookRepository,
+	deliveryRepo DeliveryRepository,
+	sender Sender,
+	logger util.Logger,
+	config DeliveryWorkerConfig,
+) *DeliveryWorker {
+	return &DeliveryWorker{
+		txFactory:    txFactory,
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		sender:       sender,
+		logger:       logger,
+		config:       config,
+		now:          time.Now,
+	}
+}
+
+// Backoff returns delay before next attempt of delivery after given number
+// of failed attempts.
+func (w *DeliveryWorker) Backoff(attempts int) time.Duration {
+	return util.Backoff(w.config.BaseBackoff, w.config.MaxBackoff, attempts)
+}
+
+// Run sends deliveries every poll interval until ctx is done. While
+// deliveries pile up, full batches are sent one after another without
+// waiting for the next poll.
+func (w *DeliveryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		sent, err := w.DeliverBatch(ctx)
+		if err != nil {
+			w.logger.WithError(err).Error("failed to deliver webhooks")
+		}
+
+		if err == nil && sent == w.config.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverBatch sends a single batch of due deliveries and returns number of
+// deliveries whose result is saved. HTTP requests to webhook urls may take
+// up to timeout of sender each, so they are sent with no transaction open
+// and result of every delivery is saved on its own, see util.ProcessBatch.
+func (w *DeliveryWorker) DeliverBatch(ctx context.Context) (int, error) {
+	return util.ProcessBatch(ctx, w.txFactory, func(tx util.Transaction) ([]webhook_model.Delivery, error) {
+		return w.deliveryRepo.Claim(ctx, w.config.BatchSize, w.config.ClaimLease, tx)
+	}, func(delivery webhook_model.Delivery) error {
+		if err := w.deliver(ctx, delivery); err != nil {
+			return fmt.Errorf("delivery %d: %w", delivery.PF().ID, err)
+		}
+		return nil
+	})
+}
+
+func (w *DeliveryWorker) deliver(ctx context.Context, delivery webhook_model.Delivery) error {
+	deliveryPF := delivery.PF()
+
+	webhook, err := w.webhookRepo.Get(ctx, deliveryPF.WebhookID, nil)
+	if errors.Is(err, webhook_model.ErrNotFound) {
+		return w.record(ctx, delivery, 0, webhook_model.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !webhook.IsEnabled() {
+		return w.record(ctx, delivery, 0, ErrWebhookDisabled)
+	}
+
+	responseCode, sendErr := w.sender.Send(ctx, webhook.PF(), deliveryPF)
+
+	return w.record(ctx, delivery, responseCode, sendErr)
+}
+
+// record saves result of sending delivery, sendErr is nil when delivery
+// succeeded. Webhook is loaded again, so results of its other deliveries
+// saved meanwhile are not lost.
+func (w *DeliveryWorker) record(
+	ctx context.Context,
+	delivery webhook_model.Delivery,
+	responseCode int,
+	sendErr error,
+) error {
+	deliveryPF := delivery.PF()
+	logger := w.logger.
+		WithField("webhook_id", deliveryPF.WebhookID).
+		WithField("delivery_id", deliveryPF.ID).
+		WithField("response_code", responseCode)
+
+	return w.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		webhook, err := w.webhookRepo.Get(ctx, deliveryPF.WebhookID, tx)
+		if errors.Is(err, webhook_model.ErrNotFound) {
+			if err := delivery.Fail(0, webhook_model.ErrNotFound.Error(), nil); err != nil {
+				return err
+			}
+			return w.deliveryRepo.Save(ctx, delivery, tx)
+		}
+		if err != nil {
+			return err
+		}
+
+		// webhook disabled before delivery was sent is not counted
+		if errors.Is(sendErr, ErrWebhookDisabled) {
+			if err := delivery.Fail(0, sendErr.Error(), nil); err != nil {
+				return err
+			}
+			return w.deliveryRepo.Save(ctx, delivery, tx)
+		}
+
+		if sendErr == nil {
+			if err := delivery.Succeed(responseCode); err != nil {
+				return err
+			}
+			webhook.RecordSuccess()
+		} else {
+			attempts := deliveryPF.Attempts + 1
+
+			var nextAttemptAt *time.Time
+			if attempts < w.config.MaxAttempts {
+				next := w.now().Add(w.Backoff(attempts))
+				nextAttemptAt = &next
+				logger.WithError(sendErr).Warn("webhook delivery failed, will retry")
+			} else {
+				logger.WithError(sendErr).Error("webhook delivery failed, giving up")
+			}
+
+			if err := delivery.Fail(responseCode, sendErr.Error(), nextAttemptAt); err != nil {
+				return err
+			}
+
+			webhook.RecordFailure()
+			if !webhook.IsEnabled() {
+				logger.Warn("webhook disabled after consecutive failures")
+			}
+		}
+
+		if err := w.deliveryRepo.Save(ctx, delivery, tx); err != nil {
+			return err
+		}
+
+		return w.webhookRepo.Save(ctx, webhook, tx)
+	})
+}
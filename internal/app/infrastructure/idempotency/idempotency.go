@@ -0,0 +1,264 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	// Header is header with key chosen by client, requests with the same
+	// key of the same user are applied once.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from stored ones.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	MaxKeyLength = 255
+	// MaxBodySize limits body of requests with key, body is read before
+	// handler to compare it with body of stored request.
+	MaxBodySize = 10 << 20
+
+	DefaultTTL           = 24 * time.Hour
+	DefaultPurgeInterval = time.Hour
+)
+
+var (
+	ErrInProgress = errors.New("idempotency: request with the same key is in progress")
+	ErrKeyReused  = errors.New("idempotency: key is reused with different request")
+)
+
+// Record is response stored for key, only status, content type and body of
+// response are stored. Fingerprint identifies request it is response to.
+type Record struct {
+	UserID      access_domain.UserID
+	Key         string
+	Fingerprint string
+	Status      int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+type Store interface {
+	// Lock locks key of user until tx ends, it reports false if key is
+	// locked by other transaction.
+	Lock(ctx context.Context, tx util.Transaction, userID access_domain.UserID, key string) (bool, error)
+	// Get returns record of key created after notBefore, nil if there is
+	// none.
+	Get(ctx context.Context, tx util.Transaction, userID access_domain.UserID, key string, notBefore time.Time) (*Record, error)
+	// Save saves record replacing expired record of the same key.
+	Save(ctx context.Context, tx util.Transaction, record Record) error
+	DeleteExpired(ctx context.Context, tx util.Transaction, before time.Time) (int64, error)
+}
+
+// Middleware applies requests with Idempotency-Key header once. Handler
+// runs in transaction carried by its ctx and its response is stored in the
+// same transaction, so either both changes and response are saved or none
+// of them. Retries get stored response back. Failed requests are not
+// stored, they are rolled back and can be retried with the same key.
+type Middleware struct {
+	txFactory util.TransactionFactory
+	store     Store
+	ttl       time.Duration
+
+	now func() time.Time
+}
+
+func NewMiddleware(txFactory util.TransactionFactory, store Store, ttl time.Duration) *Middleware {
+	return &Middleware{
+		txFactory: txFactory,
+		store:     store,
+		ttl:       ttl,
+		now:       time.Now,
+	}
+}
+
+// Wrap returns handler applying requests with key once, requests without
+// key or user are passed to handler as they are.
+func (m *Middleware) Wrap(handler util.Handler) util.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		key := r.Header.Get(Header)
+		userID, ok := ctx.Value("userID").(access_domain.UserID)
+		if key == "" || !ok {
+			return handler(ctx, w, r)
+		}
+
+		if len(key) > MaxKeyLength {
+			return util.
+				NewHTTPError("invalid idempotency key").
+				WithStatus(http.StatusBadRequest).
+				WithErrorMessage("idempotency key is too long")
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return util.
+					NewHTTPError("request body is too large").
+					WithStatus(http.StatusRequestEntityTooLarge).
+					WithError(err)
+			}
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		response, err := m.apply(ctx, r, userID, key, fingerprint(r, body), handler)
+		if err != nil {
+			return domainError(err)
+		}
+
+		return response.writeTo(w)
+	}
+}
+
+func (m *Middleware) apply(
+	ctx context.Context,
+	r *http.Request,
+	userID access_domain.UserID,
+	key string,
+	fingerprint string,
+	handler util.Handler,
+) (*recorder, error) {
+	response := newRecorder()
+
+	var committed func()
+	if err := m.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		locked, err := m.store.Lock(ctx, tx, userID, key)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return ErrInProgress
+		}
+
+		now := m.now()
+		record, err := m.store.Get(ctx, tx, userID, key, now.Add(-m.ttl))
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			if record.Fingerprint != fingerprint {
+				return ErrKeyReused
+			}
+
+			response.replay(*record)
+			return nil
+		}
+
+		var txCtx context.Context
+		txCtx, committed = util.ContextWithTransaction(ctx, tx)
+		if err := handler(txCtx, response, r.WithContext(txCtx)); err != nil {
+			return err
+		}
+
+		return m.store.Save(ctx, tx, Record{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      response.code(),
+			ContentType: response.Header().Get("Content-Type"),
+			Body:        response.body.Bytes(),
+			CreatedAt:   now,
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if committed != nil {
+		committed()
+	}
+
+	return response, nil
+}
+
+// fingerprint identifies request by method, path and body, key must not be
+// reused for other requests.
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func domainError(err error) error {
+	switch {
+	case errors.Is(err, ErrInProgress):
+		return util.
+			NewHTTPError("request with the same idempotency key is in progress").
+			WithStatus(http.StatusConflict).
+			WithError(err)
+	case errors.Is(err, ErrKeyReused):
+		return util.
+			NewHTTPError("idempotency key is already used for different request").
+			WithStatus(http.StatusUnprocessableEntity).
+			WithError(err)
+	default:
+		return err
+	}
+}
+
+// recorder keeps response of handler until transaction is committed.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header)}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+// code returns status of response, handlers writing nothing respond with
+// http.StatusOK.
+func (r *recorder) code() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
+
+func (r *recorder) replay(record Record) {
+	if record.ContentType != "" {
+		r.header.Set("Content-Type", record.ContentType)
+	}
+	r.header.Set(ReplayedHeader, "true")
+	r.WriteHeader(record.Status)
+	r.body.Write(record.Body)
+}
+
+func (r *recorder) writeTo(w http.ResponseWriter) error {
+	for key, values := range r.header {
+		w.Header()[key] = values
+	}
+
+	w.WriteHeader(r.code())
+
+	_, err := w.Write(r.body.Bytes())
+	return err
+}
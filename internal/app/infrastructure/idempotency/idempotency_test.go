@@ -0,0 +1,239 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type memoryStore struct {
+	records map[string]Record
+	// busy keys are locked by other transaction
+	busy map[string]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: make(map[string]Record), busy: make(map[string]bool)}
+}
+
+var _ Store = (*memoryStore)(nil)
+
+func (s *memoryStore) Lock(ctx context.Context, tx util.Transaction, userID access_domain.UserID, key string) (bool, error) {
+	return !s.busy[key], nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, tx util.Transaction, userID access_domain.UserID, key string, notBefore time.Time) (*Record, error) {
+	record, ok := s.records[key]
+	if !ok || !record.CreatedAt.After(notBefore) {
+		return nil, nil
+	}
+
+	return &record, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, tx util.Transaction, record Record) error {
+	s.records[record.Key] = record
+	return nil
+}
+
+func (s *memoryStore) DeleteExpired(ctx context.Context, tx util.Transaction, before time.Time) (int64, error) {
+	var deleted int64
+	for key, record := range s.records {
+		if !record.CreatedAt.After(before) {
+			delete(s.records, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+type fixture struct {
+	store      *memoryStore
+	middleware *Middleware
+	handler    util.Handler
+	apiHelper  *util.ApiHelper
+
+	calls int
+	fail  bool
+	now   time.Time
+}
+
+func newFixture() *fixture {
+	f := &fixture{
+		store:     newMemoryStore(),
+		apiHelper: util.NewApiHelper(util.NewLoggerTest()),
+		now:       time.Date(2024, 12, 9, 12, 0, 0, 0, time.UTC),
+	}
+	f.middleware = NewMiddleware(util.NewTransactionFactoryTest(), f.store, DefaultTTL)
+	f.middleware.now = func() time.Time { return f.now }
+
+	f.handler = f.middleware.Wrap(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		f.calls++
+		if f.fail {
+			return errors.New("failed")
+		}
+
+		var request struct {
+			Title string `json:"title"`
+		}
+		if err := f.apiHelper.ReadValidJSON(w, r, &request); err != nil {
+			return err
+		}
+
+		return f.apiHelper.WriteJSON(w, http.StatusCreated, util.JsonResponse{Data: request.Title + " created"})
+	})
+
+	return f
+}
+
+func (f *fixture) do(key string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/todolist/todo", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	ctx := context.WithValue(r.Context(), "userID", access_domain.UserID(1))
+
+	w := httptest.NewRecorder()
+	f.apiHelper.Wrapper(f.handler)(w, r.WithContext(ctx))
+
+	return w
+}
+
+func TestMiddlewareReplay(t *testing.T) {
+	f := newFixture()
+
+	first := f.do("key", `{"title": "milk"}`)
+	second := f.do("key", `{"title": "milk"}`)
+
+	if f.calls != 1 {
+		t.Fatalf("handler is called %d times", f.calls)
+	}
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("status = %d, %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Fatalf("replayed body = %s, want %s", second.Body, first.Body)
+	}
+	if first.Header().Get(ReplayedHeader) != "" || second.Header().Get(ReplayedHeader) != "true" {
+		t.Fatalf("replayed headers = %q, %q", first.Header().Get(ReplayedHeader), second.Header().Get(ReplayedHeader))
+	}
+	if second.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("content type = %q", second.Header().Get("Content-Type"))
+	}
+
+	// other key is other request
+	f.do("other", `{"title": "milk"}`)
+	if f.calls != 2 {
+		t.Fatalf("handler is called %d times", f.calls)
+	}
+}
+
+func TestMiddlewareConflicts(t *testing.T) {
+	f := newFixture()
+	f.do("key", `{"title": "milk"}`)
+
+	if w := f.do("key", `{"title": "bread"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: status = %d", w.Code)
+	}
+
+	f.store.busy["key"] = true
+	if w := f.do("key", `{"title": "milk"}`); w.Code != http.StatusConflict {
+		t.Errorf("in progress: status = %d", w.Code)
+	}
+
+	if w := f.do(strings.Repeat("k", MaxKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("long key: status = %d", w.Code)
+	}
+
+	if f.calls != 1 {
+		t.Fatalf("handler is called %d times", f.calls)
+	}
+}
+
+func TestMiddlewareFailedRequest(t *testing.T) {
+	f := newFixture()
+
+	f.fail = true
+	if w := f.do("key", `{"title": "milk"}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if len(f.store.records) != 0 {
+		t.Fatalf("failed request is stored: %+v", f.store.records)
+	}
+
+	// invalid requests are not stored either
+	f.fail = false
+	if w := f.do("key", `{"title": 1}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	if w := f.do("key", `{"title": "milk"}`); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if f.calls != 3 {
+		t.Fatalf("handler is called %d times", f.calls)
+	}
+}
+
+func TestMiddlewareExpiry(t *testing.T) {
+	f := newFixture()
+	f.do("key", `{"title": "milk"}`)
+
+	f.now = f.now.Add(DefaultTTL)
+	if w := f.do("key", `{"title": "bread"}`); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if f.calls != 2 {
+		t.Fatalf("handler is called %d times", f.calls)
+	}
+
+	// expired record is replaced by record of the new request
+	purged, err := f.store.DeleteExpired(context.Background(), nil, f.now.Add(-DefaultTTL))
+	if err != nil || purged != 0 {
+		t.Fatalf("purged = %d, %v", purged, err)
+	}
+}
+
+func TestMiddlewareWithoutKey(t *testing.T) {
+	f := newFixture()
+	f.do("", `{"title": "milk"}`)
+	f.do("", `{"title": "milk"}`)
+
+	if f.calls != 2 {
+		t.Fatalf("handler is called %d times", f.calls)
+	}
+	if len(f.store.records) != 0 {
+		t.Fatalf("request without key is stored: %+v", f.store.records)
+	}
+}
+
+func TestMiddlewareTransaction(t *testing.T) {
+	f := newFixture()
+
+	var notified []string
+	f.handler = f.middleware.Wrap(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if _, ok := util.TransactionFromContext(ctx).(*util.TestTransaction); !ok {
+			t.Error("handler ctx carries no transaction")
+		}
+
+		util.AfterCommit(ctx, func() { notified = append(notified, "created") })
+		if len(notified) != 0 {
+			t.Error("notified before commit")
+		}
+
+		return f.apiHelper.OkJSON(w, nil)
+	})
+
+	f.do("key", `{}`)
+	if len(notified) != 1 {
+		t.Fatalf("notified = %v", notified)
+	}
+}
@@ -0,0 +1,58 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// Purger periodically deletes records of expired keys, expired records are
+// ignored by Middleware before they are deleted.
+type Purger struct {
+	store  Store
+	logger util.Logger
+
+	ttl      time.Duration
+	interval time.Duration
+}
+
+func NewPurger(store Store, logger util.Logger, ttl time.Duration, interval time.Duration) *Purger {
+	return &Purger{
+		store:    store,
+		logger:   logger,
+		ttl:      ttl,
+		interval: interval,
+	}
+}
+
+// Run purges expired keys every interval until ctx is done.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Purge(ctx); err != nil {
+			p.logger.WithError(err).Error("failed to purge idempotency keys")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) Purge(ctx context.Context) error {
+	purged, err := p.store.DeleteExpired(ctx, nil, time.Now().Add(-p.ttl))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		p.logger.WithField("count", purged).Info("purged idempotency keys")
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// Lock takes transaction level advisory lock, it is released when tx is
+// committed or rolled back.
+func (s *PostgresStore) Lock(
+	ctx context.Context,
+	tx util.Transaction,
+	userID access_domain.UserID,
+	key string,
+) (bool, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	err = exec.QueryRow(
+		`select pg_try_advisory_xact_lock($1, hashtext($2::text || ':' || $3))`,
+		storage.IdempotencyLockSpace,
+		userID,
+		key,
+	).Scan(&locked)
+	return locked, err
+}
+
+func (s *PostgresStore) Get(
+	ctx context.Context,
+	tx util.Transaction,
+	userID access_domain.UserID,
+	key string,
+	notBefore time.Time,
+) (*Record, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	record := Record{UserID: userID, Key: key}
+	err = exec.QueryRow(`select fingerprint, status, content_type, body, created_at
+		from idempotency_keys
+		where user_id = $1 and key = $2 and created_at > $3`, userID, key, notBefore).Scan(
+		&record.Fingerprint,
+		&record.Status,
+		&record.ContentType,
+		&record.Body,
+		&record.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (s *PostgresStore) Save(ctx context.Context, tx util.Transaction, record Record) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`insert into idempotency_keys
+		(user_id, key, fingerprint, status, content_type, body, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (user_id, key) do update
+		set fingerprint = excluded.fingerprint,
+			status = excluded.status,
+			content_type = excluded.content_type,
+			body = excluded.body,
+			created_at = excluded.created_at`,
+		record.UserID,
+		record.Key,
+		record.Fingerprint,
+		record.Status,
+		record.ContentType,
+		record.Body,
+		record.CreatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) DeleteExpired(ctx context.Context, tx util.Transaction, before time.Time) (int64, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := exec.Exec(`delete from idempotency_keys where created_at <= $1`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
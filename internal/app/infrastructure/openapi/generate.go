@@ -0,0 +1,523 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	// SecuritySchemeName is name of scheme of authenticated operations,
+	// user is identified by gateway in user-id header.
+	SecuritySchemeName = "UserID"
+	// ErrorResponseName is name of component response of failed requests.
+	ErrorResponseName = "Error"
+)
+
+var (
+	ErrRoute         = errors.New("openapi: invalid route")
+	ErrDuplicate     = fmt.Errorf("%w: duplicate operation", ErrRoute)
+	ErrPathParameter = fmt.Errorf("%w: path parameter", ErrRoute)
+)
+
+// Route describes route of the api for the document, it is written next to
+// registration of the route, so both are built from the same table.
+type Route struct {
+	Method string
+	// Path is gorilla/mux path template, e.g. /todolist/todo/{id}.
+	Path        string
+	OperationID string
+	Summary     string
+	Tag         string
+	// Public operations are available without user-id header.
+	Public     bool
+	Parameters []Parameter
+	// Request is value of type of request body, nil if there is no body.
+	Request any
+	// RequestContentType is application/json unless set.
+	RequestContentType string
+	// Response is value of type of data field of util.JsonResponse
+	// envelope, nil if there is no body.
+	Response any
+	// ResponseContentTypes are set for routes responding with raw body,
+	// e.g. file downloads, instead of or in addition to envelope.
+	ResponseContentTypes []string
+	// Status is status of successful response, http.StatusOK unless set.
+	Status int
+	// Paginated responses have next_cursor field in envelope.
+	Paginated bool
+	// Deprecation is time route was deprecated at, Sunset is time it is
+	// removed at. Zero values mean route is not deprecated or removal is
+	// not planned yet.
+	Deprecation time.Time
+	Sunset      time.Time
+}
+
+type Parameter struct {
+	Name string
+	// In is query unless set, path parameters have to be declared for
+	// every variable of path template.
+	In          string
+	Description string
+	// Type is string unless set.
+	Type   string
+	Format string
+	Enum   []string
+	// Repeated parameters can be given multiple times.
+	Repeated bool
+	Required bool
+}
+
+const (
+	InQuery  = "query"
+	InPath   = "path"
+	InHeader = "header"
+)
+
+var pathVariable = regexp.MustCompile(`\{([^{}:]+)(:[^{}]*)?\}`)
+
+// PathTemplate converts gorilla/mux path template to OpenAPI one by
+// dropping patterns of variables.
+func PathTemplate(muxPath string) string {
+	return pathVariable.ReplaceAllString(muxPath, "{$1}")
+}
+
+func pathVariables(muxPath string) []string {
+	var names []string
+	for _, match := range pathVariable.FindAllStringSubmatch(muxPath, -1) {
+		names = append(names, match[1])
+	}
+
+	return names
+}
+
+// Generate builds document describing routes. Request and response types
+// are described by their json encoding and shared as component schemas.
+func Generate(info Info, routes []Route) (Document, error) {
+	generator := newSchemaGenerator()
+
+	doc := Document{
+		OpenAPI:  Version,
+		Info:     info,
+		Security: []SecurityRequirement{{SecuritySchemeName: {}}},
+		Paths:    make(map[string]PathItem),
+		Components: Components{
+			Schemas: generator.schemas,
+			Responses: map[string]Response{
+				ErrorResponseName: {
+					Description: "Request failed, message describes the error",
+					Content: map[string]MediaType{
+						"application/json": {Schema: errorEnvelope()},
+					},
+				},
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				SecuritySchemeName: {
+					Type:        "apiKey",
+					In:          InHeader,
+					Name:        "user-id",
+					Description: "Id of user, set by gateway after authentication",
+				},
+			},
+		},
+	}
+
+	for _, route := range routes {
+		operation, err := generator.operation(route)
+		if err != nil {
+			return Document{}, fmt.Errorf("%s %s: %w", route.Method, route.Path, err)
+		}
+
+		pathTemplate := PathTemplate(route.Path)
+		pathItem, ok := doc.Paths[pathTemplate]
+		if !ok {
+			pathItem = make(PathItem)
+			doc.Paths[pathTemplate] = pathItem
+		}
+
+		method := strings.ToLower(route.Method)
+		if _, ok := pathItem[method]; ok {
+			return Document{}, fmt.Errorf("%s %s: %w", route.Method, route.Path, ErrDuplicate)
+		}
+		pathItem[method] = operation
+	}
+
+	return doc, nil
+}
+
+func (g *schemaGenerator) operation(route Route) (*Operation, error) {
+	operation := &Operation{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Responses:   make(map[string]Response),
+	}
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+	if route.Public {
+		operation.Security = &[]SecurityRequirement{}
+	}
+	if !route.Deprecation.IsZero() {
+		operation.Deprecated = true
+		if !route.Sunset.IsZero() {
+			operation.Description = "Removed after " + route.Sunset.UTC().Format(time.DateOnly) + "."
+		}
+	}
+
+	declared := make(map[string]bool)
+	for _, parameter := range route.Parameters {
+		if parameter.In == InPath {
+			declared[parameter.Name] = true
+		}
+		operation.Parameters = append(operation.Parameters, parameterObject(parameter))
+	}
+
+	variables := pathVariables(route.Path)
+	for _, name := range variables {
+		if !declared[name] {
+			return nil, fmt.Errorf("%w: %s is not declared", ErrPathParameter, name)
+		}
+		delete(declared, name)
+	}
+	for name := range declared {
+		return nil, fmt.Errorf("%w: %s is not in path", ErrPathParameter, name)
+	}
+
+	if route.Request != nil {
+		contentType := route.RequestContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				contentType: {Schema: g.schema(reflect.TypeOf(route.Request), false)},
+			},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	response := Response{Description: http.StatusText(status)}
+	if route.Response != nil || len(route.ResponseContentTypes) > 0 {
+		response.Content = make(map[string]MediaType)
+	}
+	if route.Response != nil {
+		response.Content["application/json"] = MediaType{
+			Schema: envelope(g.schema(reflect.TypeOf(route.Response), true), route.Paginated),
+		}
+	}
+	for _, contentType := range route.ResponseContentTypes {
+		response.Content[contentType] = MediaType{Schema: rawSchema(contentType)}
+	}
+
+	operation.Responses[strconv.Itoa(status)] = response
+	operation.Responses["default"] = Response{Ref: "#/components/responses/" + ErrorResponseName}
+
+	return operation, nil
+}
+
+func parameterObject(parameter Parameter) ParameterObject {
+	in := parameter.In
+	if in == "" {
+		in = InQuery
+	}
+
+	typ := parameter.Type
+	if typ == "" {
+		typ = "string"
+	}
+
+	schema := &Schema{Type: typ, Format: parameter.Format, Enum: parameter.Enum}
+	if parameter.Repeated {
+		schema = &Schema{Type: "array", Items: schema}
+	}
+
+	return ParameterObject{
+		Name:        parameter.Name,
+		In:          in,
+		Description: parameter.Description,
+		// path parameters are always required
+		Required: parameter.Required || in == InPath,
+		Schema:   schema,
+	}
+}
+
+// envelope describes util.JsonResponse of successful request.
+func envelope(data *Schema, paginated bool) *Schema {
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error": {Type: "boolean", Const: false},
+			"data":  data,
+		},
+		Required: []string{"error", "data"},
+	}
+
+	if paginated {
+		schema.Properties["next_cursor"] = &Schema{
+			Type:        "string",
+			Description: "Cursor of the next page, omitted on the last page",
+		}
+	}
+
+	return schema
+}
+
+// errorEnvelope describes util.JsonResponse of failed request, data is
+// util.ValidationErrorsResponse of invalid request.
+func errorEnvelope() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error":   {Type: "boolean", Const: true},
+			"message": {Type: "string"},
+			"data": {
+				Type:        "object",
+				Description: "Errors of fields of invalid request",
+				Properties: map[string]*Schema{
+					"errors": {
+						Type: "array",
+						Items: &Schema{
+							Type: "object",
+							Properties: map[string]*Schema{
+								"field":   {Type: "string", Description: "Path of field, empty for errors of whole body"},
+								"message": {Type: "string"},
+							},
+							Required: []string{"field", "message"},
+						},
+					},
+				},
+				Required: []string{"errors"},
+			},
+		},
+		Required: []string{"error", "message"},
+	}
+}
+
+func rawSchema(contentType string) *Schema {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch {
+	case mediaType == "application/json":
+		return &Schema{}
+	case strings.HasPrefix(mediaType, "text/"), mediaType == "application/x-ndjson":
+		return &Schema{Type: "string"}
+	default:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGenerator describes go types by their encoding/json encoding.
+// Named structs become component schemas, names of types from different
+// packages are prefixed with package name on collision.
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schema returns schema of t. Fields without omitempty are required in
+// responses, in requests only fields with validate:"required" are.
+func (g *schemaGenerator) schema(t reflect.Type, response bool) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Pointer:
+		return nullable(g.schema(t.Elem(), response))
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), response)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem(), response)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, response)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t, response)}
+	default:
+		return &Schema{}
+	}
+}
+
+func nullable(schema *Schema) *Schema {
+	if typ, ok := schema.Type.(string); ok {
+		nullableSchema := *schema
+		nullableSchema.Type = []string{typ, "null"}
+		return &nullableSchema
+	}
+
+	return &Schema{OneOf: []*Schema{schema, {Type: "null"}}}
+}
+
+func (g *schemaGenerator) component(t reflect.Type, response bool) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, ok := g.schemas[name]; ok {
+		pkg, _, _ := strings.Cut(path.Base(t.PkgPath()), "_")
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	// registered before fields are described, so recursive types refer
+	// to themselves
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.structSchema(t, response)
+
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type, response bool) *Schema {
+	schema := &Schema{Type: "object"}
+	g.addFields(schema, t, response)
+	sort.Strings(schema.Required)
+
+	return schema
+}
+
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type, response bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		// fields of embedded structs are promoted like encoding/json does
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				g.addFields(schema, fieldType, response)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldSchema := g.schema(field.Type, response)
+		if enum := field.Tag.Get("enum"); enum != "" {
+			fieldSchema.Enum = strings.Split(enum, ",")
+		}
+		if format := field.Tag.Get("format"); format != "" {
+			fieldSchema.Format = format
+			fieldSchema.ContentEncoding = ""
+		}
+
+		required := addValidationRules(fieldSchema, field.Tag.Get("validate"))
+
+		if schema.Properties == nil {
+			schema.Properties = make(map[string]*Schema)
+		}
+		schema.Properties[name] = fieldSchema
+
+		if response && !hasOption(options, "omitempty") || !response && required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// addValidationRules describes rules of util.Validate tag and reports whether
+// field is required.
+func addValidationRules(schema *Schema, tag string) bool {
+	// broken tags are reported by util.CheckValidationTags when routes are
+	// registered
+	rules, _ := util.ParseValidationRules(tag)
+
+	required := false
+	for _, rule := range rules {
+		bound, _ := strconv.Atoi(rule.Param)
+
+		typ := schema.Type
+		if types, ok := typ.([]string); ok {
+			typ = types[0]
+		}
+
+		switch {
+		case rule.Name == "required":
+			required = true
+		case rule.Name == "oneof":
+			schema.Enum = strings.Fields(rule.Param)
+		case typ == "string" && rule.Name == "min":
+			schema.MinLength = &bound
+		case typ == "string" && rule.Name == "max":
+			schema.MaxLength = &bound
+		case typ == "array" && rule.Name == "min":
+			schema.MinItems = &bound
+		case typ == "array" && rule.Name == "max":
+			schema.MaxItems = &bound
+		case (typ == "integer" || typ == "number") && rule.Name == "min":
+			minimum := float64(bound)
+			schema.Minimum = &minimum
+		case (typ == "integer" || typ == "number") && rule.Name == "max":
+			maximum := float64(bound)
+			schema.Maximum = &maximum
+		}
+	}
+
+	return required
+}
+
+func hasOption(options string, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,161 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Item struct {
+	ID      int        `json:"id"`
+	Note    string     `json:"note,omitempty"`
+	DueAt   *time.Time `json:"due_at"`
+	Secret  string     `json:"-"`
+	Raw     json.RawMessage
+	private int
+}
+
+type ItemWithChildren struct {
+	Item
+	Children []*ItemWithChildren `json:"children"`
+}
+
+func TestGenerate(t *testing.T) {
+	doc, err := Generate(Info{Title: "test", Version: "1"}, []Route{{
+		Method:      "GET",
+		Path:        "/items/{id:[0-9]+}",
+		OperationID: "GetItem",
+		Parameters: []Parameter{
+			{Name: "id", In: InPath, Type: "integer"},
+			{Name: "tag", Repeated: true},
+		},
+		Response:  ItemWithChildren{},
+		Paginated: true,
+	}, {
+		Method:      "DELETE",
+		Path:        "/items/{id}",
+		OperationID: "DeleteItem",
+		Parameters:  []Parameter{{Name: "id", In: InPath, Type: "integer"}},
+		Deprecation: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:      time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+	}, {
+		Method:      "POST",
+		Path:        "/items",
+		OperationID: "PostItem",
+		Public:      true,
+		Request:     Item{},
+		Status:      201,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := doc.Paths["/items/{id}"]["get"]
+	if get == nil {
+		t.Fatalf("paths = %v", doc.Paths)
+	}
+	if !get.Parameters[0].Required || get.Parameters[1].Schema.Type != "array" {
+		t.Fatalf("parameters = %+v", get.Parameters)
+	}
+
+	data := get.Responses["200"].Content["application/json"].Schema.Properties["data"]
+	if data.Ref != "#/components/schemas/ItemWithChildren" {
+		t.Fatalf("data = %+v", data)
+	}
+	if _, ok := get.Responses["200"].Content["application/json"].Schema.Properties["next_cursor"]; !ok {
+		t.Fatal("paginated response has no next_cursor")
+	}
+
+	item := doc.Components.Schemas["ItemWithChildren"]
+	for _, name := range []string{"id", "note", "due_at", "Raw", "children"} {
+		if _, ok := item.Properties[name]; !ok {
+			t.Errorf("property %s is missing", name)
+		}
+	}
+	if len(item.Properties) != 5 {
+		t.Errorf("properties = %v", item.Properties)
+	}
+	if !reflect.DeepEqual(item.Required, []string{"Raw", "children", "due_at", "id"}) {
+		t.Errorf("required = %v", item.Required)
+	}
+	if !reflect.DeepEqual(item.Properties["due_at"].Type, []string{"string", "null"}) {
+		t.Errorf("due_at = %+v", item.Properties["due_at"])
+	}
+	children := item.Properties["children"].Items
+	if len(children.OneOf) != 2 || children.OneOf[0].Ref != "#/components/schemas/ItemWithChildren" {
+		t.Errorf("children = %+v", children)
+	}
+
+	if get.Deprecated {
+		t.Error("get is deprecated")
+	}
+	if del := doc.Paths["/items/{id}"]["delete"]; !del.Deprecated || del.Description != "Removed after 2025-06-01." {
+		t.Errorf("deprecated operation = %+v", del)
+	}
+
+	post := doc.Paths["/items"]["post"]
+	if post.Security == nil || len(*post.Security) != 0 {
+		t.Errorf("public operation security = %v", post.Security)
+	}
+	if _, ok := post.Responses["201"]; !ok {
+		t.Errorf("responses = %v", post.Responses)
+	}
+	// nothing is required in requests
+	if required := doc.Components.Schemas["Item"].Required; len(required) != 0 {
+		t.Errorf("request required = %v", required)
+	}
+}
+
+type ValidatedItem struct {
+	Title string   `json:"title" validate:"required,max=100"`
+	Kind  string   `json:"kind" validate:"oneof=a b"`
+	Tags  []string `json:"tags" validate:"max=5"`
+	Count *int     `json:"count" validate:"min=1"`
+}
+
+func TestGenerateValidationRules(t *testing.T) {
+	doc, err := Generate(Info{}, []Route{{
+		Method:      "POST",
+		Path:        "/items",
+		OperationID: "PostItem",
+		Request:     ValidatedItem{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := doc.Components.Schemas["ValidatedItem"]
+	if !reflect.DeepEqual(item.Required, []string{"title"}) {
+		t.Errorf("required = %v", item.Required)
+	}
+	if title := item.Properties["title"]; title.MaxLength == nil || *title.MaxLength != 100 {
+		t.Errorf("title = %+v", title)
+	}
+	if kind := item.Properties["kind"]; !reflect.DeepEqual(kind.Enum, []string{"a", "b"}) {
+		t.Errorf("kind = %+v", kind)
+	}
+	if tags := item.Properties["tags"]; tags.MaxItems == nil || *tags.MaxItems != 5 {
+		t.Errorf("tags = %+v", tags)
+	}
+	if count := item.Properties["count"]; count.Minimum == nil || *count.Minimum != 1 {
+		t.Errorf("count = %+v", count)
+	}
+}
+
+func TestGeneratePathParameters(t *testing.T) {
+	for _, route := range []Route{
+		{Method: "GET", Path: "/items/{id}"},
+		{Method: "GET", Path: "/items", Parameters: []Parameter{{Name: "id", In: InPath}}},
+	} {
+		if _, err := Generate(Info{}, []Route{route}); !errors.Is(err, ErrPathParameter) {
+			t.Errorf("%s: err = %v", route.Path, err)
+		}
+	}
+
+	_, err := Generate(Info{}, []Route{{Method: "GET", Path: "/items"}, {Method: "GET", Path: "/items"}})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate: err = %v", err)
+	}
+}
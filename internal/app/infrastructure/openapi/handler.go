@@ -0,0 +1,57 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+)
+
+// Marshal encodes document the way it is served, so generated files can be
+// compared byte by byte.
+func Marshal(doc Document) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// SpecHandler serves document as json, document is encoded once.
+func SpecHandler(doc Document) (http.Handler, error) {
+	data, err := Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		_, _ = w.Write(data)
+	}), nil
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>%s</title>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+	<redoc spec-url="%s"></redoc>
+	<script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// DocsHandler serves page rendering document served at specURL.
+func DocsHandler(title string, specURL string) http.Handler {
+	page := fmt.Sprintf(docsPage, html.EscapeString(title), html.EscapeString(specURL))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	})
+}
@@ -0,0 +1,177 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+	DefaultMaxAttempts  = 10
+	DefaultBaseBackoff  = time.Second
+	DefaultMaxBackoff   = time.Hour
+	// DefaultClaimLease covers delivery of the whole batch to every sink.
+	DefaultClaimLease      = 5 * time.Minute
+	DefaultRetention       = 7 * 24 * time.Hour
+	DefaultCleanupInterval = time.Hour
+)
+
+type DispatcherConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// ClaimLease is how long claimed messages are not claimed again,
+	// messages which are not marked by then are delivered again.
+	ClaimLease time.Duration
+	// Retention is how long delivered messages are kept, they are deleted
+	// every CleanupInterval.
+	Retention       time.Duration
+	CleanupInterval time.Duration
+}
+
+var DefaultDispatcherConfig = DispatcherConfig{
+	BatchSize:       DefaultBatchSize,
+	PollInterval:    DefaultPollInterval,
+	MaxAttempts:     DefaultMaxAttempts,
+	BaseBackoff:     DefaultBaseBackoff,
+	MaxBackoff:      DefaultMaxBackoff,
+	ClaimLease:      DefaultClaimLease,
+	Retention:       DefaultRetention,
+	CleanupInterval: DefaultCleanupInterval,
+}
+
+// Dispatcher polls outbox and delivers messages to every sink. Message is
+// marked delivered only when all sinks accepted it, otherwise it is retried
+// later with exponential backoff.
+type Dispatcher struct {
+	txFactory util.TransactionFactory
+	store     Store
+	sinks     []Sink
+	logger    util.Logger
+	config    DispatcherConfig
+}
+
+func NewDispatcher(
+	txFactory util.TransactionFactory,
+	store Store,
+	logger util.Logger,
+	config DispatcherConfig,
+	sinks ...Sink,
+) *Dispatcher {
+	return &Dispatcher{
+		txFactory: txFactory,
+		store:     store,
+		sinks:     sinks,
+		logger:    logger,
+		config:    config,
+	}
+}
+
+// Backoff returns delay before next attempt after given number of failed
+// attempts.
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	return util.Backoff(d.config.BaseBackoff, d.config.MaxBackoff, attempts)
+}
+
+// Run dispatches messages every poll interval until ctx is done. Full
+// batches are followed by the next batch without waiting. Delivered
+// messages are deleted every cleanup interval, also while full batches
+// keep coming.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(d.config.CleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			if _, err := d.Cleanup(ctx); err != nil {
+				d.logger.WithError(err).Error("failed to clean up outbox")
+			}
+		default:
+		}
+
+		dispatched, err := d.DispatchBatch(ctx)
+		if err != nil {
+			d.logger.WithError(err).Error("failed to dispatch outbox")
+		}
+
+		if err == nil && dispatched == d.config.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cleanup deletes messages delivered longer than retention ago and returns
+// their number.
+func (d *Dispatcher) Cleanup(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := d.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		var err error
+		deleted, err = d.store.DeleteDelivered(ctx, tx, d.config.Retention)
+		return err
+	})
+
+	return deleted, err
+}
+
+// DispatchBatch delivers a single batch of due messages and returns number
+// of processed messages. Messages are claimed in one transaction and
+// delivered to sinks with no transaction open, each of them is marked in
+// its own transaction, see util.ProcessBatch.
+func (d *Dispatcher) DispatchBatch(ctx context.Context) (int, error) {
+	return util.ProcessBatch(ctx, d.txFactory, func(tx util.Transaction) ([]Message, error) {
+		return d.store.Claim(ctx, tx, d.config.BatchSize, d.config.ClaimLease)
+	}, func(message Message) error {
+		if err := d.dispatch(ctx, message); err != nil {
+			return fmt.Errorf("message %d: %w", message.ID, err)
+		}
+		return nil
+	})
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, message Message) error {
+	var deliveryErr error
+	for _, sink := range d.sinks {
+		if err := sink.Deliver(ctx, message); err != nil {
+			deliveryErr = errors.Join(deliveryErr, err)
+		}
+	}
+
+	return d.txFactory.WithTransaction(ctx, func(tx util.Transaction) error {
+		if deliveryErr == nil {
+			return d.store.MarkDelivered(ctx, tx, message.ID)
+		}
+
+		attempts := message.Attempts + 1
+		logger := d.logger.
+			WithError(deliveryErr).
+			WithField("message_id", message.ID).
+			WithField("attempts", attempts)
+
+		if attempts >= d.config.MaxAttempts {
+			logger.Error("outbox message delivery failed, giving up")
+			return d.store.MarkFailed(ctx, tx, message.ID, deliveryErr.Error())
+		}
+
+		logger.Warn("outbox message delivery failed, will retry")
+		return d.store.MarkRetry(ctx, tx, message.ID, d.Backoff(attempts), deliveryErr.Error())
+	})
+}
@@ -0,0 +1,340 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type memoryRecord struct {
+	message       Message
+	nextAttemptAt time.Time
+	deliveredAt   *time.Time
+	failed        bool
+	// records are addressed by id, deleted records are kept
+	deleted bool
+}
+
+type memoryStore struct {
+	now     time.Time
+	records []*memoryRecord
+	// failMark is message which can not be marked.
+	failMark int64
+}
+
+func (s *memoryStore) Enqueue(ctx context.Context, tx util.Transaction, messages ...Message) error {
+	for _, message := range messages {
+		message.ID = int64(len(s.records) + 1)
+		s.records = append(s.records, &memoryRecord{message: message, nextAttemptAt: s.now})
+	}
+	return nil
+}
+
+func (s *memoryStore) Claim(ctx context.Context, tx util.Transaction, limit int, lease time.Duration) ([]Message, error) {
+	var messages []Message
+	for _, record := range s.records {
+		if len(messages) == limit {
+			break
+		}
+		if record.deliveredAt == nil && !record.failed && !record.deleted && !record.nextAttemptAt.After(s.now) {
+			record.nextAttemptAt = s.now.Add(lease)
+			messages = append(messages, record.message)
+		}
+	}
+	return messages, nil
+}
+
+func (s *memoryStore) record(id int64) *memoryRecord {
+	return s.records[id-1]
+}
+
+func (s *memoryStore) MarkDelivered(ctx context.Context, tx util.Transaction, id int64) error {
+	if id == s.failMark {
+		return errors.New("mark failed")
+	}
+
+	now := s.now
+	s.record(id).message.Attempts++
+	s.record(id).deliveredAt = &now
+	return nil
+}
+
+func (s *memoryStore) MarkRetry(ctx context.Context, tx util.Transaction, id int64, backoff time.Duration, lastErr string) error {
+	s.record(id).message.Attempts++
+	s.record(id).nextAttemptAt = s.now.Add(backoff)
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(ctx context.Context, tx util.Transaction, id int64, lastErr string) error {
+	s.record(id).message.Attempts++
+	s.record(id).failed = true
+	return nil
+}
+
+func (s *memoryStore) DeleteDelivered(ctx context.Context, tx util.Transaction, retention time.Duration) (int64, error) {
+	var deleted int64
+	for _, record := range s.records {
+		if !record.deleted && record.deliveredAt != nil && record.deliveredAt.Before(s.now.Add(-retention)) {
+			record.deleted = true
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func newTestDispatcher(store *memoryStore, sinks ...Sink) *Dispatcher {
+	return NewDispatcher(util.NewTransactionFactoryTest(), store, util.NewLoggerTest(), DispatcherConfig{
+		BatchSize:    10,
+		PollInterval: time.Millisecond,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   time.Minute,
+		ClaimLease:   time.Minute,
+		Retention:    time.Hour,
+	}, sinks...)
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{now: time.Unix(0, 0)}
+
+	failures := 1
+	var delivered []string
+
+	sink := NewLocalSink()
+	sink.Subscribe("todolist", func(ctx context.Context, message Message) error {
+		if failures > 0 {
+			failures--
+			return errors.New("sink is down")
+		}
+		delivered = append(delivered, string(message.Payload))
+		return nil
+	})
+
+	d := newTestDispatcher(store, sink)
+
+	if err := store.Enqueue(ctx, nil, Message{Topic: "todolist", Payload: []byte(`{"n":1}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.DispatchBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 0 {
+		t.Fatalf("expected first delivery to fail")
+	}
+
+	// message is not due before backoff passes
+	if n, _ := d.DispatchBatch(ctx); n != 0 {
+		t.Fatalf("expected no messages before backoff, got %d", n)
+	}
+
+	store.now = store.now.Add(d.Backoff(1))
+	if _, err := d.DispatchBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(delivered) != 1 || delivered[0] != `{"n":1}` {
+		t.Fatalf("unexpected deliveries: %v", delivered)
+	}
+	if record := store.record(1); record.deliveredAt == nil || record.message.Attempts != 2 {
+		t.Fatalf("unexpected record state: %+v", record)
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{now: time.Unix(0, 0)}
+
+	sink := NewLocalSink()
+	sink.Subscribe("todolist", func(ctx context.Context, message Message) error {
+		return errors.New("sink is down")
+	})
+
+	d := newTestDispatcher(store, sink)
+	if err := store.Enqueue(ctx, nil, Message{Topic: "todolist", Payload: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := d.DispatchBatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+		store.now = store.now.Add(time.Hour)
+	}
+
+	if record := store.record(1); !record.failed || record.message.Attempts != 3 {
+		t.Fatalf("expected message to fail after 3 attempts: %+v", record)
+	}
+}
+
+func TestDispatcherMarksMessagesSeparately(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{now: time.Unix(0, 0)}
+
+	var delivered int
+	sink := NewLocalSink()
+	sink.Subscribe("todolist", func(ctx context.Context, message Message) error {
+		delivered++
+		return nil
+	})
+
+	d := newTestDispatcher(store, sink)
+	if err := store.Enqueue(ctx, nil,
+		Message{Topic: "todolist", Payload: []byte(`{"n":1}`)},
+		Message{Topic: "todolist", Payload: []byte(`{"n":2}`)},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	store.failMark = 1
+	if n, err := d.DispatchBatch(ctx); err == nil || n != 1 {
+		t.Fatalf("dispatched %d messages, err = %v", n, err)
+	}
+	// mark of the other message is kept
+	if store.record(2).deliveredAt == nil {
+		t.Fatal("message 2 is not marked delivered")
+	}
+
+	// message which was not marked is delivered again after lease
+	store.failMark = 0
+	if n, _ := d.DispatchBatch(ctx); n != 0 {
+		t.Fatalf("dispatched %d messages before lease ended", n)
+	}
+	store.now = store.now.Add(time.Minute)
+	if n, err := d.DispatchBatch(ctx); err != nil || n != 1 {
+		t.Fatalf("dispatched %d messages, err = %v", n, err)
+	}
+	if delivered != 3 {
+		t.Fatalf("delivered = %d", delivered)
+	}
+}
+
+func TestDispatcherCleanup(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{now: time.Unix(0, 0)}
+
+	d := newTestDispatcher(store, NewLocalSink())
+	if err := store.Enqueue(ctx, nil, Message{Topic: "todolist", Payload: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.DispatchBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted, err := d.Cleanup(ctx); err != nil || deleted != 0 {
+		t.Fatalf("deleted %d messages before retention, err = %v", deleted, err)
+	}
+
+	store.now = store.now.Add(2 * time.Hour)
+	if deleted, err := d.Cleanup(ctx); err != nil || deleted != 1 {
+		t.Fatalf("deleted %d messages, err = %v", deleted, err)
+	}
+}
+
+// busyStore always has a full batch of due messages.
+type busyStore struct {
+	memoryStore
+	batchSize int
+	cleaned   chan struct{}
+}
+
+func (s *busyStore) Claim(ctx context.Context, tx util.Transaction, limit int, lease time.Duration) ([]Message, error) {
+	messages := make([]Message, s.batchSize)
+	for i := range messages {
+		messages[i] = Message{ID: int64(i + 1), Topic: "todolist"}
+	}
+	return messages, nil
+}
+
+func (s *busyStore) MarkDelivered(ctx context.Context, tx util.Transaction, id int64) error {
+	return nil
+}
+
+func (s *busyStore) DeleteDelivered(ctx context.Context, tx util.Transaction, retention time.Duration) (int64, error) {
+	select {
+	case s.cleaned <- struct{}{}:
+	default:
+	}
+	return 0, nil
+}
+
+func TestDispatcherCleansUpUnderLoad(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := newTestDispatcher(&memoryStore{}).config
+	config.CleanupInterval = time.Millisecond
+	store := &busyStore{batchSize: config.BatchSize, cleaned: make(chan struct{}, 1)}
+	d := NewDispatcher(util.NewTransactionFactoryTest(), store, util.NewLoggerTest(), config)
+
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-store.cleaned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("outbox is not cleaned up while batches are full")
+	}
+
+	cancel()
+	<-done
+}
+
+func TestBackoff(t *testing.T) {
+	d := newTestDispatcher(&memoryStore{})
+
+	for attempts, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		20: time.Minute,
+	} {
+		if got := d.Backoff(attempts); got != want {
+			t.Fatalf("backoff after %d attempts: got %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.ndjson")
+	store := &memoryStore{now: time.Unix(0, 0)}
+
+	d := newTestDispatcher(store, NewFileSink(path))
+	if err := store.Enqueue(ctx, nil,
+		Message{Topic: "todolist", Payload: []byte(`{"n":1}`)},
+		Message{Topic: "todolist", Payload: []byte(`{"n":2}`)},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := d.DispatchBatch(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 dispatched messages, got %d (%v)", n, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+
+	if lines != 2 {
+		t.Fatalf("expected 2 lines in file sink, got %d", lines)
+	}
+}
@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// Message is a record of outbox table. It is written in the same
+// transaction as the change it describes and delivered to sinks by
+// Dispatcher after commit.
+type Message struct {
+	ID        int64
+	Topic     string
+	Key       string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// Store keeps messages, delays are applied by clock of store, so every
+// timestamp of message comes from the same clock.
+type Store interface {
+	Enqueue(ctx context.Context, tx util.Transaction, messages ...Message) error
+	// Claim returns up to limit pending messages which are due for delivery
+	// and postpones their next attempt by lease, so they are not claimed
+	// again while they are delivered. Messages locked by other transactions
+	// are skipped.
+	Claim(ctx context.Context, tx util.Transaction, limit int, lease time.Duration) ([]Message, error)
+	MarkDelivered(ctx context.Context, tx util.Transaction, id int64) error
+	// MarkRetry makes message due again after backoff.
+	MarkRetry(ctx context.Context, tx util.Transaction, id int64, backoff time.Duration, lastErr string) error
+	// MarkFailed stops delivery of message after too many attempts.
+	MarkFailed(ctx context.Context, tx util.Transaction, id int64, lastErr string) error
+	// DeleteDelivered deletes messages delivered more than retention ago.
+	DeleteDelivered(ctx context.Context, tx util.Transaction, retention time.Duration) (int64, error)
+}
+
+// Sink receives delivered messages. Delivery is at-least-once, so sinks must
+// tolerate duplicates.
+type Sink interface {
+	Deliver(ctx context.Context, message Message) error
+}
@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+type LocalHandler func(ctx context.Context, message Message) error
+
+// LocalSink delivers messages to in-process handlers subscribed to topic.
+type LocalSink struct {
+	mu       sync.RWMutex
+	handlers map[string][]LocalHandler
+}
+
+func NewLocalSink() *LocalSink {
+	return &LocalSink{handlers: map[string][]LocalHandler{}}
+}
+
+var _ Sink = (*LocalSink)(nil)
+
+func (s *LocalSink) Subscribe(topic string, handler LocalHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[topic] = append(s.handlers[topic], handler)
+}
+
+func (s *LocalSink) Deliver(ctx context.Context, message Message) error {
+	s.mu.RLock()
+	handlers := s.handlers[message.Topic]
+	s.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type fileRecord struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// FileSink appends every message as a json line to a file.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+var _ Sink = (*FileSink)(nil)
+
+func (s *FileSink) Deliver(ctx context.Context, message Message) error {
+	line, err := json.Marshal(fileRecord{
+		ID:        message.ID,
+		Topic:     message.Topic,
+		Key:       message.Key,
+		Payload:   message.Payload,
+		CreatedAt: message.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
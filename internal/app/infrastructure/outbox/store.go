@@ -0,0 +1,169 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+func (s *PostgresStore) Enqueue(ctx context.Context, tx util.Transaction, messages ...Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return err
+	}
+
+	stmtInsert, err := exec.Prepare(`insert into outbox
+		(topic, key, payload)
+		values ($1, $2, $3)`)
+	if err != nil {
+		return err
+	}
+	defer stmtInsert.Close()
+
+	for _, message := range messages {
+		if _, err := stmtInsert.Exec(message.Topic, message.Key, message.Payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) Claim(
+	ctx context.Context,
+	tx util.Transaction,
+	limit int,
+	lease time.Duration,
+) (_ []Message, err error) {
+	exec, commit, rollback, err := storage.GetTxOrCreateTx(ctx, tx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(rollback(), err)
+		} else {
+			err = commit()
+		}
+	}()
+
+	rows, err := exec.Query(`with claimed as (
+			select id as claimed_id
+			from outbox
+			where delivered_at is null
+			and failed_at is null
+			and next_attempt_at <= now()
+			order by id
+			limit $1
+			for update skip locked
+		), leased as (
+			update outbox
+			set next_attempt_at = now() + make_interval(secs => $2)
+			from claimed
+			where id = claimed_id
+			returning id, topic, key, payload, attempts, created_at
+		)
+		select id, topic, key, payload, attempts, created_at
+		from leased
+		order by id`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var message Message
+		if err := rows.Scan(
+			&message.ID,
+			&message.Topic,
+			&message.Key,
+			&message.Payload,
+			&message.Attempts,
+			&message.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+func (s *PostgresStore) MarkDelivered(ctx context.Context, tx util.Transaction, id int64) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`update outbox
+		set delivered_at = now(), attempts = attempts + 1
+		where id = $1`, id)
+	return err
+}
+
+func (s *PostgresStore) MarkRetry(
+	ctx context.Context,
+	tx util.Transaction,
+	id int64,
+	backoff time.Duration,
+	lastErr string,
+) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`update outbox
+		set attempts = attempts + 1,
+			next_attempt_at = now() + make_interval(secs => $2),
+			last_error = $3
+		where id = $1`, id, backoff.Seconds(), lastErr)
+	return err
+}
+
+func (s *PostgresStore) MarkFailed(ctx context.Context, tx util.Transaction, id int64, lastErr string) error {
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`update outbox
+		set attempts = attempts + 1, failed_at = now(), last_error = $2
+		where id = $1`, id, lastErr)
+	return err
+}
+
+func (s *PostgresStore) DeleteDelivered(ctx context.Context, tx util.Transaction, retention time.Duration) (int64, error) {
+	exec, err := storage.GetDBExecutor(ctx, tx, s.db)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := exec.Exec(`delete from outbox
+		where delivered_at < now() - make_interval(secs => $1)`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
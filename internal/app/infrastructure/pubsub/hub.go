@@ -0,0 +1,217 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultReplayBuffer     = 256
+	DefaultSubscriberBuffer = 64
+	DefaultReplayTTL        = 10 * time.Minute
+)
+
+// ErrMessagesLost is returned by Subscribe when messages after lastID are
+// no longer buffered, subscriber has to reload state it keeps in sync.
+var ErrMessagesLost = errors.New("pubsub: messages after last id are lost")
+
+type Message struct {
+	ID    uint64
+	Topic string
+	Data  []byte
+}
+
+type topic struct {
+	// replay is a ring of latest messages, next is index of the oldest one
+	// once the ring is full.
+	replay  []Message
+	next    int
+	evicted uint64
+
+	subscribers map[*Subscription]struct{}
+	// idleSince is when the last subscriber left, zero while topic has
+	// subscribers.
+	idleSince time.Time
+}
+
+// Hub is in-process publish/subscribe. Message ids grow across all topics
+// and start from hub creation time, so ids issued before restart are
+// detected as lost instead of being mixed with new ones. Topics without
+// subscribers for replayTTL are dropped together with their replay.
+type Hub struct {
+	mu     sync.Mutex
+	seq    uint64
+	start  uint64
+	topics map[string]*topic
+
+	replayBuffer     int
+	subscriberBuffer int
+	replayTTL        time.Duration
+}
+
+func NewHub(replayBuffer int, subscriberBuffer int, replayTTL time.Duration) *Hub {
+	start := uint64(time.Now().UnixMicro())
+
+	return &Hub{
+		seq:              start,
+		start:            start,
+		topics:           map[string]*topic{},
+		replayBuffer:     replayBuffer,
+		subscriberBuffer: subscriberBuffer,
+		replayTTL:        replayTTL,
+	}
+}
+
+// topic returns topic by name, new topic treats every message published
+// before it as lost, since it may have been published to dropped topic of
+// the same name.
+func (h *Hub) topic(name string) *topic {
+	t, ok := h.topics[name]
+	if !ok {
+		t = &topic{
+			evicted:     h.seq,
+			subscribers: map[*Subscription]struct{}{},
+			idleSince:   time.Now(),
+		}
+		h.topics[name] = t
+	}
+
+	return t
+}
+
+// Run drops idle topics every replayTTL until ctx is done.
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.replayTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Reclaim(time.Now().Add(-h.replayTTL))
+		}
+	}
+}
+
+// Reclaim drops topics without subscribers since before idleBefore and
+// returns number of dropped topics.
+func (h *Hub) Reclaim(idleBefore time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var reclaimed int
+	for name, t := range h.topics {
+		if len(t.subscribers) == 0 && t.idleSince.Before(idleBefore) {
+			delete(h.topics, name)
+			reclaimed++
+		}
+	}
+
+	return reclaimed
+}
+
+// Publish buffers message for replay and sends it to subscribers of topic.
+// Subscribers which do not keep up are closed, they may resubscribe with
+// id of the last received message.
+func (h *Hub) Publish(topicName string, data []byte) Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	message := Message{ID: h.seq, Topic: topicName, Data: data}
+
+	t := h.topic(topicName)
+	if len(t.replay) < h.replayBuffer {
+		t.replay = append(t.replay, message)
+	} else if h.replayBuffer > 0 {
+		t.evicted = t.replay[t.next].ID
+		t.replay[t.next] = message
+		t.next = (t.next + 1) % len(t.replay)
+	}
+
+	for subscription := range t.subscribers {
+		select {
+		case subscription.messages <- message:
+		default:
+			h.unsubscribe(subscription)
+		}
+	}
+
+	return message
+}
+
+// Subscribe subscribes to topic and returns buffered messages published
+// after lastID, zero lastID subscribes to new messages only. When messages
+// after lastID are lost subscription is still returned along with
+// ErrMessagesLost.
+func (h *Hub) Subscribe(topicName string, lastID uint64) (*Subscription, []Message, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t := h.topic(topicName)
+	subscription := &Subscription{
+		hub:      h,
+		topic:    topicName,
+		messages: make(chan Message, h.subscriberBuffer),
+	}
+	t.subscribers[subscription] = struct{}{}
+	t.idleSince = time.Time{}
+
+	if lastID == 0 {
+		return subscription, nil, nil
+	}
+
+	if lastID < h.start || lastID > h.seq || lastID < t.evicted {
+		return subscription, nil, ErrMessagesLost
+	}
+
+	var missed []Message
+	for i := range t.replay {
+		message := t.replay[(t.next+i)%len(t.replay)]
+		if message.ID > lastID {
+			missed = append(missed, message)
+		}
+	}
+
+	return subscription, missed, nil
+}
+
+func (h *Hub) unsubscribe(subscription *Subscription) {
+	t, ok := h.topics[subscription.topic]
+	if !ok {
+		return
+	}
+
+	if _, ok := t.subscribers[subscription]; !ok {
+		return
+	}
+
+	delete(t.subscribers, subscription)
+	close(subscription.messages)
+
+	if len(t.subscribers) == 0 {
+		t.idleSince = time.Now()
+	}
+}
+
+type Subscription struct {
+	hub      *Hub
+	topic    string
+	messages chan Message
+}
+
+// Messages returns channel of published messages, it is closed when
+// subscription is closed or subscriber falls behind.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.unsubscribe(s)
+}
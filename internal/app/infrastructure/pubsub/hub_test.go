@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHubReplaysMessagesAfterLastID(t *testing.T) {
+	hub := NewHub(3, 10, time.Minute)
+
+	first := hub.Publish("a", []byte("1"))
+	hub.Publish("b", []byte("other"))
+	hub.Publish("a", []byte("2"))
+	hub.Publish("a", []byte("3"))
+
+	subscription, missed, err := hub.Subscribe("a", first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Close()
+
+	if len(missed) != 2 || string(missed[0].Data) != "2" || string(missed[1].Data) != "3" {
+		t.Fatalf("missed = %v", missed)
+	}
+
+	live := hub.Publish("a", []byte("4"))
+	if got := <-subscription.Messages(); got.ID != live.ID {
+		t.Fatalf("got %d, want %d", got.ID, live.ID)
+	}
+}
+
+func TestHubDetectsLostMessages(t *testing.T) {
+	hub := NewHub(2, 10, time.Minute)
+
+	first := hub.Publish("a", []byte("1"))
+	second := hub.Publish("a", []byte("2"))
+	hub.Publish("a", []byte("3"))
+	hub.Publish("a", []byte("4"))
+
+	if _, _, err := hub.Subscribe("a", first.ID); !errors.Is(err, ErrMessagesLost) {
+		t.Fatalf("evicted id: err = %v", err)
+	}
+
+	// id issued before hub was created, e.g. before restart
+	if _, _, err := hub.Subscribe("a", 1); !errors.Is(err, ErrMessagesLost) {
+		t.Fatalf("stale id: err = %v", err)
+	}
+
+	_, missed, err := hub.Subscribe("a", second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missed) != 2 || string(missed[0].Data) != "3" {
+		t.Fatalf("missed = %v", missed)
+	}
+}
+
+func TestHubClosesSlowSubscriber(t *testing.T) {
+	hub := NewHub(10, 1, time.Minute)
+
+	slow, _, _ := hub.Subscribe("a", 0)
+	hub.Publish("a", []byte("1"))
+	hub.Publish("a", []byte("2"))
+
+	if _, ok := <-slow.Messages(); !ok {
+		t.Fatal("buffered message is lost")
+	}
+	if _, ok := <-slow.Messages(); ok {
+		t.Fatal("slow subscriber is not closed")
+	}
+
+	// closing evicted subscription is a no-op
+	slow.Close()
+}
+
+func TestHubReclaimsIdleTopics(t *testing.T) {
+	hub := NewHub(10, 10, time.Minute)
+
+	idle := hub.Publish("idle", []byte("1"))
+	subscription, _, _ := hub.Subscribe("active", 0)
+	defer subscription.Close()
+	hub.Publish("active", []byte("1"))
+
+	if reclaimed := hub.Reclaim(time.Now().Add(-time.Minute)); reclaimed != 0 {
+		t.Fatalf("reclaimed %d topics idle for less than ttl", reclaimed)
+	}
+
+	if reclaimed := hub.Reclaim(time.Now().Add(time.Second)); reclaimed != 1 {
+		t.Fatalf("reclaimed %d topics", reclaimed)
+	}
+	if _, ok := hub.topics["active"]; !ok || len(hub.topics) != 1 {
+		t.Fatalf("topics = %v", hub.topics)
+	}
+
+	// replay of dropped topic is gone
+	if _, _, err := hub.Subscribe("idle", idle.ID); !errors.Is(err, ErrMessagesLost) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
+)
+
+const (
+	DefaultSendBuffer = 64
+	MaxMessageSize    = 4096
+
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+)
+
+var clientSeq atomic.Uint64
+
+// Client is a websocket connection of user. Messages are queued to a
+// bounded buffer and written by WritePump, client which does not drain its
+// buffer is disconnected instead of blocking senders.
+type Client struct {
+	id     uint64
+	userID access_domain.UserID
+	conn   *websocket.Conn
+
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewClient(conn *websocket.Conn, userID access_domain.UserID, sendBuffer int) *Client {
+	if conn != nil {
+		conn.SetReadLimit(MaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+	}
+
+	return &Client{
+		id:     clientSeq.Add(1),
+		userID: userID,
+		conn:   conn,
+		send:   make(chan []byte, sendBuffer),
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *Client) ID() uint64 {
+	return c.id
+}
+
+func (c *Client) UserID() access_domain.UserID {
+	return c.userID
+}
+
+// Send queues message without blocking, it returns false and closes client
+// when its buffer is full.
+func (c *Client) Send(message []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- message:
+		return true
+	default:
+		c.Close()
+		return false
+	}
+}
+
+// Done is closed when client is closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close stops client, WritePump sends close message and closes connection.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// Read returns next message of client, it fails when client does not
+// answer pings in time.
+func (c *Client) Read() ([]byte, error) {
+	_, message, err := c.conn.ReadMessage()
+	return message, err
+}
+
+// WritePump writes queued messages and pings until client is closed, it
+// must be the only writer of connection.
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	// closing connection also stops Read of the client
+	defer c.conn.Close()
+	defer c.Close()
+
+	for {
+		select {
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
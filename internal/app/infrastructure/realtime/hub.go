@@ -0,0 +1,77 @@
+package realtime
+
+import (
+	"sync"
+)
+
+// Hub groups clients into rooms and fans messages out to them. Sending is
+// never blocked by a slow client, see Client.Send.
+type Hub interface {
+	// Join adds client to room and returns clients of room, client
+	// included.
+	Join(room string, client *Client) []*Client
+	// Leave removes client from room and reports whether it was there.
+	Leave(room string, client *Client) bool
+	// Broadcast sends message to clients of room except the given one, nil
+	// except sends to everyone.
+	Broadcast(room string, message []byte, except *Client)
+}
+
+type MemoryHub struct {
+	mu    sync.RWMutex
+	rooms map[string]map[*Client]struct{}
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{rooms: map[string]map[*Client]struct{}{}}
+}
+
+var _ Hub = (*MemoryHub)(nil)
+
+func (h *MemoryHub) Join(room string, client *Client) []*Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rooms[room] == nil {
+		h.rooms[room] = map[*Client]struct{}{}
+	}
+	h.rooms[room][client] = struct{}{}
+
+	members := make([]*Client, 0, len(h.rooms[room]))
+	for member := range h.rooms[room] {
+		members = append(members, member)
+	}
+
+	return members
+}
+
+func (h *MemoryHub) Leave(room string, client *Client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.rooms[room][client]; !ok {
+		return false
+	}
+
+	delete(h.rooms[room], client)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+
+	return true
+}
+
+func (h *MemoryHub) Broadcast(room string, message []byte, except *Client) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.rooms[room] {
+		if client == except {
+			continue
+		}
+
+		// slow client is closed by Send, it leaves rooms when its
+		// connection handler returns
+		client.Send(message)
+	}
+}
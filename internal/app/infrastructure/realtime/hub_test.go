@@ -0,0 +1,64 @@
+package realtime
+
+import (
+	"testing"
+)
+
+func TestMemoryHubBroadcast(t *testing.T) {
+	hub := NewMemoryHub()
+
+	alice := NewClient(nil, 1, 10)
+	bob := NewClient(nil, 2, 10)
+	other := NewClient(nil, 3, 10)
+
+	hub.Join("list", alice)
+	if members := hub.Join("list", bob); len(members) != 2 {
+		t.Fatalf("members = %d, want 2", len(members))
+	}
+	hub.Join("other", other)
+
+	hub.Broadcast("list", []byte("hello"), alice)
+
+	if len(alice.send) != 0 {
+		t.Fatal("message is sent to excluded client")
+	}
+	if message := <-bob.send; string(message) != "hello" {
+		t.Fatalf("bob got %q", message)
+	}
+	if len(other.send) != 0 {
+		t.Fatal("message is sent to other room")
+	}
+
+	if !hub.Leave("list", bob) || hub.Leave("list", bob) {
+		t.Fatal("leave must report membership")
+	}
+	hub.Broadcast("list", []byte("bye"), nil)
+	if len(bob.send) != 0 {
+		t.Fatal("message is sent after leave")
+	}
+}
+
+func TestMemoryHubDisconnectsSlowClient(t *testing.T) {
+	hub := NewMemoryHub()
+
+	slow := NewClient(nil, 1, 1)
+	fast := NewClient(nil, 2, 10)
+	hub.Join("list", slow)
+	hub.Join("list", fast)
+
+	for i := 0; i < 5; i++ {
+		hub.Broadcast("list", []byte("event"), nil)
+	}
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("slow client is not closed")
+	}
+	if slow.Send([]byte("event")) {
+		t.Fatal("closed client accepted message")
+	}
+	if len(fast.send) != 5 {
+		t.Fatalf("fast client got %d messages, want 5", len(fast.send))
+	}
+}
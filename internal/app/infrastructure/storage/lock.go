@@ -0,0 +1,13 @@
+package storage
+
+// Lock spaces are the first key of transaction level advisory locks taken
+// with two keys, every kind of lock has its own space, so locks of
+// different kinds never share a key.
+const (
+	// TodolistLockSpace serializes saves of todolist, the second key is id
+	// of user.
+	TodolistLockSpace = 1
+	// IdempotencyLockSpace serializes requests with the same idempotency
+	// key, the second key is hash of user id and key.
+	IdempotencyLockSpace = 2
+)
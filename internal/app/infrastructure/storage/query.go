@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Placeholders returns a comma separated list of n positional parameters
+// starting from $start, e.g. Placeholders(2, 3) returns "$2, $3, $4".
+func Placeholders(start int, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", start+i)
+	}
+
+	return strings.Join(params, ", ")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/kotsmile/everd-backend/internal/util"
 )
@@ -12,6 +13,8 @@ var ErrInvalidTransaction = errors.New("invalid transaction type")
 
 type SQLTransaction struct {
 	tx *sql.Tx
+	// savepoints is number of savepoints taken by functions joining tx.
+	savepoints int
 }
 
 type SQLTransactionFactory struct {
@@ -22,7 +25,15 @@ func NewSQLTransactionFactory(db *sql.DB) *SQLTransactionFactory {
 	return &SQLTransactionFactory{db: db}
 }
 
+// WithTransaction runs fn in new transaction or, if ctx carries one, in
+// transaction of ctx, which is committed by its owner. Failed fn is rolled
+// back to savepoint taken before it, so its owner can still commit changes
+// made by others.
 func (f *SQLTransactionFactory) WithTransaction(ctx context.Context, fn func(util.Transaction) error) error {
+	if tx := util.TransactionFromContext(ctx); tx != nil {
+		return withSavepoint(ctx, tx, fn)
+	}
+
 	tx, err := f.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -39,7 +50,37 @@ func (f *SQLTransactionFactory) WithTransaction(ctx context.Context, fn func(uti
 	return tx.Commit()
 }
 
-func GetDBExecutor(tx util.Transaction, db *sql.DB) (Executor, error) {
+func withSavepoint(ctx context.Context, tx util.Transaction, fn func(util.Transaction) error) error {
+	sqlTx, ok := tx.(*SQLTransaction)
+	if !ok {
+		return ErrInvalidTransaction
+	}
+
+	sqlTx.savepoints++
+	savepoint := fmt.Sprintf("sp_%d", sqlTx.savepoints)
+	if _, err := sqlTx.tx.ExecContext(ctx, "savepoint "+savepoint); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if _, rollbackErr := sqlTx.tx.ExecContext(ctx, "rollback to savepoint "+savepoint); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+
+		return err
+	}
+
+	_, err := sqlTx.tx.ExecContext(ctx, "release savepoint "+savepoint)
+	return err
+}
+
+// GetDBExecutor returns executor of tx or, if tx is nil, of transaction
+// carried by ctx, so reads see changes made earlier in that transaction.
+// Without either of them queries run on db.
+func GetDBExecutor(ctx context.Context, tx util.Transaction, db *sql.DB) (Executor, error) {
+	if tx == nil {
+		tx = util.TransactionFromContext(ctx)
+	}
 	if tx == nil {
 		return db, nil
 	}
@@ -59,6 +100,9 @@ func GetTxOrCreateTx(
 ) (Executor, func() error, func() error, error) {
 	nilFn := func() error { return nil }
 
+	if tx == nil {
+		tx = util.TransactionFromContext(ctx)
+	}
 	if tx != nil {
 		sqlTx, ok := tx.(*SQLTransaction)
 		if !ok {
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// recordingConnector opens connections recording statements they execute
+// instead of running them.
+type recordingConnector struct {
+	statements []string
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &recordingConn{connector: c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver {
+	return nil
+}
+
+type recordingConn struct {
+	connector *recordingConnector
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	c.connector.statements = append(c.connector.statements, "begin")
+	return c, nil
+}
+
+func (c *recordingConn) Commit() error {
+	c.connector.statements = append(c.connector.statements, "commit")
+	return nil
+}
+
+func (c *recordingConn) Rollback() error {
+	c.connector.statements = append(c.connector.statements, "rollback")
+	return nil
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.connector.statements = append(c.connector.statements, query)
+	return driver.RowsAffected(1), nil
+}
+
+func TestWithTransactionInContextTransaction(t *testing.T) {
+	connector := &recordingConnector{}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	factory := NewSQLTransactionFactory(db)
+	errDryRun := errors.New("dry run")
+
+	if err := factory.WithTransaction(context.Background(), func(tx util.Transaction) error {
+		ctx, _ := util.ContextWithTransaction(context.Background(), tx)
+
+		// dry run joining transaction of request is rolled back on its own
+		err := factory.WithTransaction(ctx, func(tx util.Transaction) error {
+			exec, err := GetDBExecutor(ctx, tx, db)
+			if err != nil {
+				return err
+			}
+			if _, err := exec.Exec("insert todo"); err != nil {
+				return err
+			}
+
+			return errDryRun
+		})
+		if !errors.Is(err, errDryRun) {
+			t.Fatalf("err = %v", err)
+		}
+
+		if err := factory.WithTransaction(ctx, func(tx util.Transaction) error {
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		exec, err := GetDBExecutor(ctx, tx, db)
+		if err != nil {
+			return err
+		}
+		_, err = exec.Exec("insert response")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"begin",
+		"savepoint sp_1",
+		"insert todo",
+		"rollback to savepoint sp_1",
+		"savepoint sp_2",
+		"release savepoint sp_2",
+		"insert response",
+		"commit",
+	}
+	if !reflect.DeepEqual(connector.statements, want) {
+		t.Fatalf("statements = %q, want %q", connector.statements, want)
+	}
+}
+
+func TestGetDBExecutorInContextTransaction(t *testing.T) {
+	db := sql.OpenDB(&recordingConnector{})
+	defer db.Close()
+
+	if exec, err := GetDBExecutor(context.Background(), nil, db); err != nil || exec != db {
+		t.Fatalf("executor without transaction = %T, %v", exec, err)
+	}
+
+	if err := NewSQLTransactionFactory(db).WithTransaction(context.Background(), func(tx util.Transaction) error {
+		ctx, _ := util.ContextWithTransaction(context.Background(), tx)
+
+		// reads without tx see changes of transaction carried by ctx
+		exec, err := GetDBExecutor(ctx, nil, db)
+		if err != nil {
+			return err
+		}
+		if exec != tx.(*SQLTransaction).tx {
+			t.Errorf("executor = %T, want transaction of ctx", exec)
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
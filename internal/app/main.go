@@ -1,37 +1,209 @@
 package app
 
 import (
+	"context"
+	"io"
+	"os"
+
 	"github.com/gorilla/mux"
+	access_domain "github.com/kotsmile/everd-backend/internal/app/domain/access"
 	access_handler "github.com/kotsmile/everd-backend/internal/app/domain/access/handler"
+	account_domain "github.com/kotsmile/everd-backend/internal/app/domain/account"
+	account_handler "github.com/kotsmile/everd-backend/internal/app/domain/account/handler"
+	account_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/account/infrastructure"
 	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
 	todolist_handler "github.com/kotsmile/everd-backend/internal/app/domain/todolist/handler"
 	todolist_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/todolist/infrastructure"
+	todolist_model "github.com/kotsmile/everd-backend/internal/app/domain/todolist/model"
+	webhook_domain "github.com/kotsmile/everd-backend/internal/app/domain/webhook"
+	webhook_handler "github.com/kotsmile/everd-backend/internal/app/domain/webhook/handler"
+	webhook_infrastructure "github.com/kotsmile/everd-backend/internal/app/domain/webhook/infrastructure"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/idempotency"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/outbox"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/pubsub"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/realtime"
 	"github.com/kotsmile/everd-backend/internal/app/infrastructure/storage"
 	"github.com/kotsmile/everd-backend/internal/util"
 )
 
 func run() {
+	ctx := context.Background()
 	logger := util.NewLogger(false)
 	apiHelper := util.NewApiHelper(logger)
 
+	cfg, err := loadConfig(os.LookupEnv)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to load config")
+	}
+
 	// repositories
 	todoRepo := todolist_infrastructure.NewPostrgesTodoRepository(nil)
 	todolistRepo := todolist_infrastructure.NewPostrgesTodolistRepository(nil)
+	tagRepo := todolist_infrastructure.NewPostrgesTagRepository(nil)
+	todoQueryRepo := todolist_infrastructure.NewPostrgesTodoQueryRepository(nil)
+	searchRepo := todolist_infrastructure.NewPostrgesSearchRepository(nil)
+	trashRepo := todolist_infrastructure.NewPostrgesTrashRepository(nil)
+	historyRepo := todolist_infrastructure.NewPostrgesHistoryRepository(nil)
+	syncRepo := todolist_infrastructure.NewPostrgesSyncRepository(nil)
+	undoRepo := todolist_infrastructure.NewPostrgesUndoRepository(nil)
+	archiveRepo := todolist_infrastructure.NewPostrgesArchiveRepository(nil)
+	exportRepo := todolist_infrastructure.NewPostrgesExportRepository(nil)
+	calendarRepo := todolist_infrastructure.NewPostrgesCalendarRepository(nil)
+	calendarObjectRepo := todolist_infrastructure.NewPostrgesCalendarObjectRepository(nil)
+	webhookRepo := webhook_infrastructure.NewPostrgesWebhookRepository(nil)
+	deliveryRepo := webhook_infrastructure.NewPostrgesDeliveryRepository(nil)
+	userRepo := account_infrastructure.NewPostrgesUserRepository(nil)
+	dataExportRepo := account_infrastructure.NewPostrgesExportRepository(nil)
+	accountArchiveRepo := account_infrastructure.NewPostrgesArchiveRepository(nil)
 
 	// infrastructure
 	txFactory := storage.NewSQLTransactionFactory(nil)
+	outboxStore := outbox.NewPostgresStore(nil)
+	localSink := outbox.NewLocalSink()
+	eventOutbox := todolist_infrastructure.NewOutboxEventWriter(outboxStore)
+	hub := pubsub.NewHub(pubsub.DefaultReplayBuffer, pubsub.DefaultSubscriberBuffer, pubsub.DefaultReplayTTL)
+	rooms := realtime.NewMemoryHub()
+	eventNotifier := todolist_domain.EventNotifiers{
+		todolist_infrastructure.NewHubNotifier(hub, logger),
+		todolist_infrastructure.NewRealtimeNotifier(rooms, logger),
+	}
 
 	// services
-	todolistService := todolist_domain.NewTodoService(
+	clock, err := todolist_domain.NewServerClock()
+	if err != nil {
+		logger.WithError(err).Fatal("failed to create clock")
+	}
+	todolistService := todolist_domain.NewTodoService(todolist_domain.TodoServiceDeps{
+		TxFactory:          txFactory,
+		Clock:              clock,
+		TodolistRepo:       todolistRepo,
+		TodoRepo:           todoRepo,
+		TagRepo:            tagRepo,
+		TodoQueryRepo:      todoQueryRepo,
+		SearchRepo:         searchRepo,
+		TrashRepo:          trashRepo,
+		HistoryRepo:        historyRepo,
+		SyncRepo:           syncRepo,
+		UndoRepo:           undoRepo,
+		ArchiveRepo:        archiveRepo,
+		ExportRepo:         exportRepo,
+		CalendarRepo:       calendarRepo,
+		CalendarObjectRepo: calendarObjectRepo,
+		Outbox:             eventOutbox,
+		Notifier:           eventNotifier,
+	})
+
+	eventNames := make([]string, len(todolist_model.EventNames))
+	for i, name := range todolist_model.EventNames {
+		eventNames[i] = string(name)
+	}
+	webhookService := webhook_domain.NewWebhookService(
+		txFactory,
+		webhookRepo,
+		deliveryRepo,
+		eventNames,
+	)
+	localSink.Subscribe(
+		todolist_infrastructure.EventTopic,
+		webhook_infrastructure.TodolistEventHandler(webhookService),
+	)
+
+	accountService := account_domain.NewAccountService(
 		txFactory,
-		todolistRepo,
-		todoRepo,
+		userRepo,
+		dataExportRepo,
+		account_domain.DefaultDeletionGracePeriod,
+	)
+
+	idempotencyStore := idempotency.NewPostgresStore(nil)
+
+	// background jobs
+	trashPurger := todolist_domain.NewTrashPurger(
+		trashRepo,
+		logger,
+		cfg.trashRetention,
+		todolist_domain.DefaultTrashPurgeInterval,
+	)
+	go trashPurger.Run(ctx)
+
+	autoArchiver := todolist_domain.NewAutoArchiver(
+		todolistService,
+		archiveRepo,
+		logger,
+		todolist_domain.DefaultAutoArchiveAfter,
+		todolist_domain.DefaultAutoArchiveInterval,
+	)
+	go autoArchiver.Run(ctx)
+
+	outboxDispatcher := outbox.NewDispatcher(
+		txFactory,
+		outboxStore,
+		logger,
+		outbox.DefaultDispatcherConfig,
+		localSink,
+	)
+	go outboxDispatcher.Run(ctx)
+
+	go hub.Run(ctx)
+
+	webhookWorker := webhook_domain.NewDeliveryWorker(
+		txFactory,
+		webhookRepo,
+		deliveryRepo,
+		webhook_infrastructure.NewHTTPSender(nil),
+		logger,
+		webhook_domain.DefaultDeliveryWorkerConfig,
+	)
+	go webhookWorker.Run(ctx)
+
+	archiveSections := []account_infrastructure.ArchiveSection{
+		account_infrastructure.ReadmeSection(),
+		{
+			Name: "todos.json",
+			Write: func(ctx context.Context, userID access_domain.UserID, w io.Writer) error {
+				return todolistService.Export(
+					ctx,
+					userID,
+					todolist_infrastructure.NewExportWriter(todolist_domain.ExportJSON, w),
+				)
+			},
+		},
+	}
+	exportWorker := account_domain.NewExportWorker(
+		txFactory,
+		dataExportRepo,
+		account_infrastructure.NewZipArchiveBuilder(append(archiveSections, accountArchiveRepo.Sections()...)...),
+		logger,
+		account_domain.DefaultExportWorkerConfig,
+	)
+	go exportWorker.Run(ctx)
+
+	accountPurger := account_domain.NewAccountPurger(
+		txFactory,
+		userRepo,
+		logger,
+		account_domain.DefaultDeletionGracePeriod,
+		account_domain.DefaultPurgeInterval,
+	)
+	go accountPurger.Run(ctx)
+
+	idempotencyPurger := idempotency.NewPurger(
+		idempotencyStore,
+		logger,
+		idempotency.DefaultTTL,
+		idempotency.DefaultPurgeInterval,
 	)
+	go idempotencyPurger.Run(ctx)
 
 	r := mux.NewRouter()
-	access := access_handler.NewAccessHandler(apiHelper)
+	if err := registerRoutes(r, apiHelper, handlers{
+		access:   access_handler.NewAccessHandler(apiHelper, userRepo),
+		todolist: todolist_handler.NewTodolistHandler(todolistService, hub, rooms, apiHelper),
+		webhook:  webhook_handler.NewWebhookHandler(webhookService, apiHelper),
+		account:  account_handler.NewAccountHandler(accountService, apiHelper),
 
-	todolist := todolist_handler.NewTodolistHandler(todolistService, apiHelper)
-	r.HandleFunc("/todolist", apiHelper.Wrapper(todolist.GetTodolist, access.AuthMiddlerware)).Methods("GET")
-	r.HandleFunc("/todolist/todo", apiHelper.Wrapper(todolist.PostTodo, access.AuthMiddlerware)).Methods("POST")
+		idempotency: idempotency.NewMiddleware(txFactory, idempotencyStore, idempotency.DefaultTTL),
+	}); err != nil {
+		logger.WithError(err).Fatal("failed to register routes")
+	}
 }
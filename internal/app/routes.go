@@ -0,0 +1,512 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/gorilla/mux"
+	access_handler "github.com/kotsmile/everd-backend/internal/app/domain/access/handler"
+	account_handler "github.com/kotsmile/everd-backend/internal/app/domain/account/handler"
+	todolist_domain "github.com/kotsmile/everd-backend/internal/app/domain/todolist"
+	todolist_handler "github.com/kotsmile/everd-backend/internal/app/domain/todolist/handler"
+	webhook_handler "github.com/kotsmile/everd-backend/internal/app/domain/webhook/handler"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/idempotency"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/openapi"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+const (
+	OpenAPIPath = "/openapi.json"
+	DocsPath    = "/docs"
+)
+
+// apiInfo is info of document of every version, version is set by
+// apiVersion.
+var apiInfo = openapi.Info{
+	Title: "everd",
+	Description: "Every successful json response is wrapped in envelope with " +
+		"`error` set to false and payload in `data`, failed requests have " +
+		"`error` set to true and `message`.\n\n" +
+		"Routes are served under prefix of version, e.g. `/v1/todolist`. " +
+		"Deprecated routes announce it in `Deprecation` header and, once " +
+		"removal is planned, in `Sunset` header.\n\n" +
+		"CalDAV endpoints under `/caldav/` are not described here, calendar " +
+		"apps discover them through `/.well-known/caldav`.",
+}
+
+type handlers struct {
+	access   *access_handler.AccessHandler
+	todolist *todolist_handler.TodolistHandler
+	webhook  *webhook_handler.WebhookHandler
+	account  *account_handler.AccountHandler
+
+	idempotency *idempotency.Middleware
+}
+
+// route is entry of route table. Routes are registered and described in
+// OpenAPI document from the same table, so they can not drift apart.
+type route struct {
+	openapi.Route
+	handler util.Handler
+}
+
+// handlerName returns name of handler method, it is used as operation id.
+func handlerName(handler util.Handler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func pathParameter(name string, typ string, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: openapi.InPath, Type: typ, Description: description}
+}
+
+var (
+	todoIDParameter = pathParameter("id", "integer", "Id of todo")
+	tagIDParameter  = pathParameter("id", "integer", "Id of tag")
+
+	webhookIDParameter = pathParameter("id", "integer", "Id of webhook")
+	exportIDParameter  = pathParameter("id", "integer", "Id of data export")
+
+	idempotencyKeyParameter = openapi.Parameter{
+		Name: idempotency.Header,
+		In:   openapi.InHeader,
+		Description: "Key chosen by client, retries with the same key get response of the first request " +
+			"instead of being applied again. Keys expire after 24 hours",
+	}
+
+	limitParameter = openapi.Parameter{
+		Name:        "limit",
+		Type:        "integer",
+		Description: "Maximum number of items, server default is used unless set",
+	}
+
+	// todoQueryParameters are parsed by todolist_handler.parseTodoQuery.
+	todoQueryParameters = []openapi.Parameter{
+		{Name: "done", Type: "boolean"},
+		{Name: "q", Description: "Text searched in title and comment"},
+		{Name: "tag", Repeated: true, Description: "Tag name, todo has to have all of the tags unless tag_mode=or"},
+		{Name: "tag_mode", Enum: []string{"and", "or"}},
+		{Name: "created_after", Format: "date-time"},
+		{Name: "created_before", Format: "date-time"},
+		{Name: "updated_after", Format: "date-time"},
+		{Name: "updated_before", Format: "date-time"},
+		{Name: "sort", Description: "Comma separated fields, prefixed with - for descending order, e.g. `-priority,due`, or `smart`"},
+		limitParameter,
+		{Name: "cursor", Description: "next_cursor of the previous page"},
+	}
+)
+
+func apiRoutes(h handlers) []route {
+	return []route{
+		{openapi.Route{
+			Method: "GET", Path: "/todolist", Tag: "todolist", Paginated: true,
+			Summary: "List todos",
+			Parameters: append([]openapi.Parameter{{
+				Name:        "format",
+				Enum:        []string{"json", "markdown"},
+				Description: "markdown returns the whole todolist as markdown task list, other parameters are ignored then",
+			}}, todoQueryParameters...),
+			Response:             todolist_handler.GetTodolistResponse{},
+			ResponseContentTypes: []string{"text/markdown"},
+		}, h.todolist.GetTodolist},
+		{openapi.Route{
+			Method: "GET", Path: "/todolist/events", Tag: "realtime",
+			Summary: "Stream todolist events as server-sent events",
+			Parameters: []openapi.Parameter{{
+				Name:        "Last-Event-ID",
+				In:          openapi.InHeader,
+				Type:        "integer",
+				Description: "Id of the last received event to resume stream from",
+			}},
+			ResponseContentTypes: []string{"text/event-stream"},
+		}, h.todolist.GetTodolistEvents},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/markdown", Tag: "import/export",
+			Summary:            "Upsert todos from markdown task list",
+			Request:            "",
+			RequestContentType: "text/markdown",
+			Response:           todolist_handler.PostTodolistMarkdownResponse{},
+		}, h.todolist.PostTodolistMarkdown},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist:clear-completed", Tag: "archive",
+			Summary:  "Archive all completed todos",
+			Response: todolist_handler.PostClearCompletedResponse{},
+		}, h.todolist.PostClearCompleted},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/todo", Tag: "todos",
+			Summary:  "Create todo",
+			Request:  todolist_handler.PostTodoRequest{},
+			Response: todolist_handler.TodoResponse{},
+		}, h.todolist.PostTodo},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/todo:batch", Tag: "todos",
+			Summary:  "Apply batch of operations",
+			Request:  todolist_handler.PostTodoBatchRequest{},
+			Response: todolist_handler.PostTodoBatchResponse{},
+		}, h.todolist.PostTodoBatch},
+		{openapi.Route{
+			Method: "DELETE", Path: "/todolist/todo/{id}", Tag: "trash",
+			Summary:    "Move todo to trash",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Response:   todolist_handler.DeleteTodoResponse{},
+		}, h.todolist.DeleteTodo},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/todo/{id}/complete", Tag: "todos",
+			Summary:    "Complete todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Response:   todolist_handler.PostTodoCompleteResponse{},
+		}, h.todolist.PostTodoComplete},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/todo/{id}/uncomplete", Tag: "todos",
+			Summary:    "Uncomplete todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Response:   todolist_handler.PostTodoUncompleteResponse{},
+		}, h.todolist.PostTodoUncomplete},
+		{openapi.Route{
+			Method: "PUT", Path: "/todolist/todo/{id}/title", Tag: "todos",
+			Summary:    "Change title of todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Request:    todolist_handler.PutTodoTitleRequest{},
+			Response:   todolist_handler.PutTodoTitleResponse{},
+		}, h.todolist.PutTodoTitle},
+		{openapi.Route{
+			Method: "PUT", Path: "/todolist/todo/{id}/comment", Tag: "todos",
+			Summary:    "Change comment of todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Request:    todolist_handler.PutTodoCommentRequest{},
+			Response:   todolist_handler.PutTodoCommentResponse{},
+		}, h.todolist.PutTodoComment},
+		{openapi.Route{
+			Method: "GET", Path: "/todolist/todo/{id}/history", Tag: "todos",
+			Summary:    "List changes of todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Response:   todolist_handler.GetTodoHistoryResponse{},
+		}, h.todolist.GetTodoHistory},
+		{openapi.Route{
+			Method: "PUT", Path: "/todolist/todo/{id}/priority", Tag: "todos",
+			Summary:    "Change priority of todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Request:    todolist_handler.PutTodoPriorityRequest{},
+			Response:   todolist_handler.PutTodoPriorityResponse{},
+		}, h.todolist.PutTodoPriority},
+		{openapi.Route{
+			Method: "PUT", Path: "/todolist/todo/{id}/due", Tag: "todos",
+			Summary:    "Change due time of todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Request:    todolist_handler.PutTodoDueRequest{},
+			Response:   todolist_handler.PutTodoDueResponse{},
+		}, h.todolist.PutTodoDue},
+		{openapi.Route{
+			Method: "PUT", Path: "/todolist/todo/{id}/position", Tag: "todos",
+			Summary:    "Move todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Request:    todolist_handler.PutTodoPositionRequest{},
+			Response:   todolist_handler.PutTodoPositionResponse{},
+		}, h.todolist.PutTodoPosition},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/todo/{id}/tags", Tag: "tags",
+			Summary:    "Tag todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Request:    todolist_handler.PostTodoTagRequest{},
+			Response:   todolist_handler.PostTodoTagResponse{},
+		}, h.todolist.PostTodoTag},
+		{openapi.Route{
+			Method: "DELETE", Path: "/todolist/todo/{id}/tags/{tagID}", Tag: "tags",
+			Summary: "Untag todo",
+			Parameters: []openapi.Parameter{
+				todoIDParameter,
+				pathParameter("tagID", "integer", "Id of tag"),
+			},
+			Response: todolist_handler.DeleteTodoTagResponse{},
+		}, h.todolist.DeleteTodoTag},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/todo/{id}/archive", Tag: "archive",
+			Summary:    "Archive completed todo",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Response:   todolist_handler.PostTodoArchiveResponse{},
+		}, h.todolist.PostTodoArchive},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/undo", Tag: "todolist",
+			Summary:  "Undo the last operation",
+			Response: todolist_handler.PostUndoResponse{},
+		}, h.todolist.PostUndo},
+		{openapi.Route{
+			Method: "POST", Path: "/todolist/redo", Tag: "todolist",
+			Summary:  "Redo the last undone operation",
+			Response: todolist_handler.PostRedoResponse{},
+		}, h.todolist.PostRedo},
+
+		{openapi.Route{
+			Method: "GET", Path: "/ws", Tag: "realtime",
+			Summary: "Upgrade connection to websocket of collaborative lists",
+			Status:  http.StatusSwitchingProtocols,
+		}, h.todolist.GetWS},
+
+		{openapi.Route{
+			Method: "GET", Path: "/trash", Tag: "trash", Paginated: true,
+			Summary:    "List trashed todos",
+			Parameters: todoQueryParameters,
+			Response:   todolist_handler.GetTrashResponse{},
+		}, h.todolist.GetTrash},
+		{openapi.Route{
+			Method: "POST", Path: "/trash/{id}/restore", Tag: "trash",
+			Summary:    "Restore todo from trash",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Response:   todolist_handler.PostTrashRestoreResponse{},
+		}, h.todolist.PostTrashRestore},
+
+		{openapi.Route{
+			Method: "GET", Path: "/archive", Tag: "archive", Paginated: true,
+			Summary:    "List archived todos",
+			Parameters: todoQueryParameters,
+			Response:   todolist_handler.GetArchiveResponse{},
+		}, h.todolist.GetArchive},
+		{openapi.Route{
+			Method: "POST", Path: "/archive/{id}/unarchive", Tag: "archive",
+			Summary:    "Move archived todo back to todolist",
+			Parameters: []openapi.Parameter{todoIDParameter},
+			Response:   todolist_handler.PostArchiveUnarchiveResponse{},
+		}, h.todolist.PostArchiveUnarchive},
+
+		{openapi.Route{
+			Method: "GET", Path: "/export", Tag: "import/export",
+			Summary: "Download every todo and tag",
+			Parameters: []openapi.Parameter{{
+				Name: "format",
+				Enum: []string{
+					string(todolist_domain.ExportJSON),
+					string(todolist_domain.ExportNDJSON),
+					string(todolist_domain.ExportCSV),
+				},
+			}},
+			ResponseContentTypes: []string{"application/json", "application/x-ndjson", "text/csv"},
+		}, h.todolist.GetExport},
+		{openapi.Route{
+			Method: "POST", Path: "/import", Tag: "import/export",
+			Summary: "Import todos from file",
+			Parameters: []openapi.Parameter{{
+				Name:        "dry_run",
+				Type:        "boolean",
+				Description: "Report what would be imported without creating anything",
+			}},
+			Request: struct {
+				File   []byte `json:"file" format:"binary"`
+				Format string `json:"format" enum:"todotxt,todoist,everd,ics"`
+			}{},
+			RequestContentType: "multipart/form-data",
+			Response:           todolist_handler.PostImportResponse{},
+		}, h.todolist.PostImport},
+
+		{openapi.Route{
+			Method: "POST", Path: "/calendar/token", Tag: "calendar",
+			Summary:  "Create secret url of calendar feed, previous one stops working",
+			Response: todolist_handler.PostCalendarTokenResponse{},
+		}, h.todolist.PostCalendarToken},
+		{openapi.Route{
+			Method: "DELETE", Path: "/calendar/token", Tag: "calendar",
+			Summary:  "Revoke url of calendar feed",
+			Response: todolist_handler.DeleteCalendarTokenResponse{},
+		}, h.todolist.DeleteCalendarToken},
+		// feed is authorized by secret token in path, calendar apps can not
+		// send auth headers
+		{openapi.Route{
+			Method: "GET", Path: "/calendar/{token}.ics", Tag: "calendar", Public: true,
+			Summary:              "Calendar feed of todos",
+			Parameters:           []openapi.Parameter{pathParameter("token", "string", "Secret token of feed")},
+			ResponseContentTypes: []string{"text/calendar"},
+		}, h.todolist.GetCalendarFeed},
+
+		{openapi.Route{
+			Method: "GET", Path: "/sync", Tag: "sync",
+			Summary: "List todos changed after cursor",
+			Parameters: []openapi.Parameter{
+				{Name: "since", Description: "cursor of the previous response, empty for the first sync"},
+				limitParameter,
+			},
+			Response: todolist_handler.GetSyncResponse{},
+		}, h.todolist.GetSync},
+		{openapi.Route{
+			Method: "POST", Path: "/sync", Tag: "sync",
+			Summary:  "Apply operations recorded offline",
+			Request:  todolist_handler.PostSyncRequest{},
+			Response: todolist_handler.PostSyncResponse{},
+		}, h.todolist.PostSync},
+
+		{openapi.Route{
+			Method: "GET", Path: "/search", Tag: "todolist",
+			Summary: "Search todos by relevance",
+			Parameters: []openapi.Parameter{
+				{Name: "q", Required: true},
+				limitParameter,
+			},
+			Response: todolist_handler.GetSearchResponse{},
+		}, h.todolist.GetSearch},
+
+		{openapi.Route{
+			Method: "GET", Path: "/tags", Tag: "tags",
+			Summary:  "List tags",
+			Response: todolist_handler.GetTagsResponse{},
+		}, h.todolist.GetTags},
+		{openapi.Route{
+			Method: "POST", Path: "/tags", Tag: "tags",
+			Summary:  "Create tag",
+			Request:  todolist_handler.PostTagRequest{},
+			Response: todolist_handler.PostTagResponse{},
+		}, h.todolist.PostTag},
+		{openapi.Route{
+			Method: "PUT", Path: "/tags/{id}", Tag: "tags",
+			Summary:    "Rename tag",
+			Parameters: []openapi.Parameter{tagIDParameter},
+			Request:    todolist_handler.PutTagRequest{},
+			Response:   todolist_handler.PutTagResponse{},
+		}, h.todolist.PutTag},
+		{openapi.Route{
+			Method: "POST", Path: "/tags/{id}/merge", Tag: "tags",
+			Summary:    "Merge tag into another one",
+			Parameters: []openapi.Parameter{tagIDParameter},
+			Request:    todolist_handler.PostTagMergeRequest{},
+			Response:   todolist_handler.PostTagMergeResponse{},
+		}, h.todolist.PostTagMerge},
+
+		{openapi.Route{
+			Method: "GET", Path: "/webhooks", Tag: "webhooks",
+			Summary:  "List webhooks",
+			Response: webhook_handler.GetWebhooksResponse{},
+		}, h.webhook.GetWebhooks},
+		{openapi.Route{
+			Method: "POST", Path: "/webhooks", Tag: "webhooks",
+			Summary:  "Register webhook, response is the only one containing secret",
+			Request:  webhook_handler.PostWebhookRequest{},
+			Response: webhook_handler.PostWebhookResponse{},
+		}, h.webhook.PostWebhook},
+		{openapi.Route{
+			Method: "DELETE", Path: "/webhooks/{id}", Tag: "webhooks",
+			Summary:    "Delete webhook",
+			Parameters: []openapi.Parameter{webhookIDParameter},
+			Response:   webhook_handler.DeleteWebhookResponse{},
+		}, h.webhook.DeleteWebhook},
+		{openapi.Route{
+			Method: "POST", Path: "/webhooks/{id}/enable", Tag: "webhooks",
+			Summary:    "Enable webhook disabled after failures",
+			Parameters: []openapi.Parameter{webhookIDParameter},
+			Response:   webhook_handler.PostWebhookEnableResponse{},
+		}, h.webhook.PostWebhookEnable},
+		{openapi.Route{
+			Method: "GET", Path: "/webhooks/{id}/deliveries", Tag: "webhooks",
+			Summary:    "List deliveries of webhook, newest first",
+			Parameters: []openapi.Parameter{webhookIDParameter, limitParameter},
+			Response:   webhook_handler.GetDeliveriesResponse{},
+		}, h.webhook.GetDeliveries},
+		{openapi.Route{
+			Method: "POST", Path: "/webhooks/{id}/deliveries/{deliveryID}/redeliver", Tag: "webhooks",
+			Summary: "Deliver event again",
+			Parameters: []openapi.Parameter{
+				webhookIDParameter,
+				pathParameter("deliveryID", "integer", "Id of delivery"),
+			},
+			Response: webhook_handler.PostRedeliverResponse{},
+		}, h.webhook.PostRedeliver},
+
+		{openapi.Route{
+			Method: "DELETE", Path: "/me", Tag: "account",
+			Summary:  "Delete account, data is deleted after grace period",
+			Response: account_handler.DeleteMeResponse{},
+		}, h.account.DeleteMe},
+		{openapi.Route{
+			Method: "POST", Path: "/me/export", Tag: "account",
+			Summary:  "Request archive of all data of user",
+			Status:   http.StatusAccepted,
+			Response: account_handler.ExportResponse{},
+		}, h.account.PostMeExport},
+		{openapi.Route{
+			Method: "GET", Path: "/me/export/{id}", Tag: "account",
+			Summary:    "Get status of data export",
+			Parameters: []openapi.Parameter{exportIDParameter},
+			Response:   account_handler.ExportResponse{},
+		}, h.account.GetMeExport},
+		{openapi.Route{
+			Method: "GET", Path: "/me/export/{id}/archive", Tag: "account",
+			Summary:              "Download archive of ready data export",
+			Parameters:           []openapi.Parameter{exportIDParameter},
+			ResponseContentTypes: []string{"application/zip"},
+		}, h.account.GetMeExportArchive},
+	}
+}
+
+// davRoutes serve CalDAV, it is WebDAV based protocol with methods which
+// can not be described in OpenAPI document.
+func davRoutes(h handlers) []route {
+	return []route{
+		{openapi.Route{Method: "PROPFIND", Path: "/caldav{slash:/?}"}, h.todolist.PropfindCalDAVHome},
+		{openapi.Route{Method: "PROPFIND", Path: "/caldav/todolist{slash:/?}"}, h.todolist.PropfindCalDAVCollection},
+		{openapi.Route{Method: "REPORT", Path: "/caldav/todolist{slash:/?}"}, h.todolist.ReportCalDAVCollection},
+		{openapi.Route{Method: "GET", Path: "/caldav/todolist/{name}"}, h.todolist.GetCalDAVObject},
+		{openapi.Route{Method: "HEAD", Path: "/caldav/todolist/{name}"}, h.todolist.GetCalDAVObject},
+		{openapi.Route{Method: "PUT", Path: "/caldav/todolist/{name}"}, h.todolist.PutCalDAVObject},
+		{openapi.Route{Method: "DELETE", Path: "/caldav/todolist/{name}"}, h.todolist.DeleteCalDAVObject},
+		{openapi.Route{Method: "PROPFIND", Path: "/caldav/todolist/{name}"}, h.todolist.PropfindCalDAVObject},
+	}
+}
+
+func openAPIDocument(info openapi.Info, routes []route) (openapi.Document, error) {
+	openAPIRoutes := make([]openapi.Route, len(routes))
+	for i, route := range routes {
+		openAPIRoutes[i] = route.Route
+		if openAPIRoutes[i].OperationID == "" {
+			openAPIRoutes[i].OperationID = handlerName(route.handler)
+		}
+	}
+
+	return openapi.Generate(info, openAPIRoutes)
+}
+
+// registerRoutes registers routes of every api version in r together with
+// OpenAPI documents describing them.
+func registerRoutes(r *mux.Router, apiHelper *util.ApiHelper, h handlers) error {
+	for _, version := range apiVersions() {
+		if err := version.register(r, apiHelper, h); err != nil {
+			return err
+		}
+	}
+
+	latest := latestVersion()
+	r.Handle(OpenAPIPath, http.RedirectHandler(latest.prefix+OpenAPIPath, http.StatusFound)).Methods("GET")
+	r.Handle(DocsPath, http.RedirectHandler(latest.prefix+DocsPath, http.StatusFound)).Methods("GET")
+
+	r.Handle("/.well-known/caldav", http.RedirectHandler(todolist_handler.CalDAVHome, http.StatusMovedPermanently))
+	r.PathPrefix("/caldav").Methods("OPTIONS").HandlerFunc(apiHelper.Wrapper(h.todolist.OptionsCalDAV))
+
+	// CalDAV paths are fixed by calendar apps, so they are not versioned
+	if err := registerAPIRoutes(r, apiHelper, h, davRoutes(h)); err != nil {
+		return err
+	}
+
+	return unversionedAPI.register(r, apiHelper, h)
+}
+
+// registerAPIRoutes registers routes in r, validate tags of their requests
+// are checked first.
+func registerAPIRoutes(r *mux.Router, apiHelper *util.ApiHelper, h handlers, routes []route) error {
+	for _, route := range routes {
+		if err := util.CheckValidationTags(route.Request); err != nil {
+			return fmt.Errorf("%s %s: %w", route.Method, route.Path, err)
+		}
+
+		var middlewares []util.Middleware
+		if !route.Deprecation.IsZero() {
+			middlewares = append(middlewares, deprecationMiddleware(route.Deprecation, route.Sunset))
+		}
+		if !route.Public {
+			middlewares = append(middlewares, h.access.AuthMiddlerware)
+		}
+
+		r.HandleFunc(route.Path, apiHelper.Wrapper(route.handler, middlewares...)).Methods(route.Method)
+	}
+
+	return nil
+}
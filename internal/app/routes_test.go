@@ -0,0 +1,197 @@
+package app
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/openapi"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// openAPIFile is document of version committed for client generators.
+func openAPIFile(version apiVersion) string {
+	return filepath.Join("..", "..", "api", strings.TrimPrefix(version.prefix, "/"), "openapi.json")
+}
+
+// testHandlers are handlers without services, routes only need their
+// methods.
+func testHandlers() handlers {
+	return handlers{}
+}
+
+func testDocument(t *testing.T, version apiVersion) openapi.Document {
+	t.Helper()
+
+	doc, err := version.document(version.routes(testHandlers()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc
+}
+
+// TestOpenAPIRoutes fails when route is registered without being described
+// in document or document describes route which is not registered.
+func TestOpenAPIRoutes(t *testing.T) {
+	undescribed := map[string]bool{
+		"GET " + OpenAPIPath:      true,
+		"GET " + DocsPath:         true,
+		"OPTIONS /caldav":         true,
+		"ANY /.well-known/caldav": true,
+	}
+
+	// unversioned routes are described by v1 document
+	described := make(map[string]bool)
+	for _, version := range append(apiVersions(), unversionedAPI) {
+		for path, pathItem := range testDocument(t, version).Paths {
+			for method := range pathItem {
+				described[strings.ToUpper(method)+" "+version.prefix+path] = true
+			}
+		}
+
+		if version.prefix != "" {
+			undescribed["GET "+version.prefix+OpenAPIPath] = true
+			undescribed["GET "+version.prefix+DocsPath] = true
+		}
+	}
+	for _, route := range davRoutes(testHandlers()) {
+		undescribed[route.Method+" "+openapi.PathTemplate(route.Path)] = true
+	}
+
+	r := mux.NewRouter()
+	if err := registerRoutes(r, util.NewApiHelper(util.NewLoggerTest()), testHandlers()); err != nil {
+		t.Fatal(err)
+	}
+
+	registered := make(map[string]bool)
+	if err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		// prefixes of versions only hold subrouters
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		path = openapi.PathTemplate(path)
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"ANY"}
+		}
+
+		for _, method := range methods {
+			key := method + " " + path
+			registered[key] = true
+
+			if !described[key] && !undescribed[key] {
+				t.Errorf("%s is registered, but not described in OpenAPI document", key)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for key := range described {
+		if !registered[key] {
+			t.Errorf("%s is described in OpenAPI document, but not registered", key)
+		}
+	}
+}
+
+func TestOpenAPIOperationIDs(t *testing.T) {
+	doc := testDocument(t, latestVersion())
+
+	operationIDs := make(map[string]string)
+	for path, pathItem := range doc.Paths {
+		for method, operation := range pathItem {
+			key := strings.ToUpper(method) + " " + path
+			if operation.OperationID == "" {
+				t.Errorf("%s has no operation id", key)
+			}
+			if other, ok := operationIDs[operation.OperationID]; ok {
+				t.Errorf("%s and %s have the same operation id %s", key, other, operation.OperationID)
+			}
+			operationIDs[operation.OperationID] = key
+		}
+	}
+
+	if key := operationIDs["GetTodolist"]; key != "GET /todolist" {
+		t.Fatalf("GetTodolist is operation of %q", key)
+	}
+}
+
+// TestOpenAPIFile fails when committed documents are outdated, they are
+// updated with go test ./internal/app -run TestOpenAPIFile -update.
+func TestOpenAPIFile(t *testing.T) {
+	for _, version := range apiVersions() {
+		data, err := openapi.Marshal(testDocument(t, version))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkGolden(t, openAPIFile(version), data)
+	}
+}
+
+// checkGolden compares data with committed file, file is written instead
+// with -update flag.
+func checkGolden(t *testing.T, file string, data []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	committed, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(committed, data) {
+		t.Errorf("%s is outdated, run go test ./internal/app -run %s -update", file, t.Name())
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := testDocument(t, latestVersion())
+
+	todo, ok := doc.Components.Schemas["TodoResponse"]
+	if !ok {
+		t.Fatal("TodoResponse schema is missing")
+	}
+
+	var properties []string
+	for name := range todo.Properties {
+		properties = append(properties, name)
+	}
+	sort.Strings(properties)
+	if strings.Join(properties, ",") != "archived_at,comment,completed_at,created_at,deleted_at,done,due,id,position,priority,tags,title,updated_at" {
+		t.Fatalf("TodoResponse properties = %v", properties)
+	}
+
+	// fields with omitempty are optional
+	if strings.Join(todo.Required, ",") != "comment,created_at,done,id,position,priority,tags,title,updated_at" {
+		t.Fatalf("TodoResponse required = %v", todo.Required)
+	}
+
+	// ExportResponse of account handler is the only one with this name
+	if _, ok := doc.Components.Schemas["ExportResponse"]; !ok {
+		t.Fatal("ExportResponse schema is missing")
+	}
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	todolist_handler "github.com/kotsmile/everd-backend/internal/app/domain/todolist/handler"
+	"github.com/kotsmile/everd-backend/internal/app/infrastructure/openapi"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+// handlerAdapter wraps handler of the latest version, so older version keeps
+// its request and response shapes when handler changes them.
+type handlerAdapter func(util.Handler) util.Handler
+
+// routeAdapter keeps route of older version as it was before the latest
+// version changed it.
+type routeAdapter struct {
+	handler handlerAdapter
+	// response is response type described in document of version, nil
+	// keeps response type of the latest version.
+	response any
+}
+
+// apiVersion is group of routes served under prefix. Every version serves
+// route table of apiRoutes, handlers are written for the latest version and
+// older versions adapt them by operation id.
+type apiVersion struct {
+	// prefix is /v<n>, unversioned routes have empty prefix.
+	prefix  string
+	version string
+	// deprecation and sunset are announced by every route of version, see
+	// openapi.Route.
+	deprecation time.Time
+	sunset      time.Time
+	adapters    map[string]routeAdapter
+}
+
+var (
+	apiV1 = apiVersion{
+		prefix:  "/v1",
+		version: "1.0.0",
+		adapters: map[string]routeAdapter{
+			// v2 responds with created todo
+			"PostTodo": {
+				handler:  replaceResponse(todolist_handler.PostTodoResponse{}),
+				response: todolist_handler.PostTodoResponse{},
+			},
+		},
+	}
+
+	apiV2 = apiVersion{
+		prefix:  "/v2",
+		version: "2.0.0",
+	}
+
+	// unversionedAPI serves v1 at root for clients released before
+	// versioning, sunset is announced once they are migrated.
+	unversionedAPI = apiVersion{
+		version:     apiV1.version,
+		deprecation: time.Date(2024, 12, 9, 0, 0, 0, 0, time.UTC),
+		adapters:    apiV1.adapters,
+	}
+)
+
+// apiVersions are versions served under their prefixes, the last one is
+// the latest. Version is added only with adapters keeping previous one
+// unchanged, see TestV1Compatibility.
+func apiVersions() []apiVersion {
+	return []apiVersion{apiV1, apiV2}
+}
+
+func latestVersion() apiVersion {
+	versions := apiVersions()
+
+	return versions[len(versions)-1]
+}
+
+// routes returns route table of version with handlers adapted to it, POST
+// handlers honour Idempotency-Key header.
+func (v apiVersion) routes(h handlers) []route {
+	routes := apiRoutes(h)
+	for i := range routes {
+		// operation id is taken before handler is wrapped by adapter
+		if routes[i].OperationID == "" {
+			routes[i].OperationID = handlerName(routes[i].handler)
+		}
+		if adapter, ok := v.adapters[routes[i].OperationID]; ok {
+			routes[i].handler = adapter.handler(routes[i].handler)
+			if adapter.response != nil {
+				routes[i].Response = adapter.response
+			}
+		}
+		// stored responses are the ones of version client sees
+		if routes[i].Method == http.MethodPost {
+			parameters := routes[i].Parameters
+			routes[i].Parameters = append(parameters[:len(parameters):len(parameters)], idempotencyKeyParameter)
+			routes[i].handler = h.idempotency.Wrap(routes[i].handler)
+		}
+		if routes[i].Deprecation.IsZero() {
+			routes[i].Deprecation = v.deprecation
+			routes[i].Sunset = v.sunset
+		}
+	}
+
+	return routes
+}
+
+func (v apiVersion) info() openapi.Info {
+	info := apiInfo
+	info.Version = v.version
+
+	return info
+}
+
+// document describes routes of version, paths are relative to prefix of
+// version.
+func (v apiVersion) document(routes []route) (openapi.Document, error) {
+	doc, err := openAPIDocument(v.info(), routes)
+	if err != nil {
+		return openapi.Document{}, fmt.Errorf("%s: %w", v.version, err)
+	}
+
+	if v.prefix != "" {
+		doc.Servers = []openapi.Server{{URL: v.prefix}}
+	}
+
+	return doc, nil
+}
+
+// register registers routes of version in subrouter of r together with
+// document describing them.
+func (v apiVersion) register(r *mux.Router, apiHelper *util.ApiHelper, h handlers) error {
+	routes := v.routes(h)
+
+	doc, err := v.document(routes)
+	if err != nil {
+		return err
+	}
+
+	if v.prefix != "" {
+		r = r.PathPrefix(v.prefix).Subrouter()
+
+		specHandler, err := openapi.SpecHandler(doc)
+		if err != nil {
+			return err
+		}
+		r.Handle(OpenAPIPath, specHandler).Methods("GET")
+		r.Handle(DocsPath, openapi.DocsHandler(apiInfo.Title+" "+v.prefix, v.prefix+OpenAPIPath)).Methods("GET")
+	}
+
+	return registerAPIRoutes(r, apiHelper, h, routes)
+}
+
+// responseHelper writes responses of adapters, it does not log.
+var responseHelper = util.NewApiHelper(nil)
+
+// replaceResponse adapts handler whose successful response changed in the
+// latest version, data is sent instead of it.
+func replaceResponse(data any) handlerAdapter {
+	return func(handler util.Handler) util.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if err := handler(ctx, discardBody{w}, r); err != nil {
+				return err
+			}
+
+			return responseHelper.OkJSON(w, data)
+		}
+	}
+}
+
+// discardBody is writer dropping status and body written by handler,
+// headers are kept.
+type discardBody struct {
+	http.ResponseWriter
+}
+
+func (w discardBody) WriteHeader(int) {}
+
+func (w discardBody) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+// deprecationMiddleware announces deprecation of route in Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers.
+func deprecationMiddleware(deprecation time.Time, sunset time.Time) util.Middleware {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, error) {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecation.Unix()))
+		if !sunset.IsZero() {
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+
+		return ctx, nil
+	}
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kotsmile/everd-backend/internal/util"
+)
+
+func testRouter(t *testing.T) *mux.Router {
+	t.Helper()
+
+	r := mux.NewRouter()
+	if err := registerRoutes(r, util.NewApiHelper(util.NewLoggerTest()), testHandlers()); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestVersionRoutes(t *testing.T) {
+	r := testRouter(t)
+
+	for path, deprecated := range map[string]bool{
+		"/todolist":    true,
+		"/v1/todolist": false,
+		"/v2/todolist": false,
+	} {
+		// requests without user are rejected before handler is called
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d", path, w.Code)
+		}
+		if got := w.Header().Get("Deprecation") != ""; got != deprecated {
+			t.Errorf("%s: Deprecation = %q", path, w.Header().Get("Deprecation"))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	if location := w.Header().Get("Location"); location != latestVersion().prefix+OpenAPIPath {
+		t.Errorf("%s redirects to %q", OpenAPIPath, location)
+	}
+}
+
+func TestDeprecationMiddleware(t *testing.T) {
+	deprecation := time.Date(2024, 12, 9, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	w := httptest.NewRecorder()
+	if _, err := deprecationMiddleware(deprecation, sunset)(context.Background(), w, httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := w.Header().Get("Deprecation"); got != "@1733702400" {
+		t.Errorf("Deprecation = %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Sun, 01 Jun 2025 00:00:00 GMT" {
+		t.Errorf("Sunset = %q", got)
+	}
+}
+
+func TestVersionAdapters(t *testing.T) {
+	adapted := false
+	version := apiVersion{
+		prefix: "/v0",
+		adapters: map[string]routeAdapter{
+			"GetTodolist": {handler: func(handler util.Handler) util.Handler {
+				adapted = true
+				return handler
+			}},
+		},
+		deprecation: time.Date(2024, 12, 9, 0, 0, 0, 0, time.UTC),
+	}
+
+	for _, route := range version.routes(testHandlers()) {
+		if route.Deprecation != version.deprecation {
+			t.Errorf("%s is not deprecated", route.OperationID)
+		}
+	}
+	if !adapted {
+		t.Fatal("handler is not adapted")
+	}
+
+	doc, err := version.document(version.routes(testHandlers()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// adapted handler keeps its operation id
+	if get := doc.Paths["/todolist"]["get"]; get.OperationID != "GetTodolist" || !get.Deprecated {
+		t.Errorf("operation = %+v", get)
+	}
+	if doc.Servers[0].URL != "/v0" {
+		t.Errorf("servers = %+v", doc.Servers)
+	}
+}
+
+var sampleTime = time.Date(2024, 12, 1, 12, 30, 0, 0, time.UTC)
+
+// sample returns value of t with every field set, so json encoding of
+// value shows every field.
+func sample(t reflect.Type, depth int) reflect.Value {
+	value := reflect.New(t).Elem()
+	if depth > 8 {
+		return value
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		value.Set(reflect.ValueOf(sampleTime))
+	case t == reflect.TypeOf(json.RawMessage{}):
+		value.SetBytes([]byte(`{"key":"value"}`))
+	}
+	if !value.IsZero() {
+		return value
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(1.5)
+	case reflect.String:
+		value.SetString("string")
+	case reflect.Pointer:
+		value.Set(sample(t.Elem(), depth+1).Addr())
+	case reflect.Slice:
+		value.Set(reflect.Append(value, sample(t.Elem(), depth+1)))
+	case reflect.Map:
+		value.Set(reflect.MakeMap(t))
+		value.SetMapIndex(sample(t.Key(), depth+1), sample(t.Elem(), depth+1))
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				value.Field(i).Set(sample(t.Field(i).Type, depth+1))
+			}
+		}
+	}
+
+	return value
+}
+
+// TestV1Compatibility fails when encoding of v1 response changes. Response
+// of every route is built from sample of its response type in the latest
+// version by handler adapted to v1, so change of type which is not undone
+// by v1 adapter is caught. Files are updated with -update flag only when
+// change is intended to reach v1 clients.
+func TestV1Compatibility(t *testing.T) {
+	apiHelper := util.NewApiHelper(util.NewLoggerTest())
+
+	for _, route := range latestVersion().routes(testHandlers()) {
+		if route.Response == nil {
+			continue
+		}
+
+		t.Run(route.OperationID, func(t *testing.T) {
+			data := sample(reflect.TypeOf(route.Response), 0).Interface()
+
+			var handler util.Handler = func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				if route.Paginated {
+					return apiHelper.OkPageJSON(w, data, "cursor")
+				}
+				return apiHelper.OkJSON(w, data)
+			}
+			if adapter, ok := apiV1.adapters[route.OperationID]; ok {
+				handler = adapter.handler(handler)
+			}
+
+			w := httptest.NewRecorder()
+			apiHelper.Wrapper(handler)(w, httptest.NewRequest(route.Method, "/", nil))
+
+			checkGolden(t, filepath.Join("testdata", "v1", route.OperationID+".json"), w.Body.Bytes())
+		})
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		apiHelper.SendError(w, httptest.NewRequest(http.MethodGet, "/", nil), util.
+			NewHTTPError("invalid request").
+			WithStatus(http.StatusBadRequest).
+			WithErrorMessage("title is required").
+			WithData(util.ValidationErrorsResponse{Errors: util.ValidationErrors{{Field: "title", Message: "is required"}}}))
+
+		checkGolden(t, filepath.Join("testdata", "v1", "Error.json"), w.Body.Bytes())
+	})
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"reflect"
+	"strings"
 )
 
 type Handler func(
@@ -26,6 +30,7 @@ func (e *HTTPError) Error() string {
 type HTTPError struct {
 	message    string
 	statusCode int
+	data       any
 
 	err error
 }
@@ -49,10 +54,18 @@ func (e *HTTPError) WithErrorMessage(errMessage string) *HTTPError {
 	return e
 }
 
+// WithData sets details of error which are sent to client in data field,
+// e.g. validation errors of request.
+func (e *HTTPError) WithData(data any) *HTTPError {
+	e.data = data
+	return e
+}
+
 type JsonResponse struct {
-	Error   bool   `json:"error"`
-	Message string `json:"message,omitempty"`
-	Data    any    `json:"data,omitempty"`
+	Error      bool   `json:"error"`
+	Message    string `json:"message,omitempty"`
+	Data       any    `json:"data,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type ApiHelper struct {
@@ -71,6 +84,114 @@ func (h *ApiHelper) ReadJSON(
 	return json.NewDecoder(r.Body).Decode(data)
 }
 
+// DefaultMaxJSONSize is max size of request body read by ReadValidJSON.
+const DefaultMaxJSONSize = 1 << 20
+
+// ValidationErrorsResponse is data of error response of invalid request.
+type ValidationErrorsResponse struct {
+	Errors ValidationErrors `json:"errors"`
+}
+
+// ReadValidJSON is strict ReadJSON: body must be single json value not
+// larger than DefaultMaxJSONSize without unknown fields, and decoded data
+// must pass Validate. Every problem found is reported in one 400 response.
+func (h *ApiHelper) ReadValidJSON(
+	w http.ResponseWriter,
+	r *http.Request,
+	data any,
+) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, DefaultMaxJSONSize))
+	decoder.DisallowUnknownFields()
+
+	var errs ValidationErrors
+	err := decoder.Decode(data)
+	if err == nil {
+		if err = decoder.Decode(&json.RawMessage{}); errors.Is(err, io.EOF) {
+			err = nil
+		} else if err == nil {
+			err = errors.New("body must contain single json value")
+		}
+	}
+
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return NewHTTPError("request body is too large").
+			WithStatus(http.StatusRequestEntityTooLarge).
+			WithError(err)
+	}
+
+	// decoder reports only the first unknown field or type mismatch, but it
+	// still decodes the rest of body, so other fields are validated as well
+	if err != nil {
+		field, message := decodeError(err)
+		errs.Add(field, message)
+		if field == "" {
+			return invalidRequest(errs)
+		}
+	}
+
+	for _, fieldError := range Validate(data) {
+		if len(errs) > 0 && fieldError.Field == errs[0].Field {
+			continue
+		}
+		errs = append(errs, fieldError)
+	}
+	if len(errs) > 0 {
+		return invalidRequest(errs)
+	}
+
+	return nil
+}
+
+func invalidRequest(errs ValidationErrors) *HTTPError {
+	return NewHTTPError("invalid request").
+		WithStatus(http.StatusBadRequest).
+		WithError(errs).
+		WithData(ValidationErrorsResponse{Errors: errs})
+}
+
+// decodeError describes error of json decoder, field is empty when body is
+// not json at all.
+func decodeError(err error) (field string, message string) {
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	switch {
+	case errors.As(err, &typeError):
+		return typeError.Field, "must be " + jsonType(typeError.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`), "is unknown"
+	case errors.Is(err, io.EOF):
+		return "", "body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "", "body is not complete json"
+	case errors.As(err, &syntaxError):
+		return "", fmt.Sprintf("body is not valid json at offset %d", syntaxError.Offset)
+	default:
+		return "", err.Error()
+	}
+}
+
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch kind := t.Kind().String(); {
+	case kind == "string":
+		return "string"
+	case kind == "bool":
+		return "boolean"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"):
+		return "integer"
+	case strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice", kind == "array":
+		return "array"
+	default:
+		return "object"
+	}
+}
+
 func (h *ApiHelper) WriteJSON(
 	w http.ResponseWriter,
 	status int,
@@ -104,6 +225,19 @@ func (h *ApiHelper) OkJSON(w http.ResponseWriter, data any) error {
 	return nil
 }
 
+// OkPageJSON writes a page of paginated data, nextCursor is omitted when it
+// is empty.
+func (h *ApiHelper) OkPageJSON(w http.ResponseWriter, data any, nextCursor string) error {
+	if err := h.WriteJSON(w, http.StatusOK, JsonResponse{
+		Data:       data,
+		NextCursor: nextCursor,
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (h *ApiHelper) SendError(w http.ResponseWriter, r *http.Request, err error) {
 	httpError, ok := err.(*HTTPError)
 	if !ok {
@@ -115,14 +249,14 @@ func (h *ApiHelper) SendError(w http.ResponseWriter, r *http.Request, err error)
 	h.logger.WithField("status", httpError.statusCode).
 		WithField("message", httpError.message).
 		Errorf("%s", httpError.err)
-	if err := h.ErrorJSON(w, httpError.message, httpError.statusCode); err != nil {
+	if err := h.errorJSON(w, httpError.message, httpError.statusCode, httpError.data); err != nil {
 		h.logger.Errorf("failed to write error json: %s", err)
 	}
 }
 
 func (h *ApiHelper) Wrapper(handler Handler, middlewares ...Middleware) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.Background()
+		ctx := r.Context()
 		for _, middleware := range middlewares {
 			var err error
 			ctx, err = middleware(ctx, w, r)
@@ -140,10 +274,15 @@ func (h *ApiHelper) Wrapper(handler Handler, middlewares ...Middleware) http.Han
 }
 
 func (h *ApiHelper) ErrorJSON(w http.ResponseWriter, message string, statusCode int) error {
+	return h.errorJSON(w, message, statusCode, nil)
+}
+
+func (h *ApiHelper) errorJSON(w http.ResponseWriter, message string, statusCode int, data any) error {
 	var payload JsonResponse
 
 	payload.Error = true
 	payload.Message = message
+	payload.Data = data
 
 	if err := h.WriteJSON(w, statusCode, payload); err != nil {
 		return err
@@ -0,0 +1,151 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func (i testItem) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if i.Color != "" && !strings.HasPrefix(i.Color, "#") {
+		errs.Add("color", "must be hex color")
+	}
+
+	return errs
+}
+
+type testRequest struct {
+	Title string     `json:"title" validate:"required,max=5"`
+	Kind  string     `json:"kind" validate:"oneof=a b"`
+	Count int        `json:"count" validate:"min=1,max=10"`
+	Items []testItem `json:"items" validate:"max=2"`
+}
+
+func readValidJSON(t *testing.T, body string) error {
+	t.Helper()
+
+	h := NewApiHelper(NewLoggerTest())
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	var request testRequest
+	return h.ReadValidJSON(httptest.NewRecorder(), r, &request)
+}
+
+func fieldErrors(t *testing.T, err error) ValidationErrors {
+	t.Helper()
+
+	var httpError *HTTPError
+	if !errors.As(err, &httpError) || httpError.statusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v", err)
+	}
+
+	return httpError.data.(ValidationErrorsResponse).Errors
+}
+
+func TestReadValidJSON(t *testing.T) {
+	if err := readValidJSON(t, `{"title": "ok", "kind": "a", "count": 3, "items": [{"color": "#fff"}]}`); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+
+	errs := fieldErrors(t, readValidJSON(t, `{"title": "too long", "kind": "c", "count": 11, "items": [{}, {"color": "red"}, {}]}`))
+	want := ValidationErrors{
+		{Field: "title", Message: "must be at most 5 bytes long"},
+		{Field: "kind", Message: "must be one of: a, b"},
+		{Field: "count", Message: "must be at most 10"},
+		{Field: "items", Message: "must have at most 2 items"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Fatalf("errors = %+v", errs)
+	}
+
+	errs = fieldErrors(t, readValidJSON(t, `{"items": [{}, {"color": "red"}]}`))
+	want = ValidationErrors{
+		{Field: "title", Message: "is required"},
+		{Field: "items[1].color", Message: "must be hex color"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Fatalf("errors = %+v", errs)
+	}
+}
+
+func TestReadValidJSONBody(t *testing.T) {
+	for body, want := range map[string]ValidationErrors{
+		`{"title": "ok", "extra": 1}`:   {{Field: "extra", Message: "is unknown"}},
+		`{"title": "ok", "count": "1"}`: {{Field: "count", Message: "must be integer"}},
+		`{"title": "ok"} {}`:            {{Field: "", Message: "body must contain single json value"}},
+		``:                              {{Field: "", Message: "body is empty"}},
+		`{"title": `:                    {{Field: "", Message: "body is not complete json"}},
+		// errors of other fields are reported together with decoding error
+		`{"extra": 1}`: {{Field: "extra", Message: "is unknown"}, {Field: "title", Message: "is required"}},
+	} {
+		if errs := fieldErrors(t, readValidJSON(t, body)); !reflect.DeepEqual(errs, want) {
+			t.Errorf("%q: errors = %+v", body, errs)
+		}
+	}
+}
+
+func TestReadValidJSONTooLarge(t *testing.T) {
+	err := readValidJSON(t, `{"title": "`+strings.Repeat("a", DefaultMaxJSONSize)+`"}`)
+
+	var httpError *HTTPError
+	if !errors.As(err, &httpError) || httpError.statusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestSendErrorData(t *testing.T) {
+	h := NewApiHelper(NewLoggerTest())
+	w := httptest.NewRecorder()
+	h.SendError(w, httptest.NewRequest(http.MethodPost, "/", nil), readValidJSON(t, `{}`))
+
+	var response struct {
+		Error   bool                     `json:"error"`
+		Message string                   `json:"message"`
+		Data    ValidationErrorsResponse `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusBadRequest || !response.Error || response.Message != "invalid request" {
+		t.Fatalf("response = %d %+v", w.Code, response)
+	}
+	if len(response.Data.Errors) != 1 || response.Data.Errors[0].Field != "title" {
+		t.Fatalf("errors = %+v", response.Data.Errors)
+	}
+}
+
+func TestCheckValidationTags(t *testing.T) {
+	if err := CheckValidationTags(testRequest{}); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+
+	type brokenItem struct {
+		Name string `json:"name" validate:"required,maxlen=5"`
+	}
+	for name, request := range map[string]any{
+		"unknown rule": struct {
+			Items []brokenItem `json:"items"`
+		}{},
+		"bound is not a number": struct {
+			Title string `json:"title" validate:"max=ten"`
+		}{},
+		"oneof without values": &struct {
+			Kind string `json:"kind" validate:"oneof="`
+		}{},
+	} {
+		if err := CheckValidationTags(request); err == nil {
+			t.Errorf("%s: tag is not reported", name)
+		}
+	}
+}
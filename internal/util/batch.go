@@ -0,0 +1,54 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backoff returns delay before next attempt after given number of failed
+// attempts, delay starts at base and doubles up to max.
+func Backoff(base time.Duration, max time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+
+	return backoff
+}
+
+// ProcessBatch claims items in one transaction and processes them one by
+// one with no transaction open, so slow processing does not hold locks of
+// the whole batch. process saves result of item in its own transaction,
+// failure of one item does not undo results of others. It returns number
+// of processed items and errors of the others.
+func ProcessBatch[T any](
+	ctx context.Context,
+	txFactory TransactionFactory,
+	claim func(tx Transaction) ([]T, error),
+	process func(item T) error,
+) (int, error) {
+	var items []T
+	if err := txFactory.WithTransaction(ctx, func(tx Transaction) error {
+		var err error
+		items, err = claim(tx)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	var processed int
+	var errs []error
+	for _, item := range items {
+		if err := process(item); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		processed++
+	}
+
+	return processed, errors.Join(errs...)
+}
@@ -9,3 +9,95 @@ type Transaction interface{}
 type TransactionFactory interface {
 	WithTransaction(ctx context.Context, f func(Transaction) error) error
 }
+
+type contextTransactionKey struct{}
+
+// contextTransaction is transaction started by caller of services, e.g. by
+// middleware storing response of request together with its changes.
+type contextTransaction struct {
+	tx          Transaction
+	afterCommit []func()
+}
+
+// ContextWithTransaction returns ctx carrying tx, transactions started with
+// such ctx join tx instead of being committed on their own. Caller commits
+// tx and then calls committed, which runs functions given to AfterCommit.
+func ContextWithTransaction(ctx context.Context, tx Transaction) (_ context.Context, committed func()) {
+	contextTx := &contextTransaction{tx: tx}
+
+	return context.WithValue(ctx, contextTransactionKey{}, contextTx), func() {
+		for _, f := range contextTx.afterCommit {
+			f()
+		}
+	}
+}
+
+// TransactionFromContext returns transaction carried by ctx, nil if there
+// is none.
+func TransactionFromContext(ctx context.Context) Transaction {
+	contextTx, ok := ctx.Value(contextTransactionKey{}).(*contextTransaction)
+	if !ok {
+		return nil
+	}
+
+	return contextTx.tx
+}
+
+// AfterCommit runs f once transaction carried by ctx is committed, right
+// away if ctx carries no transaction. f is not run when transaction is
+// rolled back.
+func AfterCommit(ctx context.Context, f func()) {
+	contextTx, ok := ctx.Value(contextTransactionKey{}).(*contextTransaction)
+	if !ok {
+		f()
+		return
+	}
+
+	contextTx.afterCommit = append(contextTx.afterCommit, f)
+}
+
+// TestTransaction is transaction of factory returned by
+// NewTransactionFactoryTest, in-memory repositories of tests register how to
+// undo their changes with OnRollbackTest.
+type TestTransaction struct {
+	undo []func()
+}
+
+func (tx *TestTransaction) rollbackTo(savepoint int) {
+	for i := len(tx.undo) - 1; i >= savepoint; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = tx.undo[:savepoint]
+}
+
+// OnRollbackTest runs undo when tx is rolled back, changes made without
+// TestTransaction are never undone.
+func OnRollbackTest(tx Transaction, undo func()) {
+	if testTx, ok := tx.(*TestTransaction); ok {
+		testTx.undo = append(testTx.undo, undo)
+	}
+}
+
+type testTransactionFactory struct{}
+
+// NewTransactionFactoryTest returns factory of TestTransaction for tests with
+// in-memory repositories. Like SQL factory it joins transaction carried by ctx
+// and then undoes only changes of failed function.
+func NewTransactionFactoryTest() TransactionFactory {
+	return testTransactionFactory{}
+}
+
+func (testTransactionFactory) WithTransaction(ctx context.Context, f func(Transaction) error) error {
+	tx, ok := TransactionFromContext(ctx).(*TestTransaction)
+	if !ok {
+		tx = &TestTransaction{}
+	}
+
+	savepoint := len(tx.undo)
+	if err := f(tx); err != nil {
+		tx.rollbackTo(savepoint)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError is error of single field of request. Field is path of field in
+// json document, e.g. operations[2].title.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every error of request instead of stopping at
+// the first one.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = strings.TrimSpace(fieldError.Field + " " + fieldError.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationErrors) Add(field string, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Validator is implemented by requests with rules which can not be expressed
+// by validate tags, e.g. rules depending on several fields. Fields are named
+// relative to the validated value.
+type Validator interface {
+	Validate() ValidationErrors
+}
+
+// ValidationRule is rule of validate tag, e.g. max=100 is rule max with
+// param 100.
+type ValidationRule struct {
+	Name  string
+	Param string
+
+	// bound is param of min and max rules.
+	bound float64
+}
+
+// ParseValidationRules parses validate tag, unknown rule or invalid param is
+// an error. Supported rules are:
+//   - required: value is not zero, other rules are skipped for zero values
+//     of optional fields
+//   - min=n, max=n: length in bytes of string, number of items of slice or
+//     map, or value of number
+//   - oneof=a b c: string is one of space separated values
+func ParseValidationRules(tag string) ([]ValidationRule, error) {
+	var rules []ValidationRule
+	for _, part := range strings.Split(tag, ",") {
+		if part == "" {
+			continue
+		}
+
+		name, param, _ := strings.Cut(part, "=")
+		rule := ValidationRule{Name: name, Param: param}
+
+		switch name {
+		case "required":
+		case "min", "max":
+			bound, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, fmt.Errorf("validate tag %q: param of %s must be a number", tag, name)
+			}
+			rule.bound = bound
+		case "oneof":
+			if len(strings.Fields(param)) == 0 {
+				return nil, fmt.Errorf("validate tag %q: oneof has no values", tag)
+			}
+		default:
+			return nil, fmt.Errorf("validate tag %q: unknown rule %s", tag, name)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// CheckValidationTags checks validate tags of type of data and of types
+// of its fields, so broken tags are found when routes are registered
+// rather than when request is validated.
+func CheckValidationTags(data any) error {
+	if data == nil {
+		return nil
+	}
+
+	return checkTypeTags(reflect.TypeOf(data), make(map[reflect.Type]bool))
+}
+
+func checkTypeTags(t reflect.Type, checked map[reflect.Type]bool) error {
+	if checked[t] {
+		return nil
+	}
+	checked[t] = true
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return checkTypeTags(t.Elem(), checked)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() && !field.Anonymous {
+				continue
+			}
+
+			if _, err := ParseValidationRules(field.Tag.Get("validate")); err != nil {
+				return fmt.Errorf("%s.%s: %w", t, field.Name, err)
+			}
+			if err := checkTypeTags(field.Type, checked); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks validate tags of fields of data, nested structs, slices
+// and maps included, and calls Validate of every Validator it meets.
+func Validate(data any) ValidationErrors {
+	var errs ValidationErrors
+	validateValue(&errs, "", reflect.ValueOf(data))
+
+	return errs
+}
+
+func joinField(prefix string, field string) string {
+	if prefix == "" {
+		return field
+	}
+	if field == "" {
+		return prefix
+	}
+	if strings.HasPrefix(field, "[") {
+		return prefix + field
+	}
+
+	return prefix + "." + field
+}
+
+func validateValue(errs *ValidationErrors, field string, value reflect.Value) {
+	if !value.IsValid() {
+		return
+	}
+
+	if value.CanInterface() {
+		if validator, ok := value.Interface().(Validator); ok {
+			if value.Kind() != reflect.Pointer || !value.IsNil() {
+				for _, fieldError := range validator.Validate() {
+					errs.Add(joinField(field, fieldError.Field), fieldError.Message)
+				}
+			}
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			validateValue(errs, field, value.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateValue(errs, fmt.Sprintf("%s[%d]", field, i), value.Index(i))
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			validateValue(errs, fmt.Sprintf("%s[%v]", field, iter.Key()), iter.Value())
+		}
+	case reflect.Struct:
+		validateStruct(errs, field, value)
+	}
+}
+
+func validateStruct(errs *ValidationErrors, prefix string, value reflect.Value) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() && !structField.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if structField.Anonymous && name == "" {
+			// fields of embedded structs are promoted like encoding/json does
+			validateValue(errs, prefix, fieldValue)
+			continue
+		}
+
+		if name == "" {
+			name = structField.Name
+		}
+		field := joinField(prefix, name)
+
+		// broken tags are reported by CheckValidationTags, they are not
+		// checked here on every request
+		rules, _ := ParseValidationRules(structField.Tag.Get("validate"))
+		if message := checkRules(fieldValue, rules); message != "" {
+			errs.Add(field, message)
+			continue
+		}
+
+		validateValue(errs, field, fieldValue)
+	}
+}
+
+// checkRules returns message of the first broken rule.
+func checkRules(value reflect.Value, rules []ValidationRule) string {
+	if value.IsZero() {
+		for _, rule := range rules {
+			if rule.Name == "required" {
+				return "is required"
+			}
+		}
+		return ""
+	}
+
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	for _, rule := range rules {
+		switch rule.Name {
+		case "required":
+		case "min", "max":
+			if message := checkBound(value, rule); message != "" {
+				return message
+			}
+		case "oneof":
+			options := strings.Fields(rule.Param)
+			if value.Kind() == reflect.String && !contains(options, value.String()) {
+				return "must be one of: " + strings.Join(options, ", ")
+			}
+		}
+	}
+
+	return ""
+}
+
+func checkBound(value reflect.Value, rule ValidationRule) string {
+	var (
+		actual float64
+		unit   string
+	)
+	switch value.Kind() {
+	case reflect.String:
+		actual, unit = float64(value.Len()), " bytes long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual, unit = float64(value.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	default:
+		return ""
+	}
+
+	switch {
+	case rule.Name == "min" && actual < rule.bound:
+		if unit == " items" {
+			return "must have at least " + rule.Param + unit
+		}
+		return "must be at least " + rule.Param + unit
+	case rule.Name == "max" && actual > rule.bound:
+		if unit == " items" {
+			return "must have at most " + rule.Param + unit
+		}
+		return "must be at most " + rule.Param + unit
+	}
+
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
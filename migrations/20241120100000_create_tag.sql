@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+create table tags (
+    id integer primary key,
+    user_id integer not null,
+
+    name varchar(50) not null,
+    color varchar(7) not null default '#808080',
+
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+
+    constraint fk_user foreign key (user_id)
+        references users (id)
+        on delete cascade
+        on update cascade,
+
+    unique (user_id, name)
+);
+
+create table todo_tags (
+    todo_id integer not null,
+    tag_id integer not null,
+
+    constraint fk_todo foreign key (todo_id)
+        references todos (id)
+        on delete cascade
+        on update cascade,
+
+    constraint fk_tag foreign key (tag_id)
+        references tags (id)
+        on delete cascade
+        on update cascade,
+
+    primary key (todo_id, tag_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table todo_tags;
+drop table tags;
+-- +goose StatementEnd
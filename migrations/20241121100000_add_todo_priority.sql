@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table todos
+    add column priority smallint not null default 0,
+    add column due_at timestamp default null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table todos
+    drop column due_at,
+    drop column priority;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+create index todos_created_at_idx on todos (created_at, id);
+create index todos_updated_at_idx on todos (updated_at, id);
+create index todos_due_at_idx on todos (due_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index todos_due_at_idx;
+drop index todos_updated_at_idx;
+drop index todos_created_at_idx;
+-- +goose StatementEnd
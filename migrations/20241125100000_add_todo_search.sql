@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table todos
+    add column search_vector tsvector generated always as (
+        setweight(to_tsvector('simple', title), 'A') ||
+        setweight(to_tsvector('simple', comment), 'B')
+    ) stored;
+
+create index todos_search_vector_idx on todos using gin (search_vector);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index todos_search_vector_idx;
+alter table todos drop column search_vector;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table todos add column deleted_at timestamp default null;
+
+create index todos_deleted_at_idx on todos (deleted_at)
+    where deleted_at is not null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index todos_deleted_at_idx;
+alter table todos drop column deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+create table todo_events (
+    id bigserial primary key,
+    todo_id integer not null,
+    user_id integer not null,
+
+    name varchar(50) not null,
+    old_value text not null default '',
+    new_value text not null default '',
+
+    occurred_at timestamp not null default now(),
+
+    constraint fk_todo foreign key (todo_id)
+        references todos (id)
+        on delete cascade
+        on update cascade,
+
+    constraint fk_user foreign key (user_id)
+        references users (id)
+        on delete cascade
+        on update cascade
+);
+
+create index todo_events_todo_id_idx on todo_events (todo_id, occurred_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table todo_events;
+-- +goose StatementEnd
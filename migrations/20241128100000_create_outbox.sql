@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+create table outbox (
+    id bigserial primary key,
+
+    topic varchar(100) not null,
+    key varchar(100) not null default '',
+    payload jsonb not null,
+
+    attempts integer not null default 0,
+    last_error text not null default '',
+
+    created_at timestamp not null default now(),
+    next_attempt_at timestamp not null default now(),
+    delivered_at timestamp default null,
+    failed_at timestamp default null
+);
+
+create index outbox_pending_idx on outbox (next_attempt_at, id)
+    where delivered_at is null and failed_at is null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table outbox;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+create table webhooks (
+    id integer primary key,
+    user_id integer not null,
+
+    url varchar(2000) not null,
+    secret varchar(100) not null,
+    events jsonb not null default '[]',
+
+    enabled boolean not null default true,
+    consecutive_failures integer not null default 0,
+
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now()
+);
+
+create index webhooks_user_id_idx on webhooks (user_id);
+
+create table webhook_deliveries (
+    id bigserial primary key,
+    webhook_id integer not null references webhooks (id) on delete cascade,
+
+    event_id varchar(100) not null,
+    event_name varchar(100) not null,
+    payload jsonb not null,
+
+    status varchar(20) not null default 'pending',
+    attempts integer not null default 0,
+    next_attempt_at timestamp not null default now(),
+    response_code integer not null default 0,
+    last_error text not null default '',
+
+    created_at timestamp not null default now(),
+    delivered_at timestamp default null
+);
+
+create index webhook_deliveries_webhook_id_idx on webhook_deliveries (webhook_id, id);
+create index webhook_deliveries_pending_idx on webhook_deliveries (next_attempt_at, id)
+    where status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table webhook_deliveries;
+drop table webhooks;
+-- +goose StatementEnd
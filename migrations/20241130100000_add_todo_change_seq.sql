@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+create sequence todo_change_seq;
+
+alter table todos add column seq bigint;
+update todos set seq = nextval('todo_change_seq');
+alter table todos alter column seq set default nextval('todo_change_seq');
+alter table todos alter column seq set not null;
+
+create index todos_seq_idx on todos (seq);
+
+create table todo_tombstones (
+    todo_id integer primary key,
+    user_id integer not null,
+    seq bigint not null,
+    created_at timestamp not null default now()
+);
+
+create index todo_tombstones_user_seq_idx on todo_tombstones (user_id, seq);
+
+create table sync_operations (
+    user_id integer not null,
+    operation_id varchar(100) not null,
+
+    status varchar(20) not null,
+    todo_id integer not null default 0,
+    error text not null default '',
+
+    created_at timestamp not null default now(),
+    primary key (user_id, operation_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table sync_operations;
+drop table todo_tombstones;
+alter table todos drop column seq;
+drop sequence todo_change_seq;
+-- +goose StatementEnd
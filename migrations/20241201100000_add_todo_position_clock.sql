@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- positions are compared bytewise, existing todos keep order by id
+alter table todos add column position varchar(100) collate "C" not null default '';
+update todos set position = lpad(id::text, 12, '0') || 'V';
+alter table todos alter column position drop default;
+
+alter table todos add column clock jsonb not null default '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table todos drop column clock;
+alter table todos drop column position;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+create table undo_operations (
+    id bigserial primary key,
+    user_id integer not null,
+    stack varchar(10) not null,
+    events jsonb not null,
+    created_at timestamp not null default now()
+);
+
+create index undo_operations_user_stack_idx on undo_operations (user_id, stack, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table undo_operations;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table todos add column completed_at timestamp;
+alter table todos add column archived_at timestamp;
+
+-- completion time of already completed todos is unknown, the last change
+-- is the closest guess
+update todos set completed_at = updated_at where done;
+
+create index todos_completed_at_idx on todos (completed_at)
+    where done and deleted_at is null and archived_at is null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index todos_completed_at_idx;
+alter table todos drop column archived_at;
+alter table todos drop column completed_at;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+create table calendar_tokens (
+    user_id integer primary key,
+    -- sha256 of token, token itself is not stored
+    token_hash varchar(64) not null unique,
+    created_at timestamp not null default now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table calendar_tokens;
+-- +goose StatementEnd
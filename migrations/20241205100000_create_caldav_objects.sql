@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- names and UIDs of todos created by CalDAV clients, other todos have
+-- default names derived from their ids
+create table caldav_objects (
+    todo_id integer primary key references todos (id) on delete cascade,
+    user_id integer not null,
+    name varchar(255) not null,
+    uid varchar(255) not null,
+    unique (user_id, name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table caldav_objects;
+-- +goose StatementEnd
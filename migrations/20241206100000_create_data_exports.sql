@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+create table data_exports (
+    id bigserial primary key,
+    user_id integer not null,
+
+    status varchar(20) not null default 'pending',
+    error text not null default '',
+    -- zip archive, deleted with export once it expires
+    archive bytea default null,
+
+    created_at timestamp not null default now(),
+    started_at timestamp default null,
+    finished_at timestamp default null,
+    expires_at timestamp default null,
+
+    constraint fk_user foreign key (user_id)
+        references users (id)
+        on delete cascade
+        on update cascade
+);
+
+create index data_exports_unfinished_idx on data_exports (id)
+    where status in ('pending', 'running');
+
+create index users_deleted_at_idx on users (deleted_at)
+    where deleted_at is not null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index users_deleted_at_idx;
+drop table data_exports;
+-- +goose StatementEnd
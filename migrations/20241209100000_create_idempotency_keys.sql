@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+create table idempotency_keys (
+    user_id integer not null,
+    key varchar(255) not null,
+
+    -- sha256 of method, path and body of request
+    fingerprint varchar(64) not null,
+    status integer not null,
+    content_type varchar(255) not null default '',
+    body bytea not null,
+
+    created_at timestamp not null default now(),
+
+    primary key (user_id, key),
+
+    constraint fk_user foreign key (user_id)
+        references users (id)
+        on delete cascade
+        on update cascade
+);
+
+create index idempotency_keys_created_at_idx on idempotency_keys (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- delivered messages are deleted after retention
+create index outbox_delivered_at_idx on outbox (delivered_at)
+    where delivered_at is not null;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index outbox_delivered_at_idx;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- ids of purged todos are known only to tombstones
+create sequence todo_id_seq owned by todos.id;
+
+select setval(
+    'todo_id_seq',
+    greatest(
+        (select coalesce(max(id), 0) from todos),
+        (select coalesce(max(todo_id), 0) from todo_tombstones),
+        1
+    ),
+    exists (select 1 from todos) or exists (select 1 from todo_tombstones)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop sequence todo_id_seq;
+-- +goose StatementEnd